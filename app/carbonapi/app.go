@@ -10,6 +10,7 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 	"unicode"
@@ -20,11 +21,13 @@ import (
 	"github.com/bookingcom/carbonapi/cfg"
 	"github.com/bookingcom/carbonapi/expr/functions"
 	"github.com/bookingcom/carbonapi/expr/functions/cairo/png"
+	"github.com/bookingcom/carbonapi/expr/metadata"
 	"github.com/bookingcom/carbonapi/mstats"
 	"github.com/bookingcom/carbonapi/pathcache"
 	"github.com/bookingcom/carbonapi/pkg/backend"
 	bnet "github.com/bookingcom/carbonapi/pkg/backend/net"
 	"github.com/bookingcom/carbonapi/pkg/parser"
+	"github.com/bookingcom/carbonapi/pkg/promql"
 	"github.com/bookingcom/carbonapi/pkg/trace"
 	"github.com/bookingcom/carbonapi/util"
 
@@ -55,12 +58,24 @@ func init() {
 
 // App is the main carbonapi runnable
 type App struct {
-	config         cfg.API
-	queryCache     cache.BytesCache
-	findCache      cache.BytesCache
+	config      cfg.API
+	queryCache  cache.BytesCache
+	findCache   cache.BytesCache
+	seriesCache *seriesCache
+	// cacheMetrics are metrics of the configured caches
+	cacheMetrics []prometheus.Collector
+	// refreshing holds keys of expired cache entries being refreshed in background
+	refreshing     sync.Map
 	requestBlocker *blocker.RequestBlocker
 
 	defaultTimeZone *time.Location
+	// userTimeZones are time zones of users, replacing the default one in their requests
+	userTimeZones map[string]*time.Location
+
+	// promTemplate maps paths to labels in the Prometheus API, nil if it's disabled
+	promTemplate *promql.Template
+	// graphiteVersion is the version of graphite reported to grafana
+	graphiteVersion string
 
 	backend backend.Backend
 
@@ -155,6 +170,9 @@ func (app *App) registerPrometheusMetrics(logger *zap.Logger) *http.Server {
 	prometheus.MustRegister(app.prometheusMetrics.FindDurationLinComplex)
 	prometheus.MustRegister(app.prometheusMetrics.TimeInQueueExp)
 	prometheus.MustRegister(app.prometheusMetrics.TimeInQueueLin)
+	prometheus.MustRegister(app.prometheusMetrics.SeriesCacheChunks)
+	prometheus.MustRegister(app.prometheusMetrics.StaleResponses)
+	prometheus.MustRegister(app.cacheMetrics...)
 
 	writeTimeout := app.config.Timeouts.Global
 	if writeTimeout < 30*time.Second {
@@ -193,6 +211,13 @@ func setUpConfig(app *App, logger *zap.Logger) {
 	// TODO (grzkv): Move expvars to init since they are global to the package
 	expvar.Publish("config", expvar.Func(func() interface{} { return app.config }))
 
+	seriesSize := app.config.Cache.Series.SizeMB
+	if seriesSize == 0 {
+		seriesSize = app.config.Cache.Size
+	}
+	seriesEnabled := app.config.Cache.Series.ChunkSec > 0
+
+	var seriesStore cache.BytesCache
 	switch app.config.Cache.Type {
 	case "memcache":
 		if len(app.config.Cache.MemcachedServers) == 0 {
@@ -205,6 +230,9 @@ func setUpConfig(app *App, logger *zap.Logger) {
 
 		app.queryCache = cache.NewMemcached(app.config.Cache.Prefix, app.config.Cache.QueryTimeoutMs, app.config.Cache.MemcachedServers...)
 		app.findCache = cache.NewMemcached(app.config.Cache.Prefix, app.config.Cache.QueryTimeoutMs, app.config.Cache.MemcachedServers...)
+		if seriesEnabled {
+			seriesStore = cache.NewMemcached(app.config.Cache.Prefix, app.config.Cache.QueryTimeoutMs, app.config.Cache.MemcachedServers...)
+		}
 
 		mcache := app.queryCache.(*cache.MemcachedCache)
 
@@ -222,10 +250,16 @@ func setUpConfig(app *App, logger *zap.Logger) {
 
 		app.queryCache = cache.NewReplicatedMemcached(app.config.Cache.Prefix, app.config.Cache.QueryTimeoutMs, app.config.Cache.MemcachedServers...)
 		app.findCache = cache.NewReplicatedMemcached(app.config.Cache.Prefix, app.config.Cache.QueryTimeoutMs, app.config.Cache.MemcachedServers...)
+		if seriesEnabled {
+			seriesStore = cache.NewReplicatedMemcached(app.config.Cache.Prefix, app.config.Cache.QueryTimeoutMs, app.config.Cache.MemcachedServers...)
+		}
 
 	case "mem":
 		app.queryCache = cache.NewExpireCache(uint64(app.config.Cache.Size * 1024 * 1024))
 		app.findCache = cache.NewExpireCache(uint64(app.config.Cache.Size * 1024 * 1024))
+		if seriesEnabled {
+			seriesStore = cache.NewExpireCache(uint64(seriesSize * 1024 * 1024))
+		}
 
 		qcache := app.queryCache.(*cache.ExpireCache)
 
@@ -239,6 +273,61 @@ func setUpConfig(app *App, logger *zap.Logger) {
 		})
 		expvar.Publish("cache_items", apiMetrics.CacheItems)
 
+	case "tiered":
+		if len(app.config.Cache.MemcachedServers) == 0 {
+			logger.Fatal("tiered cache requested but no memcache servers provided")
+		}
+		logger.Info("tiered cache configured",
+			zap.Strings("servers", app.config.Cache.MemcachedServers),
+			zap.Int("local_size_mb", app.config.Cache.Size),
+			zap.Int32("local_timeout_sec", app.config.Cache.LocalTimeoutSec),
+		)
+
+		newTiered := func(sizeMB int) *cache.TieredCache {
+			return cache.NewTiered(
+				cache.NewExpireCache(uint64(sizeMB*1024*1024)),
+				cache.NewMemcached(app.config.Cache.Prefix, app.config.Cache.QueryTimeoutMs, app.config.Cache.MemcachedServers...),
+				app.config.Cache.LocalTimeoutSec)
+		}
+		qcache, fcache := newTiered(app.config.Cache.Size), newTiered(app.config.Cache.Size)
+		app.queryCache = qcache
+		app.findCache = fcache
+		if seriesEnabled {
+			seriesStore = newTiered(seriesSize)
+		}
+
+		app.cacheMetrics = append(app.cacheMetrics, newTieredCacheMetrics("render", qcache)...)
+		app.cacheMetrics = append(app.cacheMetrics, newTieredCacheMetrics("find", fcache)...)
+
+	case "redis":
+		logger.Info("redis configured",
+			zap.String("mode", app.config.Cache.Redis.Mode),
+			zap.Strings("addresses", app.config.Cache.Redis.Addresses),
+		)
+
+		rcache, err := cache.NewRedis(app.config.Cache.Prefix, app.config.Cache.QueryTimeoutMs, cache.RedisOptions{
+			Mode:       app.config.Cache.Redis.Mode,
+			Addrs:      app.config.Cache.Redis.Addresses,
+			MasterName: app.config.Cache.Redis.MasterName,
+			Password:   app.config.Cache.Redis.Password,
+			DB:         app.config.Cache.Redis.DB,
+		})
+		if err != nil {
+			logger.Fatal("failed to configure redis cache", zap.Error(err))
+		}
+
+		// caches are flushed by the prefix of their keys
+		app.queryCache = rcache.WithPrefix("render:")
+		app.findCache = rcache.WithPrefix("find:")
+		if seriesEnabled {
+			seriesStore = rcache.WithPrefix("series:")
+		}
+
+		// TODO (grzkv) Move to conventional Prom metrics.
+		expvar.Publish("redis_timeouts", expvar.Func(func() interface{} {
+			return rcache.Timeouts()
+		}))
+
 	case "null":
 		// defaults
 		app.queryCache = cache.NullCache{}
@@ -246,34 +335,102 @@ func setUpConfig(app *App, logger *zap.Logger) {
 	default:
 		logger.Error("unknown cache type",
 			zap.String("cache_type", app.config.Cache.Type),
-			zap.Strings("known_cache_types", []string{"null", "mem", "memcache"}),
+			zap.Strings("known_cache_types", []string{"null", "mem", "memcache", "memcacheReplicated", "tiered", "redis"}),
 		)
 	}
 
-	if app.config.TimezoneString != "" {
-		fields := strings.Split(app.config.TimezoneString, ",")
+	// Entries of a compressed cache have a header, so caches are only wrapped when needed
+	// and entries written without it aren't all misses after upgrading.
+	compression := app.config.Cache.Compression
+	if (compression != "" && compression != cache.CompressionNone) || app.config.Cache.MaxEntrySizeKB > 0 {
+		compressed := func(name string, c cache.BytesCache) cache.BytesCache {
+			cc, err := cache.NewCompressed(c, app.config.Cache.Compression, app.config.Cache.MaxEntrySizeKB*1024)
+			if err != nil {
+				logger.Fatal("failed to configure cache compression", zap.Error(err))
+			}
+			app.cacheMetrics = append(app.cacheMetrics, newCompressedCacheMetrics(name, cc)...)
+			return cc
+		}
+
+		app.queryCache = compressed("render", app.queryCache)
+		app.findCache = compressed("find", app.findCache)
+		if seriesStore != nil {
+			seriesStore = compressed("series", seriesStore)
+		}
+	}
 
-		if len(fields) != 2 {
-			logger.Fatal("unexpected amount of fields in tz",
+	if app.config.Cache.RenderStale.StaleSec > 0 {
+		app.queryCache = cache.NewStale(app.queryCache, app.config.Cache.RenderStale.StaleSec)
+	}
+	if app.config.Cache.FindStale.StaleSec > 0 {
+		app.findCache = cache.NewStale(app.findCache, app.config.Cache.FindStale.StaleSec)
+	}
+
+	if seriesStore != nil {
+		app.seriesCache = newSeriesCache(seriesStore,
+			app.config.Cache.Series.ChunkSec,
+			app.config.Cache.Series.MinAgeSec,
+			app.config.Cache.Series.TimeoutSec,
+			app.prometheusMetrics.SeriesCacheChunks)
+	}
+
+	if app.config.TimezoneString != "" {
+		tz, err := parseTimeZone(app.config.TimezoneString)
+		if err != nil {
+			logger.Fatal("failed to parse tz",
 				zap.String("timezone_string", app.config.TimezoneString),
-				zap.Int("fields_got", len(fields)),
-				zap.Int("fields_expected", 2),
+				zap.Error(err),
 			)
 		}
+		app.defaultTimeZone = tz
+		logger.Info("using timezone",
+			zap.String("timezone", app.defaultTimeZone.String()),
+		)
+	}
 
-		offs, err := strconv.Atoi(fields[1])
+	app.userTimeZones = make(map[string]*time.Location, len(app.config.UserTimezones))
+	for user, s := range app.config.UserTimezones {
+		tz, err := parseTimeZone(s)
 		if err != nil {
-			logger.Fatal("unable to parse seconds",
-				zap.String("field[1]", fields[1]),
+			logger.Fatal("failed to parse tz of user",
+				zap.String("username", user),
+				zap.String("timezone_string", s),
 				zap.Error(err),
 			)
 		}
+		app.userTimeZones[user] = tz
+	}
 
-		app.defaultTimeZone = time.FixedZone(fields[0], offs)
-		logger.Info("using fixed timezone",
-			zap.String("timezone", app.defaultTimeZone.String()),
-			zap.Int("offset", offs),
-		)
+	app.graphiteVersion = metadata.GraphiteWebVersion
+	if app.config.GraphiteWeb09Compatibility {
+		app.graphiteVersion = "0.9.15"
+	}
+	if app.config.GraphiteVersionForGrafana != "" {
+		major, minor, err := parseGraphiteVersion(app.config.GraphiteVersionForGrafana)
+		if err != nil {
+			logger.Fatal("failed to parse graphite version for grafana",
+				zap.String("version", app.config.GraphiteVersionForGrafana),
+				zap.Error(err),
+			)
+		}
+		if major < 1 || (major == 1 && minor < 1) {
+			logger.Warn("grafana doesn't use descriptions of functions of graphite older than 1.1",
+				zap.String("version", app.config.GraphiteVersionForGrafana),
+				zap.String("functions_version", metadata.GraphiteWebVersion),
+			)
+		}
+		app.graphiteVersion = app.config.GraphiteVersionForGrafana
+	}
+
+	if app.config.Prometheus.PathTemplate != "" {
+		t, err := promql.ParseTemplate(app.config.Prometheus.PathTemplate)
+		if err != nil {
+			logger.Fatal("failed to parse path template of the Prometheus API",
+				zap.String("template", app.config.Prometheus.PathTemplate),
+				zap.Error(err),
+			)
+		}
+		app.promTemplate = t
 	}
 
 	if len(app.config.UnicodeRangeTables) != 0 {
@@ -394,6 +551,69 @@ func setUpConfig(app *App, logger *zap.Logger) {
 
 }
 
+// parseTimeZone parses a time zone of the config, either an IANA name, like
+// Europe/Amsterdam, or a fixed zone as a name and an offset in seconds, like UTC+1,3600.
+func parseTimeZone(s string) (*time.Location, error) {
+	fields := strings.Split(s, ",")
+	switch len(fields) {
+	case 1:
+		return time.LoadLocation(s)
+	case 2:
+		offs, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse seconds %q: %w", fields[1], err)
+		}
+		return time.FixedZone(fields[0], offs), nil
+	}
+	return nil, fmt.Errorf("unexpected amount of fields %d, expected a name or a name and seconds", len(fields))
+}
+
+// userTimeZone returns the time zone of requests of the user, the default one
+// unless the user has one.
+func (app *App) userTimeZone(username string) *time.Location {
+	if tz, ok := app.userTimeZones[username]; ok {
+		return tz
+	}
+	if app.defaultTimeZone == nil {
+		return time.Local
+	}
+	return app.defaultTimeZone
+}
+
+// requestLocation returns the time zone of a request, the one of its user
+// if its tz parameter is empty or invalid.
+func (app *App) requestLocation(qtz string, username string, logger *zap.Logger) *time.Location {
+	if qtz == "" {
+		return app.userTimeZone(username)
+	}
+
+	z, err := time.LoadLocation(qtz)
+	if err != nil {
+		logger.Warn("Invalid time zone",
+			zap.String("tz", qtz),
+		)
+		return app.userTimeZone(username)
+	}
+	return z
+}
+
+// parseGraphiteVersion parses a version of graphite like 1.1 or 1.1.8,
+// as grafana does.
+func parseGraphiteVersion(s string) (major, minor int, err error) {
+	fields := strings.Split(s, ".")
+	if len(fields) < 2 || len(fields) > 3 {
+		return 0, 0, fmt.Errorf("unexpected amount of fields %d, expected major.minor or major.minor.patch", len(fields))
+	}
+	nums := make([]int, len(fields))
+	for i, f := range fields {
+		nums[i], err = strconv.Atoi(f)
+		if err != nil || nums[i] < 0 {
+			return 0, 0, fmt.Errorf("unable to parse %q of version", f)
+		}
+	}
+	return nums[0], nums[1], nil
+}
+
 func (app *App) deferredAccessLogging(r *http.Request, accessLogDetails *carbonapipb.AccessLogDetails, t time.Time, logAsError bool) {
 	accessLogger := zapwriter.Logger("access")
 
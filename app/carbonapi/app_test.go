@@ -1,21 +1,34 @@
 package carbonapi
 
 import (
+	"bytes"
 	"context"
+	ejson "encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/bookingcom/carbonapi/blocker"
 	"github.com/bookingcom/carbonapi/cache"
+	"github.com/bookingcom/carbonapi/carbonapipb"
 	"github.com/bookingcom/carbonapi/cfg"
 	"github.com/bookingcom/carbonapi/pkg/backend/mock"
+	"github.com/bookingcom/carbonapi/pkg/promql"
 	types "github.com/bookingcom/carbonapi/pkg/types"
+	"github.com/bookingcom/carbonapi/pkg/types/encoding/arrow"
+	"github.com/bookingcom/carbonapi/pkg/types/encoding/carbonapi_v2"
 	"github.com/bookingcom/carbonapi/pkg/types/encoding/json"
 
 	"github.com/lomik/zapwriter"
+	"github.com/vmihailenco/msgpack/v5"
+	"go.uber.org/zap"
 )
 
 // TODO (grzkv) Clean this
@@ -121,9 +134,27 @@ func TestAppHandlers(t *testing.T) {
 	t.Run("RenderHandler", renderHandler)
 	t.Run("RenderHandlerErrors", renderHandlerErrs)
 	t.Run("RenderHandlerNotFoundErrors", renderHandlerNotFoundErrs)
+	t.Run("RenderHandlerTargetOrder", renderHandlerTargetOrder)
+	t.Run("RenderHandlerMsgpack", renderHandlerMsgpack)
+	t.Run("RenderHandlerEvalLimits", renderHandlerEvalLimits)
+	t.Run("RenderHandlerValidation", renderHandlerValidation)
+	t.Run("RenderHandlerParseErrors", renderHandlerParseErrors)
+	t.Run("RenderHandlerStrictTimeParsing", renderHandlerStrictTimeParsing)
+	t.Run("RenderHandlerStale", renderHandlerStale)
+	t.Run("RenderHandlerStreamed", renderHandlerStreamed)
+	t.Run("RenderHandlerArrow", renderHandlerArrow)
+	t.Run("RenderHandlerMeta", renderHandlerMeta)
+	t.Run("RenderHandlerMaxDataPoints", renderHandlerMaxDataPoints)
+	t.Run("RenderHandlerConsolidateBy", renderHandlerConsolidateBy)
+	t.Run("RenderHandlerPanics", renderHandlerPanics)
+	t.Run("CacheAdminHandlers", cacheAdminHandlers)
+	t.Run("PrometheusHandlers", prometheusHandlers)
+	t.Run("ParseHandler", parseHandler)
 	t.Run("FindHandler", findHandler)
 	t.Run("FindHandlerCompleter", findHandlerCompleter)
 	t.Run("RenderHandlerNotFoundErrors", infoHandler)
+	t.Run("FunctionsHandler", functionsHandler)
+	t.Run("VersionHandler", versionHandler)
 }
 
 func SetUpTestConfig() (*App, http.Handler) {
@@ -211,6 +242,40 @@ func renderHandler(t *testing.T) {
 	}
 }
 
+func renderHandlerMsgpack(t *testing.T) {
+	req := httptest.NewRequest("GET", "/render?target=foo.bar&from=-10minutes&format=msgpack&noCache=1", nil)
+	rr := httptest.NewRecorder()
+
+	// WARNING: Test results depend on the order of execution now. ENJOY THE GLOBAL STATE!!!
+	// TODO (grzkv): Fix this
+	testApp.backend = mock.New(mock.Config{
+		Find:   find,
+		Info:   info,
+		Render: render,
+	})
+
+	testRouter.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, rr.Code)
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "application/x-msgpack" {
+		t.Errorf("Expected content type application/x-msgpack, got %s", ct)
+	}
+
+	var got []map[string]interface{}
+	if err := msgpack.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0]["name"] != "foo.bar" || got[0]["pathExpression"] != "foo.bar" {
+		t.Fatalf("Unexpected response %v", got)
+	}
+	values, _ := got[0]["values"].([]interface{})
+	if len(values) != 3 || values[0] != nil || values[1] != 1510913759.0 {
+		t.Errorf("Unexpected values %v", got[0]["values"])
+	}
+}
+
 func renderHandlerErrs(t *testing.T) {
 	tests := []struct {
 		req     string
@@ -276,6 +341,863 @@ func renderHandlerNotFoundErrs(t *testing.T) {
 	}
 }
 
+func renderByName(ctx context.Context, request types.RenderRequest) ([]types.Metric, error) {
+	return []types.Metric{
+		{
+			Name:      request.Targets[0],
+			StartTime: 1510913280,
+			StopTime:  1510913400,
+			StepTime:  60,
+			Values:    []float64{1, 2},
+			IsAbsent:  []bool{false, false},
+		},
+	}, nil
+}
+
+func renderHandlerTargetOrder(t *testing.T) {
+	req := httptest.NewRequest("GET",
+		"/render/?target=foo.a&target=divideSeries(foo.b,foo.c)&target=foo.d&target=foo.a&from=-10minutes&format=json&noCache=1", nil)
+	rr := httptest.NewRecorder()
+
+	// WARNING: Test results depend on the order of execution now. ENJOY THE GLOBAL STATE!!!
+	// TODO (grzkv): Fix this
+	testApp.backend = mock.New(mock.Config{
+		Find:   find,
+		Info:   info,
+		Render: renderByName,
+	})
+
+	testRouter.ServeHTTP(rr, req)
+
+	expected := `[{"target":"foo.a","datapoints":[[1,1510913280],[2,1510913340]]},` +
+		`{"target":"divideSeries(foo.b,foo.c)","datapoints":[[1,1510913280],[1,1510913340]]},` +
+		`{"target":"foo.d","datapoints":[[1,1510913280],[2,1510913340]]},` +
+		`{"target":"foo.a","datapoints":[[1,1510913280],[2,1510913340]]}]`
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected status code %d, got %d", http.StatusOK, rr.Code)
+	}
+	if expected != rr.Body.String() {
+		t.Errorf("Expected response %s, got %s", expected, rr.Body.String())
+	}
+}
+
+func renderHandlerEvalLimits(t *testing.T) {
+	req := httptest.NewRequest("GET",
+		"/render/?target=sumSeries(foo.b*)&from=-10minutes&format=json&noCache=1", nil)
+	rr := httptest.NewRecorder()
+
+	// WARNING: Test results depend on the order of execution now. ENJOY THE GLOBAL STATE!!!
+	// TODO (grzkv): Fix this
+	testApp.backend = mock.New(mock.Config{
+		Find:   find,
+		Info:   info,
+		Render: render,
+	})
+	testApp.config.EvalLimits.MaxSeries = 1
+	defer func() { testApp.config.EvalLimits.MaxSeries = 0 }()
+
+	testRouter.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status code %d, got %d", http.StatusUnprocessableEntity, rr.Code)
+	}
+}
+
+func renderHandlerValidation(t *testing.T) {
+	req := httptest.NewRequest("GET",
+		"/render/?target=foo.bar&target=movingAverage(foo.bar,'forever')&from=-10minutes&format=json&noCache=1", nil)
+	rr := httptest.NewRecorder()
+
+	calls := 0
+	// WARNING: Test results depend on the order of execution now. ENJOY THE GLOBAL STATE!!!
+	// TODO (grzkv): Fix this
+	testApp.backend = mock.New(mock.Config{
+		Find: func(ctx context.Context, request types.FindRequest) (types.Matches, error) {
+			calls++
+			return find(ctx, request)
+		},
+		Info: info,
+		Render: func(ctx context.Context, request types.RenderRequest) ([]types.Metric, error) {
+			calls++
+			return render(ctx, request)
+		},
+	})
+
+	testRouter.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+	}
+	if !strings.Contains(rr.Body.String(), "movingAverage: argument 2 (windowSize)") {
+		t.Errorf("Expected argument position in response, got %s", rr.Body.String())
+	}
+	if calls != 0 {
+		t.Errorf("Expected no backend calls, got %d", calls)
+	}
+}
+
+func renderHandlerStale(t *testing.T) {
+	// WARNING: Test results depend on the order of execution now. ENJOY THE GLOBAL STATE!!!
+	// TODO (grzkv): Fix this
+	queryCache, staleConfig := testApp.queryCache, testApp.config.Cache.RenderStale
+	defer func() {
+		testApp.queryCache, testApp.config.Cache.RenderStale = queryCache, staleConfig
+	}()
+	testApp.queryCache = cache.NewStale(cache.NewExpireCache(0), 600)
+	testApp.config.Cache.RenderStale = cfg.StaleConfig{StaleSec: 600, ServeOnError: true}
+
+	serve := func(renderFunc func(context.Context, types.RenderRequest) ([]types.Metric, error)) *httptest.ResponseRecorder {
+		testApp.backend = mock.New(mock.Config{
+			Find:   find,
+			Info:   info,
+			Render: renderFunc,
+		})
+		// absolute times keep the cache key from changing with the time bucket
+		req := httptest.NewRequest("GET", "/render/?target=foo.bar&from=1510913280&until=1510913880&format=json&cacheTimeout=1", nil)
+		rr := httptest.NewRecorder()
+		testRouter.ServeHTTP(rr, req)
+		return rr
+	}
+
+	fresh := serve(render)
+	if fresh.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, fresh.Code)
+	}
+	// the cached response expires after a second
+	time.Sleep(1100 * time.Millisecond)
+
+	rr := serve(renderErr)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected expired response when backends fail, got status code %d", rr.Code)
+	}
+	if rr.Body.String() != fresh.Body.String() {
+		t.Errorf("Expected expired response %q, got %q", fresh.Body.String(), rr.Body.String())
+	}
+	if w := rr.Header().Get("Warning"); w != staleOnErrorWarning {
+		t.Errorf("Expected warning %q, got %q", staleOnErrorWarning, w)
+	}
+
+	testApp.config.Cache.RenderStale.Revalidate = true
+	refreshed := make(chan struct{}, 1)
+	rr = serve(func(ctx context.Context, request types.RenderRequest) ([]types.Metric, error) {
+		refreshed <- struct{}{}
+		return render(ctx, request)
+	})
+	if rr.Code != http.StatusOK || rr.Body.String() != fresh.Body.String() {
+		t.Errorf("Expected expired response, got status code %d and %q", rr.Code, rr.Body.String())
+	}
+	if w := rr.Header().Get("Warning"); w != staleWarning {
+		t.Errorf("Expected warning %q, got %q", staleWarning, w)
+	}
+	select {
+	case <-refreshed:
+	case <-time.After(time.Second):
+		t.Fatal("Expected expired response to be refreshed in background")
+	}
+	// let the refresh finish before the cache is restored
+	for i := 0; i < 100; i++ {
+		running := false
+		testApp.refreshing.Range(func(k, v interface{}) bool {
+			running = true
+			return false
+		})
+		if !running {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Error("Expected refresh to finish")
+}
+
+func renderHandlerStreamed(t *testing.T) {
+	// WARNING: Test results depend on the order of execution now. ENJOY THE GLOBAL STATE!!!
+	// TODO (grzkv): Fix this
+	queryCache, limit := testApp.queryCache, testApp.config.Cache.MaxStreamedSizeKB
+	defer func() {
+		testApp.queryCache, testApp.config.Cache.MaxStreamedSizeKB = queryCache, limit
+	}()
+	store := cache.NewExpireCache(0)
+	testApp.queryCache = store
+	testApp.config.Cache.MaxStreamedSizeKB = 1
+
+	renderLarge := func(ctx context.Context, request types.RenderRequest) ([]types.Metric, error) {
+		m := types.Metric{
+			Name:      "foo.bar",
+			StartTime: 1510913280,
+			StepTime:  60,
+			Values:    make([]float64, 1000),
+			IsAbsent:  make([]bool, 1000),
+		}
+		m.StopTime = m.StartTime + 60*int32(len(m.Values))
+		return []types.Metric{m}, nil
+	}
+
+	tests := []struct {
+		name     string
+		req      string
+		render   func(context.Context, types.RenderRequest) ([]types.Metric, error)
+		expected string
+		cached   bool
+	}{
+		{
+			name:     "small",
+			req:      "/render?target=foo.bar&from=-10minutes&format=json&jsonp=cb",
+			render:   render,
+			expected: `cb([{"target":"foo.bar","datapoints":[[null,1510913280],[1510913759,1510913340],[1510913818,1510913400]]}])`,
+			cached:   true,
+		},
+		{
+			name:     "consolidated",
+			req:      "/render?target=foo.bar&from=-10minutes&format=json&maxDataPoints=2",
+			render:   render,
+			expected: `[{"target":"foo.bar","datapoints":[[1510913788.5,1510913400]]}]`,
+			cached:   true,
+		},
+		{
+			name:   "large",
+			req:    "/render?target=foo.bar&from=-1day&format=csv",
+			render: renderLarge,
+			cached: false,
+		},
+		{
+			name:   "large arrow",
+			req:    "/render?target=foo.bar&from=-1day&format=arrow",
+			render: renderLarge,
+			cached: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store.(*cache.ExpireCache).Flush()
+			testApp.backend = mock.New(mock.Config{
+				Find:   find,
+				Info:   info,
+				Render: tt.render,
+			})
+
+			req := httptest.NewRequest("GET", tt.req, nil)
+			rr := httptest.NewRecorder()
+			testRouter.ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusOK {
+				t.Fatalf("Expected status code %d, got %d", http.StatusOK, rr.Code)
+			}
+			if tt.expected != "" && rr.Body.String() != tt.expected {
+				t.Errorf("Expected response %q, got %q", tt.expected, rr.Body.String())
+			}
+			if items := store.(*cache.ExpireCache).Items(); (items != 0) != tt.cached {
+				t.Errorf("Expected response to be cached: %v, got %d cached entries", tt.cached, items)
+			}
+		})
+	}
+}
+
+func renderHandlerConsolidateBy(t *testing.T) {
+	testApp.backend = mock.New(mock.Config{
+		Find: find,
+		Info: info,
+		Render: func(ctx context.Context, request types.RenderRequest) ([]types.Metric, error) {
+			return []types.Metric{{
+				Name:      "foo.bar",
+				StartTime: 1510913280,
+				StopTime:  1510913880,
+				StepTime:  60,
+				Values:    []float64{5, 1, 7, 3, 9, 2, 8, 4, 6, 10},
+				IsAbsent:  make([]bool, 10),
+			}}, nil
+		},
+	})
+	defer func() {
+		testApp.backend = mock.New(mock.Config{Find: find, Info: info, Render: render})
+	}()
+
+	// graphite-web for maxDataPoints=3: valuesPerPoint = ceil(10/3) = 4, secondsPerPoint = 240,
+	// nudge = 240 + 1510913280%60 - 1510913280%240 = 240, start = 1510913520, del series[:3],
+	// max of [3 9 2 8] and [4 6 10], timestamps = range(1510913520, 1510913880+1, 240)
+	tests := []struct {
+		format   string
+		expected string
+	}{
+		{"json", `[{"target":"foo.bar","datapoints":[[9,1510913520],[10,1510913760]]}]`},
+		{"csv", "\"foo.bar\",2017-11-17 10:12:00,9\n\"foo.bar\",2017-11-17 10:16:00,10\n"},
+		{"protobuf", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/render?target="+url.QueryEscape("consolidateBy(foo.bar,'max')")+
+				"&from=1510913280&until=1510913880&maxDataPoints=3&tz=UTC&noCache=1&format="+tt.format, nil)
+			rr := httptest.NewRecorder()
+			testRouter.ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusOK {
+				t.Fatalf("Expected status code %d, got %d", http.StatusOK, rr.Code)
+			}
+			if tt.expected != "" {
+				if rr.Body.String() != tt.expected {
+					t.Errorf("Expected response %q, got %q", tt.expected, rr.Body.String())
+				}
+				return
+			}
+
+			metrics, err := carbonapi_v2.RenderDecoder(rr.Body.Bytes())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(metrics) != 1 {
+				t.Fatalf("Expected a single series, got %d", len(metrics))
+			}
+			m := metrics[0]
+			if m.StartTime != 1510913520 || m.StepTime != 240 || !reflect.DeepEqual(m.Values, []float64{9, 10}) {
+				t.Errorf("Expected [9 10] from 1510913520 every 240s, got %v from %d every %ds", m.Values, m.StartTime, m.StepTime)
+			}
+		})
+	}
+}
+
+func renderHandlerPanics(t *testing.T) {
+	defer func() {
+		testApp.backend = mock.New(mock.Config{Find: find, Info: info, Render: render})
+	}()
+
+	tests := []struct {
+		name    string
+		backend mock.Config
+	}{
+		{
+			name: "render",
+			backend: mock.Config{Find: find, Info: info,
+				Render: func(context.Context, types.RenderRequest) ([]types.Metric, error) { panic("boom") }},
+		},
+		{
+			name: "find",
+			backend: mock.Config{Info: info, Render: render,
+				Find: func(context.Context, types.FindRequest) (types.Matches, error) { panic("boom") }},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testApp.backend = mock.New(tt.backend)
+
+			req := httptest.NewRequest("GET", "/render?target=foo.*&target=scale(foo.bar,1)"+
+				"&from=1510913280&until=1510913880&format=json&noCache=1", nil)
+			rr := httptest.NewRecorder()
+			testRouter.ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusInternalServerError {
+				t.Errorf("Expected status code %d, got %d", http.StatusInternalServerError, rr.Code)
+			}
+		})
+	}
+
+	// the panics don't take the process or later requests down
+	testApp.backend = mock.New(mock.Config{Find: find, Info: info, Render: render})
+	req := httptest.NewRequest("GET", "/render?target=foo.bar&from=1510913280&until=1510913880&format=json&noCache=1", nil)
+	rr := httptest.NewRecorder()
+	testRouter.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected status code %d after the panics, got %d", http.StatusOK, rr.Code)
+	}
+}
+
+func renderHandlerMaxDataPoints(t *testing.T) {
+	tests := []struct {
+		target   string
+		pushDown int
+	}{
+		{"foo.bar", 2},
+		{"scale(foo.bar,1)", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			// WARNING: Test results depend on the order of execution now. ENJOY THE GLOBAL STATE!!!
+			// TODO (grzkv): Fix this
+			requested := -1
+			testApp.backend = mock.New(mock.Config{
+				Find: find,
+				Info: info,
+				Render: func(ctx context.Context, request types.RenderRequest) ([]types.Metric, error) {
+					requested = request.MaxDataPoints
+					return render(ctx, request)
+				},
+			})
+
+			req := httptest.NewRequest("GET", "/render?target="+url.QueryEscape(tt.target)+
+				"&from=1510913280&until=1510913880&format=csv&maxDataPoints=2&noCache=1", nil)
+			rr := httptest.NewRecorder()
+			testRouter.ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusOK {
+				t.Fatalf("Expected status code %d, got %d", http.StatusOK, rr.Code)
+			}
+			if requested != tt.pushDown {
+				t.Errorf("Expected the backend to be asked for maxDataPoints %d, got %d", tt.pushDown, requested)
+			}
+			// the backend ignores maxDataPoints, carbonapi consolidates anyway
+			lines := strings.Split(strings.TrimSpace(rr.Body.String()), "\n")
+			if len(lines) != 1 || !strings.HasSuffix(lines[0], ",1510913788.5") {
+				t.Errorf("Expected a single consolidated point, got %q", rr.Body.String())
+			}
+		})
+	}
+}
+
+func renderHandlerArrow(t *testing.T) {
+	// WARNING: Test results depend on the order of execution now. ENJOY THE GLOBAL STATE!!!
+	// TODO (grzkv): Fix this
+	queryCache := testApp.queryCache
+	defer func() { testApp.queryCache = queryCache }()
+	testApp.queryCache = cache.NewExpireCache(0)
+	testApp.backend = mock.New(mock.Config{
+		Find:   find,
+		Info:   info,
+		Render: render,
+	})
+
+	metrics, _ := render(context.Background(), types.RenderRequest{})
+	expected, err := arrow.RenderEncoder(metrics)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// streamed, then served from the cache
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest("GET", "/render?target=foo.bar&from=1510913280&until=1510913880&format=arrow", nil)
+		rr := httptest.NewRecorder()
+		testRouter.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+		if ct := rr.Header().Get("Content-Type"); ct != arrow.ContentType {
+			t.Errorf("Expected content type %s, got %s", arrow.ContentType, ct)
+		}
+		if !bytes.Equal(rr.Body.Bytes(), expected) {
+			t.Errorf("Expected Arrow stream %v, got %v", expected, rr.Body.Bytes())
+		}
+	}
+	if items := testApp.queryCache.(*cache.ExpireCache).Items(); items != 1 {
+		t.Errorf("Expected response to be cached, got %d cached entries", items)
+	}
+}
+
+func renderHandlerMeta(t *testing.T) {
+	// WARNING: Test results depend on the order of execution now. ENJOY THE GLOBAL STATE!!!
+	// TODO (grzkv): Fix this
+	queryCache := testApp.queryCache
+	defer func() { testApp.queryCache = queryCache }()
+	testApp.queryCache = cache.NewExpireCache(0)
+	testApp.backend = mock.New(mock.Config{
+		Find: find,
+		Info: info,
+		Render: func(ctx context.Context, request types.RenderRequest) ([]types.Metric, error) {
+			if request.Targets[0] == "foo.baz" {
+				return nil, errors.New("error during render")
+			}
+			return render(ctx, request)
+		},
+	})
+
+	type envelope struct {
+		Series []map[string]interface{} `json:"series"`
+		Meta   responseMeta             `json:"meta"`
+	}
+	serve := func(t *testing.T, url string) envelope {
+		req := httptest.NewRequest("GET", url, nil)
+		rr := httptest.NewRecorder()
+		testRouter.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		var res envelope
+		if err := ejson.Unmarshal(rr.Body.Bytes(), &res); err != nil {
+			t.Fatalf("Expected response with metadata, got %q: %v", rr.Body.String(), err)
+		}
+		return res
+	}
+
+	url := "/render?target=consolidateBy(foo.bar,'max')&target=sum(foo.bar,foo.baz)&from=1510913280&until=1510913880&format=json&meta=1&maxDataPoints=2"
+	res := serve(t, url)
+
+	if len(res.Series) != 2 || len(res.Meta.Series) != 2 {
+		t.Fatalf("Expected 2 series with metadata, got %+v", res)
+	}
+	expected := seriesMeta{
+		Target:            "foo.bar",
+		ValuesPerPoint:    5,
+		ConsolidationFunc: "max",
+		Step:              300,
+	}
+	if got := res.Meta.Series[0]; got.Target != expected.Target || got.ValuesPerPoint != expected.ValuesPerPoint ||
+		got.ConsolidationFunc != expected.ConsolidationFunc || got.Step != expected.Step {
+		t.Errorf("Expected metadata %+v, got %+v", expected, got)
+	}
+	if got := res.Meta.Series[1].ConsolidationFunc; got != "average" {
+		t.Errorf("Expected default consolidation function average, got %s", got)
+	}
+	if res.Meta.FromCache {
+		t.Error("Expected response not to be from cache")
+	}
+	warnings := strings.Join(res.Meta.Warnings, "\n")
+	for _, w := range []string{"some metrics of target sum(foo.bar,foo.baz) failed", "points consolidated to maxDataPoints"} {
+		if !strings.Contains(warnings, w) {
+			t.Errorf("Expected warning %q, got %v", w, res.Meta.Warnings)
+		}
+	}
+
+	cached := serve(t, url)
+	if !cached.Meta.FromCache || len(cached.Series) != 2 {
+		t.Errorf("Expected cached response with metadata, got %+v", cached)
+	}
+}
+
+func prometheusHandlers(t *testing.T) {
+	// WARNING: Test results depend on the order of execution now. ENJOY THE GLOBAL STATE!!!
+	// TODO (grzkv): Fix this
+	backend := testApp.backend
+	defer func() {
+		testApp.promTemplate = nil
+		testApp.backend = backend
+	}()
+	testApp.backend = mock.New(mock.Config{
+		Find: func(ctx context.Context, request types.FindRequest) (types.Matches, error) {
+			if strings.Contains(request.Query, "nowhere") {
+				return types.Matches{Name: request.Query}, nil
+			}
+			return types.Matches{
+				Name: request.Query,
+				Matches: []types.Match{
+					{Path: "servers.ams.h1.cpu", IsLeaf: true},
+					{Path: "servers.lon.h2.cpu", IsLeaf: true},
+					{Path: "servers.lon.h2", IsLeaf: false},
+				},
+			}, nil
+		},
+		Info: info,
+		Render: func(ctx context.Context, request types.RenderRequest) ([]types.Metric, error) {
+			var res []types.Metric
+			for _, target := range request.Targets {
+				m := types.Metric{
+					Name:      target,
+					StartTime: 1000,
+					StopTime:  1300,
+					StepTime:  60,
+					Values:    []float64{1, 2, 3, 4, 5},
+					IsAbsent:  []bool{false, false, false, false, false},
+				}
+				if strings.Contains(target, "lon") {
+					m.Values = []float64{10, 20, 30, 40, 50}
+				}
+				res = append(res, m)
+			}
+			return res, nil
+		},
+	})
+
+	type response struct {
+		Status    string           `json:"status"`
+		Data      ejson.RawMessage `json:"data"`
+		ErrorType string           `json:"errorType"`
+		Warnings  []string         `json:"warnings"`
+	}
+	serve := func(t *testing.T, url string, code int) response {
+		req := httptest.NewRequest("GET", url, nil)
+		rr := httptest.NewRecorder()
+		testRouter.ServeHTTP(rr, req)
+		if rr.Code != code {
+			t.Fatalf("Expected status code %d, got %d: %s", code, rr.Code, rr.Body.String())
+		}
+
+		var res response
+		if err := ejson.Unmarshal(rr.Body.Bytes(), &res); err != nil {
+			t.Fatalf("Expected Prometheus response, got %q: %v", rr.Body.String(), err)
+		}
+		return res
+	}
+
+	if res := serve(t, "/api/v1/labels", http.StatusNotFound); res.Status != "error" || res.ErrorType != "not_found" {
+		t.Errorf("Expected API to be disabled without template, got %+v", res)
+	}
+
+	tmpl, err := promql.ParseTemplate("servers.{dc}.{host}.{__name__}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testApp.promTemplate = tmpl
+
+	tests := []struct {
+		url  string
+		data string
+	}{
+		{
+			"/api/v1/query_range?query=cpu&start=1060&end=1180&step=60",
+			`{"resultType":"matrix","result":[` +
+				`{"metric":{"__name__":"cpu","dc":"ams","host":"h1"},"values":[[1060,"2"],[1120,"3"],[1180,"4"]]},` +
+				`{"metric":{"__name__":"cpu","dc":"lon","host":"h2"},"values":[[1060,"20"],[1120,"30"],[1180,"40"]]}]}`,
+		},
+		{
+			"/api/v1/query_range?query=sum+by+(dc)+(cpu)&start=1970-01-01T00:18:00Z&end=1200&step=2m",
+			`{"resultType":"matrix","result":[` +
+				`{"metric":{"dc":"ams"},"values":[[1080,"2"],[1200,"4"]]},` +
+				`{"metric":{"dc":"lon"},"values":[[1080,"20"],[1200,"40"]]}]}`,
+		},
+		{
+			"/api/v1/query_range?query=cpu{dc=\"nowhere\"}&start=1060&end=1180&step=60",
+			`{"resultType":"matrix","result":[]}`,
+		},
+		{
+			"/api/v1/series?match[]=cpu{dc=\"lon\"}",
+			`[{"__name__":"cpu","dc":"lon","host":"h2"}]`,
+		},
+		{
+			"/api/v1/series?match[]=cpu{host=~\"h.*\"}&match[]=cpu{dc=\"ams\"}",
+			`[{"__name__":"cpu","dc":"ams","host":"h1"},{"__name__":"cpu","dc":"lon","host":"h2"}]`,
+		},
+		{
+			"/api/v1/labels",
+			`["__name__","dc","host"]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			res := serve(t, tt.url, http.StatusOK)
+			if res.Status != "success" {
+				t.Fatalf("Expected success, got %+v", res)
+			}
+			if string(res.Data) != tt.data {
+				t.Errorf("Expected data %s, got %s", tt.data, res.Data)
+			}
+		})
+	}
+
+	res := serve(t, "/api/v1/query_range?query=topk(1,cpu)&start=1060&end=1180&step=60", http.StatusOK)
+	if res.Status != "success" || len(res.Warnings) != 1 || !strings.HasPrefix(res.Warnings[0], "topk ") {
+		t.Errorf("Expected a warning about topk, got %+v", res)
+	}
+	if res := serve(t, "/api/v1/query_range?query=cpu&start=1060&end=1180&step=60", http.StatusOK); res.Warnings != nil {
+		t.Errorf("Expected no warnings, got %q", res.Warnings)
+	}
+
+	errorTests := []string{
+		"/api/v1/query_range?query=cpu&start=1180&end=1060&step=60",
+		"/api/v1/query_range?query=cpu&start=1060&end=1180&step=0",
+		"/api/v1/query_range?query=cpu&start=0&end=1000000&step=1",
+		"/api/v1/query_range?query=count(cpu)&start=1060&end=1180&step=60",
+		"/api/v1/query_range?query=cpu{rack=\"r1\"}&start=1060&end=1180&step=60",
+		"/api/v1/series",
+		"/api/v1/series?match[]={}",
+	}
+	for _, url := range errorTests {
+		if res := serve(t, url, http.StatusBadRequest); res.Status != "error" || res.ErrorType != "bad_data" {
+			t.Errorf("%s: expected bad_data error, got %+v", url, res)
+		}
+	}
+}
+
+func cacheAdminHandlers(t *testing.T) {
+	// WARNING: Test results depend on the order of execution now. ENJOY THE GLOBAL STATE!!!
+	// TODO (grzkv): Fix this
+	queryCache := testApp.queryCache
+	defer func() { testApp.queryCache = queryCache }()
+	testApp.queryCache = cache.NewExpireCache(0)
+	testApp.backend = mock.New(mock.Config{
+		Find:   find,
+		Info:   info,
+		Render: render,
+	})
+	internal := initHandlersInternal(testApp)
+
+	for _, target := range []string{"foo.bar", "sum(foo.baz)"} {
+		rr := httptest.NewRecorder()
+		testRouter.ServeHTTP(rr, httptest.NewRequest("GET", "/render/?target="+target+"&from=-10minutes&format=json", nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+	}
+
+	serve := func(method, url string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		internal.ServeHTTP(rr, httptest.NewRequest(method, url, nil))
+		return rr
+	}
+
+	rr := serve("GET", "/cache/top?cache=render&n=10")
+	var entries []cacheEntry
+	if err := ejson.Unmarshal(rr.Body.Bytes(), &entries); err != nil {
+		t.Fatalf("Failed to decode %q: %v", rr.Body.String(), err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 cached responses, got %+v", entries)
+	}
+
+	rr = serve("GET", "/cache/entry?cache=render&key="+url.QueryEscape(entries[0].Key))
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"size":`) {
+		t.Errorf("Expected entry, got status code %d and %q", rr.Code, rr.Body.String())
+	}
+
+	if rr = serve("GET", "/cache/purge?cache=render&prefix=foo.bar"); rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status code %d for GET, got %d", http.StatusMethodNotAllowed, rr.Code)
+	}
+	rr = serve("POST", "/cache/purge?cache=render&prefix=foo.bar")
+	if rr.Code != http.StatusOK || rr.Body.String() != `{"purged":1}` {
+		t.Errorf("Expected 1 purged response, got status code %d and %q", rr.Code, rr.Body.String())
+	}
+	rr = serve("POST", "/cache/purge?cache=render&regex="+url.QueryEscape(`^sum\(`))
+	if rr.Code != http.StatusOK || rr.Body.String() != `{"purged":1}` {
+		t.Errorf("Expected 1 purged response, got status code %d and %q", rr.Code, rr.Body.String())
+	}
+
+	if rr = serve("GET", "/cache/entry?cache=render&key="+url.QueryEscape(entries[0].Key)); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status code %d for purged entry, got %d", http.StatusNotFound, rr.Code)
+	}
+	if rr = serve("GET", "/cache/entry?cache=unknown&key=a"); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status code %d for unknown cache, got %d", http.StatusBadRequest, rr.Code)
+	}
+
+	// memcached can't match its keys
+	cacheType := testApp.config.Cache.Type
+	defer func() { testApp.config.Cache.Type = cacheType }()
+	testApp.config.Cache.Type = "memcache"
+	testApp.queryCache = cache.NewMemcached("", 10, "127.0.0.1:0")
+	for _, purge := range []string{"prefix=foo.bar", "regex=foo", "all=1"} {
+		rr = serve("POST", "/cache/purge?cache=render&"+purge)
+		if rr.Code != http.StatusNotImplemented || !strings.Contains(rr.Body.String(), `isn't supported by caches of type "memcache"`) {
+			t.Errorf("Expected status code %d for %s, got %d and %q", http.StatusNotImplemented, purge, rr.Code, rr.Body.String())
+		}
+	}
+}
+
+func renderHandlerParseErrors(t *testing.T) {
+	tests := []struct {
+		target      string
+		format      string
+		contentType string
+		want        []string
+	}{
+		{
+			target:      "sumSeries(foo.bar",
+			format:      "json",
+			contentType: "application/json",
+			want:        []string{`"offset":17`, `"expected":[",",")"]`, `"target":"sumSeries(foo.bar"`},
+		},
+		{
+			target:      "sumSereis(foo.bar)",
+			format:      "json",
+			contentType: "application/json",
+			want:        []string{`"function":"sumSereis"`, `"suggestions":["sumSeries"`},
+		},
+		{
+			target: "sumSeries(foo.bar",
+			format: "raw",
+			want:   []string{"Position            : sumSeries(foo.bar", "offset 17"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.target+"/"+tt.format, func(t *testing.T) {
+			req := httptest.NewRequest("GET",
+				"/render/?target="+url.QueryEscape(tt.target)+"&from=-10minutes&format="+tt.format+"&noCache=1", nil)
+			rr := httptest.NewRecorder()
+			testRouter.ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusBadRequest {
+				t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+			}
+			if tt.contentType != "" && rr.Header().Get("Content-Type") != tt.contentType {
+				t.Errorf("Expected content type %s, got %s", tt.contentType, rr.Header().Get("Content-Type"))
+			}
+			for _, w := range tt.want {
+				if !strings.Contains(rr.Body.String(), w) {
+					t.Errorf("Expected %s in response, got %s", w, rr.Body.String())
+				}
+			}
+		})
+	}
+}
+
+func renderHandlerStrictTimeParsing(t *testing.T) {
+	// WARNING: Test results depend on the order of execution now. ENJOY THE GLOBAL STATE!!!
+	// TODO (grzkv): Fix this
+	strict := testApp.config.StrictTimeParsing
+	defer func() { testApp.config.StrictTimeParsing = strict }()
+
+	tests := []struct {
+		query  string
+		strict bool
+		code   int
+	}{
+		{query: "from=yesteday", strict: true, code: http.StatusBadRequest},
+		{query: "from=-1d&until=now-1", strict: true, code: http.StatusBadRequest},
+		{query: "from=noon+yesterday&until=now-1h", strict: true, code: http.StatusOK},
+		{query: "from=yesteday", strict: false, code: http.StatusOK},
+		{query: "from=-7d&tz=Nowhere/City", strict: true, code: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s/strict=%v", tt.query, tt.strict), func(t *testing.T) {
+			testApp.config.StrictTimeParsing = tt.strict
+
+			req := httptest.NewRequest("GET", "/render/?target=foo.bar&format=json&noCache=1&"+tt.query, nil)
+			rr := httptest.NewRecorder()
+			testRouter.ServeHTTP(rr, req)
+
+			if rr.Code != tt.code {
+				t.Errorf("Expected status code %d, got %d: %s", tt.code, rr.Code, rr.Body.String())
+			}
+		})
+	}
+
+	// unknown time zones are ignored unless parsing is strict
+	testApp.config.StrictTimeParsing = false
+	r := httptest.NewRequest("GET", "/render/?target=foo.bar&from=-7d&tz=Nowhere/City", nil)
+	toLog := carbonapipb.NewAccessLogDetails(r, "render", &testApp.config)
+	form, err := testApp.renderHandlerProcessForm(r, &toLog, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := form.until32 - form.from32; d != 7*24*3600 {
+		t.Errorf("Expected a range of 7 days, got %d seconds", d)
+	}
+}
+
+func parseHandler(t *testing.T) {
+	req := httptest.NewRequest("GET",
+		"/parse/?target="+url.QueryEscape("foo.bar | timeShift( \"1h\" ) | alias(\"x\")"), nil)
+	rr := httptest.NewRecorder()
+	testRouter.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	expected := `{"target":"foo.bar | timeShift( \"1h\" ) | alias(\"x\")",` +
+		`"canonical":"alias(timeShift(foo.bar,'1h'),'x')",` +
+		`"ast":{"type":"function","function":"alias","args":[` +
+		`{"type":"function","function":"timeShift","args":[{"type":"metric","metric":"foo.bar"},{"type":"string","value":"1h"}]},` +
+		`{"type":"string","value":"x"}]},` +
+		`"metrics":[{"metric":"foo.bar","fromShift":-3600,"untilShift":-3600}]}`
+	if rr.Body.String() != expected {
+		t.Errorf("Expected\n%s\ngot\n%s", expected, rr.Body.String())
+	}
+
+	req = httptest.NewRequest("GET", "/parse/?target="+url.QueryEscape("sumSeries(foo.bar"), nil)
+	rr = httptest.NewRecorder()
+	testRouter.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+	}
+	if !strings.Contains(rr.Body.String(), `"offset":17`) {
+		t.Errorf("Expected error offset in response, got %s", rr.Body.String())
+	}
+}
+
 func findHandler(t *testing.T) {
 	req := httptest.NewRequest("GET", "/metrics/find/?query=foo.bar&format=json", nil)
 	rr := httptest.NewRecorder()
@@ -332,3 +1254,147 @@ func infoHandler(t *testing.T) {
 		t.Error("Http response should be same.")
 	}
 }
+
+func functionsHandler(t *testing.T) {
+	req := httptest.NewRequest("GET", "/functions/?grouped=1", nil)
+	rr := httptest.NewRecorder()
+	testRouter.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	var grouped map[string]map[string]map[string]interface{}
+	if err := ejson.Unmarshal(rr.Body.Bytes(), &grouped); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := grouped["Combine"]["sumSeries"]; !ok {
+		t.Errorf("Expected sumSeries in the Combine group, got %v", grouped["Combine"])
+	}
+
+	req = httptest.NewRequest("GET", "/functions/groupByNode", nil)
+	rr = httptest.NewRecorder()
+	testRouter.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	var desc map[string]interface{}
+	if err := ejson.Unmarshal(rr.Body.Bytes(), &desc); err != nil {
+		t.Fatal(err)
+	}
+	// the schema of graphite-web 1.1, without fields of carbonapi
+	for _, k := range []string{"name", "function", "description", "module", "group", "params"} {
+		if _, ok := desc[k]; !ok {
+			t.Errorf("Expected %s in the description, got %v", k, desc)
+		}
+	}
+	if len(desc) != 6 {
+		t.Errorf("Expected only fields of graphite-web in the description, got %v", desc)
+	}
+	params, _ := desc["params"].([]interface{})
+	if len(params) != 3 {
+		t.Fatalf("Expected 3 params, got %v", desc["params"])
+	}
+	callback, _ := params[2].(map[string]interface{})
+	if callback["type"] != "aggFunc" || callback["default"] != "average" || callback["required"] != nil || callback["options"] == nil {
+		t.Errorf("Unexpected callback param %v", callback)
+	}
+
+	req = httptest.NewRequest("GET", "/functions/noSuchFunction", nil)
+	rr = httptest.NewRecorder()
+	testRouter.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status code %d, got %d: %s", http.StatusNotFound, rr.Code, rr.Body.String())
+	}
+}
+
+func versionHandler(t *testing.T) {
+	req := httptest.NewRequest("GET", "/version", nil)
+	rr := httptest.NewRecorder()
+	testRouter.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, rr.Code)
+	}
+	// grafana uses descriptions of functions served by graphite 1.1 and later
+	if got := rr.Body.String(); got != "1.1.0\n" {
+		t.Errorf("Expected version 1.1.0, got %q", got)
+	}
+}
+
+func TestParseTimeZone(t *testing.T) {
+	tz, err := parseTimeZone("UTC+1,3600")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, offset := time.Unix(0, 0).In(tz).Zone(); offset != 3600 {
+		t.Errorf("Expected a fixed zone of 3600 seconds, got %d", offset)
+	}
+
+	tz, err = parseTimeZone("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+	if tz.String() != "America/New_York" {
+		t.Errorf("Expected America/New_York, got %s", tz)
+	}
+
+	for _, s := range []string{"Nowhere/City", "UTC+1,one", "a,1,2"} {
+		if _, err := parseTimeZone(s); err == nil {
+			t.Errorf("Expected an error for %q", s)
+		}
+	}
+}
+
+func TestParseGraphiteVersion(t *testing.T) {
+	tests := []struct {
+		version      string
+		major, minor int
+	}{
+		{"1.1", 1, 1},
+		{"1.1.8", 1, 1},
+		{"0.9.15", 0, 9},
+	}
+	for _, tt := range tests {
+		major, minor, err := parseGraphiteVersion(tt.version)
+		if err != nil || major != tt.major || minor != tt.minor {
+			t.Errorf("parseGraphiteVersion(%q) = %d, %d, %v, want %d, %d", tt.version, major, minor, err, tt.major, tt.minor)
+		}
+	}
+
+	for _, s := range []string{"1", "1.x", "1.1.0.0", "1.-1", ""} {
+		if _, _, err := parseGraphiteVersion(s); err == nil {
+			t.Errorf("Expected an error for %q", s)
+		}
+	}
+}
+
+func TestRequestLocation(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+	app := &App{
+		defaultTimeZone: time.UTC,
+		userTimeZones:   map[string]*time.Location{"alice": ny},
+	}
+	logger := zap.NewNop()
+
+	tests := []struct {
+		qtz      string
+		username string
+		want     string
+	}{
+		{qtz: "", username: "", want: "UTC"},
+		{qtz: "", username: "alice", want: "America/New_York"},
+		{qtz: "Europe/Amsterdam", username: "alice", want: "Europe/Amsterdam"},
+		{qtz: "Nowhere/City", username: "alice", want: "America/New_York"},
+		{qtz: "Nowhere/City", username: "bob", want: "UTC"},
+	}
+	for _, tt := range tests {
+		if got := app.requestLocation(tt.qtz, tt.username, logger); got.String() != tt.want {
+			t.Errorf("requestLocation(%q, %q) = %s, want %s", tt.qtz, tt.username, got, tt.want)
+		}
+	}
+}
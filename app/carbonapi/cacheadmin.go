@@ -0,0 +1,234 @@
+package carbonapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bookingcom/carbonapi/cache"
+	"github.com/bookingcom/carbonapi/carbonapipb"
+	"github.com/bookingcom/carbonapi/pkg/parser"
+	"github.com/bookingcom/carbonapi/util"
+
+	"go.opentelemetry.io/otel/api/trace"
+)
+
+// defaultTopEntries is the number of entries /cache/top returns by default
+const defaultTopEntries = 20
+
+// cacheEntry is an entry of a cache. Age and TTL are zero when the cache doesn't know them.
+type cacheEntry struct {
+	Key    string  `json:"key"`
+	Size   int     `json:"size"`
+	AgeSec float64 `json:"ageSec"`
+	TTLSec float64 `json:"ttlSec"`
+}
+
+func newCacheEntry(info cache.EntryInfo) cacheEntry {
+	return cacheEntry{
+		Key:    info.Key,
+		Size:   info.Size,
+		AgeSec: info.Age.Seconds(),
+		TTLSec: info.TTL.Seconds(),
+	}
+}
+
+// adminCache returns the cache of the name, and a function returning targets
+// a key of the cache is for.
+func (app *App) adminCache(name string) (cache.AdminCache, func(key string) []string, bool) {
+	switch name {
+	case "render", "":
+		return cache.Admin(app.queryCache), renderKeyTargets, true
+	case "find":
+		return cache.Admin(app.findCache), func(key string) []string { return []string{key} }, true
+	case "series":
+		if app.seriesCache == nil {
+			return nil, nil, false
+		}
+		return cache.Admin(app.seriesCache.store), func(key string) []string { return []string{chunkPath(key)} }, true
+	}
+	return nil, nil, false
+}
+
+// renderKeyTargets returns the targets of a render cache key.
+func renderKeyTargets(key string) []string {
+	params, err := url.ParseQuery(key)
+	if err != nil {
+		return nil
+	}
+	return params["target"]
+}
+
+// cacheAdminError writes the response for an error of the cache admin operation op.
+// Operations the type of cache can't do are not implemented, see the cache
+// section of the config for what types can do.
+func (app *App) cacheAdminError(w http.ResponseWriter, r *http.Request, op string, err error, toLog *carbonapipb.AccessLogDetails) {
+	code := http.StatusInternalServerError
+	msg := err.Error()
+	switch {
+	case errors.Is(err, cache.ErrNotFound):
+		code = http.StatusNotFound
+	case errors.Is(err, cache.ErrNotSupported):
+		code = http.StatusNotImplemented
+		msg = fmt.Sprintf("%s isn't supported by caches of type %q, which can't list or match their keys", op, app.config.Cache.Type)
+	}
+	writeError(util.GetUUID(r.Context()), r, w, code, msg, jsonFormat, toLog, trace.SpanFromContext(r.Context()))
+}
+
+func writeCacheAdminResponse(w http.ResponseWriter, v interface{}, toLog *carbonapipb.AccessLogDetails) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		toLog.HttpCode = http.StatusInternalServerError
+		return
+	}
+
+	w.Header().Set("Content-Type", contentTypeJSON)
+	w.Write(b)
+	toLog.HttpCode = http.StatusOK
+}
+
+// cacheEntryHandler returns the size, age and TTL of the entry of a key.
+func (app *App) cacheEntryHandler(w http.ResponseWriter, r *http.Request) {
+	t0 := time.Now()
+	apiMetrics.Requests.Add(1)
+	toLog := carbonapipb.NewAccessLogDetails(r, "cacheEntry", &app.config)
+	defer func() {
+		app.deferredAccessLogging(r, &toLog, t0, toLog.HttpCode != http.StatusOK)
+	}()
+	uuid := util.GetUUID(r.Context())
+	span := trace.SpanFromContext(r.Context())
+
+	c, _, ok := app.adminCache(r.FormValue("cache"))
+	if !ok {
+		writeError(uuid, r, w, http.StatusBadRequest, "unknown cache", jsonFormat, &toLog, span)
+		return
+	}
+	key := r.FormValue("key")
+	if key == "" {
+		writeError(uuid, r, w, http.StatusBadRequest, "missing key", jsonFormat, &toLog, span)
+		return
+	}
+
+	info, err := c.Stat(key)
+	if err != nil {
+		app.cacheAdminError(w, r, "inspecting entries", err, &toLog)
+		return
+	}
+	writeCacheAdminResponse(w, newCacheEntry(info), &toLog)
+}
+
+// cacheTopHandler returns the largest entries.
+func (app *App) cacheTopHandler(w http.ResponseWriter, r *http.Request) {
+	t0 := time.Now()
+	apiMetrics.Requests.Add(1)
+	toLog := carbonapipb.NewAccessLogDetails(r, "cacheTop", &app.config)
+	defer func() {
+		app.deferredAccessLogging(r, &toLog, t0, toLog.HttpCode != http.StatusOK)
+	}()
+	uuid := util.GetUUID(r.Context())
+	span := trace.SpanFromContext(r.Context())
+
+	c, _, ok := app.adminCache(r.FormValue("cache"))
+	if !ok {
+		writeError(uuid, r, w, http.StatusBadRequest, "unknown cache", jsonFormat, &toLog, span)
+		return
+	}
+	n := defaultTopEntries
+	if s := r.FormValue("n"); s != "" {
+		var err error
+		if n, err = strconv.Atoi(s); err != nil || n <= 0 {
+			writeError(uuid, r, w, http.StatusBadRequest, "bad n", jsonFormat, &toLog, span)
+			return
+		}
+	}
+
+	infos, err := c.Entries()
+	if err != nil {
+		app.cacheAdminError(w, r, "listing entries", err, &toLog)
+		return
+	}
+	if len(infos) > n {
+		infos = infos[:n]
+	}
+
+	entries := make([]cacheEntry, 0, len(infos))
+	for _, info := range infos {
+		entries = append(entries, newCacheEntry(info))
+	}
+	writeCacheAdminResponse(w, entries, &toLog)
+}
+
+// cachePurgeHandler deletes the entry of a key, entries for targets with a prefix
+// or matching a regex, or all entries.
+func (app *App) cachePurgeHandler(w http.ResponseWriter, r *http.Request) {
+	t0 := time.Now()
+	apiMetrics.Requests.Add(1)
+	toLog := carbonapipb.NewAccessLogDetails(r, "cachePurge", &app.config)
+	defer func() {
+		app.deferredAccessLogging(r, &toLog, t0, toLog.HttpCode != http.StatusOK)
+	}()
+	uuid := util.GetUUID(r.Context())
+	span := trace.SpanFromContext(r.Context())
+
+	if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+		writeError(uuid, r, w, http.StatusMethodNotAllowed, "purge needs POST or DELETE", jsonFormat, &toLog, span)
+		return
+	}
+	c, keyTargets, ok := app.adminCache(r.FormValue("cache"))
+	if !ok {
+		writeError(uuid, r, w, http.StatusBadRequest, "unknown cache", jsonFormat, &toLog, span)
+		return
+	}
+
+	var match func(string) bool
+	switch {
+	case r.FormValue("key") != "":
+		if err := c.Delete(r.FormValue("key")); err != nil {
+			app.cacheAdminError(w, r, "purging by key", err, &toLog)
+			return
+		}
+		writeCacheAdminResponse(w, map[string]int{"purged": 1}, &toLog)
+		return
+	case parser.TruthyBool(r.FormValue("all")):
+		if err := c.Flush(); err != nil {
+			app.cacheAdminError(w, r, "purging all entries", err, &toLog)
+			return
+		}
+		writeCacheAdminResponse(w, map[string]bool{"flushed": true}, &toLog)
+		return
+	case r.FormValue("prefix") != "":
+		prefix := r.FormValue("prefix")
+		match = func(target string) bool { return strings.HasPrefix(target, prefix) }
+	case r.FormValue("regex") != "":
+		re, err := regexp.Compile(r.FormValue("regex"))
+		if err != nil {
+			writeError(uuid, r, w, http.StatusBadRequest, "bad regex: "+err.Error(), jsonFormat, &toLog, span)
+			return
+		}
+		match = re.MatchString
+	default:
+		writeError(uuid, r, w, http.StatusBadRequest, "missing key, prefix, regex or all", jsonFormat, &toLog, span)
+		return
+	}
+
+	n, err := c.DeleteMatching(func(key string) bool {
+		for _, target := range keyTargets(key) {
+			if match(target) {
+				return true
+			}
+		}
+		return false
+	})
+	if err != nil {
+		app.cacheAdminError(w, r, "purging by prefix or regex", err, &toLog)
+		return
+	}
+	writeCacheAdminResponse(w, map[string]int{"purged": n}, &toLog)
+}
@@ -0,0 +1,97 @@
+package carbonapi
+
+import (
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/bookingcom/carbonapi/date"
+	"github.com/bookingcom/carbonapi/pkg/parser"
+)
+
+// formatParams are the request parameters, besides targets, the time range,
+// the time zone and maxDataPoints, that affect responses in the format.
+// Formats missing here, like png and svg, depend on all parameters.
+var formatParams = map[string][]string{
+	jsonFormat:      {"meta"},
+	csvFormat:       {},
+	rawFormat:       {},
+	pickleFormat:    {},
+	msgpackFormat:   {},
+	arrowFormat:     {},
+	protobufFormat:  {},
+	protobuf3Format: {},
+}
+
+// keyIgnoredParams never change the response or are handled separately.
+var keyIgnoredParams = map[string]bool{
+	"target":        true,
+	"from":          true,
+	"until":         true,
+	"format":        true,
+	"noCache":       true,
+	"cacheTimeout":  true,
+	"jsonp":         true,
+	"tz":            true,
+	"maxDataPoints": true,
+	"_salt":         true,
+	"_ts":           true,
+	"_t":            true, // Used by jquery.graphite.js
+}
+
+// renderCacheKey returns the key of the render response cache. Requests with
+// the same response share the key: targets are in canonical form, only the
+// parameters used by the format are included, the time zone is the one
+// resolved for the request and times relative to now, like "-1h", are aligned
+// down to bucket seconds. Absolute times are kept as they are, since requests
+// for them are expected to get the points of exactly that range. Target order
+// is kept, as it is the order of series in the response.
+func renderCacheKey(exps []parser.Expr, form renderForm, params url.Values, bucket int32) string {
+	key := url.Values{}
+
+	for _, exp := range exps {
+		key.Add("target", parser.Format(exp))
+	}
+	key.Set("from", strconv.Itoa(int(keyTime(form.from, form.from32, form.location, bucket))))
+	key.Set("until", strconv.Itoa(int(keyTime(form.until, form.until32, form.location, bucket))))
+	key.Set("format", form.format)
+	if form.location != nil {
+		// results of calendar-aligned functions depend on the time zone
+		key.Set("tz", form.location.String())
+	}
+	if form.maxDataPoints > 0 {
+		// all formats are consolidated to maxDataPoints
+		key.Set("maxDataPoints", strconv.Itoa(form.maxDataPoints))
+	}
+
+	if names, ok := formatParams[form.format]; ok {
+		for _, name := range names {
+			if v, ok := params[name]; ok {
+				key[name] = v
+			}
+		}
+	} else {
+		for name, v := range params {
+			if !keyIgnoredParams[name] {
+				key[name] = v
+			}
+		}
+	}
+
+	return key.Encode()
+}
+
+// keyTime returns the time t parsed from spec as it is in cache keys.
+func keyTime(spec string, t int32, location *time.Location, bucket int32) int32 {
+	if !date.IsRelative(spec, location) {
+		return t
+	}
+	return alignTime(t, bucket)
+}
+
+func alignTime(t, bucket int32) int32 {
+	if bucket <= 1 {
+		return t
+	}
+	return t - t%bucket
+}
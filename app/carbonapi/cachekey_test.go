@@ -0,0 +1,190 @@
+package carbonapi
+
+import (
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/bookingcom/carbonapi/pkg/parser"
+)
+
+func cacheKeyFor(t *testing.T, query string, from, until int32) string {
+	params, err := url.ParseQuery(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var exps []parser.Expr
+	for _, target := range params["target"] {
+		exp, _, err := parser.ParseExpr(target)
+		if err != nil {
+			t.Fatal(err)
+		}
+		exps = append(exps, exp)
+	}
+
+	// UTC is the default time zone
+	location, err := time.LoadLocation(params.Get("tz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	maxDataPoints, _ := strconv.Atoi(params.Get("maxDataPoints"))
+
+	form := renderForm{
+		from:          params.Get("from"),
+		until:         params.Get("until"),
+		format:        params.Get("format"),
+		from32:        from,
+		until32:       until,
+		location:      location,
+		maxDataPoints: maxDataPoints,
+	}
+	return renderCacheKey(exps, form, params, 60)
+}
+
+func TestRenderCacheKey(t *testing.T) {
+	tests := []struct {
+		name  string
+		a, b  string
+		aFrom int32
+		bFrom int32
+		same  bool
+	}{
+		{
+			name: "whitespace in targets",
+			a:    "target=sumSeries(a.b)&format=json",
+			b:    "target=sumSeries( a.b )&format=json",
+			same: true,
+		},
+		{
+			name: "pipe syntax",
+			a:    "target=a.b|scale(2)&format=json",
+			b:    "target=scale(a.b,2)&format=json",
+			same: true,
+		},
+		{
+			name: "quoting",
+			a:    `target=alias(a.b,"x")&format=json`,
+			b:    "target=alias(a.b,'x')&format=json",
+			same: true,
+		},
+		{
+			name: "png params on json",
+			a:    "target=a.b&format=json&width=100&bgcolor=red",
+			b:    "target=a.b&format=json",
+			same: true,
+		},
+		{
+			name: "png params on png",
+			a:    "target=a.b&format=png&width=100",
+			b:    "target=a.b&format=png",
+			same: false,
+		},
+		{
+			name: "cache busters on png",
+			a:    "target=a.b&format=png&_salt=123&noCache=0&jsonp=cb",
+			b:    "target=a.b&format=png",
+			same: true,
+		},
+		{
+			name: "maxDataPoints on json",
+			a:    "target=a.b&format=json&maxDataPoints=100",
+			b:    "target=a.b&format=json",
+			same: false,
+		},
+		{
+			name: "maxDataPoints on csv",
+			a:    "target=a.b&format=csv&maxDataPoints=100",
+			b:    "target=a.b&format=csv",
+			same: false,
+		},
+		{
+			name: "zero maxDataPoints",
+			a:    "target=a.b&format=json&maxDataPoints=0",
+			b:    "target=a.b&format=json",
+			same: true,
+		},
+		{
+			name: "target order",
+			a:    "target=a.b&target=c.d&format=json",
+			b:    "target=c.d&target=a.b&format=json",
+			same: false,
+		},
+		{
+			name:  "time within a bucket",
+			a:     "target=a.b&format=json",
+			b:     "target=a.b&format=json",
+			aFrom: 1200,
+			bFrom: 1259,
+			same:  true,
+		},
+		{
+			name:  "time in another bucket",
+			a:     "target=a.b&format=json",
+			b:     "target=a.b&format=json",
+			aFrom: 1259,
+			bFrom: 1260,
+			same:  false,
+		},
+		{
+			name:  "relative time within a bucket",
+			a:     "target=a.b&format=json&from=-1h",
+			b:     "target=a.b&format=json&from=-1h",
+			aFrom: 1200,
+			bFrom: 1259,
+			same:  true,
+		},
+		{
+			name:  "absolute time within a bucket",
+			a:     "target=a.b&format=json&from=1200",
+			b:     "target=a.b&format=json&from=1259",
+			aFrom: 1200,
+			bFrom: 1259,
+			same:  false,
+		},
+		{
+			name: "time zone on json",
+			a:    "target=summarize(a.b,'1d')&format=json&tz=Europe/Amsterdam",
+			b:    "target=summarize(a.b,'1d')&format=json",
+			same: false,
+		},
+		{
+			name: "default time zone on csv",
+			a:    "target=a.b&format=csv&tz=UTC",
+			b:    "target=a.b&format=csv",
+			same: true,
+		},
+		{
+			name: "different format",
+			a:    "target=a.b&format=json",
+			b:    "target=a.b&format=raw",
+			same: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := cacheKeyFor(t, tt.a, tt.aFrom, 3600)
+			b := cacheKeyFor(t, tt.b, tt.bFrom, 3600)
+			if (a == b) != tt.same {
+				t.Errorf("keys %q and %q, expected same=%v", a, b, tt.same)
+			}
+		})
+	}
+}
+
+func TestRenderCacheKeyFormat(t *testing.T) {
+	got := cacheKeyFor(t, "target=a.b | alias( 'x' )&format=json&maxDataPoints=10&width=5", 1234, 4321)
+	expected := "format=json&from=1200&maxDataPoints=10&target=alias%28a.b%2C%27x%27%29&tz=UTC&until=4320"
+	if got != expected {
+		t.Errorf("Expected %s, got %s", expected, got)
+	}
+
+	got = cacheKeyFor(t, "target=a.b&format=json&from=1234&until=now", 1234, 4321)
+	expected = "format=json&from=1234&target=a.b&tz=UTC&until=4320"
+	if got != expected {
+		t.Errorf("Expected %s, got %s", expected, got)
+	}
+}
@@ -9,20 +9,26 @@ import (
 	"runtime/debug"
 	"strconv"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
+	"unicode/utf8"
 
 	"github.com/bookingcom/carbonapi/carbonapipb"
 	"github.com/bookingcom/carbonapi/date"
 	"github.com/bookingcom/carbonapi/expr"
+	"github.com/bookingcom/carbonapi/expr/evalctx"
 	"github.com/bookingcom/carbonapi/expr/functions/cairo/png"
 	"github.com/bookingcom/carbonapi/expr/interfaces"
+	"github.com/bookingcom/carbonapi/expr/limits"
 	"github.com/bookingcom/carbonapi/expr/metadata"
 	"github.com/bookingcom/carbonapi/expr/types"
 	"github.com/bookingcom/carbonapi/pkg/parser"
 	dataTypes "github.com/bookingcom/carbonapi/pkg/types"
+	"github.com/bookingcom/carbonapi/pkg/types/encoding/arrow"
 	"github.com/bookingcom/carbonapi/pkg/types/encoding/carbonapi_v2"
 	ourJson "github.com/bookingcom/carbonapi/pkg/types/encoding/json"
+	"github.com/bookingcom/carbonapi/pkg/types/encoding/msgpack"
 	"github.com/bookingcom/carbonapi/pkg/types/encoding/pickle"
 	"github.com/bookingcom/carbonapi/util"
 
@@ -44,6 +50,8 @@ const (
 	protobufFormat  = "protobuf"
 	protobuf3Format = "protobuf3"
 	pickleFormat    = "pickle"
+	msgpackFormat   = "msgpack"
+	arrowFormat     = "arrow"
 	completerFormat = "completer"
 )
 
@@ -90,6 +98,12 @@ func writeResponse(ctx context.Context, w http.ResponseWriter, b []byte, format
 	case pickleFormat:
 		w.Header().Set("Content-Type", contentTypePickle)
 		w.Write(b)
+	case msgpackFormat:
+		w.Header().Set("Content-Type", contentTypeMsgpack)
+		w.Write(b)
+	case arrowFormat:
+		w.Header().Set("Content-Type", contentTypeArrow)
+		w.Write(b)
 	case csvFormat:
 		w.Header().Set("Content-Type", contentTypeCSV)
 		w.Write(b)
@@ -108,6 +122,8 @@ const (
 	contentTypeJavaScript = "text/javascript"
 	contentTypeRaw        = "text/plain"
 	contentTypePickle     = "application/pickle"
+	contentTypeMsgpack    = "application/x-msgpack"
+	contentTypeArrow      = arrow.ContentType
 	contentTypePNG        = "image/png"
 	contentTypeCSV        = "text/csv"
 	contentTypeSVG        = "image/svg+xml"
@@ -127,7 +143,7 @@ func (app *App) renderHandler(w http.ResponseWriter, r *http.Request) {
 	span := trace.SpanFromContext(ctx)
 	uuid := util.GetUUID(ctx)
 
-	partiallyFailed := false
+	var partiallyFailed int32
 	toLog := carbonapipb.NewAccessLogDetails(r, "render", &app.config)
 	// TODO (grzkv): Replace with access logger
 	logger := zapwriter.Logger("render").With(
@@ -170,16 +186,63 @@ func (app *App) renderHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	exps := make([]parser.Expr, 0, len(form.targets))
+	for _, target := range form.targets {
+		exp, e, err := parser.ParseExpr(target)
+		if err == nil {
+			err = parser.TrailingInputError(target, e)
+		}
+		if err == nil {
+			// catch bad arguments before doing any finds and renders
+			err = expr.Validate(exp)
+		}
+		if err != nil {
+			writeTargetError(uuid, r, w, target, err, form.format, &toLog, span)
+			logAsError = true
+			return
+		}
+		exps = append(exps, exp)
+	}
+
+	form.cacheKey = renderCacheKey(exps, form, r.Form, app.config.Cache.KeyBucketSec)
+	toLog.CacheKey = form.cacheKey
+
+	if form.meta {
+		ctx = withRenderMeta(ctx, &renderMeta{})
+	}
+
+	var stale []byte
 	if form.useCache {
 		tc := time.Now()
-		response, err := app.queryCache.Get(form.cacheKey)
+		response, isStale, err := getStale(app.queryCache, form.cacheKey)
 		td := time.Since(tc).Nanoseconds()
 		apiMetrics.RenderCacheOverheadNS.Add(td)
 
 		toLog.CarbonzipperResponseSizeBytes = 0
 		toLog.CarbonapiResponseSizeBytes = int64(len(response))
 
-		if err == nil {
+		if err == nil && (!isStale || app.config.Cache.RenderStale.Revalidate) {
+			if isStale {
+				app.prometheusMetrics.StaleResponses.WithLabelValues("render", "revalidate").Inc()
+				// the request is done with before the refresh, so it gets a copy
+				refreshReq := r.Clone(context.Background())
+				refreshLog := carbonapipb.NewAccessLogDetails(r, "render_refresh", &app.config)
+				refreshLog.RequestMethod = r.Method
+				refreshLog.CacheKey = form.cacheKey
+				app.refresh("render:"+form.cacheKey, func(ctx context.Context) {
+					t0 := time.Now()
+					err := app.refreshRender(ctx, refreshReq, exps, form, &refreshLog, logger)
+					logRefresh(&refreshLog, t0, err)
+				})
+				w.Header().Set("Warning", staleWarning)
+			}
+
+			if form.meta {
+				if marked, err := markFromCache(response); err == nil {
+					response = marked
+				}
+			}
+
 			apiMetrics.RequestCacheHits.Add(1)
 			writeResponse(ctx, w, response, form.format, form.jsonp)
 			toLog.FromCache = true
@@ -187,70 +250,42 @@ func (app *App) renderHandler(w http.ResponseWriter, r *http.Request) {
 			toLog.HttpCode = http.StatusOK
 			return
 		}
+		if err == nil && app.config.Cache.RenderStale.ServeOnError {
+			stale = response
+		}
 		apiMetrics.RequestCacheMisses.Add(1)
 	}
 	span.SetAttribute("from_cache", false)
 
-	metricMap := make(map[parser.MetricRequest][]*types.MetricData)
-
-	tracer := span.Tracer()
-	var results []*types.MetricData
-	for targetIdx := 0; targetIdx < len(form.targets); targetIdx++ {
-		target := form.targets[targetIdx]
-		targetCtx, targetSpan := tracer.Start(ctx, "carbonapi render", trace.WithAttributes(
-			kv.String("graphite.target", target),
-		))
-		exp, e, err := parser.ParseExpr(target)
-		if err != nil || e != "" {
-			msg := buildParseErrorString(target, e, err)
-			writeError(uuid, r, w, http.StatusBadRequest, msg, form.format, &toLog, span)
-			logAsError = true
-			return
-		}
-		targetSpan.AddEvent(targetCtx, "parsed expression")
-
-		getTargetData := func(ctx context.Context, exp parser.Expr, from, until int32, metricMap map[parser.MetricRequest][]*types.MetricData) (error, int) {
-			return app.getTargetData(ctx, target, exp, metricMap, form.useCache, from, until, &toLog, logger, &partiallyFailed, targetSpan)
-		}
-		targetSpan.AddEvent(targetCtx, "retrieved target data")
-
-		targetErr, metricSize := app.getTargetData(targetCtx, target, exp, metricMap,
-			form.useCache, form.from32, form.until32, &toLog, logger, &partiallyFailed, targetSpan)
-
-		if targetErr == nil {
-			targetErr = evalExprRender(targetCtx, exp, &results, metricMap, &form, app.config.PrintErrorStackTrace, getTargetData)
-		}
-		targetSpan.AddEvent(targetCtx, "evaluated expression")
-
-		if targetErr != nil {
-			// we can have 3 error types here
-			// a) dataTypes.ErrNotFound  > Continue, at the end we check if all errors are 'not found' and we answer with http 404
-			// b) parser.ParseError -> Return with this error(like above, but with less details )
-			// c) anything else -> continue, answer will be 5xx if all targets have one error
-			var parseError parser.ParseError
-			var notFound dataTypes.ErrNotFound
-			switch {
-			case errors.As(targetErr, &notFound):
-				// When not found, graphite answers with  http 200 and []
-			case errors.As(targetErr, &parseError):
-				writeError(uuid, r, w, http.StatusBadRequest, targetErr.Error(), form.format, &toLog, span)
-				logAsError = true
-				return
-			case errors.Is(err, context.DeadlineExceeded):
-				writeError(uuid, r, w, http.StatusUnprocessableEntity, "request too complex", form.format, &toLog, span)
-				logAsError = true
-				app.prometheusMetrics.RequestCancel.WithLabelValues(
-					"render", ctx.Err().Error(),
-				).Inc()
-				return
-			default:
-				writeError(uuid, r, w, http.StatusInternalServerError, targetErr.Error(), form.format, &toLog, span)
-				logAsError = true
-				return
-			}
+	results, size, err := app.renderTargets(ctx, exps, form, &toLog, logger, &partiallyFailed)
+	if err != nil && stale != nil && isBackendError(err) {
+		logger.Warn("serving expired response after backends failed", zap.Error(err))
+		app.prometheusMetrics.StaleResponses.WithLabelValues("render", "error").Inc()
+		w.Header().Set("Warning", staleOnErrorWarning)
+		writeResponse(ctx, w, stale, form.format, form.jsonp)
+		toLog.FromCache = true
+		span.SetAttribute("from_cache", true)
+		toLog.HttpCode = http.StatusOK
+		return
+	}
+	if err != nil {
+		var parseError parser.ParseError
+		var limitErr limits.ErrLimitExceeded
+		switch {
+		case errors.As(err, &parseError):
+			writeError(uuid, r, w, http.StatusBadRequest, err.Error(), form.format, &toLog, span)
+		case errors.As(err, &limitErr):
+			writeError(uuid, r, w, http.StatusUnprocessableEntity, "request too complex: "+limitErr.Error(), form.format, &toLog, span)
+		case errors.Is(err, context.DeadlineExceeded):
+			writeError(uuid, r, w, http.StatusUnprocessableEntity, "request too complex", form.format, &toLog, span)
+			app.prometheusMetrics.RequestCancel.WithLabelValues(
+				"render", context.DeadlineExceeded.Error(),
+			).Inc()
+		default:
+			writeError(uuid, r, w, http.StatusInternalServerError, err.Error(), form.format, &toLog, span)
 		}
-		size += metricSize
-		targetSpan.End()
+		logAsError = true
+		return
 	}
 	toLog.CarbonzipperResponseSizeBytes = int64(size * 8)
 
@@ -260,16 +295,24 @@ func (app *App) renderHandler(w http.ResponseWriter, r *http.Request) {
 		).Inc()
 	}
 
-	body, err := app.renderWriteBody(results, form, r, logger)
-	if err != nil {
-		writeError(uuid, r, w, http.StatusInternalServerError, err.Error(), form.format, &toLog, span)
-		logAsError = true
-		return
-	}
+	var body []byte
+	if isStreamed(form.format) {
+		var size int
+		body, size = app.renderStream(ctx, w, results, form, logger)
+		toLog.CarbonapiResponseSizeBytes = int64(size)
+	} else {
+		body, err = app.renderWriteBody(ctx, results, form, r, logger)
+		if err != nil {
+			writeError(uuid, r, w, http.StatusInternalServerError, err.Error(), form.format, &toLog, span)
+			logAsError = true
+			return
+		}
 
-	writeResponse(ctx, w, body, form.format, form.jsonp)
+		writeResponse(ctx, w, body, form.format, form.jsonp)
+	}
 
-	if len(results) != 0 {
+	// streamed responses too large to be cached have no body
+	if len(results) != 0 && body != nil {
 		tc := time.Now()
 		// TODO (grzkv): Timeout is passed as "expire" argument.
 		// Looks like things are mixed.
@@ -278,12 +321,63 @@ func (app *App) renderHandler(w http.ResponseWriter, r *http.Request) {
 		apiMetrics.RenderCacheOverheadNS.Add(td)
 	}
 
-	if partiallyFailed {
+	if atomic.LoadInt32(&partiallyFailed) != 0 {
 		app.prometheusMetrics.RenderPartialFail.Inc()
 	}
 	toLog.HttpCode = http.StatusOK
 }
 
+// renderTargets evaluates the targets and returns their results and size,
+// or the error of the first target failing with something else than not found.
+func (app *App) renderTargets(ctx context.Context, exps []parser.Expr, form renderForm,
+	toLog *carbonapipb.AccessLogDetails, lg *zap.Logger, partFail *int32) ([]*types.MetricData, int, error) {
+
+	plan := newFetchPlan(exps, form.from32, form.until32)
+	plan.consolidateTo(exps, form.maxDataPoints)
+	defer plan.log(toLog)
+
+	var results []*types.MetricData
+	size := 0
+	for _, t := range app.evalTargets(ctx, exps, form, plan, toLog, lg, partFail) {
+		var notFound dataTypes.ErrNotFound
+		if t.err != nil && !errors.As(t.err, &notFound) {
+			return nil, 0, t.err
+		}
+		// When not found, graphite answers with  http 200 and []
+		results = append(results, t.results...)
+		size += t.size
+	}
+
+	return results, size, nil
+}
+
+// refreshRender renders the targets again and caches the response
+// in place of an expired one.
+func (app *App) refreshRender(ctx context.Context, r *http.Request, exps []parser.Expr, form renderForm,
+	toLog *carbonapipb.AccessLogDetails, lg *zap.Logger) error {
+
+	if form.meta {
+		ctx = withRenderMeta(ctx, &renderMeta{})
+	}
+
+	var partiallyFailed int32
+	results, _, err := app.renderTargets(ctx, exps, form, toLog, lg, &partiallyFailed)
+	if err != nil {
+		return err
+	}
+	if len(results) == 0 {
+		return nil
+	}
+
+	body, err := app.renderWriteBody(ctx, results, form, r, lg)
+	if err != nil {
+		return err
+	}
+	toLog.CarbonapiResponseSizeBytes = int64(len(body))
+	app.queryCache.Set(form.cacheKey, body, form.cacheTimeout)
+	return nil
+}
+
 func writeError(uuid string,
 	r *http.Request, w http.ResponseWriter,
 	code int, s string, format string,
@@ -328,16 +422,142 @@ func evalExprRender(ctx context.Context, exp parser.Expr, res *([]*types.MetricD
 	return nil
 }
 
+// panicError returns the error of a panic recovered in a goroutine of a request.
+// Unlike panics of handlers, net/http doesn't recover them.
+func (app *App) panicError(during string, r interface{}) error {
+	if app.config.PrintErrorStackTrace {
+		debug.PrintStack()
+	}
+	return fmt.Errorf("panic during %s: %v", during, r)
+}
+
+// targetResult is the outcome of evaluating a single render target.
+type targetResult struct {
+	results []*types.MetricData
+	size    int
+	err     error
+}
+
+// evalTargets fetches data for and evaluates targets concurrently, running at most
+// MaxConcurrentTargets of them at once. Results are returned in the order of exps.
+// Once a target fails the whole request, evaluation of the other ones is cancelled.
+func (app *App) evalTargets(ctx context.Context, exps []parser.Expr, form renderForm, plan *fetchPlan,
+	toLog *carbonapipb.AccessLogDetails, lg *zap.Logger, partFail *int32) []targetResult {
+
+	parent := ctx
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	workers := app.config.MaxConcurrentTargets
+	if workers <= 0 {
+		workers = 1
+	}
+	limiter := make(chan struct{}, workers)
+	store := newMetricStore()
+	res := make([]targetResult, len(exps))
+	failed := int32(-1)
+
+	var wg sync.WaitGroup
+	for i := range exps {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			select {
+			case limiter <- struct{}{}:
+			case <-ctx.Done():
+				res[i].err = ctx.Err()
+				return
+			}
+			defer func() { <-limiter }()
+
+			func() {
+				defer func() {
+					if r := recover(); r != nil {
+						res[i] = targetResult{err: app.panicError("target evaluation", r)}
+					}
+				}()
+				res[i] = app.evalTarget(ctx, form.targets[i], exps[i], store, &form, plan, toLog, lg, partFail)
+			}()
+
+			var notFound dataTypes.ErrNotFound
+			if res[i].err != nil && !errors.As(res[i].err, &notFound) {
+				if atomic.CompareAndSwapInt32(&failed, -1, int32(i)) {
+					cancel()
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// targets cancelled because of a failed sibling report the sibling's error
+	if failed >= 0 && parent.Err() == nil {
+		for i := range res {
+			if errors.Is(res[i].err, context.Canceled) {
+				res[i].err = res[failed].err
+			}
+		}
+	}
+
+	return res
+}
+
+func (app *App) evalTarget(ctx context.Context, target string, exp parser.Expr, store *metricStore,
+	form *renderForm, plan *fetchPlan,
+	toLog *carbonapipb.AccessLogDetails, lg *zap.Logger, partFail *int32) (res targetResult) {
+
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "carbonapi render", trace.WithAttributes(
+		kv.String("graphite.target", target),
+	))
+	defer span.End()
+	span.AddEvent(ctx, "parsed expression")
+
+	budget := limits.NewBudget(limits.Limits{
+		MaxSeries:       app.config.EvalLimits.MaxSeries,
+		MaxDatapoints:   app.config.EvalLimits.MaxDatapoints,
+		MaxFunctionTime: app.config.EvalLimits.MaxFunctionTime,
+	})
+	ctx = evalctx.NewContext(ctx, evalctx.Context{
+		Location: form.location,
+		Budget:   budget,
+		Logger:   lg,
+	})
+	defer func() {
+		span.SetAttribute("graphite.eval_datapoints", budget.Datapoints())
+	}()
+
+	// every target gets its own map, as functions add data to it while evaluating
+	metricMap := make(map[parser.MetricRequest][]*types.MetricData)
+	getTargetData := func(ctx context.Context, exp parser.Expr, from, until int32, metricMap map[parser.MetricRequest][]*types.MetricData) (error, int) {
+		return app.getTargetData(ctx, target, exp, metricMap, store, plan, form.useCache, from, until, toLog, lg, partFail, span)
+	}
+
+	res.err, res.size = getTargetData(ctx, exp, form.from32, form.until32, metricMap)
+	span.AddEvent(ctx, "retrieved target data")
+
+	if res.err == nil {
+		res.err = evalExprRender(ctx, exp, &res.results, metricMap, form, app.config.PrintErrorStackTrace, getTargetData)
+	}
+	span.AddEvent(ctx, "evaluated expression")
+
+	return res
+}
+
+// fetchResult is the data of a single metric request of a target.
+type fetchResult struct {
+	data    []*types.MetricData
+	metrics int
+	size    int
+	err     error
+}
+
 func (app *App) getTargetData(ctx context.Context, target string, exp parser.Expr,
-	metricMap map[parser.MetricRequest][]*types.MetricData,
+	metricMap map[parser.MetricRequest][]*types.MetricData, store *metricStore, plan *fetchPlan,
 	useCache bool, from, until int32,
-	toLog *carbonapipb.AccessLogDetails, lg *zap.Logger, partFail *bool,
+	toLog *carbonapipb.AccessLogDetails, lg *zap.Logger, partFail *int32,
 	span trace.Span) (error, int) {
 
-	size := 0
-	metrics := 0
 	var targetMetricFetches []parser.MetricRequest
-	var metricErrs []error
+	var pending []parser.MetricRequest
 	for _, m := range exp.Metrics() {
 		mfetch := m
 		mfetch.From += from
@@ -345,70 +565,160 @@ func (app *App) getTargetData(ctx context.Context, target string, exp parser.Exp
 
 		targetMetricFetches = append(targetMetricFetches, mfetch)
 		if _, ok := metricMap[mfetch]; ok {
-			// already fetched this metric for this request
+			// already fetched this metric for this target
 			continue
 		}
+		// mark it, so that a metric repeated in the expression is fetched once
+		metricMap[mfetch] = nil
+		pending = append(pending, mfetch)
+	}
+
+	// arguments of a function are independent, fetch them concurrently
+	fetched := make([]fetchResult, len(pending))
+	var wg sync.WaitGroup
+	for i, mfetch := range pending {
+		wg.Add(1)
+		go func(i int, mfetch parser.MetricRequest) {
+			defer wg.Done()
+			defer func() {
+				if r := recover(); r != nil {
+					fetched[i] = fetchResult{err: app.panicError("fetch", r)}
+				}
+			}()
+			fetched[i] = app.fetchPlanned(ctx, mfetch, store, plan, useCache, toLog, lg, partFail)
+		}(i, mfetch)
+	}
+	wg.Wait()
+	if ctx.Err() != nil {
+		return ctx.Err(), 0
+	}
 
-		// This _sometimes_ sends a *find* request
-		renderRequests, err := app.getRenderRequests(ctx, m, useCache, toLog, lg)
-		if err != nil {
-			metricErrs = append(metricErrs, err)
-			continue
-		} else if len(renderRequests) == 0 {
-			metricErrs = append(metricErrs, dataTypes.ErrMetricsNotFound)
+	size := 0
+	metrics := 0
+	var metricErrs []error
+	for i, mfetch := range pending {
+		f := fetched[i]
+		metrics += f.metrics
+		size += f.size
+		if f.err != nil {
+			delete(metricMap, mfetch)
+			metricErrs = append(metricErrs, f.err)
 			continue
 		}
-
-		// TODO(dgryski): group the render requests into batches
-		rch := make(chan renderResponse, len(renderRequests))
-		for _, m := range renderRequests {
-			// TODO (grzkv) Refactor to enable premature cancel
-			go app.sendRenderRequest(ctx, rch, m, mfetch.From, mfetch.Until, toLog)
-		}
-
-		errs := make([]error, 0)
-		for i := 0; i < len(renderRequests); i++ {
-			resp := <-rch
-			if resp.error != nil {
-				errs = append(errs, resp.error)
-				continue
-			}
-
-			for _, r := range resp.data {
-				metrics++
-				size += len(r.Values) // close enough
-				metricMap[mfetch] = append(metricMap[mfetch], r)
-			}
-		}
-		close(rch)
-		// We have to check it here because we don't want to return before closing rch
-		select {
-		case <-ctx.Done():
-			return ctx.Err(), 0
-		default:
-		}
-
-		metricErr, metricErrStr := optimistFanIn(errs, len(renderRequests), "requests")
-		*partFail = (*partFail) || (metricErrStr != "")
-		if metricErr != nil {
-			metricErrs = append(metricErrs, metricErr)
-		}
-
-		expr.SortMetrics(metricMap[mfetch], mfetch)
-	} // range exp.Metrics
+		metricMap[mfetch] = f.data
+		plan.addRequestedPoints(f.data)
+	}
 
 	span.SetAttribute("graphite.metrics", metrics)
 	span.SetAttribute("graphite.datapoints", size)
 
 	targetErr, targetErrStr := optimistFanIn(metricErrs, len(exp.Metrics()), "metrics")
-	*partFail = *partFail || (targetErrStr != "")
+	if targetErrStr != "" {
+		atomic.StoreInt32(partFail, 1)
+		renderMetaFrom(ctx).warn("some metrics of target " + target + " failed: " + targetErrStr)
+	}
 
-	logStepTimeMismatch(targetMetricFetches, metricMap, lg, target)
+	if logStepTimeMismatch(targetMetricFetches, metricMap, lg, target) {
+		renderMetaFrom(ctx).warn("metrics of target " + target + " have differing resolution")
+	}
 	span.SetAttribute("graphite.metric_errors", targetErrStr)
 
 	return targetErr, size
 }
 
+// fetchPlanned returns data for a metric request. The whole planned range covering
+// the request is fetched once per render request, other requests for the path get
+// a slice of it.
+func (app *App) fetchPlanned(ctx context.Context, mfetch parser.MetricRequest, store *metricStore, plan *fetchPlan,
+	useCache bool, toLog *carbonapipb.AccessLogDetails, lg *zap.Logger, partFail *int32) fetchResult {
+
+	var res fetchResult
+	cover := plan.cover(mfetch)
+	data, isFetcher, err := store.get(ctx, cover, func() ([]*types.MetricData, error) {
+		var data []*types.MetricData
+		var err error
+		err, data, res.size = app.fetchMetric(ctx, cover, useCache, toLog, lg, partFail, plan)
+		res.metrics = len(data)
+		plan.addFetchedPoints(res.size)
+		return data, err
+	})
+	if !isFetcher {
+		plan.addSavedFetch()
+	}
+	if err != nil {
+		res.err = err
+		return res
+	}
+
+	if cover != mfetch {
+		data = sliceMetrics(data, mfetch.From, mfetch.Until)
+	}
+	res.data = data
+
+	return res
+}
+
+// fetchMetric pulls a single metric request from the zipper.
+// It returns the error for the request, fetched series and number of datapoints.
+func (app *App) fetchMetric(ctx context.Context, mfetch parser.MetricRequest,
+	useCache bool, toLog *carbonapipb.AccessLogDetails, lg *zap.Logger, partFail *int32,
+	plan *fetchPlan) (error, []*types.MetricData, int) {
+
+	// This _sometimes_ sends a *find* request
+	renderRequests, err := app.getRenderRequests(ctx, mfetch, useCache, toLog, plan, lg)
+	if err != nil {
+		return err, nil, 0
+	} else if len(renderRequests) == 0 {
+		return dataTypes.ErrMetricsNotFound, nil, 0
+	}
+
+	size := 0
+	var data []*types.MetricData
+
+	// TODO(dgryski): group the render requests into batches
+	rch := make(chan renderResponse, len(renderRequests))
+	for _, m := range renderRequests {
+		// TODO (grzkv) Refactor to enable premature cancel
+		go app.sendRenderRequest(ctx, rch, m, mfetch.From, mfetch.Until, plan.maxDataPoints, useCache, toLog)
+	}
+
+	errs := make([]error, 0)
+	partial := false
+	for i := 0; i < len(renderRequests); i++ {
+		resp := <-rch
+		if errors.Is(resp.error, dataTypes.ErrPartialResponse) {
+			partial = true
+		} else if resp.error != nil {
+			errs = append(errs, resp.error)
+			continue
+		}
+
+		for _, r := range resp.data {
+			size += len(r.Values) // close enough
+			data = append(data, r)
+		}
+	}
+	close(rch)
+	// We have to check it here because we don't want to return before closing rch
+	if ctx.Err() != nil {
+		return ctx.Err(), data, size
+	}
+
+	metricErr, metricErrStr := optimistFanIn(errs, len(renderRequests), "requests")
+	if metricErrStr != "" {
+		atomic.StoreInt32(partFail, 1)
+		renderMetaFrom(ctx).warn("some requests for " + mfetch.Metric + " failed: " + metricErrStr)
+	}
+	if partial {
+		atomic.StoreInt32(partFail, 1)
+		renderMetaFrom(ctx).warn("some backends failed to respond for " + mfetch.Metric)
+	}
+
+	expr.SortMetrics(data, mfetch)
+
+	return metricErr, data, size
+}
+
 // returns non-nil error when errors result in an error
 // returns non-empty string when there are *some* errors, even when total err is nil
 // returned string can be used to indicate partial failure
@@ -448,17 +758,37 @@ func optimistFanIn(errs []error, n int, subj string) (error, string) {
 }
 
 func (app *App) sendRenderRequest(ctx context.Context, ch chan<- renderResponse,
-	path string, from, until int32, toLog *carbonapipb.AccessLogDetails) {
+	path string, from, until int32, maxDataPoints int, useCache bool, toLog *carbonapipb.AccessLogDetails) {
+
+	defer func() {
+		if r := recover(); r != nil {
+			ch <- renderResponse{error: app.panicError("render request", r)}
+		}
+	}()
+
+	fetch := func(ctx context.Context, from, until int32) ([]dataTypes.Metric, error) {
+		apiMetrics.RenderRequests.Add(1)
+		atomic.AddInt64(&toLog.ZipperRequests, 1)
 
-	apiMetrics.RenderRequests.Add(1)
-	atomic.AddInt64(&toLog.ZipperRequests, 1)
+		request := dataTypes.NewRenderRequest([]string{path}, from, until)
+		request.MaxDataPoints = maxDataPoints
+		metrics, err := app.backend.Render(ctx, request)
 
-	request := dataTypes.NewRenderRequest([]string{path}, from, until)
-	metrics, err := app.backend.Render(ctx, request)
+		// time in queue is converted to ms
+		app.prometheusMetrics.TimeInQueueExp.Observe(float64(request.Trace.Report()[2]) / 1000 / 1000)
+		app.prometheusMetrics.TimeInQueueLin.Observe(float64(request.Trace.Report()[2]) / 1000 / 1000)
+
+		return metrics, err
+	}
 
-	// time in queue is converted to ms
-	app.prometheusMetrics.TimeInQueueExp.Observe(float64(request.Trace.Report()[2]) / 1000 / 1000)
-	app.prometheusMetrics.TimeInQueueLin.Observe(float64(request.Trace.Report()[2]) / 1000 / 1000)
+	var metrics []dataTypes.Metric
+	var err error
+	// the series cache keeps raw points only
+	if app.seriesCache != nil && useCache && maxDataPoints == 0 {
+		metrics, err = app.seriesCache.render(ctx, path, from, until, int32(timeNow().Unix()), fetch)
+	} else {
+		metrics, err = fetch(ctx, from, until)
+	}
 
 	metricData := make([]*types.MetricData, 0)
 	for i := range metrics {
@@ -486,6 +816,12 @@ type renderForm struct {
 	cacheKey     string
 	cacheTimeout int32
 	qtz          string
+	// location is the time zone of the request, from qtz or the user
+	location *time.Location
+	// meta adds metadata to json responses
+	meta bool
+	// maxDataPoints is the number of points results are consolidated to, 0 for all
+	maxDataPoints int
 }
 
 func (app *App) renderHandlerProcessForm(r *http.Request, accessLogDetails *carbonapipb.AccessLogDetails, logger *zap.Logger) (renderForm, error) {
@@ -502,10 +838,12 @@ func (app *App) renderHandlerProcessForm(r *http.Request, accessLogDetails *carb
 	res.format = r.FormValue("format")
 	res.template = r.FormValue("template")
 	res.useCache = !parser.TruthyBool(r.FormValue("noCache"))
+	res.maxDataPoints, _ = strconv.Atoi(r.FormValue("maxDataPoints"))
 
 	if res.format == jsonFormat {
 		// TODO(dgryski): check jsonp only has valid characters
 		res.jsonp = r.FormValue("jsonp")
+		res.meta = parser.TruthyBool(r.FormValue("meta"))
 	}
 
 	if res.format == "" && (parser.TruthyBool(r.FormValue("rawData")) || parser.TruthyBool(r.FormValue("rawdata"))) {
@@ -530,23 +868,13 @@ func (app *App) renderHandlerProcessForm(r *http.Request, accessLogDetails *carb
 		}
 	}
 
-	// make sure the cache key doesn't say noCache, because it will never hit
-	r.Form.Del("noCache")
-
-	// jsonp callback names are frequently autogenerated and hurt our cache
-	r.Form.Del("jsonp")
-
-	// Strip some cache-busters.  If you don't want to cache, use noCache=1
-	r.Form.Del("_salt")
-	r.Form.Del("_ts")
-	r.Form.Del("_t") // Used by jquery.graphite.js
-
-	res.cacheKey = r.Form.Encode()
-
 	// normalize from and until values
 	res.qtz = r.FormValue("tz")
-	res.from32 = date.DateParamToEpoch(res.from, res.qtz, timeNow().Add(-24*time.Hour).Unix(), app.defaultTimeZone)
-	res.until32 = date.DateParamToEpoch(res.until, res.qtz, timeNow().Unix(), app.defaultTimeZone)
+	userTimeZone := app.userTimeZone(accessLogDetails.Username)
+	var fromErr, untilErr error
+	res.from32, fromErr = date.ParseDateParam(res.from, res.qtz, timeNow().Add(-24*time.Hour).Unix(), userTimeZone)
+	res.until32, untilErr = date.ParseDateParam(res.until, res.qtz, timeNow().Unix(), userTimeZone)
+	res.location = app.requestLocation(res.qtz, accessLogDetails.Username, logger)
 
 	accessLogDetails.UseCache = res.useCache
 	accessLogDetails.FromRaw = res.from
@@ -558,6 +886,21 @@ func (app *App) renderHandlerProcessForm(r *http.Request, accessLogDetails *carb
 	accessLogDetails.Format = res.format
 	accessLogDetails.Targets = res.targets
 
+	if fromErr != nil || untilErr != nil {
+		logger.Warn("failed to parse time range",
+			zap.String("from", res.from),
+			zap.String("until", res.until),
+			zap.Error(fromErr),
+			zap.NamedError("until_error", untilErr),
+		)
+		if app.config.StrictTimeParsing {
+			if fromErr != nil {
+				return res, fmt.Errorf("invalid from: %v", fromErr)
+			}
+			return res, fmt.Errorf("invalid until: %v", untilErr)
+		}
+	}
+
 	span := trace.SpanFromContext(r.Context())
 	span.SetAttributes(
 		kv.Bool("graphite.useCache", res.useCache),
@@ -573,17 +916,19 @@ func (app *App) renderHandlerProcessForm(r *http.Request, accessLogDetails *carb
 	return res, nil
 }
 
-func (app *App) renderWriteBody(results []*types.MetricData, form renderForm, r *http.Request, logger *zap.Logger) ([]byte, error) {
+func (app *App) renderWriteBody(ctx context.Context, results []*types.MetricData, form renderForm, r *http.Request, logger *zap.Logger) ([]byte, error) {
 	var body []byte
 	var err error
 
+	results = types.Consolidate(form.maxDataPoints, results)
+
 	switch form.format {
 	case jsonFormat:
-		if maxDataPoints, _ := strconv.Atoi(r.FormValue("maxDataPoints")); maxDataPoints != 0 {
-			results = types.ConsolidateJSON(maxDataPoints, results)
+		var buf bytes.Buffer
+		if err := writeJSONBody(ctx, &buf, results, form); err != nil {
+			return nil, fmt.Errorf("error while marshalling json: %w", err)
 		}
-
-		body = types.MarshalJSON(results)
+		body = buf.Bytes()
 	case protobufFormat, protobuf3Format:
 		body, err = types.MarshalProtobuf(results)
 		if err != nil {
@@ -592,24 +937,29 @@ func (app *App) renderWriteBody(results []*types.MetricData, form renderForm, r
 	case rawFormat:
 		body = types.MarshalRaw(results)
 	case csvFormat:
-		tz := app.defaultTimeZone
-		if form.qtz != "" {
-			z, err := time.LoadLocation(form.qtz)
-			if err != nil {
-				logger.Warn("Invalid time zone",
-					zap.String("tz", form.qtz),
-				)
-			} else {
-				tz = z
-			}
-		}
-		body = types.MarshalCSV(results, tz)
+		body = types.MarshalCSV(results, form.location)
 	case pickleFormat:
 		body = types.MarshalPickle(results)
-	case pngFormat:
-		body = png.MarshalPNGRequest(r, results, form.template)
-	case svgFormat:
-		body = png.MarshalSVGRequest(r, results, form.template)
+	case msgpackFormat:
+		body, err = types.MarshalMsgpack(results)
+		if err != nil {
+			return body, fmt.Errorf("error while marshalling msgpack: %w", err)
+		}
+	case arrowFormat:
+		body, err = types.MarshalArrow(results)
+		if err != nil {
+			return body, fmt.Errorf("error while marshalling arrow: %w", err)
+		}
+	case pngFormat, svgFormat:
+		params := png.GetPictureParamsWithTemplate(r, form.template, results)
+		if form.location != nil {
+			params.Tz = form.location
+		}
+		if form.format == pngFormat {
+			body = png.MarshalPNG(params, results)
+		} else {
+			body = png.MarshalSVG(params, results)
+		}
 	}
 
 	return body, nil
@@ -623,43 +973,73 @@ func (app *App) sendGlobs(glob dataTypes.Matches) bool {
 	return app.config.SendGlobsAsIs && len(glob.Matches) < app.config.MaxBatchSize
 }
 
-func (app *App) resolveGlobsFromCache(metric string) (dataTypes.Matches, error) {
+func (app *App) resolveGlobsFromCache(metric string) (dataTypes.Matches, bool, error) {
 	tc := time.Now()
-	blob, err := app.findCache.Get(metric)
+	blob, stale, err := getStale(app.findCache, metric)
 	td := time.Since(tc).Nanoseconds()
 	apiMetrics.FindCacheOverheadNS.Add(td)
 
 	if err != nil {
-		return dataTypes.Matches{}, err
+		return dataTypes.Matches{}, false, err
 	}
 
 	matches, err := carbonapi_v2.FindDecoder(blob)
 	if err != nil {
-		return matches, err
+		return matches, false, err
 	}
 
 	apiMetrics.FindCacheHits.Add(1)
 
-	return matches, nil
+	return matches, stale, nil
 }
 
 func (app *App) resolveGlobs(ctx context.Context, metric string, useCache bool, accessLogDetails *carbonapipb.AccessLogDetails, logger *zap.Logger) (dataTypes.Matches, bool, error) {
+	var stale *dataTypes.Matches
 	if useCache {
-		matches, err := app.resolveGlobsFromCache(metric)
-		if err == nil {
+		matches, isStale, err := app.resolveGlobsFromCache(metric)
+		if err == nil && !isStale {
 			return matches, true, nil
 		}
+		if err == nil && app.config.Cache.FindStale.Revalidate {
+			app.prometheusMetrics.StaleResponses.WithLabelValues("find", "revalidate").Inc()
+			refreshLog := carbonapipb.AccessLogDetails{
+				Handler:       "find_refresh",
+				CarbonapiUuid: accessLogDetails.CarbonapiUuid,
+				Metrics:       []string{metric},
+			}
+			app.refresh("find:"+metric, func(ctx context.Context) {
+				t0 := time.Now()
+				_, err := app.findAndCache(ctx, metric, &refreshLog)
+				logRefresh(&refreshLog, t0, err)
+			})
+			return matches, true, nil
+		}
+		if err == nil && app.config.Cache.FindStale.ServeOnError {
+			stale = &matches
+		}
 	}
 
 	apiMetrics.FindCacheMisses.Add(1)
+	matches, err := app.findAndCache(ctx, metric, accessLogDetails)
+	if err != nil && stale != nil && isBackendError(err) {
+		logger.Warn("serving expired find after backends failed", zap.Error(err))
+		app.prometheusMetrics.StaleResponses.WithLabelValues("find", "error").Inc()
+		return *stale, true, nil
+	}
+
+	return matches, false, err
+}
+
+// findAndCache finds metric in the backends and caches the result.
+func (app *App) findAndCache(ctx context.Context, metric string, accessLogDetails *carbonapipb.AccessLogDetails) (dataTypes.Matches, error) {
 	apiMetrics.FindRequests.Add(1)
-	accessLogDetails.ZipperRequests++
+	atomic.AddInt64(&accessLogDetails.ZipperRequests, 1)
 
 	request := dataTypes.NewFindRequest(metric)
 	request.IncCall()
 	matches, err := app.backend.Find(ctx, request)
 	if err != nil {
-		return matches, false, err
+		return matches, err
 	}
 
 	blob, err := carbonapi_v2.FindEncoder(matches)
@@ -670,11 +1050,11 @@ func (app *App) resolveGlobs(ctx context.Context, metric string, useCache bool,
 		apiMetrics.FindCacheOverheadNS.Add(td)
 	}
 
-	return matches, false, nil
+	return matches, nil
 }
 
 func (app *App) getRenderRequests(ctx context.Context, m parser.MetricRequest, useCache bool,
-	toLog *carbonapipb.AccessLogDetails, logger *zap.Logger) ([]string, error) {
+	toLog *carbonapipb.AccessLogDetails, plan *fetchPlan, logger *zap.Logger) ([]string, error) {
 	if app.config.AlwaysSendGlobsAsIs {
 		return []string{m.Metric}, nil
 	}
@@ -683,7 +1063,7 @@ func (app *App) getRenderRequests(ctx context.Context, m parser.MetricRequest, u
 	}
 
 	glob, _, err := app.resolveGlobs(ctx, m.Metric, useCache, toLog, logger)
-	toLog.TotalMetricCount += int64(len(glob.Matches))
+	atomic.AddInt64(&toLog.TotalMetricCount, int64(len(glob.Matches)))
 	if err != nil {
 		return nil, err
 	}
@@ -692,7 +1072,7 @@ func (app *App) getRenderRequests(ctx context.Context, m parser.MetricRequest, u
 		return []string{m.Metric}, nil
 	}
 
-	toLog.SendGlobs = false
+	plan.addExpandedGlob()
 	renderRequests := make([]string, 0, len(glob.Matches))
 	for _, m := range glob.Matches {
 		if m.IsLeaf {
@@ -812,6 +1192,9 @@ func (app *App) findHandler(w http.ResponseWriter, r *http.Request) {
 		} else {
 			blob, err = pickle.FindEncoderV1_0(metrics)
 		}
+	case msgpackFormat:
+		contentType = contentTypeMsgpack
+		blob, err = msgpack.FindEncoder(metrics)
 	case rawFormat:
 		blob, err = findList(metrics)
 		contentType = rawFormat
@@ -1014,18 +1397,9 @@ func (app *App) versionHandler(w http.ResponseWriter, r *http.Request) {
 		apiMetrics.Responses.Add(1)
 		app.prometheusMetrics.Responses.WithLabelValues(strconv.Itoa(http.StatusOK), "version", "false").Inc()
 	}()
-	// Use a specific version of graphite for grafana
-	// This handler is queried by grafana, and if needed, an override can be provided
-	if app.config.GraphiteVersionForGrafana != "" {
-		w.Write([]byte(app.config.GraphiteVersionForGrafana))
-		return
-	}
-
-	if app.config.GraphiteWeb09Compatibility {
-		w.Write([]byte("0.9.15\n"))
-	} else {
-		w.Write([]byte("1.0.0\n"))
-	}
+	// This handler is queried by grafana, the version decides which features
+	// of graphite it uses, e.g. descriptions of functions since 1.1
+	w.Write([]byte(app.graphiteVersion + "\n"))
 
 	toLog := carbonapipb.NewAccessLogDetails(r, "version", &app.config)
 	toLog.Runtime = time.Since(t0).Seconds()
@@ -1085,6 +1459,19 @@ func (app *App) functionsHandler(w http.ResponseWriter, r *http.Request) {
 		function = path[2]
 	}
 
+	if function != "" {
+		metadata.FunctionMD.RLock()
+		_, ok := metadata.FunctionMD.Descriptions[function]
+		metadata.FunctionMD.RUnlock()
+		if !ok {
+			msg := "Function not found: " + function
+			http.Error(w, msg, http.StatusNotFound)
+			toLog.HttpCode = http.StatusNotFound
+			toLog.Reason = msg
+			return
+		}
+	}
+
 	var b []byte
 	if !nativeOnly {
 		metadata.FunctionMD.RLock()
@@ -1145,6 +1532,99 @@ func (app *App) functionsHandler(w http.ResponseWriter, r *http.Request) {
 	toLog.HttpCode = http.StatusOK
 }
 
+// parseResponse is the body of /parse responses
+type parseResponse struct {
+	Target    string         `json:"target"`
+	Canonical string         `json:"canonical"`
+	AST       *parser.Node   `json:"ast"`
+	Metrics   []parsedMetric `json:"metrics"`
+}
+
+// parsedMetric is a metric referenced by a target. Shifts are in seconds
+// relative to the from and until of the request.
+type parsedMetric struct {
+	Metric     string `json:"metric"`
+	FromShift  int32  `json:"fromShift"`
+	UntilShift int32  `json:"untilShift"`
+}
+
+// parseHandler returns the parse tree, the canonical form and the metrics of a target
+// without fetching any data.
+func (app *App) parseHandler(w http.ResponseWriter, r *http.Request) {
+	t0 := time.Now()
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+	uuid := util.GetUUID(ctx)
+
+	apiMetrics.Requests.Add(1)
+	app.prometheusMetrics.Requests.Inc()
+
+	toLog := carbonapipb.NewAccessLogDetails(r, "parse", &app.config)
+
+	logAsError := false
+	defer func() {
+		app.deferredAccessLogging(r, &toLog, t0, logAsError)
+	}()
+
+	err := r.ParseForm()
+	if err != nil {
+		writeError(uuid, r, w, http.StatusBadRequest, err.Error(), jsonFormat, &toLog, span)
+		logAsError = true
+		return
+	}
+
+	target := r.FormValue("target")
+	if target == "" {
+		writeError(uuid, r, w, http.StatusBadRequest, "missing target", jsonFormat, &toLog, span)
+		logAsError = true
+		return
+	}
+	toLog.Targets = []string{target}
+
+	exp, e, err := parser.ParseExpr(target)
+	if err == nil {
+		err = parser.TrailingInputError(target, e)
+	}
+	if err == nil {
+		err = expr.Validate(exp)
+	}
+	if err != nil {
+		writeTargetError(uuid, r, w, target, err, jsonFormat, &toLog, span)
+		logAsError = true
+		return
+	}
+
+	res := parseResponse{
+		Target:    target,
+		Canonical: parser.Format(exp),
+		AST:       parser.NewNode(exp),
+		Metrics:   []parsedMetric{},
+	}
+	for _, m := range exp.Metrics() {
+		res.Metrics = append(res.Metrics, parsedMetric{
+			Metric:     m.Metric,
+			FromShift:  m.From,
+			UntilShift: m.Until,
+		})
+	}
+
+	var b []byte
+	if r.FormValue("pretty") == "1" {
+		b, err = json.MarshalIndent(res, "", "\t")
+	} else {
+		b, err = json.Marshal(res)
+	}
+	if err != nil {
+		writeError(uuid, r, w, http.StatusInternalServerError, err.Error(), jsonFormat, &toLog, span)
+		logAsError = true
+		return
+	}
+
+	w.Header().Set("Content-Type", contentTypeJSON)
+	w.Write(b)
+	toLog.HttpCode = http.StatusOK
+}
+
 // Add block rules on the basis of headers to block certain requests
 // To be used to block read abusers
 // The rules are added(appended) in the block headers config file
@@ -1202,7 +1682,8 @@ func (app *App) unblockHeaders(w http.ResponseWriter, r *http.Request) {
 	toLog.HttpCode = http.StatusOK
 }
 
-func logStepTimeMismatch(targetMetricFetches []parser.MetricRequest, metricMap map[parser.MetricRequest][]*types.MetricData, logger *zap.Logger, target string) {
+// logStepTimeMismatch logs and reports whether metrics of the target have differing resolution.
+func logStepTimeMismatch(targetMetricFetches []parser.MetricRequest, metricMap map[parser.MetricRequest][]*types.MetricData, logger *zap.Logger, target string) bool {
 	var defaultStepTime int32 = -1
 	for _, mfetch := range targetMetricFetches {
 		values := metricMap[mfetch]
@@ -1214,9 +1695,10 @@ func logStepTimeMismatch(targetMetricFetches []parser.MetricRequest, metricMap m
 		}
 		if !isStepTimeMatching(values[:], defaultStepTime) {
 			logger.Info("metrics with differing resolution", zap.Any("target", target))
-			return
+			return true
 		}
 	}
+	return false
 }
 
 func isStepTimeMatching(value []*types.MetricData, defaultStepTime int32) bool {
@@ -1234,6 +1716,7 @@ supported requests:
 	/metrics/find/?query=
 	/info/?target=
 	/functions/
+	/parse/?target=
 	/tags/autoComplete/tags
 `)
 
@@ -1248,7 +1731,7 @@ func (app *App) usageHandler(w http.ResponseWriter, r *http.Request) {
 	w.Write(usageMsg)
 }
 
-//TODO : Fix this handler if and when tag support is added
+// TODO : Fix this handler if and when tag support is added
 // This responds to grafana's tag requests, which were falling through to the usageHandler,
 // preventing a random, garbage list of tags (constructed from usageMsg) being added to the metrics list
 func (app *App) tagsHandler(w http.ResponseWriter, r *http.Request) {
@@ -1271,15 +1754,80 @@ func (app *App) debugVersionHandler(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintf(w, "GIT_TAG: %s\n", BuildVersion)
 }
 
-func buildParseErrorString(target, e string, err error) string {
+func buildParseErrorString(target string, err error) string {
 	msg := fmt.Sprintf("%s\n\n%-20s: %s\n", http.StatusText(http.StatusBadRequest), "Target", target)
-	if err != nil {
-		msg += fmt.Sprintf("%-20s: %s\n", "Error", err.Error())
-	}
-	if e != "" {
+	msg += fmt.Sprintf("%-20s: %s\n", "Error", err.Error())
+
+	var syntaxErr *parser.SyntaxError
+	if errors.As(err, &syntaxErr) {
 		msg += fmt.Sprintf("%-20s: %s\n%-20s: %s\n",
-			"Parsed so far", target[0:len(target)-len(e)],
-			"Could not parse", e)
+			"Parsed so far", target[:syntaxErr.Offset],
+			"Could not parse", target[syntaxErr.Offset:])
+		msg += fmt.Sprintf("%-20s: %s\n%-20s  %s^\n",
+			"Position", target,
+			"", strings.Repeat(" ", utf8.RuneCountInString(target[:syntaxErr.Offset])))
 	}
+
 	return msg
 }
+
+// targetError is the body of errors in targets for format=json
+type targetError struct {
+	Error targetErrorDetails `json:"error"`
+}
+
+type targetErrorDetails struct {
+	Code        int      `json:"code"`
+	Message     string   `json:"message"`
+	Target      string   `json:"target"`
+	Offset      *int     `json:"offset,omitempty"`
+	Expected    []string `json:"expected,omitempty"`
+	Function    string   `json:"function,omitempty"`
+	Argument    int      `json:"argument,omitempty"`
+	Param       string   `json:"param,omitempty"`
+	Suggestions []string `json:"suggestions,omitempty"`
+}
+
+// writeTargetError answers with 400 for a target that can't be parsed or has bad arguments.
+// For format=json the details are returned as a JSON object.
+func writeTargetError(uuid string,
+	r *http.Request, w http.ResponseWriter,
+	target string, err error, format string,
+	accessLogDetails *carbonapipb.AccessLogDetails,
+	span trace.Span) {
+
+	msg := buildParseErrorString(target, err)
+	if format != jsonFormat {
+		writeError(uuid, r, w, http.StatusBadRequest, msg, format, accessLogDetails, span)
+		return
+	}
+
+	details := targetErrorDetails{
+		Code:    http.StatusBadRequest,
+		Message: err.Error(),
+		Target:  target,
+	}
+	var syntaxErr *parser.SyntaxError
+	var argErr expr.ErrInvalidArgument
+	switch {
+	case errors.As(err, &syntaxErr):
+		details.Offset = &syntaxErr.Offset
+		details.Expected = syntaxErr.Expected
+	case errors.As(err, &argErr):
+		details.Function = argErr.Function
+		details.Argument = argErr.Position
+		details.Param = argErr.Param
+		details.Suggestions = argErr.Suggestions
+	}
+
+	accessLogDetails.HttpCode = http.StatusBadRequest
+	accessLogDetails.Reason = msg
+	span.SetAttribute("error", true)
+	span.SetAttribute("error.message", msg)
+
+	body, _ := json.Marshal(targetError{Error: details})
+	w.Header().Set("X-Carbonapi-UUID", uuid)
+	w.Header().Set("Content-Type", contentTypeJSON)
+	w.WriteHeader(http.StatusBadRequest)
+	w.Write(body)
+}
@@ -0,0 +1,157 @@
+package carbonapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/bookingcom/carbonapi/expr/types"
+)
+
+// renderMeta collects metadata of a render request for JSON responses with meta=1.
+// Such responses are an envelope with the graphite-web compatible array of series
+// in "series" and the metadata in "meta".
+type renderMeta struct {
+	mu       sync.Mutex
+	warnings []string
+}
+
+// responseMeta is the metadata part of a response.
+type responseMeta struct {
+	Series    []seriesMeta `json:"series"`
+	Warnings  []string     `json:"warnings"`
+	FromCache bool         `json:"fromCache"`
+}
+
+// seriesMeta is the metadata of a series of a response.
+type seriesMeta struct {
+	Target            string  `json:"target"`
+	ValuesPerPoint    int     `json:"valuesPerPoint"`
+	ConsolidationFunc string  `json:"consolidationFunc"`
+	Step              int32   `json:"step"`
+	XFilesFactor      float32 `json:"xFilesFactor"`
+}
+
+type renderMetaKey struct{}
+
+// withRenderMeta returns a context collecting metadata in m.
+func withRenderMeta(ctx context.Context, m *renderMeta) context.Context {
+	return context.WithValue(ctx, renderMetaKey{}, m)
+}
+
+// renderMetaFrom returns the metadata collected in the context, nil if it isn't collected.
+func renderMetaFrom(ctx context.Context) *renderMeta {
+	m, _ := ctx.Value(renderMetaKey{}).(*renderMeta)
+	return m
+}
+
+// warn adds a warning about the request, once.
+func (m *renderMeta) warn(msg string) {
+	if m == nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, w := range m.warnings {
+		if w == msg {
+			return
+		}
+	}
+	m.warnings = append(m.warnings, msg)
+}
+
+// response returns the metadata of a response with the results, possibly consolidated.
+func (m *renderMeta) response(results []*types.MetricData) responseMeta {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	res := responseMeta{
+		Series:   make([]seriesMeta, 0, len(results)),
+		Warnings: append([]string{}, m.warnings...),
+	}
+	consolidated := false
+	for _, r := range results {
+		// nil series are skipped in responses
+		if r == nil {
+			continue
+		}
+
+		s := seriesMeta{
+			Target:            r.Name,
+			ValuesPerPoint:    r.ValuesPerPoint,
+			ConsolidationFunc: r.ConsolidationFunc,
+			Step:              r.StepTime,
+			XFilesFactor:      r.XFilesFactor,
+		}
+		if s.ValuesPerPoint == 0 {
+			s.ValuesPerPoint = 1
+		}
+		if s.ValuesPerPoint > 1 {
+			consolidated = true
+		}
+		if s.ConsolidationFunc == "" {
+			s.ConsolidationFunc = "average"
+		}
+		res.Series = append(res.Series, s)
+	}
+	if consolidated {
+		res.Warnings = append(res.Warnings, "points consolidated to maxDataPoints")
+	}
+
+	return res
+}
+
+// writeMetaJSON writes the envelope of a response with meta=1.
+func writeMetaJSON(w io.Writer, results []*types.MetricData, meta *renderMeta) error {
+	if _, err := io.WriteString(w, `{"series":`); err != nil {
+		return err
+	}
+	if err := types.WriteJSON(w, results); err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(meta.response(results))
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, `,"meta":`); err != nil {
+		return err
+	}
+	if _, err := w.Write(b); err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, `}`)
+	return err
+}
+
+// markFromCache marks the metadata of a cached response with meta=1 as served from the cache.
+func markFromCache(body []byte) ([]byte, error) {
+	var envelope struct {
+		Series json.RawMessage `json:"series"`
+		Meta   responseMeta    `json:"meta"`
+	}
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return nil, err
+	}
+	if envelope.Series == nil {
+		return nil, errors.New("cached response has no series")
+	}
+
+	envelope.Meta.FromCache = true
+	envelope.Meta.Warnings = append(envelope.Meta.Warnings, "response served from cache")
+	meta, err := json.Marshal(envelope.Meta)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]byte, 0, len(body)+64)
+	res = append(res, `{"series":`...)
+	res = append(res, envelope.Series...)
+	res = append(res, `,"meta":`...)
+	res = append(res, meta...)
+	res = append(res, '}')
+	return res, nil
+}
@@ -3,6 +3,7 @@ package carbonapi
 import (
 	"expvar"
 
+	"github.com/bookingcom/carbonapi/cache"
 	"github.com/bookingcom/carbonapi/cfg"
 	"github.com/prometheus/client_golang/prometheus"
 )
@@ -27,6 +28,8 @@ type PrometheusMetrics struct {
 	FindDurationLinComplex    prometheus.Histogram
 	TimeInQueueExp            prometheus.Histogram
 	TimeInQueueLin            prometheus.Histogram
+	SeriesCacheChunks         *prometheus.CounterVec
+	StaleResponses            *prometheus.CounterVec
 }
 
 func newPrometheusMetrics(config cfg.API) PrometheusMetrics {
@@ -194,6 +197,67 @@ func newPrometheusMetrics(config cfg.API) PrometheusMetrics {
 					config.Zipper.Common.Monitoring.TimeInQueueLinHistogram.BucketsNum),
 			},
 		),
+		SeriesCacheChunks: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "series_cache_chunks_total",
+				Help: "Count of chunk lookups in the series cache, partitioned by result",
+			},
+			[]string{"result"},
+		),
+		StaleResponses: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "cache_stale_responses_total",
+				Help: "Count of expired cache entries served, partitioned by cache and reason: revalidate or error",
+			},
+			[]string{"cache", "reason"},
+		),
+	}
+}
+
+// newTieredCacheMetrics returns counters of lookups in the tiers of a tiered cache.
+func newTieredCacheMetrics(name string, c *cache.TieredCache) []prometheus.Collector {
+	counter := func(result string, f func() uint64) prometheus.Collector {
+		return prometheus.NewCounterFunc(
+			prometheus.CounterOpts{
+				Name:        "tiered_cache_requests_total",
+				Help:        "Count of tiered cache lookups, partitioned by cache and result: local or remote hit, or miss",
+				ConstLabels: prometheus.Labels{"cache": name, "result": result},
+			},
+			func() float64 { return float64(f()) },
+		)
+	}
+
+	return []prometheus.Collector{
+		counter("local_hit", c.LocalHits),
+		counter("remote_hit", c.RemoteHits),
+		counter("miss", c.Misses),
+	}
+}
+
+// newCompressedCacheMetrics returns counters of bytes stored in a compressed cache,
+// of values skipped and of entries that couldn't be decoded.
+func newCompressedCacheMetrics(name string, c *cache.CompressedCache) []prometheus.Collector {
+	counter := func(metric, help string, labels prometheus.Labels, f func() uint64) prometheus.Collector {
+		return prometheus.NewCounterFunc(
+			prometheus.CounterOpts{
+				Name:        metric,
+				Help:        help,
+				ConstLabels: labels,
+			},
+			func() float64 { return float64(f()) },
+		)
+	}
+
+	const bytesHelp = "Count of bytes written to cache, partitioned by cache and size: uncompressed or compressed"
+	return []prometheus.Collector{
+		counter("cache_written_bytes_total", bytesHelp,
+			prometheus.Labels{"cache": name, "size": "uncompressed"}, c.UncompressedBytes),
+		counter("cache_written_bytes_total", bytesHelp,
+			prometheus.Labels{"cache": name, "size": "compressed"}, c.CompressedBytes),
+		counter("cache_skipped_entries_total", "Count of values not cached for being too large, partitioned by cache",
+			prometheus.Labels{"cache": name}, c.Skipped),
+		counter("cache_decode_errors_total", "Count of cached entries that couldn't be decoded and were treated as misses, partitioned by cache",
+			prometheus.Labels{"cache": name}, c.DecodeErrors),
 	}
 }
 
@@ -0,0 +1,65 @@
+package carbonapi
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/bookingcom/carbonapi/expr/types"
+	"github.com/bookingcom/carbonapi/pkg/parser"
+)
+
+// metricStore holds metrics fetched for a render request. It is shared by
+// all targets of the request and is safe for concurrent use. Concurrent
+// requests for the same metric wait for a single fetch.
+type metricStore struct {
+	mu      sync.Mutex
+	entries map[parser.MetricRequest]*storeEntry
+}
+
+type storeEntry struct {
+	done chan struct{}
+	data []*types.MetricData
+	err  error
+}
+
+func newMetricStore() *metricStore {
+	return &metricStore{
+		entries: make(map[parser.MetricRequest]*storeEntry),
+	}
+}
+
+// get returns metrics for m, fetching them with fetch if no other caller
+// has done so yet. The returned bool is true if this call did the fetch.
+func (s *metricStore) get(ctx context.Context, m parser.MetricRequest,
+	fetch func() ([]*types.MetricData, error)) ([]*types.MetricData, bool, error) {
+
+	s.mu.Lock()
+	e, ok := s.entries[m]
+	if !ok {
+		e = &storeEntry{done: make(chan struct{})}
+		s.entries[m] = e
+	}
+	s.mu.Unlock()
+
+	if ok {
+		select {
+		case <-e.done:
+			return e.data, false, e.err
+		case <-ctx.Done():
+			return nil, false, ctx.Err()
+		}
+	}
+
+	// waiters are released however the fetch ends
+	defer close(e.done)
+	defer func() {
+		if r := recover(); r != nil {
+			e.data, e.err = nil, fmt.Errorf("panic during fetch: %v", r)
+			panic(r)
+		}
+	}()
+	e.data, e.err = fetch()
+
+	return e.data, true, e.err
+}
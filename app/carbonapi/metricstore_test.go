@@ -0,0 +1,110 @@
+package carbonapi
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/bookingcom/carbonapi/expr/types"
+	"github.com/bookingcom/carbonapi/pkg/parser"
+)
+
+func TestMetricStoreFetchesOnce(t *testing.T) {
+	s := newMetricStore()
+	m := parser.MetricRequest{Metric: "foo", From: 1000, Until: 2000}
+	want := []*types.MetricData{types.MakeMetricData("foo", []float64{1, 2}, 60, 1000)}
+
+	var calls, fetchers int32
+	release := make(chan struct{})
+	fetch := func() ([]*types.MetricData, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return want, nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got, isFetcher, err := s.get(context.Background(), m, fetch)
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if len(got) != 1 || got[0] != want[0] {
+				t.Errorf("got %v, want %v", got, want)
+			}
+			if isFetcher {
+				atomic.AddInt32(&fetchers, 1)
+			}
+		}()
+	}
+	close(release)
+	wg.Wait()
+
+	if calls != 1 || fetchers != 1 {
+		t.Errorf("got %d fetches by %d callers, want 1", calls, fetchers)
+	}
+}
+
+func TestMetricStoreCancel(t *testing.T) {
+	s := newMetricStore()
+	m := parser.MetricRequest{Metric: "foo", From: 1000, Until: 2000}
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	go s.get(context.Background(), m, func() ([]*types.MetricData, error) {
+		close(started)
+		<-release
+		return nil, nil
+	})
+	<-started
+	defer close(release)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, _, err := s.get(ctx, m, func() ([]*types.MetricData, error) {
+		t.Error("fetch must not be called twice")
+		return nil, nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("got error %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestMetricStoreFetchPanics(t *testing.T) {
+	s := newMetricStore()
+	m := parser.MetricRequest{Metric: "foo", From: 1000, Until: 2000}
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	recovered := make(chan interface{})
+	go func() {
+		defer func() { recovered <- recover() }()
+		s.get(context.Background(), m, func() ([]*types.MetricData, error) {
+			close(started)
+			<-release
+			panic("boom")
+		})
+	}()
+	<-started
+
+	waited := make(chan error)
+	go func() {
+		_, _, err := s.get(context.Background(), m, func() ([]*types.MetricData, error) {
+			t.Error("fetch must not be called twice")
+			return nil, nil
+		})
+		waited <- err
+	}()
+	close(release)
+
+	if r := <-recovered; r != "boom" {
+		t.Errorf("got panic %v, want boom", r)
+	}
+	if err := <-waited; err == nil {
+		t.Error("expected the waiter to get an error of the panicked fetch")
+	}
+}
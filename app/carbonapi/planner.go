@@ -0,0 +1,187 @@
+package carbonapi
+
+import (
+	"sort"
+	"sync/atomic"
+
+	"github.com/bookingcom/carbonapi/carbonapipb"
+	"github.com/bookingcom/carbonapi/expr/types"
+	"github.com/bookingcom/carbonapi/pkg/parser"
+)
+
+// fetchPlan collects metric requests of all targets of a render request
+// and merges overlapping time ranges of the same path, so that every path
+// is pulled from the zipper once per continuous range.
+type fetchPlan struct {
+	requests map[parser.MetricRequest]struct{}
+	fetches  map[string][]parser.MetricRequest
+	// maxDataPoints is the number of points the zipper may consolidate
+	// fetched series to, 0 if they are needed raw
+	maxDataPoints int
+
+	savedFetches    int64
+	requestedPoints int64
+	fetchedPoints   int64
+	expandedGlobs   int64
+}
+
+// newFetchPlan builds a plan for expressions evaluated over [from, until].
+func newFetchPlan(exps []parser.Expr, from, until int32) *fetchPlan {
+	p := &fetchPlan{
+		requests: make(map[parser.MetricRequest]struct{}),
+		fetches:  make(map[string][]parser.MetricRequest),
+	}
+
+	for _, exp := range exps {
+		for _, m := range exp.Metrics() {
+			m.From += from
+			m.Until += until
+			if _, ok := p.requests[m]; ok {
+				continue
+			}
+			p.requests[m] = struct{}{}
+			p.fetches[m.Metric] = append(p.fetches[m.Metric], m)
+		}
+	}
+
+	for path, reqs := range p.fetches {
+		p.fetches[path] = mergeRanges(reqs)
+	}
+
+	return p
+}
+
+// consolidateTo lets the zipper consolidate fetched series to maxDataPoints
+// if all expressions are plain series, as functions need raw points.
+func (p *fetchPlan) consolidateTo(exps []parser.Expr, maxDataPoints int) {
+	for _, exp := range exps {
+		if !exp.IsName() {
+			return
+		}
+	}
+	p.maxDataPoints = maxDataPoints
+}
+
+// mergeRanges merges overlapping and adjacent ranges of requests for one path.
+func mergeRanges(reqs []parser.MetricRequest) []parser.MetricRequest {
+	sort.Slice(reqs, func(i, j int) bool {
+		if reqs[i].From == reqs[j].From {
+			return reqs[i].Until < reqs[j].Until
+		}
+		return reqs[i].From < reqs[j].From
+	})
+
+	merged := reqs[:1]
+	for _, r := range reqs[1:] {
+		last := &merged[len(merged)-1]
+		if r.From <= last.Until {
+			if r.Until > last.Until {
+				last.Until = r.Until
+			}
+			continue
+		}
+		merged = append(merged, r)
+	}
+
+	return merged
+}
+
+// cover returns the planned fetch that contains m, or m itself if there is none.
+func (p *fetchPlan) cover(m parser.MetricRequest) parser.MetricRequest {
+	if p == nil {
+		return m
+	}
+	for _, f := range p.fetches[m.Metric] {
+		if f.From <= m.From && m.Until <= f.Until {
+			return f
+		}
+	}
+	return m
+}
+
+// requestCount returns number of distinct metric requests in the plan.
+func (p *fetchPlan) requestCount() int {
+	return len(p.requests)
+}
+
+// fetchCount returns number of fetches after merging.
+func (p *fetchPlan) fetchCount() int {
+	n := 0
+	for _, f := range p.fetches {
+		n += len(f)
+	}
+	return n
+}
+
+// addSavedFetch records a request served from an already fetched range.
+func (p *fetchPlan) addSavedFetch() {
+	atomic.AddInt64(&p.savedFetches, 1)
+}
+
+// addFetchedPoints records datapoints pulled from the zipper.
+func (p *fetchPlan) addFetchedPoints(n int) {
+	atomic.AddInt64(&p.fetchedPoints, int64(n))
+}
+
+// addRequestedPoints records datapoints handed to a function.
+func (p *fetchPlan) addRequestedPoints(metrics []*types.MetricData) {
+	n := 0
+	for _, m := range metrics {
+		n += len(m.Values)
+	}
+	atomic.AddInt64(&p.requestedPoints, int64(n))
+}
+
+// addExpandedGlob records a glob sent to the zipper as a list of its matches.
+func (p *fetchPlan) addExpandedGlob() {
+	atomic.AddInt64(&p.expandedGlobs, 1)
+}
+
+// log fills planner statistics of the access log.
+func (p *fetchPlan) log(toLog *carbonapipb.AccessLogDetails) {
+	toLog.PlannedRequests = int64(p.requestCount())
+	toLog.PlannedFetches = int64(p.fetchCount())
+	toLog.SavedFetches = atomic.LoadInt64(&p.savedFetches)
+	if saved := atomic.LoadInt64(&p.requestedPoints) - atomic.LoadInt64(&p.fetchedPoints); saved > 0 {
+		toLog.SavedDatapoints = saved
+	}
+	if atomic.LoadInt64(&p.expandedGlobs) > 0 {
+		toLog.SendGlobs = false
+	}
+}
+
+// sliceMetrics returns views of metrics restricted to (from, until],
+// which is the range a backend returns when asked for [from, until].
+// Values are shared with the original series.
+func sliceMetrics(metrics []*types.MetricData, from, until int32) []*types.MetricData {
+	res := make([]*types.MetricData, 0, len(metrics))
+	for _, m := range metrics {
+		if m.StepTime <= 0 {
+			res = append(res, m)
+			continue
+		}
+
+		lo := 0
+		if from >= m.StartTime {
+			lo = int((from-m.StartTime)/m.StepTime) + 1
+		}
+		hi := len(m.Values)
+		if until < m.StartTime {
+			hi = 0
+		} else if n := int((until-m.StartTime)/m.StepTime) + 1; n < hi {
+			hi = n
+		}
+		if lo > hi {
+			lo = hi
+		}
+
+		r := *m
+		r.Values = m.Values[lo:hi]
+		r.IsAbsent = m.IsAbsent[lo:hi]
+		r.StartTime = m.StartTime + int32(lo)*m.StepTime
+		r.StopTime = r.StartTime + int32(hi-lo)*m.StepTime
+		res = append(res, &r)
+	}
+
+	return res
+}
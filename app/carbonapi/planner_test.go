@@ -0,0 +1,120 @@
+package carbonapi
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/bookingcom/carbonapi/expr/types"
+	"github.com/bookingcom/carbonapi/pkg/parser"
+)
+
+func TestFetchPlan(t *testing.T) {
+	tests := []struct {
+		name          string
+		targets       []string
+		wantFetches   map[string][]parser.MetricRequest
+		wantRequests  int
+		coverRequest  parser.MetricRequest
+		wantCoveredBy parser.MetricRequest
+	}{
+		{
+			name:    "moving average overlaps plain fetch",
+			targets: []string{"foo", "movingAverage(foo,'5min')"},
+			wantFetches: map[string][]parser.MetricRequest{
+				"foo": {{Metric: "foo", From: 700, Until: 2000}},
+			},
+			wantRequests:  2,
+			coverRequest:  parser.MetricRequest{Metric: "foo", From: 1000, Until: 2000},
+			wantCoveredBy: parser.MetricRequest{Metric: "foo", From: 700, Until: 2000},
+		},
+		{
+			name:    "disjoint time shift is fetched separately",
+			targets: []string{"foo", "timeShift(foo,'1h')", "bar"},
+			wantFetches: map[string][]parser.MetricRequest{
+				"foo": {
+					{Metric: "foo", From: -2600, Until: -1600},
+					{Metric: "foo", From: 1000, Until: 2000},
+				},
+				"bar": {{Metric: "bar", From: 1000, Until: 2000}},
+			},
+			wantRequests:  3,
+			coverRequest:  parser.MetricRequest{Metric: "foo", From: -2600, Until: -1600},
+			wantCoveredBy: parser.MetricRequest{Metric: "foo", From: -2600, Until: -1600},
+		},
+		{
+			name:    "duplicate targets",
+			targets: []string{"foo", "foo", "sumSeries(foo)"},
+			wantFetches: map[string][]parser.MetricRequest{
+				"foo": {{Metric: "foo", From: 1000, Until: 2000}},
+			},
+			wantRequests:  1,
+			coverRequest:  parser.MetricRequest{Metric: "baz", From: 1000, Until: 2000},
+			wantCoveredBy: parser.MetricRequest{Metric: "baz", From: 1000, Until: 2000},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var exps []parser.Expr
+			for _, target := range tt.targets {
+				exp, _, err := parser.ParseExpr(target)
+				if err != nil {
+					t.Fatalf("failed to parse %s: %v", target, err)
+				}
+				exps = append(exps, exp)
+			}
+
+			plan := newFetchPlan(exps, 1000, 2000)
+			if !reflect.DeepEqual(plan.fetches, tt.wantFetches) {
+				t.Errorf("got fetches %v, want %v", plan.fetches, tt.wantFetches)
+			}
+			if plan.requestCount() != tt.wantRequests {
+				t.Errorf("got %d requests, want %d", plan.requestCount(), tt.wantRequests)
+			}
+			if got := plan.cover(tt.coverRequest); got != tt.wantCoveredBy {
+				t.Errorf("got cover %v for %v, want %v", got, tt.coverRequest, tt.wantCoveredBy)
+			}
+		})
+	}
+}
+
+func TestSliceMetrics(t *testing.T) {
+	tests := []struct {
+		name      string
+		from      int32
+		until     int32
+		want      []float64
+		wantStart int32
+	}{
+		{"inner", 1060, 1180, []float64{2, 3}, 1120},
+		{"unaligned", 1030, 1150, []float64{1, 2}, 1060},
+		{"whole", 900, 2000, []float64{0, 1, 2, 3, 4}, 1000},
+		{"empty", 1300, 1400, []float64{}, 1300},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := types.MakeMetricData("foo", []float64{0, 1, 2, 3, 4}, 60, 1000)
+			got := sliceMetrics([]*types.MetricData{m}, tt.from, tt.until)
+			if len(got) != 1 {
+				t.Fatalf("got %d metrics, want 1", len(got))
+			}
+			if got[0].StartTime != tt.wantStart {
+				t.Errorf("got start %d, want %d", got[0].StartTime, tt.wantStart)
+			}
+			if got[0].StopTime != tt.wantStart+int32(len(tt.want))*60 {
+				t.Errorf("got stop %d, want %d", got[0].StopTime, tt.wantStart+int32(len(tt.want))*60)
+			}
+			if len(got[0].Values) != len(tt.want) {
+				t.Fatalf("got values %v, want %v", got[0].Values, tt.want)
+			}
+			for i := range tt.want {
+				if math.Abs(got[0].Values[i]-tt.want[i]) > 1e-9 {
+					t.Errorf("got values %v, want %v", got[0].Values, tt.want)
+					break
+				}
+			}
+		})
+	}
+}
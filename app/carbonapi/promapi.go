@@ -0,0 +1,344 @@
+package carbonapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bookingcom/carbonapi/carbonapipb"
+	"github.com/bookingcom/carbonapi/expr"
+	"github.com/bookingcom/carbonapi/expr/limits"
+	"github.com/bookingcom/carbonapi/pkg/parser"
+	"github.com/bookingcom/carbonapi/pkg/promql"
+	dataTypes "github.com/bookingcom/carbonapi/pkg/types"
+	"github.com/bookingcom/carbonapi/util"
+
+	"github.com/lomik/zapwriter"
+	"go.uber.org/zap"
+)
+
+// maxPromPoints is the maximum number of points of a series in a range query, as in Prometheus.
+const maxPromPoints = 11000
+
+// promResponse is a response of the Prometheus API.
+type promResponse struct {
+	Status    string      `json:"status"`
+	Data      interface{} `json:"data,omitempty"`
+	ErrorType string      `json:"errorType,omitempty"`
+	Error     string      `json:"error,omitempty"`
+	Warnings  []string    `json:"warnings,omitempty"`
+}
+
+// promWarned is data of a response with warnings about it.
+type promWarned struct {
+	data     interface{}
+	warnings []string
+}
+
+// promMatrix is the data of a range query response.
+type promMatrix struct {
+	ResultType string          `json:"resultType"`
+	Result     []promql.Series `json:"result"`
+}
+
+// promError is an error of the Prometheus API, with the HTTP code it is answered with.
+type promError struct {
+	code int
+	typ  string
+	msg  string
+}
+
+func badData(format string, args ...interface{}) *promError {
+	return &promError{code: http.StatusBadRequest, typ: "bad_data", msg: fmt.Sprintf(format, args...)}
+}
+
+// promHandler serves requests of the Prometheus API with f, which returns the data of the response.
+func (app *App) promHandler(handler string, f func(ctx context.Context, r *http.Request,
+	toLog *carbonapipb.AccessLogDetails, logger *zap.Logger) (interface{}, *promError)) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		t0 := time.Now()
+
+		ctx, cancel := context.WithTimeout(r.Context(), app.config.Timeouts.Global)
+		defer cancel()
+
+		apiMetrics.Requests.Add(1)
+		app.prometheusMetrics.Requests.Inc()
+
+		toLog := carbonapipb.NewAccessLogDetails(r, handler, &app.config)
+		toLog.Format = jsonFormat
+		logger := zapwriter.Logger(handler).With(
+			zap.String("carbonapi_uuid", util.GetUUID(ctx)),
+			zap.String("username", toLog.Username),
+		)
+
+		logAsError := false
+		defer func() {
+			app.deferredAccessLogging(r, &toLog, t0, logAsError)
+		}()
+
+		var data interface{}
+		var perr *promError
+		if app.promTemplate == nil {
+			perr = &promError{code: http.StatusNotFound, typ: "not_found", msg: "Prometheus API isn't configured"}
+		} else {
+			data, perr = f(ctx, r, &toLog, logger)
+		}
+
+		code := http.StatusOK
+		resp := promResponse{Status: "success", Data: data}
+		if w, ok := data.(promWarned); ok {
+			resp.Data, resp.Warnings = w.data, w.warnings
+		}
+		if perr != nil {
+			code = perr.code
+			resp = promResponse{Status: "error", ErrorType: perr.typ, Error: perr.msg}
+			toLog.Reason = perr.msg
+			logAsError = true
+		}
+
+		b, err := json.Marshal(resp)
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			toLog.HttpCode = http.StatusInternalServerError
+			toLog.Reason = err.Error()
+			logAsError = true
+			return
+		}
+
+		w.Header().Set("Content-Type", contentTypeJSON)
+		w.Header().Set("X-Carbonapi-UUID", util.GetUUID(ctx))
+		w.WriteHeader(code)
+		w.Write(b)
+		toLog.HttpCode = int32(code)
+	}
+}
+
+// promQueryRange evaluates a range query, translated into a Graphite target,
+// and resamples the resulting series to the step of the query.
+func (app *App) promQueryRange(ctx context.Context, r *http.Request,
+	toLog *carbonapipb.AccessLogDetails, logger *zap.Logger) (interface{}, *promError) {
+
+	start, err := parsePromTime(r.FormValue("start"))
+	if err != nil {
+		return nil, badData("invalid parameter \"start\": %v", err)
+	}
+	end, err := parsePromTime(r.FormValue("end"))
+	if err != nil {
+		return nil, badData("invalid parameter \"end\": %v", err)
+	}
+	if end < start {
+		return nil, badData("invalid parameter \"end\": end timestamp must not be before start time")
+	}
+	step, err := parsePromStep(r.FormValue("step"))
+	if err != nil {
+		return nil, badData("invalid parameter \"step\": %v", err)
+	}
+	if (end-start)/step >= maxPromPoints {
+		return nil, badData("exceeded maximum resolution of %d points per timeseries. Try decreasing the query resolution (?step=XX)", maxPromPoints)
+	}
+
+	e, err := promql.ParseExpr(r.FormValue("query"))
+	if err != nil {
+		return nil, badData("invalid parameter \"query\": %v", err)
+	}
+	q, err := app.promTemplate.Translate(e)
+	if err != nil {
+		return nil, badData("invalid parameter \"query\": %v", err)
+	}
+
+	exp, _, err := parser.ParseExpr(q.Target)
+	if err == nil {
+		err = expr.Validate(exp)
+	}
+	if err != nil {
+		return nil, &promError{code: http.StatusInternalServerError, typ: "internal", msg: err.Error()}
+	}
+
+	lookback := int64(app.config.Prometheus.LookbackDelta.Seconds())
+	form := renderForm{
+		targets:  []string{q.Target},
+		from32:   int32(start - lookback),
+		until32:  int32(end),
+		format:   jsonFormat,
+		useCache: true,
+	}
+	toLog.Targets = form.targets
+	toLog.From = form.from32
+	toLog.Until = form.until32
+	toLog.UseCache = form.useCache
+
+	var partiallyFailed int32
+	results, size, err := app.renderTargets(ctx, []parser.Expr{exp}, form, toLog, logger, &partiallyFailed)
+	if err != nil {
+		var parseError parser.ParseError
+		var limitErr limits.ErrLimitExceeded
+		switch {
+		case errors.As(err, &parseError):
+			return nil, badData("%v", err)
+		case errors.As(err, &limitErr):
+			return nil, &promError{code: http.StatusUnprocessableEntity, typ: "execution", msg: "query too complex: " + limitErr.Error()}
+		case errors.Is(err, context.DeadlineExceeded):
+			app.prometheusMetrics.RequestCancel.WithLabelValues(
+				"prometheus_query_range", context.DeadlineExceeded.Error(),
+			).Inc()
+			return nil, &promError{code: http.StatusServiceUnavailable, typ: "timeout", msg: "query timed out"}
+		default:
+			return nil, &promError{code: http.StatusInternalServerError, typ: "internal", msg: err.Error()}
+		}
+	}
+	toLog.CarbonzipperResponseSizeBytes = int64(size * 8)
+	if partiallyFailed != 0 {
+		app.prometheusMetrics.RenderPartialFail.Inc()
+	}
+
+	matrix := promMatrix{ResultType: "matrix", Result: []promql.Series{}}
+	seen := make(map[string]string)
+	for _, res := range results {
+		if res == nil {
+			continue
+		}
+
+		// a point is the value of a series until the next one at least
+		lb := lookback
+		if int64(res.StepTime) > lb {
+			lb = int64(res.StepTime)
+		}
+		samples := promql.Resample(res.StartTime, res.StepTime, res.Values, res.IsAbsent, start, end, step, lb)
+		if len(samples) == 0 {
+			continue
+		}
+
+		labels := q.Labels(res.Name)
+		key := fmt.Sprint(labels)
+		if other, ok := seen[key]; ok {
+			return nil, &promError{code: http.StatusUnprocessableEntity, typ: "execution",
+				msg: fmt.Sprintf("series %s and %s have the same labels %s", other, res.Name, key)}
+		}
+		seen[key] = res.Name
+
+		matrix.Result = append(matrix.Result, promql.Series{Metric: labels, Values: samples})
+	}
+	promql.SortSeries(matrix.Result)
+
+	if len(q.Warnings) > 0 {
+		return promWarned{data: matrix, warnings: q.Warnings}, nil
+	}
+	return matrix, nil
+}
+
+// promSeries finds the label sets of series matching any of the match[] selectors.
+func (app *App) promSeries(ctx context.Context, r *http.Request,
+	toLog *carbonapipb.AccessLogDetails, logger *zap.Logger) (interface{}, *promError) {
+
+	if err := r.ParseForm(); err != nil {
+		return nil, badData("%v", err)
+	}
+	if len(r.Form["match[]"]) == 0 {
+		return nil, badData("no match[] parameter provided")
+	}
+
+	var sels []*promql.Selector
+	var globs []string
+	for _, m := range r.Form["match[]"] {
+		sel, err := promql.ParseSelector(m)
+		if err != nil {
+			return nil, badData("invalid parameter \"match[]\": %v", err)
+		}
+		glob, err := app.promTemplate.Glob(sel)
+		if err != nil {
+			return nil, badData("invalid parameter \"match[]\": %v", err)
+		}
+		sels = append(sels, sel)
+		globs = append(globs, glob)
+	}
+	toLog.Targets = globs
+
+	sets := []map[string]string{}
+	seen := make(map[string]bool)
+	for i, glob := range globs {
+		matches, fromCache, err := app.resolveGlobs(ctx, glob, true, toLog, logger)
+		toLog.FromCache = toLog.FromCache || fromCache
+		if err != nil {
+			var notFound dataTypes.ErrNotFound
+			if errors.As(err, &notFound) {
+				continue
+			}
+			if errors.Is(err, context.DeadlineExceeded) {
+				return nil, &promError{code: http.StatusServiceUnavailable, typ: "timeout", msg: "query timed out"}
+			}
+			return nil, &promError{code: http.StatusInternalServerError, typ: "internal", msg: err.Error()}
+		}
+
+		for _, m := range matches.Matches {
+			if !m.IsLeaf || seen[m.Path] {
+				continue
+			}
+			labels, ok := app.promTemplate.Labels(m.Path)
+			if !ok || !sels[i].Matches(labels) {
+				continue
+			}
+			// paths matching the template have distinct labels
+			seen[m.Path] = true
+			sets = append(sets, labels)
+		}
+	}
+	promql.SortLabelSets(sets)
+
+	return sets, nil
+}
+
+// promLabels returns the names of the labels of the template.
+func (app *App) promLabels(ctx context.Context, r *http.Request,
+	toLog *carbonapipb.AccessLogDetails, logger *zap.Logger) (interface{}, *promError) {
+
+	return app.promTemplate.LabelNames(), nil
+}
+
+// parsePromTime parses a time as Prometheus does, either a Unix timestamp
+// with possibly a fractional part or an RFC3339 time.
+func parsePromTime(s string) (int64, error) {
+	if s == "" {
+		return 0, errors.New("missing time")
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		if math.IsNaN(f) || math.IsInf(f, 0) || math.Abs(f) > math.MaxInt32 {
+			return 0, fmt.Errorf("time %q is out of range", s)
+		}
+		return int64(math.Floor(f)), nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return 0, fmt.Errorf("cannot parse %q to a valid timestamp", s)
+	}
+	return t.Unix(), nil
+}
+
+// parsePromStep parses a step either in seconds or a duration. Graphite data
+// has a resolution of seconds, so steps are whole seconds.
+func parsePromStep(s string) (int64, error) {
+	if s == "" {
+		return 0, errors.New("missing step")
+	}
+
+	var secs float64
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		secs = f
+	} else {
+		d, err := promql.ParseDuration(s)
+		if err != nil {
+			return 0, fmt.Errorf("cannot parse %q to a valid duration", s)
+		}
+		secs = d.Seconds()
+	}
+
+	if math.IsNaN(secs) || secs < 1 || secs > math.MaxInt32 {
+		return 0, fmt.Errorf("step %q must be at least 1s", s)
+	}
+	return int64(secs), nil
+}
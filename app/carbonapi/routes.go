@@ -23,6 +23,10 @@ func initHandlersInternal(app *App) http.Handler {
 
 	r.HandleFunc("/debug/version", app.debugVersionHandler)
 
+	r.HandleFunc("/cache/entry", httputil.TimeHandler(app.cacheEntryHandler, app.bucketRequestTimes))
+	r.HandleFunc("/cache/top", httputil.TimeHandler(app.cacheTopHandler, app.bucketRequestTimes))
+	r.HandleFunc("/cache/purge", httputil.TimeHandler(app.cachePurgeHandler, app.bucketRequestTimes))
+
 	r.Handle("/debug/vars", expvar.Handler())
 	r.PathPrefix("/debug/pprof").HandlerFunc(pprof.Index)
 
@@ -49,11 +53,23 @@ func initHandlers(app *App) http.Handler {
 	r.HandleFunc("/info", httputil.TimeHandler(
 		app.validateRequest(http.HandlerFunc(app.infoHandler), "info"), app.bucketRequestTimes))
 
+	r.HandleFunc("/api/v1/query_range", httputil.TimeHandler(
+		app.validateRequest(app.promHandler("prometheus_query_range", app.promQueryRange), "prometheus_query_range"), app.bucketRequestTimes))
+
+	r.HandleFunc("/api/v1/series", httputil.TimeHandler(
+		app.validateRequest(app.promHandler("prometheus_series", app.promSeries), "prometheus_series"), app.bucketRequestTimes))
+
+	r.HandleFunc("/api/v1/labels", httputil.TimeHandler(
+		app.validateRequest(app.promHandler("prometheus_labels", app.promLabels), "prometheus_labels"), app.bucketRequestTimes))
+
 	r.HandleFunc("/lb_check", httputil.TimeHandler(app.lbcheckHandler, app.bucketRequestTimes))
 
 	r.HandleFunc("/version", httputil.TimeHandler(app.versionHandler, app.bucketRequestTimes))
 
 	r.HandleFunc("/functions", httputil.TimeHandler(app.functionsHandler, app.bucketRequestTimes))
+	r.HandleFunc("/functions/{function}", httputil.TimeHandler(app.functionsHandler, app.bucketRequestTimes))
+
+	r.HandleFunc("/parse", httputil.TimeHandler(app.parseHandler, app.bucketRequestTimes))
 
 	r.HandleFunc("/tags/autoComplete/tags", httputil.TimeHandler(app.tagsHandler, app.bucketRequestTimes))
 
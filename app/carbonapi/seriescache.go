@@ -0,0 +1,321 @@
+package carbonapi
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/bookingcom/carbonapi/cache"
+	dataTypes "github.com/bookingcom/carbonapi/pkg/types"
+	"github.com/bookingcom/carbonapi/pkg/types/encoding/carbonapi_v2"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// seriesCache caches series fetched from the backends in chunks of time
+// aligned to the chunk size. A chunk holds the points with timestamps in
+// [start, start+chunk). Only chunks old enough not to receive new points
+// are cached, so a rolling dashboard fetches just the ranges around the
+// cached chunks: the head before them and the tail up to now. Neither globs,
+// whose matches change, nor partial responses of the backends are cached.
+type seriesCache struct {
+	store  cache.BytesCache
+	chunk  int32
+	minAge int32
+	expire int32
+
+	// chunks counts chunk lookups, partitioned by result: hit or miss
+	chunks *prometheus.CounterVec
+}
+
+// renderFunc fetches a path from the backends over [from, until].
+type renderFunc func(ctx context.Context, from, until int32) ([]dataTypes.Metric, error)
+
+func newSeriesCache(store cache.BytesCache, chunkSec, minAgeSec, expireSec int32, chunks *prometheus.CounterVec) *seriesCache {
+	return &seriesCache{
+		store:  store,
+		chunk:  chunkSec,
+		minAge: minAgeSec,
+		expire: expireSec,
+		chunks: chunks,
+	}
+}
+
+// render returns series of path over [from, until] as a backend would, that is
+// with points in (from, until]. Cached chunks are used for the part of the range
+// older than now minus the minimum age, the rest is fetched with fetch.
+// Like a backend, it returns dataTypes.ErrPartialResponse with the series
+// if some backends failed to respond.
+func (c *seriesCache) render(ctx context.Context, path string, from, until, now int32, fetch renderFunc) ([]dataTypes.Metric, error) {
+	if strings.ContainsAny(path, "*?[{") {
+		return fetch(ctx, from, until)
+	}
+
+	first := alignTime(from, c.chunk)
+	cutoff := now - c.minAge
+
+	var starts []int32
+	for s := first; s <= until && s+c.chunk <= cutoff; s += c.chunk {
+		starts = append(starts, s)
+	}
+	if len(starts) == 0 {
+		return fetch(ctx, from, until)
+	}
+
+	cached, hit := c.getChunks(path, starts)
+	lo, hi := longestRun(hit)
+
+	var pieces [][]dataTypes.Metric
+	var partial error
+	if lo > 0 {
+		head, err := c.fetchOwned(ctx, fetch, first, starts[lo])
+		if errors.Is(err, dataTypes.ErrPartialResponse) {
+			partial = err
+		} else if err != nil {
+			return nil, err
+		} else {
+			c.setChunks(path, head, starts[:lo], starts[lo])
+		}
+		pieces = append(pieces, head)
+	}
+	pieces = append(pieces, cached[lo:hi]...)
+	if tailFrom := first + int32(hi)*c.chunk; tailFrom <= until {
+		tail, err := c.fetchOwned(ctx, fetch, tailFrom, until+1)
+		if errors.Is(err, dataTypes.ErrPartialResponse) {
+			partial = err
+		} else if err != nil {
+			return nil, err
+		} else {
+			c.setChunks(path, tail, starts[hi:], until+1)
+		}
+		pieces = append(pieces, tail)
+	}
+
+	res, ok := stitch(pieces, from, until)
+	if !ok {
+		// the resolution differs between pieces, e.g. the range crosses
+		// a retention boundary, fetch everything at once
+		return fetch(ctx, from, until)
+	}
+	if len(res) == 0 {
+		return nil, dataTypes.ErrMetricsNotFound
+	}
+
+	return res, partial
+}
+
+// fetchOwned fetches the points with timestamps in [from, until).
+// Points of partial responses are returned with dataTypes.ErrPartialResponse.
+func (c *seriesCache) fetchOwned(ctx context.Context, fetch renderFunc, from, until int32) ([]dataTypes.Metric, error) {
+	metrics, err := fetch(ctx, from-1, until-1)
+	var notFound dataTypes.ErrNotFound
+	if errors.As(err, &notFound) {
+		return nil, nil
+	}
+	if err != nil && !errors.Is(err, dataTypes.ErrPartialResponse) {
+		return nil, err
+	}
+
+	res := make([]dataTypes.Metric, 0, len(metrics))
+	for _, m := range metrics {
+		res = append(res, slicePoints(m, from, until))
+	}
+	return res, err
+}
+
+// getChunks returns the cached chunks of path starting at starts and whether they were found.
+func (c *seriesCache) getChunks(path string, starts []int32) ([][]dataTypes.Metric, []bool) {
+	blobs := make([][]byte, len(starts))
+	found := make([]bool, len(starts))
+	if mg, ok := c.store.(cache.MultiGetter); ok {
+		keys := make([]string, len(starts))
+		for i, s := range starts {
+			keys[i] = chunkKey(path, s)
+		}
+		if res, err := mg.GetMulti(keys); err == nil {
+			for i, k := range keys {
+				blobs[i], found[i] = res[k]
+			}
+		}
+	} else {
+		for i, s := range starts {
+			if blob, err := c.store.Get(chunkKey(path, s)); err == nil {
+				blobs[i], found[i] = blob, true
+			}
+		}
+	}
+
+	cached := make([][]dataTypes.Metric, len(starts))
+	hit := make([]bool, len(starts))
+	for i, blob := range blobs {
+		if found[i] {
+			metrics, err := carbonapi_v2.RenderDecoder(blob)
+			if err == nil {
+				cached[i], hit[i] = metrics, true
+				c.chunks.WithLabelValues("hit").Inc()
+				continue
+			}
+		}
+		c.chunks.WithLabelValues("miss").Inc()
+	}
+
+	return cached, hit
+}
+
+// setChunks caches the chunks starting at starts, which are complete in metrics
+// fetched up to until. Paths without series aren't cached, as they may still
+// be created with older points.
+func (c *seriesCache) setChunks(path string, metrics []dataTypes.Metric, starts []int32, until int32) {
+	if len(metrics) == 0 {
+		return
+	}
+	for _, s := range starts {
+		if s+c.chunk > until {
+			return
+		}
+
+		chunk := make([]dataTypes.Metric, 0, len(metrics))
+		for _, m := range metrics {
+			chunk = append(chunk, slicePoints(m, s, s+c.chunk))
+		}
+		blob, err := carbonapi_v2.RenderEncoder(chunk)
+		if err != nil {
+			return
+		}
+		c.store.Set(chunkKey(path, s), blob, c.expire)
+	}
+}
+
+func chunkKey(path string, start int32) string {
+	return "series:" + path + "@" + strconv.Itoa(int(start))
+}
+
+// chunkPath returns the path of a chunk key.
+func chunkPath(key string) string {
+	key = strings.TrimPrefix(key, "series:")
+	if i := strings.LastIndexByte(key, '@'); i >= 0 {
+		key = key[:i]
+	}
+	return key
+}
+
+// longestRun returns the bounds [lo, hi) of the longest run of true values.
+func longestRun(hit []bool) (int, int) {
+	lo, hi := 0, 0
+	for i := 0; i < len(hit); {
+		if !hit[i] {
+			i++
+			continue
+		}
+		j := i
+		for j < len(hit) && hit[j] {
+			j++
+		}
+		if j-i > hi-lo {
+			lo, hi = i, j
+		}
+		i = j
+	}
+	return lo, hi
+}
+
+// slicePoints returns the points of m with timestamps in [from, until).
+func slicePoints(m dataTypes.Metric, from, until int32) dataTypes.Metric {
+	if m.StepTime <= 0 {
+		return m
+	}
+
+	lo := 0
+	if from > m.StartTime {
+		lo = int((from - m.StartTime + m.StepTime - 1) / m.StepTime)
+	}
+	hi := len(m.Values)
+	if until <= m.StartTime {
+		hi = 0
+	} else if n := int((until - m.StartTime + m.StepTime - 1) / m.StepTime); n < hi {
+		hi = n
+	}
+	if lo > hi {
+		lo = hi
+	}
+
+	r := m
+	r.Values = m.Values[lo:hi]
+	r.IsAbsent = m.IsAbsent[lo:hi]
+	r.StartTime = m.StartTime + int32(lo)*m.StepTime
+	r.StopTime = r.StartTime + int32(hi-lo)*m.StepTime
+	return r
+}
+
+// stitch joins pieces of series by name into series with points in (from, until].
+// It fails if pieces of a series have different steps or aren't aligned to the same step.
+func stitch(pieces [][]dataTypes.Metric, from, until int32) ([]dataTypes.Metric, bool) {
+	var names []string
+	byName := make(map[string][]dataTypes.Metric)
+	for _, p := range pieces {
+		for _, m := range p {
+			if _, ok := byName[m.Name]; !ok {
+				names = append(names, m.Name)
+			}
+			byName[m.Name] = append(byName[m.Name], m)
+		}
+	}
+
+	res := make([]dataTypes.Metric, 0, len(names))
+	for _, name := range names {
+		parts := byName[name]
+		step := parts[0].StepTime
+		if step <= 0 {
+			return nil, false
+		}
+		phase := mod(parts[0].StartTime, step)
+		for _, p := range parts[1:] {
+			if p.StepTime != step || mod(p.StartTime, step) != phase {
+				return nil, false
+			}
+		}
+
+		// first and last timestamps in (from, until] aligned like the pieces
+		start := from + 1 + mod(phase-from-1, step)
+		stop := until - mod(until-phase, step)
+		n := 0
+		if stop >= start {
+			n = int((stop-start)/step) + 1
+		}
+
+		m := dataTypes.Metric{
+			Name:      name,
+			StartTime: start,
+			StopTime:  start + int32(n)*step,
+			StepTime:  step,
+			Values:    make([]float64, n),
+			IsAbsent:  make([]bool, n),
+		}
+		for i := range m.IsAbsent {
+			m.IsAbsent[i] = true
+		}
+		for _, p := range parts {
+			for j, v := range p.Values {
+				t := p.StartTime + int32(j)*step
+				if t < start || t > stop {
+					continue
+				}
+				i := (t - start) / step
+				m.Values[i] = v
+				m.IsAbsent[i] = p.IsAbsent[j]
+			}
+		}
+		res = append(res, m)
+	}
+
+	return res, true
+}
+
+func mod(a, b int32) int32 {
+	m := a % b
+	if m < 0 {
+		m += b
+	}
+	return m
+}
@@ -0,0 +1,193 @@
+package carbonapi
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/bookingcom/carbonapi/cache"
+	dataTypes "github.com/bookingcom/carbonapi/pkg/types"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// fakeRender returns the points of a.b with timestamps in (from, until],
+// every step seconds, valued with their timestamps.
+func fakeRender(step int32, calls *[][2]int32) renderFunc {
+	return func(ctx context.Context, from, until int32) ([]dataTypes.Metric, error) {
+		*calls = append(*calls, [2]int32{from, until})
+
+		start := from - from%step + step
+		m := dataTypes.Metric{
+			Name:      "a.b",
+			StartTime: start,
+			StepTime:  step,
+		}
+		for t := start; t <= until; t += step {
+			m.Values = append(m.Values, float64(t))
+			m.IsAbsent = append(m.IsAbsent, false)
+		}
+		m.StopTime = start + int32(len(m.Values))*step
+		return []dataTypes.Metric{m}, nil
+	}
+}
+
+func counterValue(t *testing.T, c *prometheus.CounterVec, label string) float64 {
+	var m dto.Metric
+	if err := c.WithLabelValues(label).Write(&m); err != nil {
+		t.Fatal(err)
+	}
+	return m.GetCounter().GetValue()
+}
+
+func TestSeriesCacheRollingRange(t *testing.T) {
+	chunks := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_chunks"}, []string{"result"})
+	c := newSeriesCache(cache.NewExpireCache(0), 600, 300, 3600, chunks)
+
+	var calls, direct [][2]int32
+	fetch := fakeRender(60, &calls)
+	expected := fakeRender(60, &direct)
+
+	now := int32(100000)
+	for i := int32(0); i < 5; i++ {
+		calls = nil
+		now += 60
+		from, until := now-86400/4, now
+
+		got, err := c.render(context.Background(), "a.b", from, until, now, fetch)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, _ := expected(context.Background(), from, until)
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("render %d: got %+v, want %+v", i, got, want)
+		}
+
+		if i == 0 {
+			continue
+		}
+		// the range is cached but for the head and the tail
+		if len(calls) == 0 || len(calls) > 2 {
+			t.Fatalf("render %d: expected fetches of head and tail, got %v", i, calls)
+		}
+		for _, r := range calls {
+			if r[1]-r[0] > 2*600 {
+				t.Errorf("render %d: fetched %d seconds, expected at most two chunks", i, r[1]-r[0])
+			}
+		}
+	}
+
+	// a longer range needs the head before the cached chunks
+	calls = nil
+	from := now - 86400/4 - 1800
+	got, err := c.render(context.Background(), "a.b", from, now, now, fetch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := expected(context.Background(), from, now)
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	if len(calls) != 2 || calls[0][0] != alignTime(from, 600)-1 {
+		t.Errorf("expected fetches of the head from %d and the tail, got %v", alignTime(from, 600)-1, calls)
+	}
+
+	if hits := counterValue(t, chunks, "hit"); hits == 0 {
+		t.Error("expected chunk hits to be counted")
+	}
+	if misses := counterValue(t, chunks, "miss"); misses == 0 {
+		t.Error("expected chunk misses to be counted")
+	}
+}
+
+func TestSeriesCacheRecentRange(t *testing.T) {
+	chunks := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_chunks"}, []string{"result"})
+	c := newSeriesCache(cache.NewExpireCache(0), 600, 300, 3600, chunks)
+
+	var calls [][2]int32
+	now := int32(100000)
+	_, err := c.render(context.Background(), "a.b", now-200, now, now, fakeRender(60, &calls))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(calls) != 1 || calls[0] != [2]int32{now - 200, now} {
+		t.Errorf("expected a single fetch of the whole range, got %v", calls)
+	}
+	if n := counterValue(t, chunks, "hit") + counterValue(t, chunks, "miss"); n != 0 {
+		t.Errorf("expected no chunk lookups, got %v", n)
+	}
+}
+
+func TestSeriesCacheNotFound(t *testing.T) {
+	chunks := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_chunks"}, []string{"result"})
+	store := cache.NewExpireCache(0).(*cache.ExpireCache)
+	c := newSeriesCache(store, 600, 300, 3600, chunks)
+
+	fetch := func(ctx context.Context, from, until int32) ([]dataTypes.Metric, error) {
+		return nil, dataTypes.ErrMetricsNotFound
+	}
+	now := int32(100000)
+	if _, err := c.render(context.Background(), "a.b", now-86400/4, now, now, fetch); err != dataTypes.ErrMetricsNotFound {
+		t.Fatalf("expected metrics not to be found, got %v", err)
+	}
+	if n := store.Items(); n != 0 {
+		t.Errorf("expected no chunks to be cached for a path not found, got %d", n)
+	}
+}
+
+func TestSeriesCachePartialResponse(t *testing.T) {
+	chunks := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_chunks"}, []string{"result"})
+	store := cache.NewExpireCache(0).(*cache.ExpireCache)
+	c := newSeriesCache(store, 600, 300, 3600, chunks)
+
+	var calls [][2]int32
+	complete := fakeRender(60, &calls)
+	fetch := func(ctx context.Context, from, until int32) ([]dataTypes.Metric, error) {
+		metrics, _ := complete(ctx, from, until)
+		return metrics, dataTypes.ErrPartialResponse
+	}
+
+	now := int32(100000)
+	got, err := c.render(context.Background(), "a.b", now-86400/4, now, now, fetch)
+	if err != dataTypes.ErrPartialResponse {
+		t.Fatalf("expected a partial response, got %v", err)
+	}
+	if len(got) != 1 || len(got[0].Values) == 0 {
+		t.Errorf("expected the series of the partial response, got %+v", got)
+	}
+	if n := store.Items(); n != 0 {
+		t.Errorf("expected no chunks to be cached for a partial response, got %d", n)
+	}
+}
+
+func TestSeriesCacheGlob(t *testing.T) {
+	chunks := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_chunks"}, []string{"result"})
+	store := cache.NewExpireCache(0).(*cache.ExpireCache)
+	c := newSeriesCache(store, 600, 300, 3600, chunks)
+
+	var calls [][2]int32
+	now := int32(100000)
+	from := now - 86400/4
+	if _, err := c.render(context.Background(), "a.*", from, now, now, fakeRender(60, &calls)); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(calls) != 1 || calls[0] != [2]int32{from, now} {
+		t.Errorf("expected a single fetch of the whole range, got %v", calls)
+	}
+	if n := store.Items(); n != 0 {
+		t.Errorf("expected no chunks to be cached for a glob, got %d", n)
+	}
+}
+
+func TestStitchDifferentSteps(t *testing.T) {
+	pieces := [][]dataTypes.Metric{
+		{{Name: "a", StartTime: 60, StopTime: 120, StepTime: 60, Values: []float64{1}, IsAbsent: []bool{false}}},
+		{{Name: "a", StartTime: 120, StopTime: 420, StepTime: 300, Values: []float64{1}, IsAbsent: []bool{false}}},
+	}
+	if _, ok := stitch(pieces, 0, 600); ok {
+		t.Error("expected pieces with different steps not to be stitched")
+	}
+}
@@ -0,0 +1,75 @@
+package carbonapi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/bookingcom/carbonapi/cache"
+	"github.com/bookingcom/carbonapi/carbonapipb"
+	"github.com/bookingcom/carbonapi/expr/limits"
+	"github.com/bookingcom/carbonapi/pkg/parser"
+	dataTypes "github.com/bookingcom/carbonapi/pkg/types"
+	"github.com/bookingcom/carbonapi/util"
+
+	"github.com/lomik/zapwriter"
+	"go.uber.org/zap"
+)
+
+// Warning headers of responses served from expired cache entries, as in RFC 7234
+const (
+	staleWarning        = `110 carbonapi "Response is Stale"`
+	staleOnErrorWarning = `111 carbonapi "Revalidation Failed"`
+)
+
+// getStale gets the value of the key and whether it is expired,
+// if the cache keeps expired entries.
+func getStale(c cache.BytesCache, k string) ([]byte, bool, error) {
+	if sg, ok := c.(cache.StaleGetter); ok {
+		return sg.GetStale(k)
+	}
+
+	v, err := c.Get(k)
+	return v, false, err
+}
+
+// refresh runs f in background, unless a refresh of key is already running.
+func (app *App) refresh(key string, f func(ctx context.Context)) {
+	if _, running := app.refreshing.LoadOrStore(key, struct{}{}); running {
+		return
+	}
+
+	go func() {
+		defer app.refreshing.Delete(key)
+
+		ctx, cancel := context.WithTimeout(util.WithUUID(context.Background()), app.config.Timeouts.Global)
+		defer cancel()
+		f(ctx)
+	}()
+}
+
+// logRefresh writes the access log of a refresh started at t0, which
+// outlives the request it was started by.
+func logRefresh(toLog *carbonapipb.AccessLogDetails, t0 time.Time, err error) {
+	accessLogger := zapwriter.Logger("access")
+
+	toLog.Runtime = time.Since(t0).Seconds()
+	if err != nil {
+		toLog.HttpCode = http.StatusInternalServerError
+		toLog.Reason = err.Error()
+		accessLogger.Error("refresh failed", zap.Any("data", *toLog))
+		return
+	}
+	toLog.HttpCode = http.StatusOK
+	accessLogger.Info("refresh done", zap.Any("data", *toLog))
+}
+
+// isBackendError tells whether err is a failure of the backends,
+// rather than a problem with the request.
+func isBackendError(err error) bool {
+	var notFound dataTypes.ErrNotFound
+	var parseError parser.ParseError
+	var limitErr limits.ErrLimitExceeded
+	return !errors.As(err, &notFound) && !errors.As(err, &parseError) && !errors.As(err, &limitErr)
+}
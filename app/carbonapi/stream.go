@@ -0,0 +1,114 @@
+package carbonapi
+
+import (
+	"context"
+	"io"
+	"net/http"
+
+	"github.com/bookingcom/carbonapi/expr/types"
+	"github.com/bookingcom/carbonapi/util"
+
+	"go.uber.org/zap"
+)
+
+// isStreamed tells whether render responses in the format are streamed.
+func isStreamed(format string) bool {
+	return format == jsonFormat || format == csvFormat || format == arrowFormat
+}
+
+// streamWriter writes a response to the client, flushing every write, and keeps
+// a copy of it for the cache as long as it doesn't get larger than the limit.
+type streamWriter struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+
+	// limit is the maximum size of the copy, 0 means no limit
+	limit    int
+	body     []byte
+	size     int
+	tooLarge bool
+}
+
+func newStreamWriter(w http.ResponseWriter, limit int) *streamWriter {
+	f, _ := w.(http.Flusher)
+	return &streamWriter{
+		w:       w,
+		flusher: f,
+		limit:   limit,
+	}
+}
+
+func (s *streamWriter) Write(p []byte) (int, error) {
+	if !s.tooLarge {
+		if s.limit > 0 && len(s.body)+len(p) > s.limit {
+			s.tooLarge = true
+			s.body = nil
+		} else {
+			s.body = append(s.body, p...)
+		}
+	}
+
+	n, err := s.w.Write(p)
+	s.size += n
+	if err != nil {
+		return n, err
+	}
+	if s.flusher != nil {
+		s.flusher.Flush()
+	}
+	return n, nil
+}
+
+// renderStream streams results in the format of the form to w. It returns the size
+// of the response and its body, which is nil if the response is too large to be cached
+// or it couldn't be written entirely.
+func (app *App) renderStream(ctx context.Context, w http.ResponseWriter, results []*types.MetricData, form renderForm, logger *zap.Logger) ([]byte, int) {
+	w.Header().Set("X-Carbonapi-UUID", util.GetUUID(ctx))
+	sw := newStreamWriter(w, app.config.Cache.MaxStreamedSizeKB*1024)
+	results = types.Consolidate(form.maxDataPoints, results)
+
+	var err error
+	switch form.format {
+	case jsonFormat:
+		if form.jsonp != "" {
+			w.Header().Set("Content-Type", contentTypeJavaScript)
+			w.Write([]byte(form.jsonp))
+			w.Write([]byte{'('})
+			err = writeJSONBody(ctx, sw, results, form)
+			w.Write([]byte{')'})
+		} else {
+			w.Header().Set("Content-Type", contentTypeJSON)
+			err = writeJSONBody(ctx, sw, results, form)
+		}
+	case csvFormat:
+		w.Header().Set("Content-Type", contentTypeCSV)
+		err = types.WriteCSV(sw, results, form.location)
+	case arrowFormat:
+		w.Header().Set("Content-Type", contentTypeArrow)
+		err = types.WriteArrow(sw, results)
+	}
+
+	if err != nil {
+		logger.Warn("failed to stream response",
+			zap.Error(err),
+		)
+		return nil, sw.size
+	}
+	if sw.tooLarge {
+		return nil, sw.size
+	}
+	// an empty response is cached too
+	if sw.body == nil {
+		return []byte{}, sw.size
+	}
+	return sw.body, sw.size
+}
+
+// writeJSONBody writes results as json, in the envelope with metadata if
+// they were requested.
+func writeJSONBody(ctx context.Context, w io.Writer, results []*types.MetricData, form renderForm) error {
+	if meta := renderMetaFrom(ctx); form.meta && meta != nil {
+		return writeMetaJSON(w, results, meta)
+	}
+	return types.WriteJSON(w, results)
+}
@@ -0,0 +1,32 @@
+package carbonapi
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestStreamWriter(t *testing.T) {
+	rr := httptest.NewRecorder()
+	sw := newStreamWriter(rr, 8)
+
+	sw.Write([]byte("abcd"))
+	if !rr.Flushed {
+		t.Error("Expected write to be flushed")
+	}
+	if sw.tooLarge || string(sw.body) != "abcd" {
+		t.Errorf("Expected body to be kept, got %q", sw.body)
+	}
+
+	sw.Write([]byte("efghi"))
+	if !sw.tooLarge || sw.body != nil {
+		t.Errorf("Expected body over the limit to be dropped, got %q", sw.body)
+	}
+
+	sw.Write([]byte("j"))
+	if sw.body != nil {
+		t.Errorf("Expected body to stay dropped, got %q", sw.body)
+	}
+	if rr.Body.String() != "abcdefghij" || sw.size != 10 {
+		t.Errorf("Expected whole response to be written, got %q of size %d", rr.Body.String(), sw.size)
+	}
+}
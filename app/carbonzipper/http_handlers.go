@@ -25,6 +25,7 @@ import (
 	"github.com/bookingcom/carbonapi/pkg/types"
 	"github.com/bookingcom/carbonapi/pkg/types/encoding/carbonapi_v2"
 	"github.com/bookingcom/carbonapi/pkg/types/encoding/json"
+	"github.com/bookingcom/carbonapi/pkg/types/encoding/msgpack"
 	"github.com/bookingcom/carbonapi/pkg/types/encoding/pickle"
 	"github.com/bookingcom/carbonapi/util"
 	"go.opentelemetry.io/otel/api/kv"
@@ -38,6 +39,7 @@ const (
 	contentTypeJSON     = "application/json"
 	contentTypeProtobuf = "application/x-protobuf"
 	contentTypePickle   = "application/pickle"
+	contentTypeMsgpack  = "application/x-msgpack"
 )
 
 const (
@@ -46,6 +48,7 @@ const (
 	formatTypeJSON      = "json"
 	formatTypeProtobuf  = "protobuf"
 	formatTypeProtobuf3 = "protobuf3"
+	formatTypeMsgpack   = "msgpack"
 )
 
 func (app *App) findHandler(w http.ResponseWriter, req *http.Request) {
@@ -155,6 +158,9 @@ func (app *App) findHandler(w http.ResponseWriter, req *http.Request) {
 		} else {
 			blob, err = pickle.FindEncoderV1_0(metrics)
 		}
+	case formatTypeMsgpack:
+		contentType = contentTypeMsgpack
+		blob, err = msgpack.FindEncoder(metrics)
 	default:
 		err = fmt.Errorf("Unknown format %s", format)
 	}
@@ -276,9 +282,13 @@ func (app *App) renderHandler(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	// backends are asked for raw points, they are consolidated after merging
+	maxDataPoints, _ := strconv.Atoi(req.FormValue("maxDataPoints"))
+
 	span.SetAttributes(
 		kv.Int("graphite.from", from),
 		kv.Int("graphite.until", until),
+		kv.Int("graphite.maxDataPoints", maxDataPoints),
 	)
 
 	if target == "" {
@@ -341,6 +351,8 @@ func (app *App) renderHandler(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	metrics = types.ConsolidateMetrics(metrics, maxDataPoints)
+
 	var blob []byte
 	var contentType string
 	switch format {
@@ -353,6 +365,9 @@ func (app *App) renderHandler(w http.ResponseWriter, req *http.Request) {
 	case formatTypeEmpty, formatTypePickle:
 		contentType = contentTypePickle
 		blob, err = pickle.RenderEncoder(metrics)
+	case formatTypeMsgpack:
+		contentType = contentTypeMsgpack
+		blob, err = msgpack.RenderEncoder(metrics)
 	default:
 		err = fmt.Errorf("Unknown format %s", format)
 	}
@@ -376,6 +391,9 @@ func (app *App) renderHandler(w http.ResponseWriter, req *http.Request) {
 	}
 
 	w.Header().Set("Content-Type", contentType)
+	if failedBackends(errs) > 0 {
+		w.Header().Set(types.PartialResponseHeader, "true")
+	}
 	w.Write(blob)
 
 	accessLogger.Info("request served",
@@ -581,6 +599,19 @@ func (app *App) filterByTopLevelDomain(backends []backend.Backend, targetTLDs []
 	return bs
 }
 
+// failedBackends counts errors other than not found, which backends
+// without the metrics return.
+func failedBackends(errs []error) int {
+	n := 0
+	for _, e := range errs {
+		var notFound types.ErrNotFound
+		if !errors.As(e, &notFound) {
+			n++
+		}
+	}
+	return n
+}
+
 func errorsFanIn(ctx context.Context, errs []error, nBackends int) error {
 	nErrs := len(errs)
 	var counts = make(map[string]int)
@@ -5,12 +5,14 @@ import (
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/bookingcom/carbonapi/cfg"
 	"github.com/bookingcom/carbonapi/pkg/backend"
 	"github.com/bookingcom/carbonapi/pkg/backend/mock"
 	types "github.com/bookingcom/carbonapi/pkg/types"
+	"github.com/bookingcom/carbonapi/pkg/types/encoding/carbonapi_v2"
 	"go.uber.org/zap"
 )
 
@@ -89,6 +91,99 @@ func TestRenderSingleBackend(t *testing.T) {
 	}
 }
 
+func TestRenderSingleBackendMsgpack(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	defer logger.Sync()
+
+	app, err := New(cfg.DefaultZipperConfig(), logger, "test")
+	if err != nil {
+		t.Fatalf("got error %v when making new app", err)
+	}
+	app.backends = []backend.Backend{
+		mock.New(mock.Config{
+			Find:   find,
+			Info:   info,
+			Render: render,
+		}),
+	}
+
+	for _, url := range []string{
+		"/render?target=foo.bar&from=1110&until=1111&format=msgpack",
+		"/metrics/find?query=foo.bar&format=msgpack",
+	} {
+		req, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			t.Fatalf("error making request %v", err)
+		}
+
+		w := httptest.NewRecorder()
+		if strings.HasPrefix(url, "/render") {
+			app.renderHandler(w, req)
+		} else {
+			app.findHandler(w, req)
+		}
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: got code %d expected %d", url, w.Code, http.StatusOK)
+		}
+		if ct := w.Header().Get("Content-Type"); ct != "application/x-msgpack" {
+			t.Errorf("%s: got content type %s expected application/x-msgpack", url, ct)
+		}
+	}
+}
+
+func TestRenderMaxDataPoints(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	defer logger.Sync()
+
+	app, err := New(cfg.DefaultZipperConfig(), logger, "test")
+	if err != nil {
+		t.Fatalf("got error %v when making new app", err)
+	}
+	app.backends = []backend.Backend{
+		mock.New(mock.Config{
+			Find: find,
+			Info: info,
+			Render: func(ctx context.Context, request types.RenderRequest) ([]types.Metric, error) {
+				if request.MaxDataPoints != 0 {
+					t.Errorf("expected backends to be asked for raw points, got maxDataPoints %d", request.MaxDataPoints)
+				}
+				return []types.Metric{{
+					Name:      "foo.bar",
+					StartTime: 0,
+					StopTime:  360,
+					StepTime:  60,
+					Values:    []float64{1, 2, 3, 0, 5, 6},
+					IsAbsent:  []bool{false, false, false, true, false, false},
+				}}, nil
+			},
+		}),
+	}
+
+	req, err := http.NewRequest("GET", "/render?target=foo.bar&from=0&until=360&format=protobuf&maxDataPoints=3", nil)
+	if err != nil {
+		t.Fatalf("error making request %v", err)
+	}
+	w := httptest.NewRecorder()
+	app.renderHandler(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("got code %d expected %d", w.Code, http.StatusOK)
+	}
+
+	metrics, err := carbonapi_v2.RenderDecoder(w.Body.Bytes())
+	if err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(metrics) != 1 {
+		t.Fatalf("got %d metrics expected 1", len(metrics))
+	}
+	// the buckets of graphite-web start at the multiple of 120 after the start
+	// with the value before, the first value is dropped
+	if m := metrics[0]; m.StartTime != 120 || m.StepTime != 120 || len(m.Values) != 3 || m.Values[0] != 2.5 || m.Values[1] != 5 || m.Values[2] != 6 {
+		t.Errorf("got start %d, step %d and values %v expected start 120, step 120 and values [2.5 5 6]", m.StartTime, m.StepTime, m.Values)
+	}
+}
+
 func TestRenderSingleGenericBackendError(t *testing.T) {
 	logger, _ := zap.NewDevelopment()
 	defer logger.Sync()
@@ -237,6 +332,9 @@ func TestRenderMultipleBackendsSomeErrors(t *testing.T) {
 	if w.Code != http.StatusOK {
 		t.Fatalf("got code %d expected %d", w.Code, http.StatusOK)
 	}
+	if w.Header().Get(types.PartialResponseHeader) == "" {
+		t.Errorf("expected the response to be marked partial")
+	}
 }
 
 func TestRenderMultipleBackendsAllNotfoundErrors(t *testing.T) {
@@ -0,0 +1,55 @@
+package cache
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrNotSupported is returned by operations a cache can't do.
+var ErrNotSupported = errors.New("cache: operation not supported")
+
+// EntryInfo describes a cache entry.
+type EntryInfo struct {
+	Key  string
+	Size int
+	// Age is the time since the entry was stored, zero if unknown
+	Age time.Duration
+	// TTL is the time until the entry expires, zero if unknown
+	TTL time.Duration
+}
+
+// AdminCache is a cache that can be inspected and purged.
+// Operations a cache can't do return ErrNotSupported.
+type AdminCache interface {
+	BytesCache
+
+	// Stat returns information about the entry of the key.
+	Stat(k string) (EntryInfo, error)
+	// Delete deletes the entry of the key.
+	Delete(k string) error
+	// DeleteMatching deletes entries with keys matched by match and returns their number.
+	DeleteMatching(match func(k string) bool) (int, error)
+	// Flush deletes all entries.
+	Flush() error
+	// Entries returns information about all entries, largest first.
+	Entries() ([]EntryInfo, error)
+}
+
+// Admin returns c as an AdminCache. Caches that aren't return ErrNotSupported
+// for all admin operations.
+func Admin(c BytesCache) AdminCache {
+	if a, ok := c.(AdminCache); ok {
+		return a
+	}
+	return unsupported{c}
+}
+
+type unsupported struct {
+	BytesCache
+}
+
+func (unsupported) Stat(string) (EntryInfo, error)                { return EntryInfo{}, ErrNotSupported }
+func (unsupported) Delete(string) error                           { return ErrNotSupported }
+func (unsupported) DeleteMatching(func(string) bool) (int, error) { return 0, ErrNotSupported }
+func (unsupported) Flush() error                                  { return ErrNotSupported }
+func (unsupported) Entries() ([]EntryInfo, error)                 { return nil, ErrNotSupported }
@@ -9,8 +9,6 @@ import (
 	"time"
 
 	"github.com/bradfitz/gomemcache/memcache"
-
-	"github.com/dgryski/go-expirecache"
 )
 
 var (
@@ -23,38 +21,21 @@ type BytesCache interface {
 	Set(k string, v []byte, expire int32)
 }
 
-type NullCache struct{}
-
-func (NullCache) Get(string) ([]byte, error) { return nil, ErrNotFound }
-func (NullCache) Set(string, []byte, int32)  {}
-
-func NewExpireCache(maxsize uint64) BytesCache {
-	ec := expirecache.New(maxsize)
-	go ec.ApproximateCleaner(10 * time.Second)
-	return &ExpireCache{ec: ec}
-}
-
-type ExpireCache struct {
-	ec *expirecache.Cache
-}
-
-func (ec ExpireCache) Get(k string) ([]byte, error) {
-	v, ok := ec.ec.Get(k)
-
-	if !ok {
-		return nil, ErrNotFound
-	}
-
-	return v.([]byte), nil
-}
-
-func (ec ExpireCache) Set(k string, v []byte, expire int32) {
-	ec.ec.Set(k, v, uint64(len(v)), expire)
+// MultiGetter is implemented by caches that get values of several keys in one round trip.
+// Keys that aren't found are missing in the result.
+type MultiGetter interface {
+	GetMulti(keys []string) (map[string][]byte, error)
 }
 
-func (ec ExpireCache) Items() int { return ec.ec.Items() }
+type NullCache struct{}
 
-func (ec ExpireCache) Size() uint64 { return ec.ec.Size() }
+func (NullCache) Get(string) ([]byte, error)                    { return nil, ErrNotFound }
+func (NullCache) Set(string, []byte, int32)                     {}
+func (NullCache) Stat(string) (EntryInfo, error)                { return EntryInfo{}, ErrNotFound }
+func (NullCache) Delete(string) error                           { return ErrNotFound }
+func (NullCache) DeleteMatching(func(string) bool) (int, error) { return 0, nil }
+func (NullCache) Flush() error                                  { return nil }
+func (NullCache) Entries() ([]EntryInfo, error)                 { return nil, nil }
 
 func NewMemcached(prefix string, timeoutMs uint64, servers ...string) BytesCache {
 	return &MemcachedCache{
@@ -118,6 +99,42 @@ func (m *MemcachedCache) Timeouts() uint64 {
 	return atomic.LoadUint64(&m.timeouts)
 }
 
+// Stat returns the size of the entry of the key, memcached doesn't tell its age and TTL.
+func (m *MemcachedCache) Stat(k string) (EntryInfo, error) {
+	v, err := m.Get(k)
+	if err != nil {
+		return EntryInfo{}, err
+	}
+	return EntryInfo{Key: k, Size: len(v)}, nil
+}
+
+// Delete deletes the entry of the key.
+func (m *MemcachedCache) Delete(k string) error {
+	key := sha1.Sum([]byte(k))
+	hk := hex.EncodeToString(key[:])
+	err := m.client.Delete(m.prefix + hk)
+	if err == memcache.ErrCacheMiss {
+		return ErrNotFound
+	}
+	return err
+}
+
+// DeleteMatching isn't supported, as keys are hashed.
+func (m *MemcachedCache) DeleteMatching(func(string) bool) (int, error) {
+	return 0, ErrNotSupported
+}
+
+// Flush isn't supported, as memcached can only flush all entries of the
+// servers, including ones of other caches and prefixes.
+func (m *MemcachedCache) Flush() error {
+	return ErrNotSupported
+}
+
+// Entries isn't supported, as memcached can't list keys.
+func (m *MemcachedCache) Entries() ([]EntryInfo, error) {
+	return nil, ErrNotSupported
+}
+
 // ReplicatedMemcached represents the caching setup when all the memcached instances
 // are identical. Each read and write refers to all of them.
 type ReplicatedMemcached struct {
@@ -131,6 +148,7 @@ type ReplicatedMemcached struct {
 type Cache interface {
 	Get(string) (*memcache.Item, error)
 	Set(*memcache.Item) error
+	Delete(string) error
 }
 
 // NewReplicatedMemcached creates a set of identical memcached instances.
@@ -206,6 +224,48 @@ type cacheResponse struct {
 	err   error
 }
 
+// Stat returns the size of the entry of the key, memcached doesn't tell its age and TTL.
+func (rm *ReplicatedMemcached) Stat(k string) (EntryInfo, error) {
+	v, err := rm.Get(k)
+	if err != nil {
+		return EntryInfo{}, err
+	}
+	return EntryInfo{Key: k, Size: len(v)}, nil
+}
+
+// Delete deletes the entry of the key from all cache instances.
+func (rm *ReplicatedMemcached) Delete(k string) error {
+	key := sha1.Sum([]byte(k))
+	hk := hex.EncodeToString(key[:])
+
+	err := ErrNotFound
+	for _, m := range rm.instances {
+		switch e := m.Delete(rm.prefix + hk); {
+		case e == nil:
+			err = nil
+		case e != memcache.ErrCacheMiss && err == ErrNotFound:
+			err = e
+		}
+	}
+	return err
+}
+
+// DeleteMatching isn't supported, as keys are hashed.
+func (rm *ReplicatedMemcached) DeleteMatching(func(string) bool) (int, error) {
+	return 0, ErrNotSupported
+}
+
+// Flush isn't supported, as memcached can only flush all entries of the
+// servers, including ones of other caches and prefixes.
+func (rm *ReplicatedMemcached) Flush() error {
+	return ErrNotSupported
+}
+
+// Entries isn't supported, as memcached can't list keys.
+func (rm *ReplicatedMemcached) Entries() ([]EntryInfo, error) {
+	return nil, ErrNotSupported
+}
+
 func getFromReplica(m Cache, k string, prefix string, res chan<- cacheResponse) {
 	key := sha1.Sum([]byte(k))
 	hk := hex.EncodeToString(key[:])
@@ -222,3 +282,120 @@ func getFromReplica(m Cache, k string, prefix string, res chan<- cacheResponse)
 
 	res <- cacheResponse{found: true, data: item.Value}
 }
+
+// TieredCache is a local in-process cache in front of a remote one, usually memcached.
+// Writes go to both tiers, values found only in the remote tier are promoted to the local one.
+type TieredCache struct {
+	local  BytesCache
+	remote BytesCache
+	// localExpire caps expiration of values in the local tier, so that
+	// it doesn't serve values that were overwritten in the remote tier for long.
+	// 0 means no cap
+	localExpire int32
+
+	localHits  uint64
+	remoteHits uint64
+	misses     uint64
+}
+
+// NewTiered creates a cache with local in front of remote.
+// Values are kept in the local tier for at most localExpire seconds. If it's 0,
+// values are kept as long as in the remote tier, which is only known for values
+// written through, so values found only in the remote tier aren't promoted.
+func NewTiered(local, remote BytesCache, localExpire int32) *TieredCache {
+	return &TieredCache{
+		local:       local,
+		remote:      remote,
+		localExpire: localExpire,
+	}
+}
+
+// Get gets the value from the local tier, then from the remote tier.
+func (t *TieredCache) Get(k string) ([]byte, error) {
+	if v, err := t.local.Get(k); err == nil {
+		atomic.AddUint64(&t.localHits, 1)
+		return v, nil
+	}
+
+	v, err := t.remote.Get(k)
+	if err != nil {
+		atomic.AddUint64(&t.misses, 1)
+		return nil, err
+	}
+
+	atomic.AddUint64(&t.remoteHits, 1)
+	if t.localExpire > 0 {
+		t.local.Set(k, v, t.localExpire)
+	}
+	return v, nil
+}
+
+// Set writes the value through to both tiers.
+func (t *TieredCache) Set(k string, v []byte, expire int32) {
+	local := expire
+	if t.localExpire > 0 && (local <= 0 || local > t.localExpire) {
+		local = t.localExpire
+	}
+	t.local.Set(k, v, local)
+	t.remote.Set(k, v, expire)
+}
+
+// LocalHits returns number of values found in the local tier.
+func (t *TieredCache) LocalHits() uint64 {
+	return atomic.LoadUint64(&t.localHits)
+}
+
+// RemoteHits returns number of values found only in the remote tier.
+func (t *TieredCache) RemoteHits() uint64 {
+	return atomic.LoadUint64(&t.remoteHits)
+}
+
+// Misses returns number of values found in neither tier, including remote errors.
+func (t *TieredCache) Misses() uint64 {
+	return atomic.LoadUint64(&t.misses)
+}
+
+// Stat returns information about the entry of the key in the local tier,
+// or the remote one if it isn't there.
+func (t *TieredCache) Stat(k string) (EntryInfo, error) {
+	if info, err := Admin(t.local).Stat(k); err == nil {
+		return info, nil
+	}
+	return Admin(t.remote).Stat(k)
+}
+
+// Delete deletes the entry of the key from both tiers.
+func (t *TieredCache) Delete(k string) error {
+	localErr := Admin(t.local).Delete(k)
+	remoteErr := Admin(t.remote).Delete(k)
+	if localErr == nil || remoteErr == nil {
+		return nil
+	}
+	return remoteErr
+}
+
+// DeleteMatching deletes matching entries from both tiers. It fails if the
+// remote tier can't do it, as deleted entries would be promoted back.
+func (t *TieredCache) DeleteMatching(match func(string) bool) (int, error) {
+	n, err := Admin(t.remote).DeleteMatching(match)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := Admin(t.local).DeleteMatching(match); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// Flush deletes all entries from both tiers.
+func (t *TieredCache) Flush() error {
+	if err := Admin(t.remote).Flush(); err != nil {
+		return err
+	}
+	return Admin(t.local).Flush()
+}
+
+// Entries returns information about entries of the remote tier, which has all of them.
+func (t *TieredCache) Entries() ([]EntryInfo, error) {
+	return Admin(t.remote).Entries()
+}
@@ -33,6 +33,14 @@ func (m *TestMemcache) Set(i *memcache.Item) error {
 	return nil
 }
 
+func (m *TestMemcache) Delete(k string) error {
+	if _, there := m.data[k]; !there {
+		return memcache.ErrCacheMiss
+	}
+	delete(m.data, k)
+	return nil
+}
+
 func TestReplicatedMemcacheWithPartialTimeout(t *testing.T) {
 	m := ReplicatedMemcached{
 		prefix:    "test",
@@ -97,3 +105,146 @@ func TestReplicatedMemcacheTimeout(t *testing.T) {
 		t.Fatalf("Expected timeout, got val %v, err %v", aRes, err)
 	}
 }
+
+type mapCache struct {
+	data map[string][]byte
+	gets int
+}
+
+func (m *mapCache) Get(k string) ([]byte, error) {
+	m.gets++
+	if v, ok := m.data[k]; ok {
+		return v, nil
+	}
+	return nil, ErrNotFound
+}
+
+func (m *mapCache) Set(k string, v []byte, expire int32) {
+	if m.data == nil {
+		m.data = map[string][]byte{}
+	}
+	m.data[k] = v
+}
+
+func TestReplicatedMemcacheFlush(t *testing.T) {
+	m := ReplicatedMemcached{
+		prefix:    "test",
+		timeoutMs: 20,
+		instances: []Cache{&TestMemcache{}, &TestMemcache{}},
+	}
+	m.Set("a", []byte("aval"), 0)
+
+	// flushing memcached would drop entries of other caches sharing the servers
+	if err := m.Flush(); err != ErrNotSupported {
+		t.Errorf("Expected flush to be unsupported, got err %v", err)
+	}
+	if v, err := m.Get("a"); err != nil || string(v) != "aval" {
+		t.Errorf("Expected a to stay cached, got %q, err %v", v, err)
+	}
+}
+
+func TestTieredCache(t *testing.T) {
+	local := &mapCache{}
+	remote := &mapCache{}
+	c := NewTiered(local, remote, 60)
+
+	c.Set("a", []byte("aval"), 600)
+	if !cmp.Equal(local.data["a"], []byte("aval")) || !cmp.Equal(remote.data["a"], []byte("aval")) {
+		t.Fatalf("Expected write through to both tiers, got local %v and remote %v", local.data, remote.data)
+	}
+
+	res, err := c.Get("a")
+	if err != nil || !cmp.Equal(res, []byte("aval")) {
+		t.Fatalf("Expected aval, got %v and err %v", res, err)
+	}
+	if remote.gets != 0 {
+		t.Errorf("Expected local hit not to query the remote tier, got %d gets", remote.gets)
+	}
+
+	// only in the remote tier, e.g. written by another instance
+	remote.Set("b", []byte("bval"), 600)
+	res, err = c.Get("b")
+	if err != nil || !cmp.Equal(res, []byte("bval")) {
+		t.Fatalf("Expected bval, got %v and err %v", res, err)
+	}
+	if !cmp.Equal(local.data["b"], []byte("bval")) {
+		t.Errorf("Expected remote hit to be promoted to the local tier")
+	}
+
+	if _, err := c.Get("x"); err != ErrNotFound {
+		t.Errorf("Expected cache miss, got err %v", err)
+	}
+
+	if c.LocalHits() != 1 || c.RemoteHits() != 1 || c.Misses() != 1 {
+		t.Errorf("Expected 1 local hit, 1 remote hit and 1 miss, got %d, %d and %d",
+			c.LocalHits(), c.RemoteHits(), c.Misses())
+	}
+}
+
+func TestTieredCacheLocalExpiration(t *testing.T) {
+	local := NewExpireCache(0)
+	remote := &mapCache{}
+	c := NewTiered(local, remote, 1)
+
+	c.Set("a", []byte("aval"), 0)
+	if _, err := local.Get("a"); err != nil {
+		t.Fatalf("Expected value without expiration to be kept in the local tier, got %v", err)
+	}
+
+	time.Sleep(1100 * time.Millisecond)
+	if _, err := local.Get("a"); err != ErrNotFound {
+		t.Errorf("Expected value to expire from the local tier, got %v", err)
+	}
+	if _, err := c.Get("a"); err != nil {
+		t.Errorf("Expected value from the remote tier, got %v", err)
+	}
+}
+
+func TestTieredCacheNoLocalCap(t *testing.T) {
+	local := NewExpireCache(0)
+	remote := &mapCache{}
+	c := NewTiered(local, remote, 0)
+
+	c.Set("a", []byte("aval"), 600)
+	if _, err := local.Get("a"); err != nil {
+		t.Fatalf("Expected value to be kept in the local tier for the expiration of the caller, got %v", err)
+	}
+
+	// only in the remote tier, e.g. written by another instance
+	remote.Set("b", []byte("bval"), 600)
+	for i := 0; i < 2; i++ {
+		res, err := c.Get("b")
+		if err != nil || !cmp.Equal(res, []byte("bval")) {
+			t.Fatalf("Expected bval, got %v and err %v", res, err)
+		}
+	}
+	if _, err := local.Get("b"); err != ErrNotFound {
+		t.Errorf("Expected value of unknown expiration not to be promoted, got %v", err)
+	}
+	if remote.gets != 2 {
+		t.Errorf("Expected both gets to query the remote tier, got %d gets", remote.gets)
+	}
+}
+
+func TestTieredCacheDelete(t *testing.T) {
+	local := NewExpireCache(0)
+	remote := NewExpireCache(0)
+	c := NewTiered(local, remote, 60)
+
+	c.Set("a", []byte("aval"), 600)
+	if err := c.Delete("a"); err != nil {
+		t.Fatalf("Expected a to be deleted, got err %v", err)
+	}
+	if _, err := local.Get("a"); err != ErrNotFound {
+		t.Errorf("Expected a to be deleted from the local tier, got err %v", err)
+	}
+	if _, err := remote.Get("a"); err != ErrNotFound {
+		t.Errorf("Expected a to be deleted from the remote tier, got err %v", err)
+	}
+
+	// the remote tier can't list its keys
+	c = NewTiered(local, &mapCache{}, 60)
+	if _, err := c.DeleteMatching(func(string) bool { return true }); err != ErrNotSupported {
+		t.Errorf("Expected purge by match to be unsupported, got err %v", err)
+	}
+}
@@ -0,0 +1,203 @@
+package cache
+
+import (
+	"errors"
+	"sync/atomic"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression algorithms of CompressedCache
+const (
+	CompressionNone   = "none"
+	CompressionSnappy = "snappy"
+	CompressionZstd   = "zstd"
+)
+
+// Entries of CompressedCache start with a byte telling how the value is stored,
+// so that entries written with another algorithm can still be read.
+const (
+	formatRaw byte = iota
+	formatSnappy
+	formatZstd
+)
+
+var errBadEntry = errors.New("cache: bad entry")
+
+// CompressedCache compresses values stored in another cache and skips
+// values too large to be worth caching.
+type CompressedCache struct {
+	cache       BytesCache
+	compression string
+	// maxSize is the maximum size of a stored entry, 0 means no limit
+	maxSize int
+
+	zenc *zstd.Encoder
+	zdec *zstd.Decoder
+
+	uncompressedBytes uint64
+	compressedBytes   uint64
+	skipped           uint64
+	decodeErrors      uint64
+}
+
+// NewCompressed creates a cache compressing values stored in c with the given algorithm.
+// Entries larger than maxSize bytes after compression are not stored, 0 means no limit.
+func NewCompressed(c BytesCache, compression string, maxSize int) (*CompressedCache, error) {
+	cc := &CompressedCache{
+		cache:       c,
+		compression: compression,
+		maxSize:     maxSize,
+	}
+
+	var err error
+	switch compression {
+	case CompressionNone, "", CompressionSnappy:
+	case CompressionZstd:
+		if cc.zenc, err = zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedFastest)); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.New("cache: unknown compression " + compression)
+	}
+
+	// entries written with any algorithm can be read
+	if cc.zdec, err = zstd.NewReader(nil); err != nil {
+		return nil, err
+	}
+
+	return cc, nil
+}
+
+// Get gets and decompresses the value.
+func (c *CompressedCache) Get(k string) ([]byte, error) {
+	v, err := c.cache.Get(k)
+	if err != nil {
+		return nil, err
+	}
+	return c.decode(v)
+}
+
+// decode decodes the entry, counting entries that can't be decoded, like
+// ones written without CompressedCache.
+func (c *CompressedCache) decode(v []byte) ([]byte, error) {
+	res, err := c.decodeEntry(v)
+	if err != nil {
+		atomic.AddUint64(&c.decodeErrors, 1)
+		return nil, err
+	}
+	return res, nil
+}
+
+func (c *CompressedCache) decodeEntry(v []byte) ([]byte, error) {
+	if len(v) == 0 {
+		return nil, errBadEntry
+	}
+
+	switch v[0] {
+	case formatRaw:
+		return v[1:], nil
+	case formatSnappy:
+		return snappy.Decode(nil, v[1:])
+	case formatZstd:
+		return c.zdec.DecodeAll(v[1:], nil)
+	}
+	return nil, errBadEntry
+}
+
+// GetMulti gets and decompresses values of several keys, in one round trip
+// if the underlying cache supports it. Keys that aren't found are missing in the result.
+func (c *CompressedCache) GetMulti(keys []string) (map[string][]byte, error) {
+	res := make(map[string][]byte, len(keys))
+
+	mg, ok := c.cache.(MultiGetter)
+	if !ok {
+		for _, k := range keys {
+			if v, err := c.Get(k); err == nil {
+				res[k] = v
+			}
+		}
+		return res, nil
+	}
+
+	entries, err := mg.GetMulti(keys)
+	if err != nil {
+		return nil, err
+	}
+	for k, e := range entries {
+		if v, err := c.decode(e); err == nil {
+			res[k] = v
+		}
+	}
+	return res, nil
+}
+
+// Set compresses and stores the value, unless it is larger than the maximum size.
+// Bytes of values that aren't stored aren't counted as written.
+func (c *CompressedCache) Set(k string, v []byte, expire int32) {
+	var entry []byte
+	switch c.compression {
+	case CompressionSnappy:
+		entry = append([]byte{formatSnappy}, snappy.Encode(nil, v)...)
+	case CompressionZstd:
+		entry = c.zenc.EncodeAll(v, []byte{formatZstd})
+	default:
+		entry = append([]byte{formatRaw}, v...)
+	}
+
+	if c.maxSize > 0 && len(entry) > c.maxSize {
+		atomic.AddUint64(&c.skipped, 1)
+		return
+	}
+
+	atomic.AddUint64(&c.uncompressedBytes, uint64(len(v)))
+	atomic.AddUint64(&c.compressedBytes, uint64(len(entry)))
+	c.cache.Set(k, entry, expire)
+}
+
+// UncompressedBytes returns number of bytes of stored values before compression.
+func (c *CompressedCache) UncompressedBytes() uint64 {
+	return atomic.LoadUint64(&c.uncompressedBytes)
+}
+
+// CompressedBytes returns number of bytes of stored entries after compression.
+func (c *CompressedCache) CompressedBytes() uint64 {
+	return atomic.LoadUint64(&c.compressedBytes)
+}
+
+// Skipped returns number of values not stored for being too large.
+func (c *CompressedCache) Skipped() uint64 {
+	return atomic.LoadUint64(&c.skipped)
+}
+
+// DecodeErrors returns number of entries found that couldn't be decoded,
+// they are treated as misses.
+func (c *CompressedCache) DecodeErrors() uint64 {
+	return atomic.LoadUint64(&c.decodeErrors)
+}
+
+// Stat returns information about the entry of the key, with its compressed size.
+func (c *CompressedCache) Stat(k string) (EntryInfo, error) {
+	return Admin(c.cache).Stat(k)
+}
+
+// Delete deletes the entry of the key.
+func (c *CompressedCache) Delete(k string) error {
+	return Admin(c.cache).Delete(k)
+}
+
+// DeleteMatching deletes entries with keys matched by match.
+func (c *CompressedCache) DeleteMatching(match func(string) bool) (int, error) {
+	return Admin(c.cache).DeleteMatching(match)
+}
+
+// Flush deletes all entries.
+func (c *CompressedCache) Flush() error {
+	return Admin(c.cache).Flush()
+}
+
+// Entries returns information about all entries, with their compressed sizes.
+func (c *CompressedCache) Entries() ([]EntryInfo, error) {
+	return Admin(c.cache).Entries()
+}
@@ -0,0 +1,136 @@
+package cache
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCompressedCache(t *testing.T) {
+	value := bytes.Repeat([]byte("a.b.c 1 2 3 "), 100)
+
+	for _, compression := range []string{CompressionNone, CompressionSnappy, CompressionZstd} {
+		t.Run(compression, func(t *testing.T) {
+			inner := &mapCache{}
+			c, err := NewCompressed(inner, compression, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			c.Set("a", value, 60)
+			res, err := c.Get("a")
+			if err != nil {
+				t.Fatalf("Error while getting value for key %s; %v", "a", err)
+			}
+			if !cmp.Equal(res, value) {
+				t.Fatalf("Expected %s value for key %s, got %s", value, "a", res)
+			}
+
+			if c.UncompressedBytes() != uint64(len(value)) {
+				t.Errorf("Expected %d uncompressed bytes, got %d", len(value), c.UncompressedBytes())
+			}
+			if c.CompressedBytes() != uint64(len(inner.data["a"])) {
+				t.Errorf("Expected %d compressed bytes, got %d", len(inner.data["a"]), c.CompressedBytes())
+			}
+			if compression != CompressionNone && c.CompressedBytes() >= c.UncompressedBytes() {
+				t.Errorf("Expected value to be compressed, got %d bytes", c.CompressedBytes())
+			}
+
+			if xRes, err := c.Get("x"); err != ErrNotFound {
+				t.Fatalf("Expected cache miss, got %v and err %v instead", xRes, err)
+			}
+		})
+	}
+}
+
+func TestCompressedCacheChangedCompression(t *testing.T) {
+	inner := &mapCache{}
+	value := []byte("aval")
+
+	for _, compression := range []string{CompressionNone, CompressionSnappy, CompressionZstd} {
+		w, err := NewCompressed(inner, compression, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Set(compression, value, 60)
+	}
+
+	c, err := NewCompressed(inner, CompressionSnappy, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, compression := range []string{CompressionNone, CompressionSnappy, CompressionZstd} {
+		res, err := c.Get(compression)
+		if err != nil || !cmp.Equal(res, value) {
+			t.Errorf("Expected value written with %s to be read, got %v and err %v", compression, res, err)
+		}
+	}
+}
+
+func TestCompressedCacheMaxSize(t *testing.T) {
+	inner := &mapCache{}
+	c, err := NewCompressed(inner, CompressionNone, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.Set("small", []byte("aval"), 60)
+	c.Set("large", bytes.Repeat([]byte("a"), 100), 60)
+
+	if _, err := c.Get("small"); err != nil {
+		t.Errorf("Expected small value to be cached, got err %v", err)
+	}
+	if _, ok := inner.data["large"]; ok {
+		t.Errorf("Expected large value not to be cached")
+	}
+	if c.Skipped() != 1 {
+		t.Errorf("Expected 1 skipped value, got %d", c.Skipped())
+	}
+}
+
+func TestCompressedCacheDecodeErrors(t *testing.T) {
+	inner := &mapCache{}
+	c, err := NewCompressed(inner, CompressionSnappy, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// written before compression was enabled
+	inner.Set("a", []byte(`{"a":1}`), 60)
+
+	if res, err := c.Get("a"); err == nil {
+		t.Errorf("Expected entry without header not to be decoded, got %s", res)
+	}
+	if res, err := c.GetMulti([]string{"a"}); err != nil || len(res) != 0 {
+		t.Errorf("Expected entry without header to be missing, got %v and err %v", res, err)
+	}
+	if c.DecodeErrors() != 2 {
+		t.Errorf("Expected 2 decode errors, got %d", c.DecodeErrors())
+	}
+}
+
+func TestCompressedCacheGetMulti(t *testing.T) {
+	c, err := NewCompressed(&mapCache{}, CompressionZstd, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.Set("a", []byte("aval"), 60)
+	c.Set("b", []byte("bval"), 60)
+
+	res, err := c.GetMulti([]string{"a", "x", "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string][]byte{"a": []byte("aval"), "b": []byte("bval")}
+	if !cmp.Equal(res, expected) {
+		t.Errorf("Expected %v, got %v", expected, res)
+	}
+}
+
+func TestNewCompressedUnknown(t *testing.T) {
+	if _, err := NewCompressed(&mapCache{}, "lz4", 0); err == nil {
+		t.Error("Expected error for unknown compression")
+	}
+}
@@ -0,0 +1,217 @@
+package cache
+
+import (
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// timeNow is replaced in tests
+var timeNow = time.Now
+
+// NewExpireCache creates an in-memory cache of at most maxsize bytes, 0 means no limit.
+// When full, random entries are evicted.
+func NewExpireCache(maxsize uint64) BytesCache {
+	ec := &ExpireCache{
+		entries: make(map[string]*expireEntry),
+		maxSize: maxsize,
+	}
+	go ec.cleaner(10 * time.Second)
+	return ec
+}
+
+type expireEntry struct {
+	value      []byte
+	created    time.Time
+	validUntil time.Time
+	// slot is the index of the key in keys
+	slot int
+}
+
+// ExpireCache is an in-memory cache with expiration of entries.
+type ExpireCache struct {
+	mu      sync.RWMutex
+	entries map[string]*expireEntry
+	// keys are kept in a slice for random eviction
+	keys    []string
+	size    uint64
+	maxSize uint64
+}
+
+func (ec *ExpireCache) Get(k string) ([]byte, error) {
+	ec.mu.RLock()
+	e, ok := ec.entries[k]
+	ec.mu.RUnlock()
+
+	if !ok || e.validUntil.Before(timeNow()) {
+		return nil, ErrNotFound
+	}
+
+	return e.value, nil
+}
+
+func (ec *ExpireCache) Set(k string, v []byte, expire int32) {
+	now := timeNow()
+
+	ec.mu.Lock()
+	defer ec.mu.Unlock()
+
+	ec.delete(k)
+	ec.entries[k] = &expireEntry{
+		value:      v,
+		created:    now,
+		validUntil: now.Add(time.Duration(expire) * time.Second),
+		slot:       len(ec.keys),
+	}
+	ec.keys = append(ec.keys, k)
+	ec.size += uint64(len(v))
+
+	for ec.maxSize > 0 && ec.size > ec.maxSize {
+		ec.delete(ec.keys[rand.Intn(len(ec.keys))])
+	}
+}
+
+// delete deletes the entry of the key, ec.mu must be held.
+func (ec *ExpireCache) delete(k string) bool {
+	e, ok := ec.entries[k]
+	if !ok {
+		return false
+	}
+
+	last := ec.keys[len(ec.keys)-1]
+	ec.keys[e.slot] = last
+	ec.entries[last].slot = e.slot
+	ec.keys = ec.keys[:len(ec.keys)-1]
+
+	ec.size -= uint64(len(e.value))
+	delete(ec.entries, k)
+	return true
+}
+
+// cleaner periodically deletes expired entries. Every round it samples
+// entries and repeats while enough of them are expired, so that the lock
+// is held only shortly.
+func (ec *ExpireCache) cleaner(d time.Duration) {
+	// every iteration, sample and clean this many items
+	const sampleSize = 20
+	// if we cleaned at least this many, run the loop again
+	const rerunCount = 5
+
+	for {
+		time.Sleep(d)
+
+		for cleaned := rerunCount; cleaned >= rerunCount; {
+			cleaned = 0
+			now := timeNow()
+
+			ec.mu.Lock()
+			for i := 0; len(ec.keys) > 0 && i < sampleSize; i++ {
+				k := ec.keys[rand.Intn(len(ec.keys))]
+				if ec.entries[k].validUntil.Before(now) {
+					ec.delete(k)
+					cleaned++
+				}
+			}
+			ec.mu.Unlock()
+		}
+	}
+}
+
+// Items returns number of entries, including expired ones not cleaned yet.
+func (ec *ExpireCache) Items() int {
+	ec.mu.RLock()
+	defer ec.mu.RUnlock()
+	return len(ec.keys)
+}
+
+// Size returns size of the values in bytes.
+func (ec *ExpireCache) Size() uint64 {
+	ec.mu.RLock()
+	defer ec.mu.RUnlock()
+	return ec.size
+}
+
+// Stat returns information about the entry of the key.
+func (ec *ExpireCache) Stat(k string) (EntryInfo, error) {
+	now := timeNow()
+
+	ec.mu.RLock()
+	defer ec.mu.RUnlock()
+
+	e, ok := ec.entries[k]
+	if !ok || e.validUntil.Before(now) {
+		return EntryInfo{}, ErrNotFound
+	}
+	return e.info(k, now), nil
+}
+
+// Delete deletes the entry of the key.
+func (ec *ExpireCache) Delete(k string) error {
+	ec.mu.Lock()
+	defer ec.mu.Unlock()
+
+	if !ec.delete(k) {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// DeleteMatching deletes entries with keys matched by match.
+func (ec *ExpireCache) DeleteMatching(match func(k string) bool) (int, error) {
+	ec.mu.Lock()
+	defer ec.mu.Unlock()
+
+	var deleted []string
+	for k := range ec.entries {
+		if match(k) {
+			deleted = append(deleted, k)
+		}
+	}
+	for _, k := range deleted {
+		ec.delete(k)
+	}
+	return len(deleted), nil
+}
+
+// Flush deletes all entries.
+func (ec *ExpireCache) Flush() error {
+	ec.mu.Lock()
+	defer ec.mu.Unlock()
+
+	ec.entries = make(map[string]*expireEntry)
+	ec.keys = nil
+	ec.size = 0
+	return nil
+}
+
+// Entries returns information about all entries, largest first.
+func (ec *ExpireCache) Entries() ([]EntryInfo, error) {
+	now := timeNow()
+
+	ec.mu.RLock()
+	res := make([]EntryInfo, 0, len(ec.entries))
+	for k, e := range ec.entries {
+		if !e.validUntil.Before(now) {
+			res = append(res, e.info(k, now))
+		}
+	}
+	ec.mu.RUnlock()
+
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Size != res[j].Size {
+			return res[i].Size > res[j].Size
+		}
+		return res[i].Key < res[j].Key
+	})
+	return res, nil
+}
+
+func (e *expireEntry) info(k string, now time.Time) EntryInfo {
+	return EntryInfo{
+		Key:  k,
+		Size: len(e.value),
+		Age:  now.Sub(e.created),
+		TTL:  e.validUntil.Sub(now),
+	}
+}
@@ -0,0 +1,98 @@
+package cache
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestExpireCache(t *testing.T) {
+	now := time.Unix(100000, 0)
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	c := NewExpireCache(0).(*ExpireCache)
+	c.Set("a", []byte("aval"), 60)
+	c.Set("b", []byte("bvalue"), 60)
+
+	if res, err := c.Get("a"); err != nil || !cmp.Equal(res, []byte("aval")) {
+		t.Fatalf("Expected aval, got %v and err %v", res, err)
+	}
+	if c.Items() != 2 || c.Size() != 10 {
+		t.Errorf("Expected 2 items of 10 bytes, got %d items of %d bytes", c.Items(), c.Size())
+	}
+
+	now = now.Add(61 * time.Second)
+	if _, err := c.Get("a"); err != ErrNotFound {
+		t.Errorf("Expected key to expire, got err %v", err)
+	}
+}
+
+func TestExpireCacheMaxSize(t *testing.T) {
+	c := NewExpireCache(10).(*ExpireCache)
+	for _, k := range []string{"a", "b", "c", "d"} {
+		c.Set(k, []byte("1234"), 60)
+	}
+
+	if c.Size() > 10 || c.Items() != 2 {
+		t.Errorf("Expected 2 items within 10 bytes, got %d items of %d bytes", c.Items(), c.Size())
+	}
+}
+
+func TestExpireCacheAdmin(t *testing.T) {
+	now := time.Unix(100000, 0)
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	c := NewExpireCache(0).(*ExpireCache)
+	c.Set("foo.a", []byte("a"), 60)
+	c.Set("foo.b", []byte("bbb"), 60)
+	c.Set("bar.a", []byte("aa"), 60)
+
+	now = now.Add(10 * time.Second)
+	info, err := c.Stat("foo.b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := EntryInfo{Key: "foo.b", Size: 3, Age: 10 * time.Second, TTL: 50 * time.Second}
+	if info != expected {
+		t.Errorf("Expected %+v, got %+v", expected, info)
+	}
+
+	entries, err := c.Entries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	for _, e := range entries {
+		keys = append(keys, e.Key)
+	}
+	if !cmp.Equal(keys, []string{"foo.b", "bar.a", "foo.a"}) {
+		t.Errorf("Expected entries largest first, got %v", keys)
+	}
+
+	n, err := c.DeleteMatching(func(k string) bool { return strings.HasPrefix(k, "foo.") })
+	if err != nil || n != 2 {
+		t.Errorf("Expected 2 entries deleted, got %d and err %v", n, err)
+	}
+	if _, err := c.Get("foo.a"); err != ErrNotFound {
+		t.Errorf("Expected foo.a to be deleted, got err %v", err)
+	}
+
+	if err := c.Delete("bar.a"); err != nil {
+		t.Errorf("Expected bar.a to be deleted, got err %v", err)
+	}
+	if err := c.Delete("bar.a"); err != ErrNotFound {
+		t.Errorf("Expected deleted key not to be found, got err %v", err)
+	}
+
+	c.Set("x", []byte("x"), 60)
+	if err := c.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if c.Items() != 0 || c.Size() != 0 {
+		t.Errorf("Expected empty cache after flush, got %d items of %d bytes", c.Items(), c.Size())
+	}
+}
@@ -0,0 +1,271 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-redis/redis/v7"
+)
+
+// Redis modes
+const (
+	RedisStandalone = "standalone"
+	RedisSentinel   = "sentinel"
+	RedisCluster    = "cluster"
+)
+
+// RedisOptions configures connection to Redis.
+type RedisOptions struct {
+	// Mode is one of standalone, sentinel or cluster
+	Mode string
+	// Addrs are addresses of the server, of sentinels or of cluster nodes
+	Addrs []string
+	// MasterName is the name of the master monitored by sentinels
+	MasterName string
+	Password   string
+	DB         int
+}
+
+// RedisCache is a cache in Redis. Keys are hashed and prefixed like in MemcachedCache.
+type RedisCache struct {
+	prefix         string
+	client         redis.UniversalClient
+	timeouts       *uint64
+	queryTimeoutMs uint64
+}
+
+// NewRedis creates a cache in Redis. Calls time out after timeoutMs.
+func NewRedis(prefix string, timeoutMs uint64, opts RedisOptions) (*RedisCache, error) {
+	timeout := time.Duration(timeoutMs) * time.Millisecond
+
+	var client redis.UniversalClient
+	switch opts.Mode {
+	case RedisStandalone, "":
+		if len(opts.Addrs) != 1 {
+			return nil, errors.New("redis: standalone mode needs exactly one address")
+		}
+		client = redis.NewClient(&redis.Options{
+			Addr:         opts.Addrs[0],
+			Password:     opts.Password,
+			DB:           opts.DB,
+			DialTimeout:  timeout,
+			ReadTimeout:  timeout,
+			WriteTimeout: timeout,
+		})
+	case RedisSentinel:
+		if opts.MasterName == "" {
+			return nil, errors.New("redis: sentinel mode needs master name")
+		}
+		client = redis.NewFailoverClient(&redis.FailoverOptions{
+			MasterName:    opts.MasterName,
+			SentinelAddrs: opts.Addrs,
+			Password:      opts.Password,
+			DB:            opts.DB,
+			DialTimeout:   timeout,
+			ReadTimeout:   timeout,
+			WriteTimeout:  timeout,
+		})
+	case RedisCluster:
+		client = redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs:        opts.Addrs,
+			Password:     opts.Password,
+			DialTimeout:  timeout,
+			ReadTimeout:  timeout,
+			WriteTimeout: timeout,
+		})
+	default:
+		return nil, errors.New("redis: unknown mode " + opts.Mode)
+	}
+
+	return &RedisCache{
+		prefix:         prefix,
+		client:         client,
+		timeouts:       new(uint64),
+		queryTimeoutMs: timeoutMs,
+	}, nil
+}
+
+// WithPrefix returns a cache with prefix appended to the prefix of its keys.
+// It shares the connection and the count of timeouts with r.
+func (r *RedisCache) WithPrefix(prefix string) *RedisCache {
+	c := *r
+	c.prefix = r.prefix + prefix
+	return &c
+}
+
+func (r *RedisCache) key(k string) string {
+	key := sha1.Sum([]byte(k))
+	return r.prefix + hex.EncodeToString(key[:])
+}
+
+func (r *RedisCache) context() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), time.Duration(r.queryTimeoutMs)*time.Millisecond)
+}
+
+// Get gets the value of the key.
+func (r *RedisCache) Get(k string) ([]byte, error) {
+	ctx, cancel := r.context()
+	defer cancel()
+
+	cmd := redis.NewStringCmd("get", r.key(k))
+	if err := r.client.ProcessContext(ctx, cmd); err != nil {
+		return nil, r.translate(err)
+	}
+
+	return cmd.Bytes()
+}
+
+// GetMulti gets values of the keys with a single pipelined request.
+// Keys that aren't found are missing in the result.
+func (r *RedisCache) GetMulti(keys []string) (map[string][]byte, error) {
+	ctx, cancel := r.context()
+	defer cancel()
+
+	pipe := r.client.Pipeline()
+	cmds := make([]*redis.StringCmd, len(keys))
+	for i, k := range keys {
+		cmds[i] = pipe.Get(r.key(k))
+	}
+
+	_, err := pipe.ExecContext(ctx)
+	if err != nil && err != redis.Nil {
+		return nil, r.translate(err)
+	}
+
+	res := make(map[string][]byte, len(keys))
+	for i, cmd := range cmds {
+		if v, err := cmd.Bytes(); err == nil {
+			res[keys[i]] = v
+		}
+	}
+	return res, nil
+}
+
+// Set sets the value of the key in background. Zero expire means no expiration.
+func (r *RedisCache) Set(k string, v []byte, expire int32) {
+	ttl := time.Duration(0)
+	if expire > 0 {
+		ttl = time.Duration(expire) * time.Second
+	}
+
+	go func() {
+		ctx, cancel := r.context()
+		defer cancel()
+
+		cmd := redis.NewStatusCmd("set", r.key(k), v)
+		if ttl > 0 {
+			cmd = redis.NewStatusCmd("set", r.key(k), v, "px", int64(ttl/time.Millisecond))
+		}
+		if err := r.client.ProcessContext(ctx, cmd); err != nil {
+			r.translate(err)
+		}
+	}()
+}
+
+// Timeouts returns number of calls that timed out.
+func (r *RedisCache) Timeouts() uint64 {
+	return atomic.LoadUint64(r.timeouts)
+}
+
+// translate maps Redis errors to errors of the package and counts timeouts.
+func (r *RedisCache) translate(err error) error {
+	if err == redis.Nil {
+		return ErrNotFound
+	}
+
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		atomic.AddUint64(r.timeouts, 1)
+		return ErrTimeout
+	}
+
+	return err
+}
+
+// Stat returns the size and TTL of the entry of the key, Redis doesn't tell its age.
+func (r *RedisCache) Stat(k string) (EntryInfo, error) {
+	ctx, cancel := r.context()
+	defer cancel()
+
+	pipe := r.client.Pipeline()
+	get := pipe.Get(r.key(k))
+	ttl := pipe.PTTL(r.key(k))
+	if _, err := pipe.ExecContext(ctx); err != nil {
+		return EntryInfo{}, r.translate(err)
+	}
+
+	v, _ := get.Bytes()
+	info := EntryInfo{Key: k, Size: len(v)}
+	if d := ttl.Val(); d > 0 {
+		info.TTL = d
+	}
+	return info, nil
+}
+
+// Delete deletes the entry of the key.
+func (r *RedisCache) Delete(k string) error {
+	ctx, cancel := r.context()
+	defer cancel()
+
+	cmd := redis.NewIntCmd("del", r.key(k))
+	if err := r.client.ProcessContext(ctx, cmd); err != nil {
+		return r.translate(err)
+	}
+	if cmd.Val() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// DeleteMatching isn't supported, as keys are hashed.
+func (r *RedisCache) DeleteMatching(func(string) bool) (int, error) {
+	return 0, ErrNotSupported
+}
+
+// Flush deletes all entries with the prefix, on every master in cluster mode.
+func (r *RedisCache) Flush() error {
+	if cc, ok := r.client.(*redis.ClusterClient); ok {
+		return cc.ForEachMaster(func(c *redis.Client) error {
+			return r.flush(c)
+		})
+	}
+	return r.flush(r.client)
+}
+
+// flushBatch is the number of keys scanned and deleted by a round trip of Flush
+const flushBatch = 1000
+
+func (r *RedisCache) flush(c redis.Cmdable) error {
+	iter := c.Scan(0, r.prefix+"*", flushBatch).Iterator()
+	pipe := c.Pipeline()
+	n := 0
+	for iter.Next() {
+		// keys may be in different slots in cluster mode, so they are deleted one by one
+		pipe.Del(iter.Val())
+		n++
+		if n == flushBatch {
+			if _, err := pipe.Exec(); err != nil {
+				return err
+			}
+			n = 0
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+	if n == 0 {
+		return nil
+	}
+	_, err := pipe.Exec()
+	return err
+}
+
+// Entries isn't supported, as keys are hashed.
+func (r *RedisCache) Entries() ([]EntryInfo, error) {
+	return nil, ErrNotSupported
+}
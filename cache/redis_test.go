@@ -0,0 +1,223 @@
+package cache
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/google/go-cmp/cmp"
+)
+
+func newTestRedis(t *testing.T, mode string) (*miniredis.Miniredis, *RedisCache) {
+	s, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.Close)
+
+	c, err := NewRedis("test", 1000, RedisOptions{
+		Mode:  mode,
+		Addrs: []string{s.Addr()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, c
+}
+
+// waitForKeys waits for background sets to reach the server.
+func waitForKeys(t *testing.T, s *miniredis.Miniredis, n int) {
+	for i := 0; i < 100; i++ {
+		if len(s.Keys()) >= n {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("Expected %d keys, got %v", n, s.Keys())
+}
+
+func TestRedisCache(t *testing.T) {
+	for _, mode := range []string{RedisStandalone, RedisCluster} {
+		t.Run(mode, func(t *testing.T) {
+			s, c := newTestRedis(t, mode)
+
+			aData := []byte("aval")
+			c.Set("a", aData, 60)
+			waitForKeys(t, s, 1)
+
+			for _, k := range s.Keys() {
+				if !strings.HasPrefix(k, "test") {
+					t.Errorf("Expected key %s to have prefix test", k)
+				}
+			}
+
+			aRes, err := c.Get("a")
+			if err != nil {
+				t.Fatalf("Error while getting value for key %s; %v", "a", err)
+			}
+			if !cmp.Equal(aRes, aData) {
+				t.Fatalf("Expected %v value for key %s, got %v", aData, "a", aRes)
+			}
+
+			if xRes, err := c.Get("x"); err != ErrNotFound {
+				t.Fatalf("Expected cache miss, got %v and err %v instead", xRes, err)
+			}
+
+			s.FastForward(61 * time.Second)
+			if _, err := c.Get("a"); err != ErrNotFound {
+				t.Fatalf("Expected key to expire, got err %v", err)
+			}
+		})
+	}
+}
+
+func TestRedisCacheNoExpiration(t *testing.T) {
+	s, c := newTestRedis(t, RedisStandalone)
+
+	c.Set("a", []byte("aval"), 0)
+	waitForKeys(t, s, 1)
+
+	if ttl := s.TTL(s.Keys()[0]); ttl != 0 {
+		t.Errorf("Expected no TTL, got %v", ttl)
+	}
+}
+
+func TestRedisCacheGetMulti(t *testing.T) {
+	s, c := newTestRedis(t, RedisStandalone)
+
+	c.Set("a", []byte("aval"), 60)
+	c.Set("b", []byte("bval"), 60)
+	waitForKeys(t, s, 2)
+
+	res, err := c.GetMulti([]string{"a", "x", "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string][]byte{"a": []byte("aval"), "b": []byte("bval")}
+	if !cmp.Equal(res, expected) {
+		t.Errorf("Expected %v, got %v", expected, res)
+	}
+}
+
+func TestRedisCacheTimeout(t *testing.T) {
+	// a server that accepts connections and never answers
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	c, err := NewRedis("test", 20, RedisOptions{Addrs: []string{l.Addr().String()}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t0 := time.Now()
+	if _, err := c.Get("a"); err != ErrTimeout {
+		t.Fatalf("Expected timeout, got %v", err)
+	}
+	if d := time.Since(t0); d > 500*time.Millisecond {
+		t.Errorf("Expected call to time out in about 20ms, took %v", d)
+	}
+	if c.Timeouts() != 1 {
+		t.Errorf("Expected 1 timeout, got %d", c.Timeouts())
+	}
+}
+
+func TestRedisCacheAdmin(t *testing.T) {
+	s, c := newTestRedis(t, RedisStandalone)
+	s.Set("other", "value")
+
+	c.Set("a", []byte("aval"), 60)
+	c.Set("b", []byte("bval"), 60)
+	waitForKeys(t, s, 3)
+
+	info, err := c.Stat("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size != 4 || info.TTL != 60*time.Second {
+		t.Errorf("Expected entry of 4 bytes with TTL 60s, got %+v", info)
+	}
+
+	if err := c.Delete("a"); err != nil {
+		t.Errorf("Expected a to be deleted, got err %v", err)
+	}
+	if err := c.Delete("a"); err != ErrNotFound {
+		t.Errorf("Expected deleted key not to be found, got err %v", err)
+	}
+	if _, err := c.Stat("a"); err != ErrNotFound {
+		t.Errorf("Expected deleted key not to be found, got err %v", err)
+	}
+
+	if err := c.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if keys := s.Keys(); !cmp.Equal(keys, []string{"other"}) {
+		t.Errorf("Expected only keys without the prefix to be left, got %v", keys)
+	}
+}
+
+func TestRedisCacheWithPrefix(t *testing.T) {
+	s, c := newTestRedis(t, RedisStandalone)
+	render, find := c.WithPrefix("render:"), c.WithPrefix("find:")
+
+	render.Set("a", []byte("aval"), 60)
+	find.Set("a", []byte("fval"), 60)
+	waitForKeys(t, s, 2)
+
+	if v, err := find.Get("a"); err != nil || string(v) != "fval" {
+		t.Errorf("Expected fval, got %q, err %v", v, err)
+	}
+
+	if err := render.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := render.Get("a"); err != ErrNotFound {
+		t.Errorf("Expected a to be flushed, got err %v", err)
+	}
+	if v, err := find.Get("a"); err != nil || string(v) != "fval" {
+		t.Errorf("Expected a to stay in the find cache, got %q, err %v", v, err)
+	}
+}
+
+func TestRedisCacheFlushBatches(t *testing.T) {
+	s, c := newTestRedis(t, RedisStandalone)
+	for i := 0; i < 2*flushBatch+1; i++ {
+		s.Set(fmt.Sprintf("test%d", i), "v")
+	}
+	s.Set("other", "value")
+
+	if err := c.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if keys := s.Keys(); !cmp.Equal(keys, []string{"other"}) {
+		t.Errorf("Expected only keys without the prefix to be left, got %d keys", len(keys))
+	}
+}
+
+func TestNewRedisErrors(t *testing.T) {
+	tests := []RedisOptions{
+		{Mode: RedisStandalone},
+		{Mode: RedisSentinel, Addrs: []string{"localhost:26379"}},
+		{Mode: "unknown", Addrs: []string{"localhost:6379"}},
+	}
+
+	for _, opts := range tests {
+		if _, err := NewRedis("test", 10, opts); err == nil {
+			t.Errorf("Expected error for %+v", opts)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package cache
+
+import (
+	"encoding/binary"
+)
+
+// StaleGetter is implemented by caches that keep entries past their expiration.
+type StaleGetter interface {
+	// GetStale gets the value of the key and whether it is past its expiration.
+	GetStale(k string) ([]byte, bool, error)
+}
+
+// staleHeaderSize is the size of the soft expiration time stored before the value
+const staleHeaderSize = 8
+
+// StaleCache keeps entries of another cache for some time after they expire.
+// The expiration passed to Set is a soft one: Get misses entries past it,
+// while GetStale returns them, flagged as stale, until the hard expiration
+// staleSec later.
+type StaleCache struct {
+	cache    BytesCache
+	staleSec int32
+}
+
+// NewStale creates a cache keeping entries stored in c for staleSec after they expire.
+func NewStale(c BytesCache, staleSec int32) *StaleCache {
+	return &StaleCache{
+		cache:    c,
+		staleSec: staleSec,
+	}
+}
+
+// Get gets the value of the key unless it is stale.
+func (c *StaleCache) Get(k string) ([]byte, error) {
+	v, stale, err := c.GetStale(k)
+	if err != nil {
+		return nil, err
+	}
+	if stale {
+		return nil, ErrNotFound
+	}
+	return v, nil
+}
+
+// GetStale gets the value of the key and whether it is past its soft expiration.
+func (c *StaleCache) GetStale(k string) ([]byte, bool, error) {
+	entry, err := c.cache.Get(k)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(entry) < staleHeaderSize {
+		return nil, false, errBadEntry
+	}
+
+	softExpire := int64(binary.BigEndian.Uint64(entry))
+	stale := softExpire > 0 && timeNow().Unix() >= softExpire
+	return entry[staleHeaderSize:], stale, nil
+}
+
+// Set sets the value of the key, stale after expire and dropped staleSec later.
+// Zero expire means no expiration.
+func (c *StaleCache) Set(k string, v []byte, expire int32) {
+	var softExpire int64
+	hardExpire := expire
+	if expire > 0 {
+		softExpire = timeNow().Unix() + int64(expire)
+		hardExpire = expire + c.staleSec
+	}
+
+	entry := make([]byte, staleHeaderSize, staleHeaderSize+len(v))
+	binary.BigEndian.PutUint64(entry, uint64(softExpire))
+	entry = append(entry, v...)
+	c.cache.Set(k, entry, hardExpire)
+}
+
+// Stat returns information about the entry of the key. The TTL is
+// until the hard expiration.
+func (c *StaleCache) Stat(k string) (EntryInfo, error) {
+	return Admin(c.cache).Stat(k)
+}
+
+// Delete deletes the entry of the key.
+func (c *StaleCache) Delete(k string) error {
+	return Admin(c.cache).Delete(k)
+}
+
+// DeleteMatching deletes entries with keys matched by match.
+func (c *StaleCache) DeleteMatching(match func(string) bool) (int, error) {
+	return Admin(c.cache).DeleteMatching(match)
+}
+
+// Flush deletes all entries.
+func (c *StaleCache) Flush() error {
+	return Admin(c.cache).Flush()
+}
+
+// Entries returns information about all entries, largest first.
+func (c *StaleCache) Entries() ([]EntryInfo, error) {
+	return Admin(c.cache).Entries()
+}
@@ -0,0 +1,70 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestStaleCache(t *testing.T) {
+	now := time.Unix(100000, 0)
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	inner := &mapCache{}
+	c := NewStale(inner, 600)
+
+	aData := []byte("aval")
+	c.Set("a", aData, 60)
+
+	res, stale, err := c.GetStale("a")
+	if err != nil || stale || !cmp.Equal(res, aData) {
+		t.Fatalf("Expected fresh %v, got %v, stale %v and err %v", aData, res, stale, err)
+	}
+	if res, err := c.Get("a"); err != nil || !cmp.Equal(res, aData) {
+		t.Fatalf("Expected %v, got %v and err %v", aData, res, err)
+	}
+
+	now = now.Add(61 * time.Second)
+	res, stale, err = c.GetStale("a")
+	if err != nil || !stale || !cmp.Equal(res, aData) {
+		t.Fatalf("Expected stale %v, got %v, stale %v and err %v", aData, res, stale, err)
+	}
+	if _, err := c.Get("a"); err != ErrNotFound {
+		t.Fatalf("Expected stale value to be missed by Get, got err %v", err)
+	}
+
+	if _, _, err := c.GetStale("x"); err != ErrNotFound {
+		t.Fatalf("Expected cache miss, got err %v", err)
+	}
+}
+
+func TestStaleCacheHardExpiration(t *testing.T) {
+	inner := &expireRecorder{}
+	c := NewStale(inner, 600)
+
+	c.Set("a", []byte("aval"), 60)
+	if inner.expire != 660 {
+		t.Errorf("Expected entry to be stored for 660 seconds, got %d", inner.expire)
+	}
+
+	c.Set("b", []byte("bval"), 0)
+	if inner.expire != 0 {
+		t.Errorf("Expected entry without expiration to be stored without expiration, got %d", inner.expire)
+	}
+	if _, stale, err := c.GetStale("b"); stale || err != nil {
+		t.Errorf("Expected entry without expiration never to be stale, got stale %v and err %v", stale, err)
+	}
+}
+
+// expireRecorder is a cache remembering the last expiration it was given.
+type expireRecorder struct {
+	mapCache
+	expire int32
+}
+
+func (r *expireRecorder) Set(k string, v []byte, expire int32) {
+	r.expire = expire
+	r.mapCache.Set(k, v, expire)
+}
@@ -41,8 +41,13 @@ type AccessLogDetails struct {
 	Path                          string            `json:"path,omitempty"`
 	Uri                           string            `json:"uri,omitempty"`
 	FromCache                     bool              `json:"from_cache"`
+	CacheKey                      string            `json:"cache_key,omitempty"`
 	ZipperRequests                int64             `json:"zipper_requests,omitempty"`
 	TotalMetricCount              int64             `json:"total_metric_count"`
+	PlannedRequests               int64             `json:"planned_requests,omitempty"`
+	PlannedFetches                int64             `json:"planned_fetches,omitempty"`
+	SavedFetches                  int64             `json:"saved_fetches,omitempty"`
+	SavedDatapoints               int64             `json:"saved_datapoints,omitempty"`
 }
 
 func splitAddr(addr string) (string, string) {
@@ -60,14 +60,37 @@ func DefaultAPIConfig() API {
 	cfg := API{
 		Zipper: fromCommon(DefaultCommonConfig()),
 
-		SendGlobsAsIs:       false,
-		AlwaysSendGlobsAsIs: false,
-		MaxBatchSize:        100,
+		SendGlobsAsIs:        false,
+		AlwaysSendGlobsAsIs:  false,
+		MaxBatchSize:         100,
+		MaxConcurrentTargets: 8,
 		Cache: CacheConfig{
 			Type:              "mem",
 			DefaultTimeoutSec: 60,
 			QueryTimeoutMs:    50,
 			Prefix:            "capi",
+			Redis: RedisConfig{
+				Mode: "standalone",
+			},
+			Compression:       "none",
+			MaxStreamedSizeKB: 10240,
+			LocalTimeoutSec:   10,
+			KeyBucketSec:      60,
+			Series: SeriesCacheConfig{
+				MinAgeSec:  300,
+				TimeoutSec: 3600,
+			},
+			RenderStale: StaleConfig{
+				Revalidate:   true,
+				ServeOnError: true,
+			},
+			FindStale: StaleConfig{
+				Revalidate:   true,
+				ServeOnError: true,
+			},
+		},
+		Prometheus: PrometheusConfig{
+			LookbackDelta: 5 * time.Minute,
 		},
 	}
 
@@ -86,15 +109,20 @@ type API struct {
 
 	// TODO (grzkv): Move backends list to a single backend here
 
-	SendGlobsAsIs           bool          `yaml:"sendGlobsAsIs"`
-	AlwaysSendGlobsAsIs     bool          `yaml:"alwaysSendGlobsAsIs"`
-	MaxBatchSize            int           `yaml:"maxBatchSize"`
-	Cache                   CacheConfig   `yaml:"cache"`
-	TimezoneString          string        `yaml:"tz"`
-	PidFile                 string        `yaml:"pidFile"`
-	BlockHeaderFile         string        `yaml:"blockHeaderFile"`
-	BlockHeaderUpdatePeriod time.Duration `yaml:"blockHeaderUpdatePeriod"`
-	HeadersToLog            []string      `yaml:"headersToLog"`
+	SendGlobsAsIs           bool              `yaml:"sendGlobsAsIs"`
+	AlwaysSendGlobsAsIs     bool              `yaml:"alwaysSendGlobsAsIs"`
+	MaxBatchSize            int               `yaml:"maxBatchSize"`
+	MaxConcurrentTargets    int               `yaml:"maxConcurrentTargets"`
+	EvalLimits              LimitsConfig      `yaml:"evalLimits"`
+	Cache                   CacheConfig       `yaml:"cache"`
+	Prometheus              PrometheusConfig  `yaml:"prometheus"`
+	TimezoneString          string            `yaml:"tz"`
+	UserTimezones           map[string]string `yaml:"userTimezones"`
+	StrictTimeParsing       bool              `yaml:"strictTimeParsing"`
+	PidFile                 string            `yaml:"pidFile"`
+	BlockHeaderFile         string            `yaml:"blockHeaderFile"`
+	BlockHeaderUpdatePeriod time.Duration     `yaml:"blockHeaderUpdatePeriod"`
+	HeadersToLog            []string          `yaml:"headersToLog"`
 
 	UnicodeRangeTables        []string          `yaml:"unicodeRangeTables"`
 	IgnoreClientTimeout       bool              `yaml:"ignoreClientTimeout"`
@@ -105,7 +133,7 @@ type API struct {
 
 // CacheConfig configs the cache
 type CacheConfig struct {
-	// possible values are: null, mem, memcache, replicatedMemcache
+	// possible values are: null, mem, memcache, replicatedMemcache, tiered, redis
 	Type             string   `yaml:"type"`
 	Size             int      `yaml:"size_mb"`
 	MemcachedServers []string `yaml:"memcachedServers"`
@@ -113,6 +141,81 @@ type CacheConfig struct {
 	DefaultTimeoutSec int32  `yaml:"defaultTimeoutSec"`
 	QueryTimeoutMs    uint64 `yaml:"queryTimeoutMs"`
 	Prefix            string `yaml:"prefix"`
+	// Compression of cached values: none, snappy or zstd
+	Compression string `yaml:"compression"`
+	// MaxEntrySizeKB is the maximum size of a cached, possibly compressed, value. 0 means no limit
+	MaxEntrySizeKB int `yaml:"maxEntrySizeKB"`
+	// MaxStreamedSizeKB is the maximum size of a streamed render response to be cached. 0 means no limit
+	MaxStreamedSizeKB int `yaml:"maxStreamedSizeKB"`
+	// Redis configures the redis type of cache
+	Redis RedisConfig `yaml:"redis"`
+	// LocalTimeoutSec caps expiration time of the local tier of the tiered cache.
+	// 0 means no cap, values are then kept locally only when written by this instance
+	LocalTimeoutSec int32 `yaml:"localTimeoutSec"`
+	// KeyBucketSec aligns times relative to now in render cache keys, so that
+	// requests for ranges within the same bucket share cached responses
+	KeyBucketSec int32 `yaml:"keyBucketSec"`
+	// Series configures caching of fetched series in chunks of time
+	Series SeriesCacheConfig `yaml:"series"`
+	// RenderStale configures serving of expired render responses
+	RenderStale StaleConfig `yaml:"renderStale"`
+	// FindStale configures serving of expired find responses
+	FindStale StaleConfig `yaml:"findStale"`
+}
+
+// StaleConfig configures serving of cache entries past their expiration time,
+// which then is a soft one.
+type StaleConfig struct {
+	// StaleSec is how long entries are kept after they expire, 0 disables serving them
+	StaleSec int32 `yaml:"staleSec"`
+	// Revalidate serves expired entries while they are refreshed in background
+	Revalidate bool `yaml:"revalidate"`
+	// ServeOnError serves expired entries when the backends fail
+	ServeOnError bool `yaml:"serveOnError"`
+}
+
+// RedisConfig configures connection to Redis
+type RedisConfig struct {
+	// Mode is one of standalone, sentinel or cluster
+	Mode string `yaml:"mode"`
+	// Addresses of the server, of sentinels or of cluster nodes
+	Addresses []string `yaml:"addresses"`
+	// MasterName is the name of the master monitored by sentinels
+	MasterName string `yaml:"masterName"`
+	Password   string `yaml:"password"`
+	DB         int    `yaml:"db"`
+}
+
+// SeriesCacheConfig configures the cache of fetched series. It uses the same
+// type of cache as the render cache.
+type SeriesCacheConfig struct {
+	// ChunkSec is the size of cached chunks of time, 0 disables the cache
+	ChunkSec int32 `yaml:"chunkSec"`
+	// MinAgeSec is the minimum age of the end of a chunk to be cached,
+	// newer points may still change
+	MinAgeSec int32 `yaml:"minAgeSec"`
+	// TimeoutSec is the expiration time of cached chunks
+	TimeoutSec int32 `yaml:"timeoutSec"`
+	// SizeMB limits the in-memory cache of chunks of the mem and tiered types,
+	// 0 means the size of the cache
+	SizeMB int `yaml:"size_mb"`
+}
+
+// PrometheusConfig configures the Prometheus compatible query API.
+type PrometheusConfig struct {
+	// PathTemplate maps nodes of paths to labels, like "servers.{dc}.{host}.{__name__}".
+	// The API is disabled when it's empty
+	PathTemplate string `yaml:"pathTemplate"`
+	// LookbackDelta is how far back the last point of a series is its value at a time
+	LookbackDelta time.Duration `yaml:"lookbackDelta"`
+}
+
+// LimitsConfig bounds resources evaluation of a single target may use.
+// Zero values mean no limit. MaxFunctionTime is wall-clock time, not CPU time.
+type LimitsConfig struct {
+	MaxSeries       int           `yaml:"maxSeries"`
+	MaxDatapoints   int64         `yaml:"maxDatapoints"`
+	MaxFunctionTime time.Duration `yaml:"maxFunctionTime"`
 }
 
 type preAPI struct {
@@ -2,133 +2,400 @@ package date
 
 import (
 	"errors"
+	"fmt"
+	"math"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
-
-	"github.com/bookingcom/carbonapi/pkg/parser"
 )
 
-var errBadTime = errors.New("bad time")
 var timeNow = time.Now
 
-// parseTime parses a time and returns hours and minutes
-func parseTime(s string) (hour, minute int, err error) {
+var (
+	months   = []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}
+	weekdays = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+)
 
-	switch s {
-	case "midnight":
-		return 0, 0, nil
-	case "noon":
-		return 12, 0, nil
-	case "teatime":
-		return 16, 0, nil
-	}
+var (
+	epochRe = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?$`)
+	isoRe   = regexp.MustCompile(`^[0-9]{4}-[0-9]{2}-[0-9]{2}`)
+)
 
-	parts := strings.Split(s, ":")
+// isoFormats are the accepted ISO 8601 forms, times without a zone being in the time zone of the request
+var isoFormats = []string{
+	"2006-01-02T15:04:05Z07:00",
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04Z0700",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02",
+}
 
-	if len(parts) != 2 {
-		return 0, 0, errBadTime
+// DateParamToEpoch turns a passed string parameter into a unix epoch.
+// It returns d if the parameter is empty or can't be parsed, and ignores
+// unknown time zones.
+func DateParamToEpoch(s string, qtz string, d int64, defaultTimeZone *time.Location) int32 {
+	tz, err := location(qtz, defaultTimeZone)
+	if err != nil {
+		tz, _ = location("", defaultTimeZone)
 	}
 
-	hour, err = strconv.Atoi(parts[0])
+	t, err := parseEpoch(s, tz, d)
 	if err != nil {
-		return 0, 0, errBadTime
+		return int32(d)
+	}
+	return t
+}
+
+// ParseDateParam turns a passed string parameter into a unix epoch like
+// DateParamToEpoch does, but returns errors instead of d. For an unknown
+// time zone, the error comes with the epoch in the default time zone.
+func ParseDateParam(s string, qtz string, d int64, defaultTimeZone *time.Location) (int32, error) {
+	tz, tzErr := location(qtz, defaultTimeZone)
+	if tzErr != nil {
+		tz, _ = location("", defaultTimeZone)
 	}
 
-	minute, err = strconv.Atoi(parts[1])
+	t, err := parseEpoch(s, tz, d)
 	if err != nil {
-		return 0, 0, errBadTime
+		return t, err
 	}
-
-	return hour, minute, nil
+	return t, tzErr
 }
 
-var TimeFormats = []string{"20060102", "01/02/06"}
+// location returns the time zone named qtz, defaultTimeZone if it's empty
+// and the local one if neither is set.
+func location(qtz string, defaultTimeZone *time.Location) (*time.Location, error) {
+	if defaultTimeZone == nil {
+		defaultTimeZone = time.Local
+	}
+	if qtz == "" {
+		return defaultTimeZone, nil
+	}
 
-// DateParamToEpoch turns a passed string parameter into a unix epoch
-func DateParamToEpoch(s string, qtz string, d int64, defaultTimeZone *time.Location) int32 {
+	tz, err := time.LoadLocation(qtz)
+	if err != nil {
+		return nil, fmt.Errorf("unknown time zone %q", qtz)
+	}
+	return tz, nil
+}
 
+func parseEpoch(s string, tz *time.Location, d int64) (int32, error) {
 	if s == "" {
 		// return the default if nothing was passed
-		return int32(d)
+		return int32(d), nil
+	}
+
+	t, err := ParseATTime(s, tz, timeNow())
+	if err != nil {
+		return int32(d), fmt.Errorf("cannot parse time %q: %v", s, err)
+	}
+	if t.Unix() < math.MinInt32 || t.Unix() > math.MaxInt32 {
+		return int32(d), fmt.Errorf("time %q is out of range", s)
+	}
+	return int32(t.Unix()), nil
+}
+
+// ParseATTime parses a time the way graphite-web does, as in from and until
+// parameters. The time is a reference, like "now", "noon yesterday", "monday",
+// "20200131" or "17:04_20200131", followed by an offset, like "-2d" or "+3h",
+// with absolute days of 24 hours, months of 30 days and years of 365 days.
+// Times of day and days are in the time zone tz.
+//
+// Unlike graphite-web, an offset may have terms of either sign, like "-2d+3h",
+// and the time may be an epoch in milliseconds or ISO 8601.
+func ParseATTime(s string, tz *time.Location, now time.Time) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if isoRe.MatchString(s) {
+		return parseISO(s, tz)
+	}
+
+	s = strings.ToLower(s)
+	s = strings.NewReplacer("_", "", ",", "", " ", "").Replace(s)
+
+	if epochRe.MatchString(s) && !isDate(s) {
+		return parseTimestamp(s)
 	}
 
-	// relative timestamp
-	if s[0] == '-' {
-		offset, err := parser.IntervalString(s, -1)
+	if strings.Contains(s, ":") && len(s) == 13 {
+		// HH:MM_YYYYMMDD
+		t, err := time.ParseInLocation("15:0420060102", s, tz)
 		if err != nil {
-			return int32(d)
+			return time.Time{}, errors.New("bad time and date")
 		}
+		return t, nil
+	}
 
-		return int32(timeNow().Add(time.Duration(offset) * time.Second).Unix())
+	ref, offset := s, ""
+	if i := strings.IndexAny(s, "+-"); i >= 0 {
+		ref, offset = s[:i], s[i:]
 	}
 
-	switch s {
-	case "now":
-		return int32(timeNow().Unix())
-	case "midnight", "noon", "teatime":
-		yy, mm, dd := timeNow().Date()
-		hh, min, _ := parseTime(s) // error ignored, we know it's valid
-		dt := time.Date(yy, mm, dd, hh, min, 0, 0, defaultTimeZone)
-		return int32(dt.Unix())
+	t, err := parseReference(ref, tz, now)
+	if err != nil {
+		return time.Time{}, err
+	}
+	d, err := parseOffset(offset)
+	if err != nil {
+		return time.Time{}, err
 	}
+	return t.Add(d), nil
+}
 
-	sint, err := strconv.Atoi(s)
-	// need to check that len(s) > 8 to avoid turning 20060102 into seconds
-	if err == nil && len(s) > 8 {
-		return int32(sint) // We got a timestamp so returning it
+// IsRelative tells whether the time s, as in from and until parameters, depends
+// on the current time, like "-1h", "now" or "noon yesterday" do. Empty and
+// unparsable times are relative, as the defaults they fall back to are.
+func IsRelative(s string, tz *time.Location) bool {
+	if strings.TrimSpace(s) == "" {
+		return true
+	}
+	if tz == nil {
+		tz = time.UTC
 	}
 
-	s = strings.Replace(s, "_", " ", 1) // Go can't parse _ in date strings
+	// the times differ in every field a reference may take from now
+	now := time.Date(2020, time.March, 4, 5, 6, 7, 0, tz)
+	later := now.AddDate(1, 1, 1).Add(time.Hour + time.Minute + time.Second)
+	t1, err1 := ParseATTime(s, tz, now)
+	t2, err2 := ParseATTime(s, tz, later)
+	return err1 != nil || err2 != nil || !t1.Equal(t2)
+}
 
-	var ts, ds string
-	split := strings.Fields(s)
+// isDate tells whether digits are a date in YYYYMMDD form rather than a timestamp.
+func isDate(s string) bool {
+	if len(s) != 8 || strings.Contains(s, ".") {
+		return false
+	}
+	y, _ := strconv.Atoi(s[:4])
+	m, _ := strconv.Atoi(s[4:6])
+	d, _ := strconv.Atoi(s[6:])
+	return y > 1900 && m < 13 && d < 32
+}
 
-	switch {
-	case len(split) == 1:
-		ds = s
-	case len(split) == 2:
-		ts, ds = split[0], split[1]
-	case len(split) > 2:
-		return int32(d)
+// parseTimestamp parses a unix epoch, in milliseconds if it's too large for seconds.
+func parseTimestamp(s string) (time.Time, error) {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return time.Time{}, errors.New("bad timestamp")
 	}
+	if f > math.MaxInt32 {
+		f /= 1000
+	}
+	if f > math.MaxInt32 {
+		return time.Time{}, errors.New("timestamp out of range")
+	}
+	return time.Unix(int64(f), 0), nil
+}
 
-	var tz = defaultTimeZone
-	if qtz != "" {
-		if z, err := time.LoadLocation(qtz); err != nil {
-			tz = z
+func parseISO(s string, tz *time.Location) (time.Time, error) {
+	s = strings.ToUpper(s)
+	for _, format := range isoFormats {
+		if t, err := time.ParseInLocation(format, s, tz); err == nil {
+			return t, nil
 		}
 	}
+	return time.Time{}, errors.New("bad ISO 8601 time")
+}
 
-	var t time.Time
-dateStringSwitch:
-	switch ds {
-	case "today":
-		t = timeNow()
-		// nothing
-	case "yesterday":
-		t = timeNow().AddDate(0, 0, -1)
-	case "tomorrow":
-		t = timeNow().AddDate(0, 0, 1)
-	default:
-		for _, format := range TimeFormats {
-			t, err = time.ParseInLocation(format, ds, tz)
-			if err == nil {
-				break dateStringSwitch
+// parseReference parses the reference part of a time, that is a time of day
+// followed by a day, both optional.
+func parseReference(ref string, tz *time.Location, now time.Time) (time.Time, error) {
+	now = now.In(tz)
+	if ref == "" || ref == "now" {
+		return now, nil
+	}
+
+	rawRef := ref
+	hour, minute := 0, 0
+	var err error
+
+	// HH:MM, possibly followed by am or pm
+	if i := strings.IndexByte(ref, ':'); i > 0 && i < 3 {
+		if len(ref) < i+3 {
+			return time.Time{}, fmt.Errorf("bad time of day %q", rawRef)
+		}
+		if hour, err = strconv.Atoi(ref[:i]); err != nil {
+			return time.Time{}, fmt.Errorf("bad hour in %q", rawRef)
+		}
+		if minute, err = strconv.Atoi(ref[i+1 : i+3]); err != nil {
+			return time.Time{}, fmt.Errorf("bad minute in %q", rawRef)
+		}
+		ref = ref[i+3:]
+		if strings.HasPrefix(ref, "am") {
+			ref = ref[2:]
+		} else if strings.HasPrefix(ref, "pm") {
+			hour = (hour + 12) % 24
+			ref = ref[2:]
+		}
+	}
+
+	// Xam, XXam, Xpm or XXpm
+	if i := strings.Index(ref, "am"); i > 0 && i < 3 {
+		if hour, err = strconv.Atoi(ref[:i]); err != nil {
+			return time.Time{}, fmt.Errorf("bad hour in %q", rawRef)
+		}
+		ref = ref[i+2:]
+	}
+	if i := strings.Index(ref, "pm"); i > 0 && i < 3 {
+		if hour, err = strconv.Atoi(ref[:i]); err != nil {
+			return time.Time{}, fmt.Errorf("bad hour in %q", rawRef)
+		}
+		hour = (hour + 12) % 24
+		ref = ref[i+2:]
+	}
+
+	switch {
+	case strings.HasPrefix(ref, "noon"):
+		hour, minute = 12, 0
+		ref = ref[4:]
+	case strings.HasPrefix(ref, "midnight"):
+		hour, minute = 0, 0
+		ref = ref[8:]
+	case strings.HasPrefix(ref, "teatime"):
+		hour, minute = 16, 0
+		ref = ref[7:]
+	}
+
+	if hour < 0 || hour > 23 || minute < 0 || minute > 59 {
+		return time.Time{}, fmt.Errorf("bad time of day in %q", rawRef)
+	}
+
+	year, month, day := now.Date()
+	explicit := false
+	switch {
+	case ref == "" || ref == "today":
+	case ref == "yesterday":
+		day--
+	case ref == "tomorrow":
+		day++
+	case strings.Count(ref, "/") == 2:
+		// MM/DD/YY[YY]
+		parts := strings.Split(ref, "/")
+		m, err1 := strconv.Atoi(parts[0])
+		d, err2 := strconv.Atoi(parts[1])
+		y, err3 := strconv.Atoi(parts[2])
+		if err1 != nil || err2 != nil || err3 != nil {
+			return time.Time{}, fmt.Errorf("bad date %q", rawRef)
+		}
+		if y < 1900 {
+			y += 1900
+		}
+		if y < 1970 {
+			y += 100
+		}
+		year, month, day = y, time.Month(m), d
+		explicit = true
+	case len(ref) == 8 && epochRe.MatchString(ref):
+		// YYYYMMDD
+		y, _ := strconv.Atoi(ref[:4])
+		m, _ := strconv.Atoi(ref[4:6])
+		d, _ := strconv.Atoi(ref[6:])
+		year, month, day = y, time.Month(m), d
+		explicit = true
+	case len(ref) >= 3 && indexOf(months, ref[:3]) >= 0:
+		// MonthName DayOfMonth
+		d := -1
+		if len(ref) > 3 {
+			d, _ = strconv.Atoi(ref[len(ref)-2:])
+			if d <= 0 {
+				d, _ = strconv.Atoi(ref[len(ref)-1:])
 			}
 		}
+		if d <= 0 {
+			return time.Time{}, fmt.Errorf("day of month required after month name in %q", rawRef)
+		}
+		month, day = time.Month(indexOf(months, ref[:3])+1), d
+		explicit = true
+	case len(ref) >= 3 && indexOf(weekdays, ref[:3]) >= 0:
+		// DayOfWeek, the last one
+		offset := int(now.Weekday()) - indexOf(weekdays, ref[:3])
+		if offset < 0 {
+			offset += 7
+		}
+		day -= offset
+	default:
+		return time.Time{}, fmt.Errorf("unknown day reference %q", rawRef)
+	}
 
-		return int32(d)
+	t := time.Date(year, month, day, hour, minute, 0, 0, tz)
+	if explicit && (month < time.January || month > time.December || t.Day() != day) {
+		return time.Time{}, fmt.Errorf("bad date %q", rawRef)
 	}
+	return t, nil
+}
+
+// parseOffset parses an offset, like "-1d12h" or "+3h-30min". The sign of a term
+// applies to the following ones until another sign.
+func parseOffset(s string) (time.Duration, error) {
+	var total time.Duration
+	sign := time.Duration(1)
+	for s != "" {
+		switch s[0] {
+		case '+':
+			sign = 1
+			s = s[1:]
+		case '-':
+			sign = -1
+			s = s[1:]
+		}
+
+		i := 0
+		for i < len(s) && '0' <= s[i] && s[i] <= '9' {
+			i++
+		}
+		if i == 0 || i > 9 {
+			return 0, fmt.Errorf("bad offset number in %q", s)
+		}
+		n, _ := strconv.Atoi(s[:i])
+		s = s[i:]
 
-	var hour, minute int
-	if ts != "" {
-		hour, minute, _ = parseTime(ts)
-		// defaults to hour=0, minute=0 on error, which is midnight, which is fine for now
+		i = 0
+		for i < len(s) && 'a' <= s[i] && s[i] <= 'z' {
+			i++
+		}
+		unit, err := offsetUnit(s[:i])
+		if err != nil {
+			return 0, err
+		}
+		s = s[i:]
+
+		total += sign * time.Duration(n) * unit
 	}
+	return total, nil
+}
 
-	yy, mm, dd := t.Date()
-	t = time.Date(yy, mm, dd, hour, minute, 0, 0, defaultTimeZone)
+// offsetUnit returns the unit of offsets, matched by prefix as graphite-web does.
+func offsetUnit(s string) (time.Duration, error) {
+	const day = 24 * time.Hour
 
-	return int32(t.Unix())
+	switch {
+	case strings.HasPrefix(s, "s"):
+		return time.Second, nil
+	case strings.HasPrefix(s, "min"):
+		return time.Minute, nil
+	case strings.HasPrefix(s, "h"):
+		return time.Hour, nil
+	case strings.HasPrefix(s, "d"):
+		return day, nil
+	case strings.HasPrefix(s, "w"):
+		return 7 * day, nil
+	case strings.HasPrefix(s, "mon"):
+		return 30 * day, nil
+	case strings.HasPrefix(s, "m"):
+		return time.Minute, nil
+	case strings.HasPrefix(s, "y"):
+		return 365 * day, nil
+	}
+	return 0, fmt.Errorf("invalid offset unit %q", s)
+}
+
+func indexOf(list []string, s string) int {
+	for i, v := range list {
+		if v == s {
+			return i
+		}
+	}
+	return -1
 }
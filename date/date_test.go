@@ -47,3 +47,144 @@ func TestDateParamToEpoch(t *testing.T) {
 		}
 	}
 }
+
+func TestIsRelative(t *testing.T) {
+	var tests = []struct {
+		input    string
+		relative bool
+	}{
+		{"", true},
+		{"now", true},
+		{"-1h", true},
+		{"noon", true},
+		{"noon yesterday", true},
+		{"monday", true},
+		{"jan1", true},
+		{"1510913280", false},
+		{"1510913280000", false},
+		{"20200131", false},
+		{"17:04_20200131", false},
+		{"midnight 20060812-1h", false},
+		{"2020-01-31T17:04:05Z", false},
+	}
+
+	for _, tt := range tests {
+		if got := IsRelative(tt.input, time.UTC); got != tt.relative {
+			t.Errorf("IsRelative(%q)=%v, want %v", tt.input, got, tt.relative)
+		}
+	}
+}
+
+func TestParseDateParam(t *testing.T) {
+	timeNow = func() time.Time {
+		// Tuesday, 16 Aug 1994 15:30
+		return time.Date(1994, time.August, 16, 15, 30, 0, 100, time.UTC)
+	}
+
+	const shortForm = "15:04 2006-Jan-02"
+
+	var tests = []struct {
+		input  string
+		output string
+	}{
+		{"now", "15:30 1994-Aug-16"},
+		{"now-2d+3h", "18:30 1994-Aug-14"},
+		{"-1hr", "14:30 1994-Aug-16"},
+		{"-1d12h", "03:30 1994-Aug-15"},
+		{"+1w", "15:30 1994-Aug-23"},
+		{"midnight+1d", "00:00 1994-Aug-17"},
+		{"noon-30min", "11:30 1994-Aug-16"},
+		{"monday", "00:00 1994-Aug-15"},
+		{"tuesday", "00:00 1994-Aug-16"},
+		{"sunday", "00:00 1994-Aug-14"},
+		{"9am tomorrow", "09:00 1994-Aug-17"},
+		{"11:15pm_yesterday", "23:15 1994-Aug-15"},
+		{"jan1", "00:00 1994-Jan-01"},
+		{"August 20", "00:00 1994-Aug-20"},
+		{"17:04_19940812", "17:04 1994-Aug-12"},
+		{"4:37_19940812", "04:37 1994-Aug-12"},
+		{"08/12/2001", "00:00 2001-Aug-12"},
+		{"2020-01-02", "00:00 2020-Jan-02"},
+		{"2020-01-02T03:04", "03:04 2020-Jan-02"},
+		{"2020-01-02T03:04+02:00", "01:04 2020-Jan-02"},
+		{"2020-01-02t03:04:00.123z", "03:04 2020-Jan-02"},
+		{"774459000", "15:30 1994-Jul-17"},
+		{"774459000123", "15:30 1994-Jul-17"},
+		{"774459000.9", "15:30 1994-Jul-17"},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseDateParam(tt.input, "UTC", 0, time.Local)
+		if err != nil {
+			t.Errorf("%q: unexpected error %v", tt.input, err)
+			continue
+		}
+		ts, err := time.ParseInLocation(shortForm, tt.output, time.UTC)
+		if err != nil {
+			t.Fatalf("error parsing time: %q: %v", tt.output, err)
+		}
+
+		if want := int32(ts.Unix()); got != want {
+			t.Errorf("%q: expected %v, got %v", tt.input, ts, time.Unix(int64(got), 0).UTC())
+		}
+	}
+}
+
+func TestParseDateParamErrors(t *testing.T) {
+	timeNow = func() time.Time {
+		return time.Date(1994, time.August, 16, 15, 30, 0, 0, time.UTC)
+	}
+
+	tests := []string{
+		"yesteday",
+		"-1",
+		"-1x",
+		"now-",
+		"1d",
+		"25:00",
+		"02/30/94",
+		"jan",
+		"2020-13-01",
+		"2020-01-02T03",
+		"99999999999999999",
+	}
+
+	for _, s := range tests {
+		if got, err := ParseDateParam(s, "", 42, time.UTC); err == nil || got != 42 {
+			t.Errorf("%q: expected error and default, got %v and error %v", s, got, err)
+		}
+		if got := DateParamToEpoch(s, "", 42, time.UTC); got != 42 {
+			t.Errorf("%q: expected default, got %v", s, got)
+		}
+	}
+
+	if got, err := ParseDateParam("midnight", "Nowhere/City", 0, time.UTC); err == nil || got != 776995200 {
+		t.Errorf("Expected error for unknown time zone with the time in the default one, got %v and error %v", got, err)
+	}
+	if got := DateParamToEpoch("midnight", "Nowhere/City", 0, time.UTC); got != 776995200 {
+		t.Errorf("Expected unknown time zone to be ignored, got %v", got)
+	}
+}
+
+func TestParseDateParamTimeZone(t *testing.T) {
+	// noon UTC on the day New York switches to daylight saving time
+	timeNow = func() time.Time {
+		return time.Date(2021, time.March, 14, 12, 0, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		input  string
+		output time.Time
+	}{
+		{"midnight", time.Date(2021, time.March, 14, 5, 0, 0, 0, time.UTC)},
+		{"noon", time.Date(2021, time.March, 14, 16, 0, 0, 0, time.UTC)},
+		{"2021-03-14T12:00", time.Date(2021, time.March, 14, 16, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseDateParam(tt.input, "America/New_York", 0, time.UTC)
+		if err != nil || int64(got) != tt.output.Unix() {
+			t.Errorf("%q: expected %v, got %v and error %v", tt.input, tt.output, time.Unix(int64(got), 0).UTC(), err)
+		}
+	}
+}
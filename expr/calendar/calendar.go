@@ -0,0 +1,106 @@
+// Package calendar aligns intervals of time to the wall clock of a time zone,
+// so that days start at local midnights across DST transitions. Functions
+// of this package take nil for UTC.
+package calendar
+
+import (
+	"time"
+)
+
+const day = 24 * 60 * 60
+
+// Truncate returns the start of the interval of size seconds containing t.
+// Intervals of whole days start at local midnights, intervals dividing a day
+// at multiples of their size since the local midnight. Other intervals, and
+// all intervals in UTC, start at multiples of their size since the epoch.
+func Truncate(t, size int32, loc *time.Location) int32 {
+	if size <= 0 {
+		return t
+	}
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	lt := time.Unix(int64(t), 0).In(loc)
+	year, month, dd := lt.Date()
+	switch {
+	case size%day == 0:
+		days := int(size / day)
+		n := int(time.Date(year, month, dd, 0, 0, 0, 0, time.UTC).Unix() / day)
+		n -= mod(n, days)
+		return int32(time.Date(1970, time.January, 1+n, 0, 0, 0, 0, loc).Unix())
+	case day%size == 0:
+		wall := int32(lt.Hour()*3600 + lt.Minute()*60 + lt.Second())
+		start := wall - wall%size
+
+		// the start is at the same offset as t, unless clocks changed since
+		res := t - (wall - start)
+		if wallClock(res, loc) == start {
+			return res
+		}
+		if s := int32(time.Date(year, month, dd, 0, 0, int(start), 0, loc).Unix()); s <= t && wallClock(s, loc) == start {
+			return s
+		}
+		// clocks went forward over the start, the interval starts when they did
+		local := localSeconds(t, loc) - int64(wall-start)
+		lo, hi := res, t
+		for lo < hi {
+			mid := lo + (hi-lo)/2
+			if localSeconds(mid, loc) >= local {
+				hi = mid
+			} else {
+				lo = mid + 1
+			}
+		}
+		return lo
+	default:
+		return t - int32(mod(int(t), int(size)))
+	}
+}
+
+// Next returns the start of the interval of size seconds following the one
+// containing t, intervals being aligned as Truncate does.
+func Next(t, size int32, loc *time.Location) int32 {
+	if size <= 0 {
+		return t
+	}
+
+	start := Truncate(t, size, loc)
+	// intervals differ from their size by at most the DST shift
+	for _, d := range []int32{size, size + 3600, size + 2*3600} {
+		if next := Truncate(start+d, size, loc); next > start {
+			return next
+		}
+	}
+	return start + size
+}
+
+// Shift returns t shifted by offset seconds. Offsets of whole days shift by
+// days of the calendar, keeping the wall clock time across DST transitions.
+func Shift(t, offset int32, loc *time.Location) int32 {
+	if offset%day != 0 || loc == nil {
+		return t + offset
+	}
+	return int32(time.Unix(int64(t), 0).In(loc).AddDate(0, 0, int(offset/day)).Unix())
+}
+
+// wallClock returns the seconds since the local midnight at t.
+func wallClock(t int32, loc *time.Location) int32 {
+	lt := time.Unix(int64(t), 0).In(loc)
+	return int32(lt.Hour()*3600 + lt.Minute()*60 + lt.Second())
+}
+
+// localSeconds returns the seconds since the epoch of the wall clock at t.
+func localSeconds(t int32, loc *time.Location) int64 {
+	_, offset := time.Unix(int64(t), 0).In(loc).Zone()
+	return int64(t) + int64(offset)
+}
+
+// mod is the modulo, which unlike the remainder isn't negative for negative a.
+func mod(a, b int) int {
+	m := a % b
+	if m < 0 {
+		m += b
+	}
+	return m
+}
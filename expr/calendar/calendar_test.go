@@ -0,0 +1,178 @@
+package calendar
+
+import (
+	"testing"
+	"time"
+)
+
+func mustLoad(t *testing.T, name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skipf("time zone %s isn't available: %v", name, err)
+	}
+	return loc
+}
+
+func unix(year int, month time.Month, day, hour, minute int, loc *time.Location) int32 {
+	return int32(time.Date(year, month, day, hour, minute, 0, 0, loc).Unix())
+}
+
+func TestTruncateUTC(t *testing.T) {
+	for _, size := range []int32{1, 60, 300, 3600, 7 * 3600, 86400, 7 * 86400} {
+		for _, ts := range []int32{0, 59, 1510913283, 1510999999} {
+			want := ts - ts%size
+			if got := Truncate(ts, size, nil); got != want {
+				t.Errorf("Truncate(%d, %d, nil) = %d, want %d", ts, size, got, want)
+			}
+			if got := Truncate(ts, size, time.UTC); got != want {
+				t.Errorf("Truncate(%d, %d, UTC) = %d, want %d", ts, size, got, want)
+			}
+		}
+	}
+}
+
+// bounds returns the bounds of the intervals covering start to stop.
+func bounds(start, stop, size int32, loc *time.Location) []int32 {
+	b := Truncate(start, size, loc)
+	res := []int32{b}
+	for b < stop {
+		b = Next(b, size, loc)
+		res = append(res, b)
+	}
+	return res
+}
+
+func TestNextDST(t *testing.T) {
+	ams := mustLoad(t, "Europe/Amsterdam")
+	kolkata := mustLoad(t, "Asia/Kolkata")
+
+	tests := []struct {
+		name  string
+		loc   *time.Location
+		start int32
+		stop  int32
+		size  int32
+		want  []int32
+	}{
+		{
+			name:  "days when clocks go forward",
+			loc:   ams,
+			start: unix(2021, time.March, 27, 13, 0, ams),
+			stop:  unix(2021, time.March, 29, 1, 0, ams),
+			size:  86400,
+			want: []int32{
+				unix(2021, time.March, 27, 0, 0, ams),
+				unix(2021, time.March, 28, 0, 0, ams),
+				unix(2021, time.March, 29, 0, 0, ams),
+				unix(2021, time.March, 30, 0, 0, ams),
+			},
+		},
+		{
+			name:  "days when clocks go back",
+			loc:   ams,
+			start: unix(2021, time.October, 30, 13, 0, ams),
+			stop:  unix(2021, time.November, 1, 0, 0, ams),
+			size:  86400,
+			want: []int32{
+				unix(2021, time.October, 30, 0, 0, ams),
+				unix(2021, time.October, 31, 0, 0, ams),
+				unix(2021, time.November, 1, 0, 0, ams),
+			},
+		},
+		{
+			name:  "quarters of days when clocks go forward",
+			loc:   ams,
+			start: unix(2021, time.March, 28, 0, 0, ams),
+			stop:  unix(2021, time.March, 28, 13, 0, ams),
+			size:  6 * 3600,
+			want: []int32{
+				unix(2021, time.March, 28, 0, 0, ams),
+				unix(2021, time.March, 28, 6, 0, ams),
+				unix(2021, time.March, 28, 12, 0, ams),
+				unix(2021, time.March, 28, 18, 0, ams),
+			},
+		},
+		{
+			name:  "quarters of days when clocks go back",
+			loc:   ams,
+			start: unix(2021, time.October, 31, 5, 0, ams),
+			stop:  unix(2021, time.October, 31, 13, 0, ams),
+			size:  6 * 3600,
+			want: []int32{
+				unix(2021, time.October, 31, 0, 0, ams),
+				unix(2021, time.October, 31, 6, 0, ams),
+				unix(2021, time.October, 31, 12, 0, ams),
+				unix(2021, time.October, 31, 18, 0, ams),
+			},
+		},
+		{
+			name:  "hours when clocks go back",
+			loc:   ams,
+			start: 1635640200, // 2021-10-31 00:30 UTC, 02:30 CEST
+			stop:  1635645600, // 2021-10-31 02:00 UTC, 03:00 CET
+			size:  3600,
+			want:  []int32{1635638400, 1635642000, 1635645600},
+		},
+		{
+			name:  "hours of a zone with a half hour offset",
+			loc:   kolkata,
+			start: unix(2021, time.March, 28, 10, 15, kolkata),
+			stop:  unix(2021, time.March, 28, 11, 45, kolkata),
+			size:  3600,
+			want: []int32{
+				unix(2021, time.March, 28, 10, 0, kolkata),
+				unix(2021, time.March, 28, 11, 0, kolkata),
+				unix(2021, time.March, 28, 12, 0, kolkata),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := bounds(tt.start, tt.stop, tt.size, tt.loc)
+			if len(got) != len(tt.want) {
+				t.Fatalf("Expected bounds %v, got %v", tt.want, got)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("Expected bound %d to be %v, got %v", i,
+						time.Unix(int64(tt.want[i]), 0).In(tt.loc), time.Unix(int64(got[i]), 0).In(tt.loc))
+				}
+			}
+		})
+	}
+}
+
+func TestNextMonotonic(t *testing.T) {
+	ny := mustLoad(t, "America/New_York")
+	start := unix(2021, time.January, 1, 0, 0, ny)
+	stop := unix(2022, time.January, 1, 0, 0, ny)
+
+	for _, size := range []int32{900, 3600, 2 * 3600, 6 * 3600, 7 * 3600, 86400, 7 * 86400} {
+		b := bounds(start, stop, size, ny)
+		for i := 1; i < len(b); i++ {
+			if d := b[i] - b[i-1]; d <= 0 || d > size+3600 || d < size-3600 {
+				t.Fatalf("size %d: interval from %v to %v", size,
+					time.Unix(int64(b[i-1]), 0).In(ny), time.Unix(int64(b[i]), 0).In(ny))
+			}
+		}
+	}
+}
+
+func TestShift(t *testing.T) {
+	ams := mustLoad(t, "Europe/Amsterdam")
+	noon := unix(2021, time.March, 29, 12, 0, ams)
+
+	if got, want := Shift(noon, -86400, ams), unix(2021, time.March, 28, 12, 0, ams); got != want {
+		t.Errorf("Expected a day before to be %d, got %d", want, got)
+	}
+	if got, want := Shift(noon, -2*86400, ams), unix(2021, time.March, 27, 12, 0, ams); got != want {
+		t.Errorf("Expected two days before to be %d, got %d", want, got)
+	}
+	if got, want := Shift(noon, -3600, ams), noon-3600; got != want {
+		t.Errorf("Expected an hour before to be %d, got %d", want, got)
+	}
+	if got, want := Shift(noon, -86400, nil), noon-86400; got != want {
+		t.Errorf("Expected a day before in UTC to be %d, got %d", want, got)
+	}
+}
@@ -0,0 +1,159 @@
+package expr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/bookingcom/carbonapi/expr/metadata"
+	"github.com/bookingcom/carbonapi/expr/types"
+	"github.com/bookingcom/carbonapi/pkg/parser"
+)
+
+// exampleArg returns a valid argument for the parameter as written in a target.
+func exampleArg(p types.FunctionParam) string {
+	if len(p.Options) > 0 {
+		switch p.Type {
+		case types.Integer, types.Float, types.Node:
+			return p.Options[0]
+		}
+		return "'" + p.Options[0] + "'"
+	}
+
+	switch p.Type {
+	case types.SeriesList, types.SeriesLists:
+		return "a.b.c"
+	case types.Boolean:
+		return "true"
+	case types.Interval, types.IntOrInterval:
+		return "'1min'"
+	case types.Date:
+		return "'-5min'"
+	case types.String, types.Tag:
+		return "'a'"
+	case types.AggFunc:
+		return "'sum'"
+	}
+	return "1"
+}
+
+// exampleTarget calls the function with example arguments for the first n params,
+// repeating the last one if it's multiple.
+func exampleTarget(name string, params []types.FunctionParam, n int) string {
+	var args []string
+	for _, p := range params[:n] {
+		args = append(args, exampleArg(p))
+	}
+	if n > 0 && n == len(params) && params[n-1].Multiple {
+		args = append(args, exampleArg(params[n-1]))
+	}
+	return name + "(" + strings.Join(args, ",") + ")"
+}
+
+// fetchAll fills values with a series for every metric the expression requests.
+func fetchAll(ctx context.Context, exp parser.Expr, from, until int32, values map[parser.MetricRequest][]*types.MetricData) (error, int) {
+	for _, m := range exp.Metrics() {
+		m.From += from
+		m.Until += until
+		points := make([]float64, (m.Until-m.From)/60)
+		for i := range points {
+			points[i] = float64(i + 1)
+		}
+		values[m] = []*types.MetricData{types.MakeMetricData(m.Metric, points, 60, m.From)}
+	}
+	return nil, 0
+}
+
+// errPanic is returned for functions panicking on described arguments
+var errPanic = errors.New("panic")
+
+// TestDescriptionsMatchParsing checks that every registered function accepts
+// the arguments its description declares and rejects calls missing required ones.
+func TestDescriptionsMatchParsing(t *testing.T) {
+	metadata.FunctionMD.RLock()
+	descriptions := make(map[string]types.FunctionDescription, len(metadata.FunctionMD.Descriptions))
+	var names []string
+	for name, d := range metadata.FunctionMD.Descriptions {
+		if _, ok := metadata.FunctionMD.Functions[name]; !ok || d.Proxied || uncheckedSignatures[name] {
+			continue
+		}
+		descriptions[name] = d
+		names = append(names, name)
+	}
+	metadata.FunctionMD.RUnlock()
+	sort.Strings(names)
+
+	eval := func(target string, validate bool) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("%w: %v", errPanic, r)
+			}
+		}()
+		exp, _, err := parser.ParseExpr(target)
+		if err != nil {
+			return err
+		}
+		if validate {
+			if err := Validate(exp); err != nil {
+				return err
+			}
+		}
+		values := make(map[parser.MetricRequest][]*types.MetricData)
+		fetchAll(context.Background(), exp, 0, 600, values)
+		_, err = EvalExpr(context.Background(), exp, 0, 600, values, fetchAll)
+		return err
+	}
+
+	for _, name := range names {
+		d := descriptions[name]
+		t.Run(name, func(t *testing.T) {
+			if d.Name != name {
+				t.Errorf("Described as %s", d.Name)
+			}
+			if d.Group == "" || d.Module == "" || d.Function == "" {
+				t.Errorf("Incomplete description %+v", d)
+			}
+			if len(d.Params) == 0 {
+				return
+			}
+
+			required := 0
+			seen := make(map[string]bool, len(d.Params))
+			for i, p := range d.Params {
+				if seen[p.Name] {
+					t.Errorf("Param %s is described twice", p.Name)
+				}
+				seen[p.Name] = true
+				if p.Required && p.Default != nil {
+					t.Errorf("Required param %s has a default", p.Name)
+				}
+				if p.Required {
+					if i > required {
+						t.Errorf("Required param %s follows optional ones", p.Name)
+					}
+					required = i + 1
+				}
+			}
+
+			for _, target := range []string{
+				exampleTarget(name, d.Params, required),
+				exampleTarget(name, d.Params, len(d.Params)),
+			} {
+				err := eval(target, true)
+				if errors.Is(err, parser.ErrBadType) || errors.Is(err, parser.ErrMissingArgument) || errors.Is(err, errPanic) {
+					t.Errorf("%s: %v", target, err)
+				}
+			}
+
+			if required > 0 {
+				target := exampleTarget(name, d.Params, required-1)
+				if err := eval(target, false); err == nil {
+					t.Errorf("%s: expected an error for missing %s", target, d.Params[required-1].Name)
+				}
+			}
+		})
+	}
+}
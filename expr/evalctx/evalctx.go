@@ -0,0 +1,47 @@
+// Package evalctx carries the properties of the request a target is evaluated
+// for. Functions get them from context, so that their signatures don't change
+// as properties are added.
+package evalctx
+
+import (
+	"context"
+	"time"
+
+	"github.com/bookingcom/carbonapi/expr/limits"
+	"go.uber.org/zap"
+)
+
+// Context is the evaluation context of a request.
+type Context struct {
+	// Location is the time zone of the request. Requests always have one,
+	// nil is treated as UTC, as in tests evaluating without a request.
+	Location *time.Location
+	// Budget is the resource budget of the evaluation, nil if unlimited.
+	Budget *limits.Budget
+	// Logger logs in the context of the request.
+	Logger *zap.Logger
+}
+
+type contextKey struct{}
+
+// NewContext returns a context carrying the evaluation context ec, and its
+// budget for the helpers checking limits.
+func NewContext(ctx context.Context, ec Context) context.Context {
+	if ec.Logger == nil {
+		ec.Logger = zap.NewNop()
+	}
+	if ec.Budget != nil {
+		ctx = limits.NewContext(ctx, ec.Budget)
+	}
+	return context.WithValue(ctx, contextKey{}, &ec)
+}
+
+// FromContext returns the evaluation context carried by ctx. Without one, it
+// returns an empty context logging nowhere, so that functions evaluated
+// outside of a request, as in tests, needn't check.
+func FromContext(ctx context.Context) *Context {
+	if ec, ok := ctx.Value(contextKey{}).(*Context); ok {
+		return ec
+	}
+	return &Context{Logger: zap.NewNop()}
+}
@@ -0,0 +1,39 @@
+package evalctx
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bookingcom/carbonapi/expr/limits"
+)
+
+func TestFromContextEmpty(t *testing.T) {
+	ec := FromContext(context.Background())
+	if ec.Location != nil || ec.Budget != nil {
+		t.Errorf("Expected an empty context, got %+v", ec)
+	}
+	if ec.Logger == nil {
+		t.Error("Expected a logger")
+	}
+}
+
+func TestNewContext(t *testing.T) {
+	loc := time.FixedZone("test", 3600)
+	budget := limits.NewBudget(limits.Limits{MaxSeries: 1})
+	ctx := NewContext(context.Background(), Context{
+		Location: loc,
+		Budget:   budget,
+	})
+
+	ec := FromContext(ctx)
+	if ec.Location != loc || ec.Budget != budget {
+		t.Errorf("Unexpected context %+v", ec)
+	}
+	if ec.Logger == nil {
+		t.Error("Expected a logger")
+	}
+	if b := limits.FromContext(ctx); b != budget {
+		t.Errorf("Expected the budget to be carried for limits, got %v", b)
+	}
+}
@@ -8,10 +8,13 @@ import (
 	_ "github.com/bookingcom/carbonapi/expr/functions"
 	"github.com/bookingcom/carbonapi/expr/helper"
 	"github.com/bookingcom/carbonapi/expr/interfaces"
+	"github.com/bookingcom/carbonapi/expr/limits"
 	"github.com/bookingcom/carbonapi/expr/metadata"
 	"github.com/bookingcom/carbonapi/expr/types"
 	"github.com/bookingcom/carbonapi/pkg/parser"
 	dataTypes "github.com/bookingcom/carbonapi/pkg/types"
+	"go.opentelemetry.io/otel/api/kv"
+	"go.opentelemetry.io/otel/api/trace"
 )
 
 type evaluator struct{}
@@ -52,17 +55,67 @@ func EvalExpr(ctx context.Context, e parser.Expr, from, until int32, values map[
 	}
 	// evaluate the function
 
-	// all functions have arguments -- check we do too
-	if len(e.Args()) == 0 {
-		return nil, parser.ErrMissingArgument
-	}
-
 	metadata.FunctionMD.RLock()
 	f, ok := metadata.FunctionMD.Functions[e.Target()]
+	desc := metadata.FunctionMD.Descriptions[e.Target()]
 	metadata.FunctionMD.RUnlock()
+
+	// all functions have arguments, unless all of their parameters are
+	// optional -- check we do too
+	if len(e.Args()) == 0 && !optionalParams(desc.Params) {
+		return nil, parser.ErrMissingArgument
+	}
 	if ok {
-		return f.Do(ctx, e, from, until, values, getTargetData)
+		return evalFunction(ctx, f, e, from, until, values, getTargetData)
 	}
 
 	return nil, fmt.Errorf("%w: %s", helper.ErrUnknownFunction, e.Target())
 }
+
+// optionalParams tells whether a function with the described parameters can
+// be called without arguments.
+func optionalParams(params []types.FunctionParam) bool {
+	if len(params) == 0 {
+		return false
+	}
+	for _, p := range params {
+		if p.Required {
+			return false
+		}
+	}
+	return true
+}
+
+// evalFunction calls the function within its evaluation budget and records
+// the cost of the call in a trace span.
+func evalFunction(ctx context.Context, f interfaces.Function, e parser.Expr, from, until int32, values map[parser.MetricRequest][]*types.MetricData, getTargetData interfaces.GetTargetData) ([]*types.MetricData, error) {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "carbonapi eval", trace.WithAttributes(
+		kv.String("graphite.function", e.Target()),
+	))
+	defer span.End()
+
+	ctx, frame := limits.Enter(ctx, e.Target())
+	res, err := f.Do(ctx, e, from, until, values, getTargetData)
+	frame.Exit()
+	if err == nil {
+		err = limits.AccountRemaining(ctx, frame, res)
+	}
+	if err == nil {
+		err = limits.CheckSeries(ctx, res)
+	}
+	// functions may hide errors of their arguments, a violation of the budget must not be lost
+	if budgetErr := limits.Err(ctx); budgetErr != nil {
+		err = budgetErr
+	}
+
+	span.SetAttributes(
+		kv.Int("graphite.series", len(res)),
+		kv.Int64("graphite.datapoints", frame.Datapoints()),
+		kv.Int64("graphite.own_time_us", frame.OwnTime().Microseconds()),
+	)
+	if err != nil {
+		span.SetAttribute("error", true)
+	}
+
+	return res, err
+}
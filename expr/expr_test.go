@@ -2,6 +2,7 @@ package expr
 
 import (
 	"context"
+	"errors"
 	"math"
 	"testing"
 	"time"
@@ -11,6 +12,7 @@ import (
 
 	"github.com/bookingcom/carbonapi/expr/functions"
 	"github.com/bookingcom/carbonapi/expr/helper"
+	"github.com/bookingcom/carbonapi/expr/limits"
 	"github.com/bookingcom/carbonapi/expr/types"
 	"github.com/bookingcom/carbonapi/pkg/parser"
 	dataTypes "github.com/bookingcom/carbonapi/pkg/types"
@@ -104,7 +106,7 @@ func TestAlignToInterval(t *testing.T) {
 	}
 
 	for _, test := range tests {
-		start := helper.AlignStartToInterval(test.inputStart, test.inputStop, test.bucketSize)
+		start := helper.AlignStartToInterval(test.inputStart, test.inputStop, test.bucketSize, nil)
 		if start != test.wantStart {
 			t.Errorf("TestAlignToInterval failed!\n%v\ngot start %d",
 				test,
@@ -1468,3 +1470,53 @@ func TestEvalCustomFromUntil(t *testing.T) {
 		})
 	}
 }
+
+func TestEvalLimits(t *testing.T) {
+	now32 := int32(time.Now().Unix())
+	m := map[parser.MetricRequest][]*types.MetricData{
+		{Metric: "metric*", From: 0, Until: 1}: {
+			types.MakeMetricData("metric1", []float64{1, 2, 3}, 1, now32),
+			types.MakeMetricData("metric2", []float64{4, 5, 6}, 1, now32),
+			types.MakeMetricData("metric3", []float64{7, 8, 9}, 1, now32),
+		},
+	}
+
+	tests := []struct {
+		target    string
+		limits    limits.Limits
+		wantLimit string
+	}{
+		{"sumSeries(metric*)", limits.Limits{}, ""},
+		{"sumSeries(metric*)", limits.Limits{MaxSeries: 3, MaxDatapoints: 12}, ""},
+		{"sumSeries(metric*)", limits.Limits{MaxSeries: 2}, "series"},
+		{"sumSeries(metric*)", limits.Limits{MaxDatapoints: 10}, "datapoints"},
+		{"absolute(metric*)", limits.Limits{MaxDatapoints: 14}, "datapoints"},
+		{"absolute(scale(metric*,2))", limits.Limits{MaxSeries: 2}, "series"},
+		{"absolute(metric*)", limits.Limits{MaxFunctionTime: time.Nanosecond}, "function time"},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s %+v", tt.target, tt.limits), func(t *testing.T) {
+			exp, _, err := parser.ParseExpr(tt.target)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx := limits.NewContext(context.Background(), limits.NewBudget(tt.limits))
+			_, err = EvalExpr(ctx, exp, 0, 1, m, noopGetTargetData)
+
+			var limitErr limits.ErrLimitExceeded
+			if tt.wantLimit == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if !errors.As(err, &limitErr) {
+				t.Fatalf("got error %v, want %T", err, limitErr)
+			}
+			if limitErr.Limit != tt.wantLimit {
+				t.Errorf("got %s limit exceeded, want %s", limitErr.Limit, tt.wantLimit)
+			}
+		})
+	}
+}
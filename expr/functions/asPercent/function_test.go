@@ -36,6 +36,15 @@ func TestAsPercent(t *testing.T) {
 			[]*types.MetricData{types.MakeMetricData("asPercent(metric1,metric2)",
 				[]float64{50, math.NaN(), math.NaN(), math.NaN(), math.NaN(), 200}, 1, now32)},
 		},
+		{
+			"pct(metric1,metric2)",
+			map[parser.MetricRequest][]*types.MetricData{
+				{"metric1", 0, 1}: {types.MakeMetricData("metric1", []float64{1, 3, 12}, 1, now32)},
+				{"metric2", 0, 1}: {types.MakeMetricData("metric2", []float64{2, math.NaN(), 6}, 1, now32)},
+			},
+			[]*types.MetricData{types.MakeMetricData("asPercent(metric1,metric2)",
+				[]float64{50, math.NaN(), 200}, 1, now32)},
+		},
 		{
 			"asPercent(metricA*,metricB*)",
 			map[parser.MetricRequest][]*types.MetricData{
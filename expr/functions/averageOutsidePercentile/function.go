@@ -0,0 +1,95 @@
+package averageOutsidePercentile
+
+import (
+	"context"
+	"math"
+
+	"github.com/bookingcom/carbonapi/expr/helper"
+	"github.com/bookingcom/carbonapi/expr/interfaces"
+	"github.com/bookingcom/carbonapi/expr/types"
+	"github.com/bookingcom/carbonapi/pkg/parser"
+)
+
+type averageOutsidePercentile struct {
+	interfaces.FunctionBase
+}
+
+func GetOrder() interfaces.Order {
+	return interfaces.Any
+}
+
+func New(configFile string) []interfaces.FunctionMetadata {
+	res := make([]interfaces.FunctionMetadata, 0)
+	f := &averageOutsidePercentile{}
+	functions := []string{"averageOutsidePercentile"}
+	for _, n := range functions {
+		res = append(res, interfaces.FunctionMetadata{Name: n, F: f})
+	}
+	return res
+}
+
+// averageOutsidePercentile(seriesList, n)
+func (f *averageOutsidePercentile) Do(ctx context.Context, e parser.Expr, from, until int32, values map[parser.MetricRequest][]*types.MetricData, getTargetData interfaces.GetTargetData) ([]*types.MetricData, error) {
+	args, err := helper.GetSeriesArg(ctx, e.Args()[0], from, until, values, getTargetData)
+	if err != nil {
+		return nil, err
+	}
+
+	n, err := e.GetFloatArg(1)
+	if err != nil {
+		return nil, err
+	}
+	if n < 50 {
+		n = 100 - n
+	}
+
+	averages := make([]float64, len(args))
+	var present []float64
+	for i, a := range args {
+		averages[i] = helper.AvgValue(a.Values, a.IsAbsent)
+		if !math.IsNaN(averages[i]) {
+			present = append(present, averages[i])
+		}
+	}
+
+	// Percentile reorders its input, so each call gets a copy
+	lowPercentile, _ := helper.Percentile(append([]float64(nil), present...), 100-n, false)
+	highPercentile, _ := helper.Percentile(present, n, false)
+
+	var results []*types.MetricData
+	for i, a := range args {
+		if math.IsNaN(averages[i]) {
+			continue
+		}
+		if !(lowPercentile < averages[i] && averages[i] < highPercentile) {
+			results = append(results, a)
+		}
+	}
+
+	return results, nil
+}
+
+// Description is auto-generated description, based on output of https://github.com/graphite-project/graphite-web
+func (f *averageOutsidePercentile) Description() map[string]types.FunctionDescription {
+	return map[string]types.FunctionDescription{
+		"averageOutsidePercentile": {
+			Description: "Removes series lying inside an average percentile interval",
+			Function:    "averageOutsidePercentile(seriesList, n)",
+			Group:       "Filter Series",
+			Module:      "graphite.render.functions",
+			Name:        "averageOutsidePercentile",
+			Params: []types.FunctionParam{
+				{
+					Name:     "seriesList",
+					Required: true,
+					Type:     types.SeriesList,
+				},
+				{
+					Name:     "n",
+					Required: true,
+					Type:     types.Integer,
+				},
+			},
+		},
+	}
+}
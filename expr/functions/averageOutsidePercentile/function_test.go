@@ -0,0 +1,72 @@
+package averageOutsidePercentile
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/bookingcom/carbonapi/expr/helper"
+	"github.com/bookingcom/carbonapi/expr/metadata"
+	"github.com/bookingcom/carbonapi/expr/types"
+	"github.com/bookingcom/carbonapi/pkg/parser"
+	th "github.com/bookingcom/carbonapi/tests"
+)
+
+func init() {
+	md := New("")
+	evaluator := th.EvaluatorFromFunc(md[0].F)
+	metadata.SetEvaluator(evaluator)
+	helper.SetEvaluator(evaluator)
+	for _, m := range md {
+		metadata.RegisterFunction(m.Name, m.F)
+	}
+}
+
+func TestAverageOutsidePercentile(t *testing.T) {
+	now32 := int32(time.Now().Unix())
+
+	tests := []th.EvalTestItem{
+		{
+			"averageOutsidePercentile(metric*,90)",
+			map[parser.MetricRequest][]*types.MetricData{
+				{"metric*", 0, 1}: {
+					types.MakeMetricData("metricA", []float64{1, 1, 1}, 1, now32),
+					types.MakeMetricData("metricB", []float64{2, 4, 3}, 1, now32),
+					types.MakeMetricData("metricC", []float64{4, math.NaN(), 4}, 1, now32),
+					types.MakeMetricData("metricD", []float64{5, 5, 5}, 1, now32),
+					types.MakeMetricData("metricE", []float64{2, 2, 2}, 1, now32),
+				},
+			},
+			[]*types.MetricData{
+				types.MakeMetricData("metricA", []float64{1, 1, 1}, 1, now32),
+				types.MakeMetricData("metricD", []float64{5, 5, 5}, 1, now32),
+				types.MakeMetricData("metricE", []float64{2, 2, 2}, 1, now32),
+			},
+		},
+		{
+			"averageOutsidePercentile(metric*,20)",
+			map[parser.MetricRequest][]*types.MetricData{
+				{"metric*", 0, 1}: {
+					types.MakeMetricData("metricA", []float64{1, 1, 1}, 1, now32),
+					types.MakeMetricData("metricB", []float64{3, 3, 3}, 1, now32),
+					types.MakeMetricData("metricC", []float64{math.NaN(), math.NaN(), math.NaN()}, 1, now32),
+					types.MakeMetricData("metricD", []float64{5, 5, 5}, 1, now32),
+					types.MakeMetricData("metricE", []float64{2, 2, 2}, 1, now32),
+					types.MakeMetricData("metricF", []float64{4, 4, 4}, 1, now32),
+				},
+			},
+			[]*types.MetricData{
+				types.MakeMetricData("metricA", []float64{1, 1, 1}, 1, now32),
+				types.MakeMetricData("metricD", []float64{5, 5, 5}, 1, now32),
+				types.MakeMetricData("metricE", []float64{2, 2, 2}, 1, now32),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		testName := tt.Target
+		t.Run(testName, func(t *testing.T) {
+			th.TestEvalExpr(t, &tt)
+		})
+	}
+}
@@ -37,9 +37,12 @@ func (f *averageSeriesWithWildcards) Do(ctx context.Context, e parser.Expr, from
 		return nil, err
 	}
 
-	fields, err := e.GetIntArgs(1)
-	if err != nil {
-		return nil, err
+	var fields []int
+	if len(e.Args()) > 1 {
+		fields, err = e.GetIntArgs(1)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	var results []*types.MetricData
@@ -42,6 +42,20 @@ func TestAverageSeriesWithWildcards(t *testing.T) {
 				"averageSeriesWithWildcards(metric1.qux)": {types.MakeMetricData("averageSeriesWithWildcards(metric1.qux)", []float64{6.5, 7.5, 8.5, 9.5, 10.5}, 1, now32)},
 			},
 		},
+		{
+			"averageSeriesWithWildcards(metric1.foo.*)",
+			map[parser.MetricRequest][]*types.MetricData{
+				{"metric1.foo.*", 0, 1}: {
+					types.MakeMetricData("metric1.foo.bar1", []float64{1, 2, 3, 4, 5}, 1, now32),
+					types.MakeMetricData("metric1.foo.bar2", []float64{11, 12, 13, 14, 15}, 1, now32),
+				},
+			},
+			"averageSeriesWithWildcardsNoPositions",
+			map[string][]*types.MetricData{
+				"averageSeriesWithWildcards(metric1.foo.bar1)": {types.MakeMetricData("averageSeriesWithWildcards(metric1.foo.bar1)", []float64{1, 2, 3, 4, 5}, 1, now32)},
+				"averageSeriesWithWildcards(metric1.foo.bar2)": {types.MakeMetricData("averageSeriesWithWildcards(metric1.foo.bar2)", []float64{11, 12, 13, 14, 15}, 1, now32)},
+			},
+		},
 	}
 
 	for _, tt := range tests {
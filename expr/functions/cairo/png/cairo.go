@@ -15,6 +15,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/bookingcom/carbonapi/expr/calendar"
 	"github.com/bookingcom/carbonapi/expr/helper"
 	"github.com/bookingcom/carbonapi/expr/interfaces"
 	"github.com/bookingcom/carbonapi/expr/types"
@@ -1993,9 +1994,9 @@ func drawYAxis(cr *cairoSurfaceContext, params *Params, results []*types.MetricD
 	}
 }
 
-func findXTimes(start int32, unit TimeUnit, step float64) (int32, int32) {
-
-	t := time.Unix(int64(start), 0)
+// findXTimes returns the first time of the x axis at or after start that is
+// aligned to the wall clock of tz, and the interval to the following ones.
+func findXTimes(start int32, unit TimeUnit, step float64, tz *time.Location) (int32, int32) {
 
 	var d time.Duration
 
@@ -2013,18 +2014,19 @@ func findXTimes(start int32, unit TimeUnit, step float64) (int32, int32) {
 	}
 
 	d *= time.Duration(step)
-	t = t.Truncate(d)
+	delta := int32(d / time.Second)
 
-	for t.Unix() < int64(start) {
-		t = t.Add(d)
+	t := calendar.Truncate(start, delta, tz)
+	if t < start {
+		t = calendar.Next(t, delta, tz)
 	}
 
-	return int32(t.Unix()), int32(d / time.Second)
+	return t, delta
 }
 
 func drawXAxis(cr *cairoSurfaceContext, params *Params, results []*types.MetricData) {
 
-	dt, xDelta := findXTimes(params.startTime, params.xConf.labelUnit, float64(params.xConf.labelStep))
+	dt, xDelta := findXTimes(params.startTime, params.xConf.labelUnit, float64(params.xConf.labelStep), params.tz)
 
 	xFormat := params.xFormat
 	if xFormat == "" {
@@ -2038,7 +2040,7 @@ func drawXAxis(cr *cairoSurfaceContext, params *Params, results []*types.MetricD
 		x := params.area.xmin + float64(dt-params.startTime)*params.xScaleFactor
 		y := params.area.ymax + maxAscent
 		drawText(cr, params, label, x, y, HAlignCenter, VAlignTop, 0)
-		dt += xDelta
+		dt = calendar.Next(dt, xDelta, params.tz)
 	}
 }
 
@@ -2134,7 +2136,7 @@ func drawGridLines(cr *cairoSurfaceContext, params *Params, results []*types.Met
 	// First we do the minor grid lines (majors will paint over them)
 	cr.context.SetLineWidth(0.25)
 	setColor(cr, string2RGBA(params.minorGridLineColor))
-	dt, xMinorDelta := findXTimes(params.startTime, params.xConf.minorGridUnit, params.xConf.minorGridStep)
+	dt, xMinorDelta := findXTimes(params.startTime, params.xConf.minorGridUnit, params.xConf.minorGridStep, params.tz)
 
 	for dt < params.endTime {
 		x := params.area.xmin + float64(dt-params.startTime)*params.xScaleFactor
@@ -2145,13 +2147,13 @@ func drawGridLines(cr *cairoSurfaceContext, params *Params, results []*types.Met
 			cr.context.Stroke()
 		}
 
-		dt += xMinorDelta
+		dt = calendar.Next(dt, xMinorDelta, params.tz)
 	}
 
 	// Now we do the major grid lines
 	cr.context.SetLineWidth(0.33)
 	setColor(cr, string2RGBA(params.majorGridLineColor))
-	dt, xMajorDelta := findXTimes(params.startTime, params.xConf.majorGridUnit, float64(params.xConf.majorGridStep))
+	dt, xMajorDelta := findXTimes(params.startTime, params.xConf.majorGridUnit, float64(params.xConf.majorGridStep), params.tz)
 
 	for dt < params.endTime {
 		x := params.area.xmin + float64(dt-params.startTime)*params.xScaleFactor
@@ -2162,7 +2164,7 @@ func drawGridLines(cr *cairoSurfaceContext, params *Params, results []*types.Met
 			cr.context.Stroke()
 		}
 
-		dt += xMajorDelta
+		dt = calendar.Next(dt, xMajorDelta, params.tz)
 	}
 
 	// Draw side borders for our graph area
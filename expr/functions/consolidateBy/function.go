@@ -61,6 +61,7 @@ func (f *consolidateBy) Do(ctx context.Context, e parser.Expr, from, until int32
 		}
 
 		r.AggregateFunction = f
+		r.ConsolidationFunc = name
 
 		results = append(results, &r)
 	}
@@ -0,0 +1,49 @@
+package consolidateBy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bookingcom/carbonapi/expr/helper"
+	"github.com/bookingcom/carbonapi/expr/metadata"
+	"github.com/bookingcom/carbonapi/expr/types"
+	"github.com/bookingcom/carbonapi/pkg/parser"
+	th "github.com/bookingcom/carbonapi/tests"
+)
+
+func init() {
+	md := New("")
+	evaluator := th.EvaluatorFromFunc(md[0].F)
+	metadata.SetEvaluator(evaluator)
+	helper.SetEvaluator(evaluator)
+	for _, m := range md {
+		metadata.RegisterFunction(m.Name, m.F)
+	}
+}
+
+func TestConsolidateBy(t *testing.T) {
+	values := []float64{1, 5, 2, 3, 4, 6}
+	m := map[parser.MetricRequest][]*types.MetricData{
+		{Metric: "metric1", From: 0, Until: 1}: {types.MakeMetricData("metric1", values, 60, 0)},
+	}
+	exp, _, err := parser.ParseExpr("consolidateBy(metric1,'max')")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g, err := metadata.GetEvaluator().EvalExpr(context.Background(), exp, 0, 1, m, th.NoopGetTargetData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// points are consolidated only when the response is written
+	if g[0].StepTime != 60 || !th.NearlyEqual(g[0].Values, g[0].IsAbsent, values) {
+		t.Errorf("Expected points to be kept, got %v with step %d", g[0].Values, g[0].StepTime)
+	}
+	if g[0].ConsolidationFunc != "max" || g[0].AggregateFunction == nil {
+		t.Errorf("Expected consolidation function max, got %s", g[0].ConsolidationFunc)
+	}
+	if v, _ := g[0].AggregateFunction([]float64{1, 5, 2}, []bool{false, false, false}); v != 5 {
+		t.Errorf("Expected the aggregate function to take the maximum, got %v", v)
+	}
+}
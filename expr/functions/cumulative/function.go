@@ -38,6 +38,7 @@ func (f *cumulative) Do(ctx context.Context, e parser.Expr, from, until int32, v
 	for _, a := range arg {
 		r := *a
 		r.AggregateFunction = types.AggSum
+		r.ConsolidationFunc = "sum"
 		results = append(results, &r)
 	}
 	return results, nil
@@ -100,9 +100,8 @@ func (f *divideSeries) Description() map[string]types.FunctionDescription {
 					Type:     types.SeriesList,
 				},
 				{
-					Name:     "divisorSeries",
-					Required: true,
-					Type:     types.SeriesList,
+					Name: "divisorSeries",
+					Type: types.SeriesList,
 				},
 			},
 		},
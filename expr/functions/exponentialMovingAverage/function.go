@@ -0,0 +1,159 @@
+package exponentialMovingAverage
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/bookingcom/carbonapi/expr/helper"
+	"github.com/bookingcom/carbonapi/expr/interfaces"
+	"github.com/bookingcom/carbonapi/expr/types"
+	"github.com/bookingcom/carbonapi/pkg/parser"
+)
+
+type exponentialMovingAverage struct {
+	interfaces.FunctionBase
+}
+
+func GetOrder() interfaces.Order {
+	return interfaces.Any
+}
+
+func New(configFile string) []interfaces.FunctionMetadata {
+	res := make([]interfaces.FunctionMetadata, 0)
+	f := &exponentialMovingAverage{}
+	functions := []string{"exponentialMovingAverage"}
+	for _, n := range functions {
+		res = append(res, interfaces.FunctionMetadata{Name: n, F: f})
+	}
+	return res
+}
+
+// exponentialMovingAverage(seriesList, windowSize)
+func (f *exponentialMovingAverage) Do(ctx context.Context, e parser.Expr, from, until int32, values map[parser.MetricRequest][]*types.MetricData, getTargetData interfaces.GetTargetData) ([]*types.MetricData, error) {
+	if len(e.Args()) < 2 {
+		return nil, parser.ErrMissingArgument
+	}
+
+	var n int
+	var err error
+
+	var scaleByStep bool
+
+	var argstr string
+
+	switch e.Args()[1].Type() {
+	case parser.EtConst:
+		n, err = e.GetIntArg(1)
+		argstr = strconv.Itoa(n)
+	case parser.EtString:
+		var n32 int32
+		n32, err = e.GetIntervalArg(1, 1)
+		argstr = fmt.Sprintf("%q", e.Args()[1].StringValue())
+		n = int(n32)
+		scaleByStep = true
+	default:
+		err = parser.ErrBadType
+	}
+	if err != nil {
+		return nil, err
+	}
+	if n <= 0 {
+		return nil, parser.ErrInvalidArgumentValue
+	}
+
+	start := from
+	if scaleByStep {
+		start -= int32(n)
+	}
+
+	arg, err := helper.GetSeriesArg(ctx, e.Args()[0], start, until, values, getTargetData)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*types.MetricData, 0, len(arg))
+	for _, a := range arg {
+		windowPoints := n
+		var offset int
+		if scaleByStep {
+			windowPoints = n / int(a.StepTime)
+			offset = windowPoints
+		}
+		if windowPoints < 1 {
+			windowPoints = 1
+		}
+		if offset > len(a.Values) {
+			offset = len(a.Values)
+		}
+
+		r := *a
+		r.Name = fmt.Sprintf("exponentialMovingAverage(%s,%s)", a.Name, argstr)
+		r.Values = make([]float64, len(a.Values)-offset)
+		r.IsAbsent = make([]bool, len(a.Values)-offset)
+		r.StartTime = a.StartTime + int32(offset)*a.StepTime
+
+		constant := 2 / (float64(windowPoints) + 1)
+
+		// the first window is seeded with its plain average
+		ema := math.NaN()
+		var sum float64
+		var count int
+		for i, v := range a.Values {
+			switch {
+			case i < windowPoints:
+				if !a.IsAbsent[i] {
+					sum += v
+					count++
+				}
+				if i == windowPoints-1 && count > 0 {
+					ema = sum / float64(count)
+				}
+			case !a.IsAbsent[i] && math.IsNaN(ema):
+				ema = v
+			case !a.IsAbsent[i]:
+				ema = constant*v + (1-constant)*ema
+			}
+
+			ridx := i - offset
+			if ridx < 0 {
+				continue
+			}
+			if i < windowPoints-1 || math.IsNaN(ema) {
+				r.IsAbsent[ridx] = true
+				continue
+			}
+			r.Values[ridx] = ema
+		}
+
+		results = append(results, &r)
+	}
+
+	return results, nil
+}
+
+// Description is auto-generated description, based on output of https://github.com/graphite-project/graphite-web
+func (f *exponentialMovingAverage) Description() map[string]types.FunctionDescription {
+	return map[string]types.FunctionDescription{
+		"exponentialMovingAverage": {
+			Description: "Takes a series of values and a window size and produces an exponential moving\naverage utilizing the following formula:\n\n.. code-block:: none\n\n  ema(current) = constant * (Current Value) + (1 - constant) * ema(previous)\n\nThe Constant is calculated as:\n\n.. code-block:: none\n\n  constant = 2 / (windowSize + 1)\n\nThe first period EMA uses a simple moving average for its value.\n\nExample:\n\n.. code-block:: none\n\n  &target=exponentialMovingAverage(*.transactions.count, 10)\n  &target=exponentialMovingAverage(*.transactions.count, '-10s')",
+			Function:    "exponentialMovingAverage(seriesList, windowSize)",
+			Group:       "Calculate",
+			Module:      "graphite.render.functions",
+			Name:        "exponentialMovingAverage",
+			Params: []types.FunctionParam{
+				{
+					Name:     "seriesList",
+					Required: true,
+					Type:     types.SeriesList,
+				},
+				{
+					Name:     "windowSize",
+					Required: true,
+					Type:     types.IntOrInterval,
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,61 @@
+package exponentialMovingAverage
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/bookingcom/carbonapi/expr/helper"
+	"github.com/bookingcom/carbonapi/expr/metadata"
+	"github.com/bookingcom/carbonapi/expr/types"
+	"github.com/bookingcom/carbonapi/pkg/parser"
+	th "github.com/bookingcom/carbonapi/tests"
+)
+
+func init() {
+	md := New("")
+	evaluator := th.EvaluatorFromFunc(md[0].F)
+	metadata.SetEvaluator(evaluator)
+	helper.SetEvaluator(evaluator)
+	for _, m := range md {
+		metadata.RegisterFunction(m.Name, m.F)
+	}
+}
+
+func TestExponentialMovingAverage(t *testing.T) {
+	now32 := int32(time.Now().Unix())
+
+	tests := []th.EvalTestItem{
+		{
+			"exponentialMovingAverage(metric1,3)",
+			map[parser.MetricRequest][]*types.MetricData{
+				{"metric1", 0, 1}: {types.MakeMetricData("metric1", []float64{1, 2, 3, 4, 5}, 1, now32)},
+			},
+			[]*types.MetricData{types.MakeMetricData("exponentialMovingAverage(metric1,3)",
+				[]float64{math.NaN(), math.NaN(), 2, 3, 4}, 1, now32)},
+		},
+		{
+			"exponentialMovingAverage(metric1,3)",
+			map[parser.MetricRequest][]*types.MetricData{
+				{"metric1", 0, 1}: {types.MakeMetricData("metric1", []float64{math.NaN(), 2, 4, math.NaN(), 6}, 1, now32)},
+			},
+			[]*types.MetricData{types.MakeMetricData("exponentialMovingAverage(metric1,3)",
+				[]float64{math.NaN(), math.NaN(), 3, 3, 4.5}, 1, now32)},
+		},
+		{
+			"exponentialMovingAverage(metric1,'3s')",
+			map[parser.MetricRequest][]*types.MetricData{
+				{"metric1", -3, 1}: {types.MakeMetricData("metric1", []float64{1, 2, 3, 4, 5, 6}, 1, now32)},
+			},
+			[]*types.MetricData{types.MakeMetricData(`exponentialMovingAverage(metric1,"3s")`,
+				[]float64{3, 4, 5}, 1, now32+3)},
+		},
+	}
+
+	for _, tt := range tests {
+		testName := tt.Target
+		t.Run(testName, func(t *testing.T) {
+			th.TestEvalExpr(t, &tt)
+		})
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/bookingcom/carbonapi/expr/functions/aliasSub"
 	"github.com/bookingcom/carbonapi/expr/functions/applyByNode"
 	"github.com/bookingcom/carbonapi/expr/functions/asPercent"
+	"github.com/bookingcom/carbonapi/expr/functions/averageOutsidePercentile"
 	"github.com/bookingcom/carbonapi/expr/functions/averageSeries"
 	"github.com/bookingcom/carbonapi/expr/functions/averageSeriesWithWildcards"
 	"github.com/bookingcom/carbonapi/expr/functions/below"
@@ -27,9 +28,11 @@ import (
 	"github.com/bookingcom/carbonapi/expr/functions/divideSeries"
 	"github.com/bookingcom/carbonapi/expr/functions/ewma"
 	"github.com/bookingcom/carbonapi/expr/functions/exclude"
+	"github.com/bookingcom/carbonapi/expr/functions/exponentialMovingAverage"
 	"github.com/bookingcom/carbonapi/expr/functions/fallbackSeries"
 	"github.com/bookingcom/carbonapi/expr/functions/fft"
 	"github.com/bookingcom/carbonapi/expr/functions/filterSeries"
+	"github.com/bookingcom/carbonapi/expr/functions/graphiteWeb"
 	"github.com/bookingcom/carbonapi/expr/functions/grep"
 	"github.com/bookingcom/carbonapi/expr/functions/group"
 	"github.com/bookingcom/carbonapi/expr/functions/groupByNode"
@@ -38,9 +41,11 @@ import (
 	"github.com/bookingcom/carbonapi/expr/functions/holtWintersAberration"
 	"github.com/bookingcom/carbonapi/expr/functions/holtWintersConfidenceBands"
 	"github.com/bookingcom/carbonapi/expr/functions/holtWintersForecast"
+	"github.com/bookingcom/carbonapi/expr/functions/identity"
 	"github.com/bookingcom/carbonapi/expr/functions/ifft"
 	"github.com/bookingcom/carbonapi/expr/functions/integral"
 	"github.com/bookingcom/carbonapi/expr/functions/integralByInterval"
+	"github.com/bookingcom/carbonapi/expr/functions/interpolate"
 	"github.com/bookingcom/carbonapi/expr/functions/invert"
 	"github.com/bookingcom/carbonapi/expr/functions/isNotNull"
 	"github.com/bookingcom/carbonapi/expr/functions/keepLastValue"
@@ -68,14 +73,20 @@ import (
 	"github.com/bookingcom/carbonapi/expr/functions/percentileOfSeries"
 	"github.com/bookingcom/carbonapi/expr/functions/polyfit"
 	"github.com/bookingcom/carbonapi/expr/functions/pow"
+	"github.com/bookingcom/carbonapi/expr/functions/powSeries"
 	"github.com/bookingcom/carbonapi/expr/functions/randomWalk"
 	"github.com/bookingcom/carbonapi/expr/functions/rangeOfSeries"
 	"github.com/bookingcom/carbonapi/expr/functions/reduce"
 	"github.com/bookingcom/carbonapi/expr/functions/removeBelowSeries"
+	"github.com/bookingcom/carbonapi/expr/functions/removeBetweenPercentile"
 	"github.com/bookingcom/carbonapi/expr/functions/removeEmptySeries"
+	"github.com/bookingcom/carbonapi/expr/functions/round"
 	"github.com/bookingcom/carbonapi/expr/functions/scale"
 	"github.com/bookingcom/carbonapi/expr/functions/scaleToSeconds"
 	"github.com/bookingcom/carbonapi/expr/functions/seriesList"
+	"github.com/bookingcom/carbonapi/expr/functions/setXFilesFactor"
+	"github.com/bookingcom/carbonapi/expr/functions/sinFunction"
+	"github.com/bookingcom/carbonapi/expr/functions/smartSummarize"
 	"github.com/bookingcom/carbonapi/expr/functions/sortBy"
 	"github.com/bookingcom/carbonapi/expr/functions/sortByName"
 	"github.com/bookingcom/carbonapi/expr/functions/squareRoot"
@@ -88,9 +99,14 @@ import (
 	"github.com/bookingcom/carbonapi/expr/functions/timeFunction"
 	"github.com/bookingcom/carbonapi/expr/functions/timeLag"
 	"github.com/bookingcom/carbonapi/expr/functions/timeShift"
+	"github.com/bookingcom/carbonapi/expr/functions/timeSlice"
 	"github.com/bookingcom/carbonapi/expr/functions/timeStack"
 	"github.com/bookingcom/carbonapi/expr/functions/transformNull"
 	"github.com/bookingcom/carbonapi/expr/functions/tukey"
+	"github.com/bookingcom/carbonapi/expr/functions/unique"
+	"github.com/bookingcom/carbonapi/expr/functions/useSeriesAbove"
+	"github.com/bookingcom/carbonapi/expr/functions/verticalLine"
+	"github.com/bookingcom/carbonapi/expr/functions/weightedAverage"
 	"github.com/bookingcom/carbonapi/expr/interfaces"
 	"github.com/bookingcom/carbonapi/expr/metadata"
 )
@@ -102,7 +118,7 @@ type initFunc struct {
 }
 
 func New(configs map[string]string) {
-	funcs := make([]initFunc, 0, 87)
+	funcs := make([]initFunc, 0, 103)
 
 	funcs = append(funcs, initFunc{name: "absolute", order: absolute.GetOrder(), f: absolute.New})
 
@@ -118,6 +134,8 @@ func New(configs map[string]string) {
 
 	funcs = append(funcs, initFunc{name: "asPercent", order: asPercent.GetOrder(), f: asPercent.New})
 
+	funcs = append(funcs, initFunc{name: "averageOutsidePercentile", order: averageOutsidePercentile.GetOrder(), f: averageOutsidePercentile.New})
+
 	funcs = append(funcs, initFunc{name: "averageSeries", order: averageSeries.GetOrder(), f: averageSeries.New})
 
 	funcs = append(funcs, initFunc{name: "averageSeriesWithWildcards", order: averageSeriesWithWildcards.GetOrder(), f: averageSeriesWithWildcards.New})
@@ -150,12 +168,16 @@ func New(configs map[string]string) {
 
 	funcs = append(funcs, initFunc{name: "exclude", order: exclude.GetOrder(), f: exclude.New})
 
+	funcs = append(funcs, initFunc{name: "exponentialMovingAverage", order: exponentialMovingAverage.GetOrder(), f: exponentialMovingAverage.New})
+
 	funcs = append(funcs, initFunc{name: "fallbackSeries", order: fallbackSeries.GetOrder(), f: fallbackSeries.New})
 
 	funcs = append(funcs, initFunc{name: "fft", order: fft.GetOrder(), f: fft.New})
 
 	funcs = append(funcs, initFunc{name: "filterSeries", order: filterSeries.GetOrder(), f: filterSeries.New})
 
+	funcs = append(funcs, initFunc{name: "graphiteWeb", order: graphiteWeb.GetOrder(), f: graphiteWeb.New})
+
 	funcs = append(funcs, initFunc{name: "grep", order: grep.GetOrder(), f: grep.New})
 
 	funcs = append(funcs, initFunc{name: "group", order: group.GetOrder(), f: group.New})
@@ -172,12 +194,16 @@ func New(configs map[string]string) {
 
 	funcs = append(funcs, initFunc{name: "holtWintersForecast", order: holtWintersForecast.GetOrder(), f: holtWintersForecast.New})
 
+	funcs = append(funcs, initFunc{name: "identity", order: identity.GetOrder(), f: identity.New})
+
 	funcs = append(funcs, initFunc{name: "ifft", order: ifft.GetOrder(), f: ifft.New})
 
 	funcs = append(funcs, initFunc{name: "integral", order: integral.GetOrder(), f: integral.New})
 
 	funcs = append(funcs, initFunc{name: "integralByInterval", order: integralByInterval.GetOrder(), f: integralByInterval.New})
 
+	funcs = append(funcs, initFunc{name: "interpolate", order: interpolate.GetOrder(), f: interpolate.New})
+
 	funcs = append(funcs, initFunc{name: "invert", order: invert.GetOrder(), f: invert.New})
 
 	funcs = append(funcs, initFunc{name: "isNotNull", order: isNotNull.GetOrder(), f: isNotNull.New})
@@ -232,6 +258,8 @@ func New(configs map[string]string) {
 
 	funcs = append(funcs, initFunc{name: "pow", order: pow.GetOrder(), f: pow.New})
 
+	funcs = append(funcs, initFunc{name: "powSeries", order: powSeries.GetOrder(), f: powSeries.New})
+
 	funcs = append(funcs, initFunc{name: "randomWalk", order: randomWalk.GetOrder(), f: randomWalk.New})
 
 	funcs = append(funcs, initFunc{name: "rangeOfSeries", order: rangeOfSeries.GetOrder(), f: rangeOfSeries.New})
@@ -240,14 +268,24 @@ func New(configs map[string]string) {
 
 	funcs = append(funcs, initFunc{name: "removeBelowSeries", order: removeBelowSeries.GetOrder(), f: removeBelowSeries.New})
 
+	funcs = append(funcs, initFunc{name: "removeBetweenPercentile", order: removeBetweenPercentile.GetOrder(), f: removeBetweenPercentile.New})
+
 	funcs = append(funcs, initFunc{name: "removeEmptySeries", order: removeEmptySeries.GetOrder(), f: removeEmptySeries.New})
 
+	funcs = append(funcs, initFunc{name: "round", order: round.GetOrder(), f: round.New})
+
 	funcs = append(funcs, initFunc{name: "scale", order: scale.GetOrder(), f: scale.New})
 
 	funcs = append(funcs, initFunc{name: "scaleToSeconds", order: scaleToSeconds.GetOrder(), f: scaleToSeconds.New})
 
 	funcs = append(funcs, initFunc{name: "seriesList", order: seriesList.GetOrder(), f: seriesList.New})
 
+	funcs = append(funcs, initFunc{name: "setXFilesFactor", order: setXFilesFactor.GetOrder(), f: setXFilesFactor.New})
+
+	funcs = append(funcs, initFunc{name: "sinFunction", order: sinFunction.GetOrder(), f: sinFunction.New})
+
+	funcs = append(funcs, initFunc{name: "smartSummarize", order: smartSummarize.GetOrder(), f: smartSummarize.New})
+
 	funcs = append(funcs, initFunc{name: "sortBy", order: sortBy.GetOrder(), f: sortBy.New})
 
 	funcs = append(funcs, initFunc{name: "sortByName", order: sortByName.GetOrder(), f: sortByName.New})
@@ -272,12 +310,22 @@ func New(configs map[string]string) {
 
 	funcs = append(funcs, initFunc{name: "timeShift", order: timeShift.GetOrder(), f: timeShift.New})
 
+	funcs = append(funcs, initFunc{name: "timeSlice", order: timeSlice.GetOrder(), f: timeSlice.New})
+
 	funcs = append(funcs, initFunc{name: "timeStack", order: timeStack.GetOrder(), f: timeStack.New})
 
 	funcs = append(funcs, initFunc{name: "transformNull", order: transformNull.GetOrder(), f: transformNull.New})
 
 	funcs = append(funcs, initFunc{name: "tukey", order: tukey.GetOrder(), f: tukey.New})
 
+	funcs = append(funcs, initFunc{name: "unique", order: unique.GetOrder(), f: unique.New})
+
+	funcs = append(funcs, initFunc{name: "useSeriesAbove", order: useSeriesAbove.GetOrder(), f: useSeriesAbove.New})
+
+	funcs = append(funcs, initFunc{name: "verticalLine", order: verticalLine.GetOrder(), f: verticalLine.New})
+
+	funcs = append(funcs, initFunc{name: "weightedAverage", order: weightedAverage.GetOrder(), f: weightedAverage.New})
+
 	sort.Slice(funcs, func(i, j int) bool {
 		if funcs[i].order == interfaces.Any && funcs[j].order == interfaces.Last {
 			return true
@@ -0,0 +1,334 @@
+package graphiteWeb
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/bookingcom/carbonapi/expr/evalctx"
+	"github.com/bookingcom/carbonapi/expr/interfaces"
+	"github.com/bookingcom/carbonapi/expr/metadata"
+	"github.com/bookingcom/carbonapi/expr/types"
+	"github.com/bookingcom/carbonapi/pkg/parser"
+	"github.com/lomik/zapwriter"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v2"
+)
+
+// graphiteWeb proxies evaluation of functions that carbonapi lacks to graphite-web
+type graphiteWeb struct {
+	interfaces.FunctionBase
+
+	client       *http.Client
+	fallbackUrls []string
+	next         uint32
+
+	descriptions map[string]types.FunctionDescription
+}
+
+type graphiteWebConfig struct {
+	Enabled                  bool          `yaml:"enabled"`
+	FallbackUrls             []string      `yaml:"fallbackUrls"`
+	Strict                   bool          `yaml:"strict"`
+	MaxConcurrentConnections int           `yaml:"maxConcurrentConnections"`
+	Timeout                  time.Duration `yaml:"timeout"`
+	KeepAliveInterval        time.Duration `yaml:"keepAliveInterval"`
+	ForceAdd                 []string      `yaml:"forceAdd"`
+	ForceSkip                []string      `yaml:"forceSkip"`
+}
+
+func GetOrder() interfaces.Order {
+	return interfaces.Last
+}
+
+func New(configFile string) []interfaces.FunctionMetadata {
+	logger := zapwriter.Logger("graphiteWeb")
+	if configFile == "" {
+		logger.Debug("no config file specified, graphite-web fallback is disabled")
+		return nil
+	}
+
+	cfg, err := readConfig(configFile)
+	if err != nil {
+		logger.Error("failed to read config file, graphite-web fallback is disabled",
+			zap.String("config_file", configFile),
+			zap.Error(err),
+		)
+		return nil
+	}
+
+	if !cfg.Enabled {
+		logger.Info("graphite-web fallback is disabled by config")
+		return nil
+	}
+	if len(cfg.FallbackUrls) == 0 {
+		logger.Error("graphite-web fallback is enabled, but no fallbackUrls are specified")
+		return nil
+	}
+
+	f := &graphiteWeb{
+		client: &http.Client{
+			Timeout: cfg.Timeout,
+			Transport: &http.Transport{
+				MaxConnsPerHost:     cfg.MaxConcurrentConnections,
+				MaxIdleConnsPerHost: cfg.MaxConcurrentConnections,
+				DialContext: (&net.Dialer{
+					Timeout:   cfg.Timeout,
+					KeepAlive: cfg.KeepAliveInterval,
+					DualStack: true,
+				}).DialContext,
+			},
+		},
+		fallbackUrls: cfg.FallbackUrls,
+		descriptions: make(map[string]types.FunctionDescription),
+	}
+
+	remote, err := f.fetchFunctions(context.Background())
+	if err != nil {
+		logger.Error("failed to get list of functions from graphite-web, graphite-web fallback is disabled",
+			zap.Strings("fallback_urls", cfg.FallbackUrls),
+			zap.Error(err),
+		)
+		return nil
+	}
+
+	forceAdd := make(map[string]bool, len(cfg.ForceAdd))
+	for _, n := range cfg.ForceAdd {
+		forceAdd[n] = true
+	}
+	forceSkip := make(map[string]bool, len(cfg.ForceSkip))
+	for _, n := range cfg.ForceSkip {
+		forceSkip[n] = true
+	}
+
+	metadata.FunctionMD.RLock()
+	for name, d := range remote {
+		if forceSkip[name] {
+			continue
+		}
+		if _, ok := metadata.FunctionMD.Functions[name]; ok && !forceAdd[name] {
+			local, hasDescription := metadata.FunctionMD.Descriptions[name]
+			if !cfg.Strict || !hasDescription || d.Params == nil || paramsEqual(local.Params, d.Params) {
+				continue
+			}
+			logger.Info("function parameters differ from graphite-web, will proxy it",
+				zap.String("function", name),
+			)
+		}
+		d.Proxied = true
+		f.descriptions[name] = d
+	}
+	metadata.FunctionMD.RUnlock()
+
+	res := make([]interfaces.FunctionMetadata, 0, len(f.descriptions))
+	for n := range f.descriptions {
+		res = append(res, interfaces.FunctionMetadata{Name: n, F: f})
+	}
+
+	logger.Info("registered functions proxied to graphite-web",
+		zap.Int("count", len(res)),
+	)
+
+	return res
+}
+
+func readConfig(configFile string) (graphiteWebConfig, error) {
+	cfg := graphiteWebConfig{
+		MaxConcurrentConnections: 10,
+		Timeout:                  60 * time.Second,
+		KeepAliveInterval:        30 * time.Second,
+	}
+
+	fh, err := os.Open(configFile)
+	if err != nil {
+		return cfg, err
+	}
+	defer fh.Close()
+
+	err = yaml.NewDecoder(fh).Decode(&cfg)
+	return cfg, err
+}
+
+// nextURL returns graphite-web URLs in round-robin order
+func (f *graphiteWeb) nextURL() string {
+	n := atomic.AddUint32(&f.next, 1)
+	return f.fallbackUrls[int(n)%len(f.fallbackUrls)]
+}
+
+func (f *graphiteWeb) get(ctx context.Context, u string) ([]byte, error) {
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := f.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("graphite-web returned %d: %s", resp.StatusCode, body)
+	}
+
+	return body, nil
+}
+
+// fetchFunctions gets function descriptions from the first graphite-web that answers
+func (f *graphiteWeb) fetchFunctions(ctx context.Context) (map[string]types.FunctionDescription, error) {
+	var lastErr error
+	for range f.fallbackUrls {
+		body, err := f.get(ctx, f.nextURL()+"/functions/")
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		var raw map[string]json.RawMessage
+		if err := json.Unmarshal(body, &raw); err != nil {
+			lastErr = err
+			continue
+		}
+
+		res := make(map[string]types.FunctionDescription, len(raw))
+		for name, r := range raw {
+			var d types.FunctionDescription
+			if err := json.Unmarshal(r, &d); err != nil {
+				// graphite-web may describe parameters with types carbonapi doesn't know,
+				// the function is still usable without them
+				var short struct {
+					Description string `json:"description"`
+					Function    string `json:"function"`
+					Group       string `json:"group"`
+					Module      string `json:"module"`
+				}
+				if err := json.Unmarshal(r, &short); err != nil {
+					continue
+				}
+				d = types.FunctionDescription{
+					Description: short.Description,
+					Function:    short.Function,
+					Group:       short.Group,
+					Module:      short.Module,
+				}
+			}
+			d.Name = name
+			res[name] = d
+		}
+
+		return res, nil
+	}
+
+	return nil, lastErr
+}
+
+func paramsEqual(a, b []types.FunctionParam) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Name != b[i].Name || a[i].Type != b[i].Type ||
+			a[i].Required != b[i].Required || a[i].Multiple != b[i].Multiple ||
+			!reflect.DeepEqual(a[i].Options, b[i].Options) {
+			return false
+		}
+	}
+	return true
+}
+
+type graphiteMetric struct {
+	Target     string        `json:"target"`
+	Datapoints [][2]*float64 `json:"datapoints"`
+}
+
+func (f *graphiteWeb) Do(ctx context.Context, e parser.Expr, from, until int32, values map[parser.MetricRequest][]*types.MetricData, getTargetData interfaces.GetTargetData) ([]*types.MetricData, error) {
+	q := url.Values{
+		"target": []string{e.ToString()},
+		"from":   []string{strconv.Itoa(int(from))},
+		"until":  []string{strconv.Itoa(int(until))},
+		"format": []string{"json"},
+	}
+	ec := evalctx.FromContext(ctx)
+	// graphite-web aligns to its own time zone unless told the one of the request
+	if ec.Location != nil && ec.Location != time.Local {
+		q.Set("tz", ec.Location.String())
+	}
+
+	var lastErr error
+	for range f.fallbackUrls {
+		u := f.nextURL()
+		body, err := f.get(ctx, u+"/render/?"+q.Encode())
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			ec.Logger.Warn("graphite-web request failed",
+				zap.String("url", u),
+				zap.String("target", e.ToString()),
+				zap.Error(err),
+			)
+			lastErr = err
+			continue
+		}
+
+		return decodeRender(body, from, until)
+	}
+
+	return nil, fmt.Errorf("%s: graphite-web request failed: %w", e.Target(), lastErr)
+}
+
+func decodeRender(body []byte, from, until int32) ([]*types.MetricData, error) {
+	var metrics []graphiteMetric
+	if err := json.Unmarshal(body, &metrics); err != nil {
+		return nil, err
+	}
+
+	results := make([]*types.MetricData, 0, len(metrics))
+	for _, m := range metrics {
+		vals := make([]float64, len(m.Datapoints))
+		isAbsent := make([]bool, len(m.Datapoints))
+
+		start, step := from, until-from
+		for i, p := range m.Datapoints {
+			if p[1] == nil {
+				return nil, fmt.Errorf("%s: datapoint %d has no timestamp", m.Target, i)
+			}
+			switch i {
+			case 0:
+				start = int32(*p[1])
+			case 1:
+				step = int32(*p[1]) - start
+			}
+
+			if p[0] == nil {
+				isAbsent[i] = true
+			} else {
+				vals[i] = *p[0]
+			}
+		}
+		if step <= 0 {
+			step = 1
+		}
+
+		results = append(results, types.New(m.Target, vals, isAbsent, step, start))
+	}
+
+	return results, nil
+}
+
+func (f *graphiteWeb) Description() map[string]types.FunctionDescription {
+	return f.descriptions
+}
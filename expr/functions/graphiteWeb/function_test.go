@@ -0,0 +1,173 @@
+package graphiteWeb
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/bookingcom/carbonapi/expr/evalctx"
+	"github.com/bookingcom/carbonapi/expr/functions/sum"
+	"github.com/bookingcom/carbonapi/expr/helper"
+	"github.com/bookingcom/carbonapi/expr/metadata"
+	"github.com/bookingcom/carbonapi/expr/types"
+	"github.com/bookingcom/carbonapi/pkg/parser"
+	th "github.com/bookingcom/carbonapi/tests"
+)
+
+const functionsResponse = `{
+	"sumSeries": {"name": "sumSeries", "function": "sumSeries(*seriesLists)", "group": "Combine", "module": "graphite.render.functions", "description": "", "params": [{"name": "seriesLists", "type": "seriesList", "required": true, "multiple": true}]},
+	"aliasByTags": {"name": "aliasByTags", "function": "aliasByTags(seriesList, *tags)", "group": "Alias", "module": "graphite.render.functions", "description": "", "params": [{"name": "seriesList", "type": "seriesList", "required": true}]},
+	"newFunction": {"name": "newFunction", "function": "newFunction(seriesList, n)", "group": "Transform", "module": "graphite.render.functions", "description": "", "params": [{"name": "seriesList", "type": "seriesList", "required": true}, {"name": "n", "type": "any"}]}
+}`
+
+func newServer(t *testing.T, tz *string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/functions/":
+			fmt.Fprint(w, functionsResponse)
+		case "/render/":
+			if got := r.FormValue("target"); got != "newFunction(foo.bar,2)" {
+				t.Errorf("unexpected target: %s", got)
+			}
+			if got := r.FormValue("format"); got != "json" {
+				t.Errorf("unexpected format: %s", got)
+			}
+			*tz = r.FormValue("tz")
+			fmt.Fprint(w, `[{"target": "newFunction(foo.bar,2)", "datapoints": [[1.5, 100], [null, 160], [3, 220]]}]`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func writeConfig(t *testing.T, cfg string) string {
+	fh, err := ioutil.TempFile("", "graphiteWeb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fh.Close()
+	if _, err := fh.WriteString(cfg); err != nil {
+		t.Fatal(err)
+	}
+	return fh.Name()
+}
+
+func TestGraphiteWeb(t *testing.T) {
+	var tz string
+	srv := newServer(t, &tz)
+	defer srv.Close()
+
+	for _, m := range sum.New("") {
+		metadata.RegisterFunction(m.Name, m.F)
+	}
+
+	configFile := writeConfig(t, fmt.Sprintf(`
+enabled: true
+fallbackUrls:
+    - %s
+forceSkip:
+    - "aliasByTags"
+`, srv.URL))
+	defer os.Remove(configFile)
+
+	md := New(configFile)
+	if len(md) != 1 || md[0].Name != "newFunction" {
+		t.Fatalf("expected only newFunction to be proxied, got %+v", md)
+	}
+	for _, m := range md {
+		metadata.RegisterFunction(m.Name, m.F)
+	}
+	evaluator := th.EvaluatorFromFuncWithMetadata(metadata.FunctionMD.Functions)
+	metadata.SetEvaluator(evaluator)
+	helper.SetEvaluator(evaluator)
+
+	if !metadata.FunctionMD.Descriptions["newFunction"].Proxied {
+		t.Errorf("newFunction description is not marked as proxied")
+	}
+
+	exp, _, err := parser.ParseExpr("newFunction(foo.bar,2)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := evaluator.EvalExpr(context.Background(), exp, 100, 280, map[parser.MetricRequest][]*types.MetricData{}, th.NoopGetTargetData)
+	if err != nil {
+		t.Fatalf("failed to eval: %v", err)
+	}
+
+	want := types.MakeMetricData("newFunction(foo.bar,2)", []float64{1.5, math.NaN(), 3}, 60, 100)
+	if len(res) != 1 {
+		t.Fatalf("expected 1 metric, got %d", len(res))
+	}
+	if res[0].Name != want.Name || res[0].StartTime != want.StartTime || res[0].StepTime != want.StepTime {
+		t.Errorf("got %s start=%d step=%d, want %s start=%d step=%d",
+			res[0].Name, res[0].StartTime, res[0].StepTime, want.Name, want.StartTime, want.StepTime)
+	}
+	if !th.NearlyEqualMetrics(res[0], want) {
+		t.Errorf("got values %v, want %v", res[0].Values, want.Values)
+	}
+	if tz != "" {
+		t.Errorf("expected no time zone, got %q", tz)
+	}
+
+	ctx := evalctx.NewContext(context.Background(), evalctx.Context{Location: time.FixedZone("Europe/Amsterdam", 3600)})
+	if _, err := evaluator.EvalExpr(ctx, exp, 100, 280, map[parser.MetricRequest][]*types.MetricData{}, th.NoopGetTargetData); err != nil {
+		t.Fatalf("failed to eval: %v", err)
+	}
+	if tz != "Europe/Amsterdam" {
+		t.Errorf("expected the time zone of the request, got %q", tz)
+	}
+}
+
+func TestGraphiteWebConfig(t *testing.T) {
+	srv := newServer(t, new(string))
+	defer srv.Close()
+
+	for _, m := range sum.New("") {
+		metadata.RegisterFunction(m.Name, m.F)
+	}
+
+	tests := []struct {
+		name   string
+		config string
+		want   []string
+	}{
+		{
+			"disabled",
+			"enabled: false\nfallbackUrls:\n    - %s\n",
+			nil,
+		},
+		{
+			"forceAdd",
+			"enabled: true\nfallbackUrls:\n    - %s\nforceAdd:\n    - sumSeries\nforceSkip:\n    - aliasByTags\n    - newFunction\n",
+			[]string{"sumSeries"},
+		},
+		{
+			"unreachable",
+			"enabled: true\nfallbackUrls:\n    - http://127.0.0.1:1%.0s\n",
+			nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configFile := writeConfig(t, fmt.Sprintf(tt.config, srv.URL))
+			defer os.Remove(configFile)
+
+			md := New(configFile)
+			if len(md) != len(tt.want) {
+				t.Fatalf("got %d functions, want %v", len(md), tt.want)
+			}
+			for i, m := range md {
+				if m.Name != tt.want[i] {
+					t.Errorf("got function %s, want %s", m.Name, tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -29,7 +29,16 @@ func New(configFile string) []interfaces.FunctionMetadata {
 	return res
 }
 
-// groupByNode(seriesList, nodeNum, callback)
+// aggFuncs maps aggregations of graphite-web to the series functions doing
+// them, for those which aren't registered under the name of the aggregation
+var aggFuncs = map[string]string{
+	"count":    "countSeries",
+	"multiply": "multiplySeries",
+	"range":    "rangeOfSeries",
+	"stddev":   "stddevSeries",
+}
+
+// groupByNode(seriesList, nodeNum, callback='average')
 // groupByNodes(seriesList, callback, *nodes)
 func (f *groupByNode) Do(ctx context.Context, e parser.Expr, from, until int32, values map[parser.MetricRequest][]*types.MetricData, getTargetData interfaces.GetTargetData) ([]*types.MetricData, error) {
 	args, err := helper.GetSeriesArg(ctx, e.Args()[0], from, until, values, getTargetData)
@@ -45,7 +54,7 @@ func (f *groupByNode) Do(ctx context.Context, e parser.Expr, from, until int32,
 			return nil, err
 		}
 
-		callback, err = e.GetStringArg(2)
+		callback, err = e.GetStringArgDefault(2, "average")
 		if err != nil {
 			return nil, err
 		}
@@ -62,6 +71,10 @@ func (f *groupByNode) Do(ctx context.Context, e parser.Expr, from, until int32,
 		}
 	}
 
+	if fn, ok := aggFuncs[callback]; ok {
+		callback = fn
+	}
+
 	var results []*types.MetricData
 
 	groups := make(map[string][]*types.MetricData)
@@ -155,8 +168,7 @@ func (f *groupByNode) Description() map[string]types.FunctionDescription {
 						"stddev",
 						"sum",
 					},
-					Required: true,
-					Type:     types.AggFunc,
+					Type: types.AggFunc,
 				},
 			},
 		},
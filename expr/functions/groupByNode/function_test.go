@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/bookingcom/carbonapi/expr/functions/averageSeries"
+	"github.com/bookingcom/carbonapi/expr/functions/countSeries"
 	"github.com/bookingcom/carbonapi/expr/functions/diffSeries"
 	"github.com/bookingcom/carbonapi/expr/functions/minMax"
 	"github.com/bookingcom/carbonapi/expr/functions/stddevSeries"
@@ -41,6 +42,10 @@ func init() {
 	for _, m := range ds {
 		metadata.RegisterFunction(m.Name, m.F)
 	}
+	cs := countSeries.New("")
+	for _, m := range cs {
+		metadata.RegisterFunction(m.Name, m.F)
+	}
 	evaluator := th.EvaluatorFromFuncWithMetadata(metadata.FunctionMD.Functions)
 	metadata.SetEvaluator(evaluator)
 	helper.SetEvaluator(evaluator)
@@ -63,6 +68,32 @@ func TestGroupByNode(t *testing.T) {
 				"baz": {types.MakeMetricData("baz", []float64{6, 17, 8, 19, 10}, 1, now32)},
 			},
 		},
+		{
+			"groupByNode(metric1.foo.*.*,3)",
+			map[parser.MetricRequest][]*types.MetricData{
+				{"metric1.foo.*.*", 0, 1}: {
+					types.MakeMetricData("metric1.foo.bar1.baz", []float64{1, 22, 3, 24, 5}, 1, now32),
+					types.MakeMetricData("metric1.foo.bar2.baz", []float64{11, 12, 13, 14, 15}, 1, now32),
+				},
+			},
+			"groupByNodeDefault",
+			map[string][]*types.MetricData{
+				"baz": {types.MakeMetricData("baz", []float64{6, 17, 8, 19, 10}, 1, now32)},
+			},
+		},
+		{
+			"groupByNode(metric1.foo.*.*,3,\"count\")",
+			map[parser.MetricRequest][]*types.MetricData{
+				{"metric1.foo.*.*", 0, 1}: {
+					types.MakeMetricData("metric1.foo.bar1.baz", []float64{1, 22, 3, 24, 5}, 1, now32),
+					types.MakeMetricData("metric1.foo.bar2.baz", []float64{11, 12, 13, 14, 15}, 1, now32),
+				},
+			},
+			"groupByNodeCount",
+			map[string][]*types.MetricData{
+				"baz": {types.MakeMetricData("baz", []float64{2, 2, 2, 2, 2}, 1, now32)},
+			},
+		},
 		{
 			"groupByNode(metric1.foo.*.*,3,\"diff\")",
 			map[parser.MetricRequest][]*types.MetricData{
@@ -110,9 +110,8 @@ func (f *highest) Description() map[string]types.FunctionDescription {
 					Type:     types.SeriesList,
 				},
 				{
-					Name:     "n",
-					Required: true,
-					Type:     types.Integer,
+					Name: "n",
+					Type: types.Integer,
 				},
 			},
 		},
@@ -129,9 +128,8 @@ func (f *highest) Description() map[string]types.FunctionDescription {
 					Type:     types.SeriesList,
 				},
 				{
-					Name:     "n",
-					Required: true,
-					Type:     types.Integer,
+					Name: "n",
+					Type: types.Integer,
 				},
 			},
 		},
@@ -148,9 +146,8 @@ func (f *highest) Description() map[string]types.FunctionDescription {
 					Type:     types.SeriesList,
 				},
 				{
-					Name:     "n",
-					Required: true,
-					Type:     types.Integer,
+					Name: "n",
+					Type: types.Integer,
 				},
 			},
 		},
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"math"
 
+	"github.com/bookingcom/carbonapi/expr/evalctx"
 	"github.com/bookingcom/carbonapi/expr/helper"
 	"github.com/bookingcom/carbonapi/expr/interfaces"
 	"github.com/bookingcom/carbonapi/expr/types"
@@ -42,6 +43,9 @@ func (f *hitcount) Do(ctx context.Context, e parser.Expr, from, until int32, val
 	if err != nil {
 		return nil, err
 	}
+	if bucketSize <= 0 {
+		return nil, parser.ErrInvalidArgumentValue
+	}
 
 	alignToInterval, err := e.GetBoolNamedOrPosArgDefault("alignToInterval", 2, false)
 	if err != nil {
@@ -55,10 +59,17 @@ func (f *hitcount) Do(ctx context.Context, e parser.Expr, from, until int32, val
 	start := args[0].StartTime
 	stop := args[0].StopTime
 	if alignToInterval {
-		start = helper.AlignStartToInterval(start, stop, bucketSize)
+		// the first bucket of days starts at a local midnight, the following
+		// ones keep bucketSize so that points stay evenly spaced
+		start = helper.AlignStartToInterval(start, stop, bucketSize, evalctx.FromContext(ctx).Location)
 	}
 
-	buckets := helper.GetBuckets(start, stop, bucketSize)
+	bounds := []int32{start}
+	for b := start; b < stop; {
+		b += bucketSize
+		bounds = append(bounds, b)
+	}
+	buckets := int32(len(bounds) - 1)
 	results := make([]*types.MetricData, 0, len(args))
 	for _, arg := range args {
 
@@ -77,7 +88,10 @@ func (f *hitcount) Do(ctx context.Context, e parser.Expr, from, until int32, val
 			StopTime:  stop,
 		}}
 
-		bucketEnd := start + bucketSize
+		bucketEnd := stop
+		if buckets > 0 {
+			bucketEnd = bounds[1]
+		}
 		t := arg.StartTime
 		ridx := 0
 		var count float64
@@ -107,7 +121,9 @@ func (f *hitcount) Do(ctx context.Context, e parser.Expr, from, until int32, val
 				}
 
 				ridx++
-				bucketEnd += bucketSize
+				if ridx < len(bounds)-1 {
+					bucketEnd = bounds[ridx+1]
+				}
 				count = math.NaN()
 				bucketItems = 0
 			}
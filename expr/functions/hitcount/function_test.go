@@ -1,9 +1,12 @@
 package hitcount
 
 import (
+	"context"
 	"math"
 	"testing"
+	"time"
 
+	"github.com/bookingcom/carbonapi/expr/evalctx"
 	"github.com/bookingcom/carbonapi/expr/helper"
 	"github.com/bookingcom/carbonapi/expr/metadata"
 	"github.com/bookingcom/carbonapi/expr/types"
@@ -95,3 +98,47 @@ func TestHitcount(t *testing.T) {
 	}
 
 }
+
+func TestHitcountTimeZone(t *testing.T) {
+	ams, err := time.LoadLocation("Europe/Amsterdam")
+	if err != nil {
+		t.Skip(err)
+	}
+
+	// a hit per hour over the switch to summer time, the first bucket
+	// starting at the local midnight before the first hit
+	start := int32(time.Date(2021, time.March, 27, 13, 0, 0, 0, ams).Unix())
+	stop := int32(time.Date(2021, time.March, 30, 0, 0, 0, 0, ams).Unix())
+	values := make([]float64, (stop-start)/3600)
+	for i := range values {
+		values[i] = 1
+	}
+	m := map[parser.MetricRequest][]*types.MetricData{
+		{"metric1", 0, 1}: {types.MakeMetricData("metric1", values, 3600, start)},
+	}
+
+	exp, _, err := parser.ParseExpr("hitcount(metric1,'1d',true)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := evalctx.NewContext(context.Background(), evalctx.Context{Location: ams})
+	g, err := metadata.GetEvaluator().EvalExpr(ctx, exp, 0, 1, m, th.NoopGetTargetData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := int32(time.Date(2021, time.March, 27, 0, 0, 0, 0, ams).Unix()); g[0].StartTime != want {
+		t.Errorf("Expected start at %v, got %v", time.Unix(int64(want), 0).In(ams), time.Unix(int64(g[0].StartTime), 0).In(ams))
+	}
+	if !th.NearlyEqual(g[0].Values, g[0].IsAbsent, []float64{11 * 3600, 24 * 3600, 23 * 3600}) {
+		t.Errorf("Expected hits per 24 hours, got %v", g[0].Values)
+	}
+	if g[0].StepTime != 86400 {
+		t.Errorf("Expected points a day apart, got step %d", g[0].StepTime)
+	}
+	// after the switch buckets start an hour past the local midnight
+	last := g[0].StartTime + 2*g[0].StepTime
+	if want := int32(time.Date(2021, time.March, 29, 1, 0, 0, 0, ams).Unix()); last != want {
+		t.Errorf("Expected the last bucket at %v, got %v", time.Unix(int64(want), 0).In(ams), time.Unix(int64(last), 0).In(ams))
+	}
+}
@@ -0,0 +1,69 @@
+package identity
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bookingcom/carbonapi/expr/helper"
+	"github.com/bookingcom/carbonapi/expr/metadata"
+	"github.com/bookingcom/carbonapi/expr/types"
+	"github.com/bookingcom/carbonapi/pkg/parser"
+	th "github.com/bookingcom/carbonapi/tests"
+)
+
+func init() {
+	md := New("")
+	evaluator := th.EvaluatorFromFunc(md[0].F)
+	metadata.SetEvaluator(evaluator)
+	helper.SetEvaluator(evaluator)
+	for _, m := range md {
+		metadata.RegisterFunction(m.Name, m.F)
+	}
+}
+
+func TestIdentity(t *testing.T) {
+	tests := []struct {
+		target string
+		from   int32
+		until  int32
+		want   *types.MetricData
+	}{
+		{
+			"identity('foo')",
+			4200,
+			4350,
+			types.MakeMetricData("foo", []float64{4200, 4260, 4320}, 60, 4200),
+		},
+		{
+			"identity('bar')",
+			4200,
+			4260,
+			types.MakeMetricData("bar", []float64{4200}, 60, 4200),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			exp, _, err := parser.ParseExpr(tt.target)
+			if err != nil {
+				t.Fatalf("failed to parse %s: %v", tt.target, err)
+			}
+			g, err := metadata.GetEvaluator().EvalExpr(context.Background(), exp, tt.from, tt.until, nil, th.NoopGetTargetData)
+			if err != nil {
+				t.Fatalf("failed to eval %s: %v", tt.target, err)
+			}
+			if len(g) != 1 {
+				t.Fatalf("%s returned %d metrics, want 1", tt.target, len(g))
+			}
+			if g[0].Name != tt.want.Name {
+				t.Errorf("bad name: got %s, want %s", g[0].Name, tt.want.Name)
+			}
+			if g[0].StepTime != tt.want.StepTime {
+				t.Errorf("bad step: got %d, want %d", g[0].StepTime, tt.want.StepTime)
+			}
+			if !th.NearlyEqualMetrics(g[0], tt.want) {
+				t.Errorf("different values: got %v, want %v", g[0].Values, tt.want.Values)
+			}
+		})
+	}
+}
@@ -86,7 +86,7 @@ func (f *ifft) Description() map[string]types.FunctionDescription {
 	return map[string]types.FunctionDescription{
 		"ifft": {
 			Description: "An algorithm that samples a signal over a period of time (or space) and divides it into its frequency components. Computes discrete Fourier transform https://en.wikipedia.org/wiki/Fast_Fourier_transform \n\nExample:\n\n.. code-block:: none\n\n  &target=fft(server*.requests_per_second)\n\n  &target=fft(server*.requests_per_second, \"abs\")\n",
-			Function:    "ifft(seriesList, phaseSeriesList)",
+			Function:    "ifft(seriesList, phaseSeriesList=None)",
 			Group:       "Transform",
 			Module:      "graphite.render.functions.custom",
 			Name:        "ifft",
@@ -97,9 +97,8 @@ func (f *ifft) Description() map[string]types.FunctionDescription {
 					Type:     types.SeriesList,
 				},
 				{
-					Name:     "phaseSeriesList",
-					Required: true,
-					Type:     types.SeriesList,
+					Name: "phaseSeriesList",
+					Type: types.SeriesList,
 				},
 			},
 		},
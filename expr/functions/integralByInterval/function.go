@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"math"
 
+	"github.com/bookingcom/carbonapi/expr/calendar"
+	"github.com/bookingcom/carbonapi/expr/evalctx"
 	"github.com/bookingcom/carbonapi/expr/helper"
 	"github.com/bookingcom/carbonapi/expr/interfaces"
 	"github.com/bookingcom/carbonapi/expr/types"
@@ -46,13 +48,32 @@ func (f *integralByInterval) Do(ctx context.Context, e parser.Expr, from, until
 	if err != nil {
 		return nil, err
 	}
+	if bucketSize <= 0 {
+		return nil, parser.ErrInvalidArgumentValue
+	}
 
-	startTime := from
+	// totals reset every bucketSize from the start of the request, days
+	// being days of the calendar of the request across DST transitions
+	loc := evalctx.FromContext(ctx).Location
 	results := make([]*types.MetricData, 0, len(args))
 	for _, arg := range args {
 		current := 0.0
 		currentTime := arg.StartTime
 
+		// the first reset after the point before the first one
+		prev := currentTime - arg.StepTime
+		n := (prev - from) / bucketSize
+		if (prev-from)%bucketSize < 0 {
+			n--
+		}
+		reset := calendar.Shift(from, n*bucketSize, loc)
+		for reset > prev {
+			reset = calendar.Shift(reset, -bucketSize, loc)
+		}
+		for reset <= prev {
+			reset = calendar.Shift(reset, bucketSize, loc)
+		}
+
 		name := fmt.Sprintf("integralByInterval(%s,'%s')", arg.Name, e.Args()[1].StringValue())
 		result := &types.MetricData{
 			Metric: dataTypes.Metric{
@@ -65,8 +86,11 @@ func (f *integralByInterval) Do(ctx context.Context, e parser.Expr, from, until
 			},
 		}
 		for i, v := range arg.Values {
-			if (currentTime-startTime)/bucketSize != (currentTime-startTime-arg.StepTime)/bucketSize {
+			if currentTime >= reset {
 				current = 0
+				for reset <= currentTime {
+					reset = calendar.Shift(reset, bucketSize, loc)
+				}
 			}
 			if math.IsNaN(v) {
 				v = 0
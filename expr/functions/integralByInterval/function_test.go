@@ -1,8 +1,11 @@
 package integralByInterval
 
 import (
+	"context"
 	"testing"
+	"time"
 
+	"github.com/bookingcom/carbonapi/expr/evalctx"
 	"github.com/bookingcom/carbonapi/expr/helper"
 	"github.com/bookingcom/carbonapi/expr/metadata"
 	"github.com/bookingcom/carbonapi/expr/types"
@@ -47,3 +50,41 @@ func TestFunction(t *testing.T) {
 	}
 
 }
+
+func TestFunctionTimeZone(t *testing.T) {
+	ams, err := time.LoadLocation("Europe/Amsterdam")
+	if err != nil {
+		t.Skip(err)
+	}
+
+	// hours of the days around the switch to summer time, which has 23 hours
+	from := int32(time.Date(2021, time.March, 27, 0, 0, 0, 0, ams).Unix())
+	until := int32(time.Date(2021, time.March, 30, 0, 0, 0, 0, ams).Unix())
+	values := make([]float64, (until-from)/3600)
+	var want []float64
+	for _, hours := range []int{24, 23, 24} {
+		for i := 1; i <= hours; i++ {
+			want = append(want, float64(i))
+		}
+	}
+	for i := range values {
+		values[i] = 1
+	}
+	m := map[parser.MetricRequest][]*types.MetricData{
+		{Metric: "metric1", From: from, Until: until}: {types.MakeMetricData("metric1", values, 3600, from)},
+	}
+
+	exp, _, err := parser.ParseExpr("integralByInterval(metric1,'1d')")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := evalctx.NewContext(context.Background(), evalctx.Context{Location: ams})
+	g, err := metadata.GetEvaluator().EvalExpr(ctx, exp, from, until, m, th.NoopGetTargetData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !th.NearlyEqual(g[0].Values, g[0].IsAbsent, want) {
+		t.Errorf("Expected totals reset at local midnights %v, got %v", want, g[0].Values)
+	}
+}
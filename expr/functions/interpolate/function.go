@@ -0,0 +1,103 @@
+package interpolate
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/bookingcom/carbonapi/expr/helper"
+	"github.com/bookingcom/carbonapi/expr/interfaces"
+	"github.com/bookingcom/carbonapi/expr/types"
+	"github.com/bookingcom/carbonapi/pkg/parser"
+)
+
+type interpolate struct {
+	interfaces.FunctionBase
+}
+
+func GetOrder() interfaces.Order {
+	return interfaces.Any
+}
+
+func New(configFile string) []interfaces.FunctionMetadata {
+	res := make([]interfaces.FunctionMetadata, 0)
+	f := &interpolate{}
+	functions := []string{"interpolate"}
+	for _, n := range functions {
+		res = append(res, interfaces.FunctionMetadata{Name: n, F: f})
+	}
+	return res
+}
+
+// interpolate(seriesList, limit=inf)
+func (f *interpolate) Do(ctx context.Context, e parser.Expr, from, until int32, values map[parser.MetricRequest][]*types.MetricData, getTargetData interfaces.GetTargetData) ([]*types.MetricData, error) {
+	args, err := helper.GetSeriesArg(ctx, e.Args()[0], from, until, values, getTargetData)
+	if err != nil {
+		return nil, err
+	}
+
+	limit, err := e.GetIntNamedOrPosArgDefault("limit", 1, -1)
+	if err != nil {
+		return nil, err
+	}
+	if limit < 0 {
+		limit = math.MaxInt32
+	}
+
+	results := make([]*types.MetricData, 0, len(args))
+	for _, a := range args {
+		r := *a
+		r.Name = fmt.Sprintf("interpolate(%s)", a.Name)
+		r.Values = make([]float64, len(a.Values))
+		r.IsAbsent = make([]bool, len(a.Values))
+		copy(r.Values, a.Values)
+		copy(r.IsAbsent, a.IsAbsent)
+
+		// index of the last seen value, -1 while no value was seen yet
+		last := -1
+		for i := range a.Values {
+			if a.IsAbsent[i] {
+				continue
+			}
+
+			gap := i - last - 1
+			if last >= 0 && gap > 0 && gap <= limit {
+				delta := (a.Values[i] - a.Values[last]) / float64(gap+1)
+				for j := last + 1; j < i; j++ {
+					r.Values[j] = a.Values[last] + float64(j-last)*delta
+					r.IsAbsent[j] = false
+				}
+			}
+			last = i
+		}
+
+		results = append(results, &r)
+	}
+
+	return results, nil
+}
+
+// Description is auto-generated description, based on output of https://github.com/graphite-project/graphite-web
+func (f *interpolate) Description() map[string]types.FunctionDescription {
+	return map[string]types.FunctionDescription{
+		"interpolate": {
+			Description: "Takes one metric or a wildcard seriesList, and optionally a limit to the number of 'None' values to skip over.\nContinues the line with linearly interpolated values when gaps ('None' values) appear in your data, rather than breaking your line.\n\nExample:\n\n.. code-block:: none\n\n  &target=interpolate(Server01.connections.handled)\n  &target=interpolate(Server01.connections.handled, 10)",
+			Function:    "interpolate(seriesList, limit=inf)",
+			Group:       "Transform",
+			Module:      "graphite.render.functions",
+			Name:        "interpolate",
+			Params: []types.FunctionParam{
+				{
+					Name:     "seriesList",
+					Required: true,
+					Type:     types.SeriesList,
+				},
+				{
+					Default: types.NewSuggestion("INF"),
+					Name:    "limit",
+					Type:    types.Integer,
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,61 @@
+package interpolate
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/bookingcom/carbonapi/expr/helper"
+	"github.com/bookingcom/carbonapi/expr/metadata"
+	"github.com/bookingcom/carbonapi/expr/types"
+	"github.com/bookingcom/carbonapi/pkg/parser"
+	th "github.com/bookingcom/carbonapi/tests"
+)
+
+func init() {
+	md := New("")
+	evaluator := th.EvaluatorFromFunc(md[0].F)
+	metadata.SetEvaluator(evaluator)
+	helper.SetEvaluator(evaluator)
+	for _, m := range md {
+		metadata.RegisterFunction(m.Name, m.F)
+	}
+}
+
+func TestInterpolate(t *testing.T) {
+	now32 := int32(time.Now().Unix())
+
+	tests := []th.EvalTestItem{
+		{
+			"interpolate(metric1)",
+			map[parser.MetricRequest][]*types.MetricData{
+				{"metric1", 0, 1}: {types.MakeMetricData("metric1", []float64{math.NaN(), 1, math.NaN(), 3, math.NaN(), math.NaN(), 6, math.NaN()}, 1, now32)},
+			},
+			[]*types.MetricData{types.MakeMetricData("interpolate(metric1)",
+				[]float64{math.NaN(), 1, 2, 3, 4, 5, 6, math.NaN()}, 1, now32)},
+		},
+		{
+			"interpolate(metric1,1)",
+			map[parser.MetricRequest][]*types.MetricData{
+				{"metric1", 0, 1}: {types.MakeMetricData("metric1", []float64{1, math.NaN(), 3, math.NaN(), math.NaN(), 6}, 1, now32)},
+			},
+			[]*types.MetricData{types.MakeMetricData("interpolate(metric1)",
+				[]float64{1, 2, 3, math.NaN(), math.NaN(), 6}, 1, now32)},
+		},
+		{
+			"interpolate(metric1,limit=2)",
+			map[parser.MetricRequest][]*types.MetricData{
+				{"metric1", 0, 1}: {types.MakeMetricData("metric1", []float64{0, math.NaN(), math.NaN(), 6}, 1, now32)},
+			},
+			[]*types.MetricData{types.MakeMetricData("interpolate(metric1)",
+				[]float64{0, 2, 4, 6}, 1, now32)},
+		},
+	}
+
+	for _, tt := range tests {
+		testName := tt.Target
+		t.Run(testName, func(t *testing.T) {
+			th.TestEvalExpr(t, &tt)
+		})
+	}
+}
@@ -97,18 +97,18 @@ func (f *kolmogorovSmirnovTest2) Description() map[string]types.FunctionDescript
 	return map[string]types.FunctionDescription{
 		"kolmogorovSmirnovTest2": {
 			Description: "Nonparametric test of the equality of continuous, one-dimensional probability distributions that can be used to compare a sample with a reference probability distribution (one-sample K–S test), or to compare two samples (two-sample K–S test). https://en.wikipedia.org/wiki/Kolmogorov%E2%80%93Smirnov_test",
-			Function:    "kolmogorovSmirnovTest2(seriesList, seriesList, windowSize)",
+			Function:    "kolmogorovSmirnovTest2(seriesA, seriesB, window)",
 			Group:       "Transform",
 			Module:      "graphite.render.functions",
 			Name:        "kolmogorovSmirnovTest2",
 			Params: []types.FunctionParam{
 				{
-					Name:     "seriesList",
+					Name:     "seriesA",
 					Required: true,
 					Type:     types.SeriesList,
 				},
 				{
-					Name:     "seriesList",
+					Name:     "seriesB",
 					Required: true,
 					Type:     types.SeriesList,
 				},
@@ -121,18 +121,18 @@ func (f *kolmogorovSmirnovTest2) Description() map[string]types.FunctionDescript
 		},
 		"ksTest2": {
 			Description: "Nonparametric test of the equality of continuous, one-dimensional probability distributions that can be used to compare a sample with a reference probability distribution (one-sample K–S test), or to compare two samples (two-sample K–S test). https://en.wikipedia.org/wiki/Kolmogorov%E2%80%93Smirnov_test",
-			Function:    "ksTest2(seriesList, seriesList, windowSize)",
+			Function:    "ksTest2(seriesA, seriesB, window)",
 			Group:       "Transform",
 			Module:      "graphite.render.functions.custom",
 			Name:        "ksTest2",
 			Params: []types.FunctionParam{
 				{
-					Name:     "seriesList",
+					Name:     "seriesA",
 					Required: true,
 					Type:     types.SeriesList,
 				},
 				{
-					Name:     "seriesList",
+					Name:     "seriesB",
 					Required: true,
 					Type:     types.SeriesList,
 				},
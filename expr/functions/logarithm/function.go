@@ -81,10 +81,10 @@ func (f *logarithm) Description() map[string]types.FunctionDescription {
 	return map[string]types.FunctionDescription{
 		"logarithm": {
 			Description: "Takes one metric or a wildcard seriesList, a base, and draws the y-axis in logarithmic\nformat.  If base is omitted, the function defaults to base 10.\n\nExample:\n\n.. code-block:: none\n\n  &target=log(carbon.agents.hostname.avgUpdateTime,2)",
-			Function:    "log(seriesList, base=10)",
+			Function:    "logarithm(seriesList, base=10)",
 			Group:       "Transform",
 			Module:      "graphite.render.functions",
-			Name:        "log",
+			Name:        "logarithm",
 			Params: []types.FunctionParam{
 				{
 					Name:     "seriesList",
@@ -92,9 +92,8 @@ func (f *lowest) Description() map[string]types.FunctionDescription {
 					Type:     types.SeriesList,
 				},
 				{
-					Name:     "n",
-					Required: true,
-					Type:     types.Integer,
+					Name: "n",
+					Type: types.Integer,
 				},
 			},
 		},
@@ -111,9 +110,8 @@ func (f *lowest) Description() map[string]types.FunctionDescription {
 					Type:     types.SeriesList,
 				},
 				{
-					Name:     "n",
-					Required: true,
-					Type:     types.Integer,
+					Name: "n",
+					Type: types.Integer,
 				},
 			},
 		},
@@ -42,7 +42,7 @@ func (f *minMax) Do(ctx context.Context, e parser.Expr, from, until int32, value
 
 	switch e.Target() {
 	case "maxSeries", "max":
-		return helper.AggregateSeries(name, args, false, false, func(values []float64) (float64, bool) {
+		return helper.AggregateSeries(ctx, name, args, false, false, func(values []float64) (float64, bool) {
 			max := math.Inf(-1)
 			for _, value := range values {
 				if value > max {
@@ -52,7 +52,7 @@ func (f *minMax) Do(ctx context.Context, e parser.Expr, from, until int32, value
 			return max, false
 		})
 	case "minSeries", "min":
-		return helper.AggregateSeries(name, args, false, false, func(values []float64) (float64, bool) {
+		return helper.AggregateSeries(ctx, name, args, false, false, func(values []float64) (float64, bool) {
 			min := math.Inf(1)
 			for _, value := range values {
 				if value < min {
@@ -86,10 +86,10 @@ func (f *minMax) Description() map[string]types.FunctionDescription {
 		},
 		"min": {
 			Description: "Takes one metric or a wildcard seriesList.\nFor each datapoint from each metric passed in, pick the minimum value and graph it.\n\nExample:\n\n.. code-block:: none\n\n  &target=minSeries(Server*.connections.total)\n\nThis is an alias for :py:func:`aggregate <aggregate>` with aggregation ``min``.",
-			Function:    "minSeries(*seriesLists)",
+			Function:    "min(*seriesLists)",
 			Group:       "Combine",
 			Module:      "graphite.render.functions",
-			Name:        "minSeries",
+			Name:        "min",
 			Params: []types.FunctionParam{
 				{
 					Multiple: true,
@@ -116,10 +116,10 @@ func (f *minMax) Description() map[string]types.FunctionDescription {
 		},
 		"max": {
 			Description: "Takes one metric or a wildcard seriesList.\nFor each datapoint from each metric passed in, pick the maximum value and graph it.\n\nExample:\n\n.. code-block:: none\n\n  &target=maxSeries(Server*.connections.total)\n\nThis is an alias for :py:func:`aggregate <aggregate>` with aggregation ``max``.",
-			Function:    "maxSeries(*seriesLists)",
+			Function:    "max(*seriesLists)",
 			Group:       "Combine",
 			Module:      "graphite.render.functions",
-			Name:        "maxSeries",
+			Name:        "max",
 			Params: []types.FunctionParam{
 				{
 					Multiple: true,
@@ -38,9 +38,12 @@ func (f *multiplySeriesWithWildcards) Do(ctx context.Context, e parser.Expr, fro
 		return nil, err
 	}
 
-	fields, err := e.GetIntArgs(1)
-	if err != nil {
-		return nil, err
+	var fields []int
+	if len(e.Args()) > 1 {
+		fields, err = e.GetIntArgs(1)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	var results []*types.MetricData
@@ -102,18 +102,18 @@ Pearson will discard epochs where either series has a missing value.
 
 Additionally there is a special case where a series (or window) containing only zeros leads to a division-by-zero
 and will manifest as if the entire window/series had missing values.`,
-			Function: "pearson(seriesList, seriesList, windowSize)",
+			Function: "pearson(seriesA, seriesB, windowSize)",
 			Group:    "Transform",
 			Module:   "graphite.render.functions.custom",
-			Name:     "pearsonClosest",
+			Name:     "pearson",
 			Params: []types.FunctionParam{
 				{
-					Name:     "seriesList",
+					Name:     "seriesA",
 					Required: true,
 					Type:     types.SeriesList,
 				},
 				{
-					Name:     "seriesList",
+					Name:     "seriesB",
 					Required: true,
 					Type:     types.SeriesList,
 				},
@@ -160,13 +160,13 @@ Pearson will discard epochs where either series has a missing value.
 
 Additionally there is a special case where a series (or window) containing only zeros leads to a division-by-zero
 and will manifest as if the entire window/series had missing values.`,
-			Function: "pearsonClosest(seriesList, seriesList, n, direction)",
+			Function: "pearsonClosest(series, seriesList, n, direction)",
 			Group:    "Transform",
 			Module:   "graphite.render.functions.custom",
 			Name:     "pearsonClosest",
 			Params: []types.FunctionParam{
 				{
-					Name:     "seriesList",
+					Name:     "series",
 					Required: true,
 					Type:     types.SeriesList,
 				},
@@ -181,8 +181,7 @@ and will manifest as if the entire window/series had missing values.`,
 					Type:     types.Integer,
 				},
 				{
-					Name:     "direction",
-					Required: true,
+					Name: "direction",
 					Options: []string{
 						"abs",
 						"pos",
@@ -124,10 +124,9 @@ func (f *polyfit) Description() map[string]types.FunctionDescription {
 					Type:     types.SeriesList,
 				},
 				{
-					Name:     "degree",
-					Default:  types.NewSuggestion(1),
-					Required: true,
-					Type:     types.Integer,
+					Name:    "degree",
+					Default: types.NewSuggestion(1),
+					Type:    types.Integer,
 				},
 				{
 					Default: types.NewSuggestion("0d"),
@@ -0,0 +1,72 @@
+package powSeries
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/bookingcom/carbonapi/expr/helper"
+	"github.com/bookingcom/carbonapi/expr/interfaces"
+	"github.com/bookingcom/carbonapi/expr/types"
+	"github.com/bookingcom/carbonapi/pkg/parser"
+)
+
+type powSeries struct {
+	interfaces.FunctionBase
+}
+
+func GetOrder() interfaces.Order {
+	return interfaces.Any
+}
+
+func New(configFile string) []interfaces.FunctionMetadata {
+	res := make([]interfaces.FunctionMetadata, 0)
+	f := &powSeries{}
+	functions := []string{"powSeries"}
+	for _, n := range functions {
+		res = append(res, interfaces.FunctionMetadata{Name: n, F: f})
+	}
+	return res
+}
+
+// powSeries(*seriesLists)
+func (f *powSeries) Do(ctx context.Context, e parser.Expr, from, until int32, values map[parser.MetricRequest][]*types.MetricData, getTargetData interfaces.GetTargetData) ([]*types.MetricData, error) {
+	args, err := helper.GetSeriesArgsAndRemoveNonExisting(ctx, e, from, until, values, getTargetData)
+	if err != nil {
+		return nil, err
+	}
+
+	name := fmt.Sprintf("powSeries(%s)", e.RawArgs())
+	return helper.AggregateSeries(ctx, name, args, false, true, func(values []float64) (float64, bool) {
+		ret := values[0]
+		for _, value := range values[1:] {
+			ret = math.Pow(ret, value)
+		}
+
+		if math.IsNaN(ret) || math.IsInf(ret, 0) {
+			return 0, true
+		}
+		return ret, false
+	})
+}
+
+// Description is auto-generated description, based on output of https://github.com/graphite-project/graphite-web
+func (f *powSeries) Description() map[string]types.FunctionDescription {
+	return map[string]types.FunctionDescription{
+		"powSeries": {
+			Description: "Takes two or more series and pows their points. A constant line may be\nused.\n\nExample:\n\n.. code-block:: none\n\n  &target=powSeries(Server.instance01.app.requests, Server.instance01.app.replies)",
+			Function:    "powSeries(*seriesLists)",
+			Group:       "Combine",
+			Module:      "graphite.render.functions",
+			Name:        "powSeries",
+			Params: []types.FunctionParam{
+				{
+					Multiple: true,
+					Name:     "seriesLists",
+					Required: true,
+					Type:     types.SeriesList,
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,56 @@
+package powSeries
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/bookingcom/carbonapi/expr/helper"
+	"github.com/bookingcom/carbonapi/expr/metadata"
+	"github.com/bookingcom/carbonapi/expr/types"
+	"github.com/bookingcom/carbonapi/pkg/parser"
+	th "github.com/bookingcom/carbonapi/tests"
+)
+
+func init() {
+	md := New("")
+	evaluator := th.EvaluatorFromFunc(md[0].F)
+	metadata.SetEvaluator(evaluator)
+	helper.SetEvaluator(evaluator)
+	for _, m := range md {
+		metadata.RegisterFunction(m.Name, m.F)
+	}
+}
+
+func TestPowSeries(t *testing.T) {
+	now32 := int32(time.Now().Unix())
+
+	tests := []th.EvalTestItem{
+		{
+			"powSeries(metric1,metric2)",
+			map[parser.MetricRequest][]*types.MetricData{
+				{"metric1", 0, 1}: {types.MakeMetricData("metric1", []float64{2, 3, math.NaN(), 4, -1}, 1, now32)},
+				{"metric2", 0, 1}: {types.MakeMetricData("metric2", []float64{3, 2, 2, math.NaN(), 0.5}, 1, now32)},
+			},
+			[]*types.MetricData{types.MakeMetricData("powSeries(metric1,metric2)",
+				[]float64{8, 9, math.NaN(), math.NaN(), math.NaN()}, 1, now32)},
+		},
+		{
+			"powSeries(metric1,metric2,metric3)",
+			map[parser.MetricRequest][]*types.MetricData{
+				{"metric1", 0, 1}: {types.MakeMetricData("metric1", []float64{2, 3}, 1, now32)},
+				{"metric2", 0, 1}: {types.MakeMetricData("metric2", []float64{2, 1}, 1, now32)},
+				{"metric3", 0, 1}: {types.MakeMetricData("metric3", []float64{0.5, 2}, 1, now32)},
+			},
+			[]*types.MetricData{types.MakeMetricData("powSeries(metric1,metric2,metric3)",
+				[]float64{2, 9}, 1, now32)},
+		},
+	}
+
+	for _, tt := range tests {
+		testName := tt.Target
+		t.Run(testName, func(t *testing.T) {
+			th.TestEvalExpr(t, &tt)
+		})
+	}
+}
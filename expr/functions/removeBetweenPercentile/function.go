@@ -0,0 +1,105 @@
+package removeBetweenPercentile
+
+import (
+	"context"
+
+	"github.com/bookingcom/carbonapi/expr/helper"
+	"github.com/bookingcom/carbonapi/expr/interfaces"
+	"github.com/bookingcom/carbonapi/expr/types"
+	"github.com/bookingcom/carbonapi/pkg/parser"
+)
+
+type removeBetweenPercentile struct {
+	interfaces.FunctionBase
+}
+
+func GetOrder() interfaces.Order {
+	return interfaces.Any
+}
+
+func New(configFile string) []interfaces.FunctionMetadata {
+	res := make([]interfaces.FunctionMetadata, 0)
+	f := &removeBetweenPercentile{}
+	functions := []string{"removeBetweenPercentile"}
+	for _, n := range functions {
+		res = append(res, interfaces.FunctionMetadata{Name: n, F: f})
+	}
+	return res
+}
+
+// removeBetweenPercentile(seriesList, n)
+func (f *removeBetweenPercentile) Do(ctx context.Context, e parser.Expr, from, until int32, values map[parser.MetricRequest][]*types.MetricData, getTargetData interfaces.GetTargetData) ([]*types.MetricData, error) {
+	args, err := helper.GetSeriesArg(ctx, e.Args()[0], from, until, values, getTargetData)
+	if err != nil {
+		return nil, err
+	}
+
+	n, err := e.GetFloatArg(1)
+	if err != nil {
+		return nil, err
+	}
+	if n < 50 {
+		n = 100 - n
+	}
+
+	var length int
+	for _, a := range args {
+		if len(a.Values) > length {
+			length = len(a.Values)
+		}
+	}
+
+	lowPercentiles := make([]float64, length)
+	highPercentiles := make([]float64, length)
+	for i := 0; i < length; i++ {
+		var column []float64
+		for _, a := range args {
+			if i < len(a.Values) && !a.IsAbsent[i] {
+				column = append(column, a.Values[i])
+			}
+		}
+		// Percentile reorders its input, so each call gets a copy
+		lowPercentiles[i], _ = helper.Percentile(append([]float64(nil), column...), 100-n, false)
+		highPercentiles[i], _ = helper.Percentile(column, n, false)
+	}
+
+	var results []*types.MetricData
+	for _, a := range args {
+		for i, v := range a.Values {
+			if a.IsAbsent[i] {
+				continue
+			}
+			if v <= lowPercentiles[i] || v >= highPercentiles[i] {
+				results = append(results, a)
+				break
+			}
+		}
+	}
+
+	return results, nil
+}
+
+// Description is auto-generated description, based on output of https://github.com/graphite-project/graphite-web
+func (f *removeBetweenPercentile) Description() map[string]types.FunctionDescription {
+	return map[string]types.FunctionDescription{
+		"removeBetweenPercentile": {
+			Description: "Removes series that do not have an value lying in the x-percentile of all the values at a moment",
+			Function:    "removeBetweenPercentile(seriesList, n)",
+			Group:       "Filter Series",
+			Module:      "graphite.render.functions",
+			Name:        "removeBetweenPercentile",
+			Params: []types.FunctionParam{
+				{
+					Name:     "seriesList",
+					Required: true,
+					Type:     types.SeriesList,
+				},
+				{
+					Name:     "n",
+					Required: true,
+					Type:     types.Integer,
+				},
+			},
+		},
+	}
+}
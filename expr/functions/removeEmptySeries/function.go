@@ -67,9 +67,8 @@ func (f *removeEmptySeries) Description() map[string]types.FunctionDescription {
 					Type:     types.SeriesList,
 				},
 				{
-					Name:     "xFilesFactor",
-					Required: true,
-					Type:     types.Float,
+					Name: "xFilesFactor",
+					Type: types.Float,
 				},
 			},
 		},
@@ -86,9 +85,8 @@ func (f *removeEmptySeries) Description() map[string]types.FunctionDescription {
 					Type:     types.SeriesList,
 				},
 				{
-					Name:     "xFilesFactor",
-					Required: true,
-					Type:     types.Float,
+					Name: "xFilesFactor",
+					Type: types.Float,
 				},
 			},
 		},
@@ -0,0 +1,99 @@
+package round
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/bookingcom/carbonapi/expr/helper"
+	"github.com/bookingcom/carbonapi/expr/interfaces"
+	"github.com/bookingcom/carbonapi/expr/types"
+	"github.com/bookingcom/carbonapi/pkg/parser"
+)
+
+type round struct {
+	interfaces.FunctionBase
+}
+
+func GetOrder() interfaces.Order {
+	return interfaces.Any
+}
+
+func New(configFile string) []interfaces.FunctionMetadata {
+	res := make([]interfaces.FunctionMetadata, 0)
+	f := &round{}
+	functions := []string{"round"}
+	for _, n := range functions {
+		res = append(res, interfaces.FunctionMetadata{Name: n, F: f})
+	}
+	return res
+}
+
+// round(seriesList, precision=None)
+func (f *round) Do(ctx context.Context, e parser.Expr, from, until int32, values map[parser.MetricRequest][]*types.MetricData, getTargetData interfaces.GetTargetData) ([]*types.MetricData, error) {
+	args, err := helper.GetSeriesArg(ctx, e.Args()[0], from, until, values, getTargetData)
+	if err != nil {
+		return nil, err
+	}
+
+	precision, err := e.GetIntNamedOrPosArgDefault("precision", 1, 0)
+	if err != nil {
+		return nil, err
+	}
+	_, withPrecision := e.NamedArgs()["precision"]
+	if !withPrecision {
+		withPrecision = len(e.Args()) > 1
+	}
+
+	scale := math.Pow10(precision)
+
+	results := make([]*types.MetricData, 0, len(args))
+	for _, a := range args {
+		r := *a
+		if withPrecision {
+			r.Name = fmt.Sprintf("round(%s,%d)", a.Name, precision)
+		} else {
+			r.Name = fmt.Sprintf("round(%s)", a.Name)
+		}
+		r.Values = make([]float64, len(a.Values))
+		r.IsAbsent = make([]bool, len(a.Values))
+
+		for i, v := range a.Values {
+			if a.IsAbsent[i] {
+				r.IsAbsent[i] = true
+				continue
+			}
+			// halves are rounded to even, as python's round does in graphite-web
+			r.Values[i] = math.RoundToEven(v*scale) / scale
+		}
+
+		results = append(results, &r)
+	}
+
+	return results, nil
+}
+
+// Description is auto-generated description, based on output of https://github.com/graphite-project/graphite-web
+func (f *round) Description() map[string]types.FunctionDescription {
+	return map[string]types.FunctionDescription{
+		"round": {
+			Description: "Takes one metric or a wildcard seriesList optionally followed by a precision, and rounds each\ndatapoint to the specified precision.\n\nExample:\n\n.. code-block:: none\n\n  &target=round(Server.instance01.threads.busy)\n  &target=round(Server.instance01.threads.busy,2)",
+			Function:    "round(seriesList, precision=None)",
+			Group:       "Transform",
+			Module:      "graphite.render.functions",
+			Name:        "round",
+			Params: []types.FunctionParam{
+				{
+					Name:     "seriesList",
+					Required: true,
+					Type:     types.SeriesList,
+				},
+				{
+					Default: types.NewSuggestion(0),
+					Name:    "precision",
+					Type:    types.Integer,
+				},
+			},
+		},
+	}
+}
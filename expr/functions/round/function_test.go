@@ -0,0 +1,61 @@
+package round
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/bookingcom/carbonapi/expr/helper"
+	"github.com/bookingcom/carbonapi/expr/metadata"
+	"github.com/bookingcom/carbonapi/expr/types"
+	"github.com/bookingcom/carbonapi/pkg/parser"
+	th "github.com/bookingcom/carbonapi/tests"
+)
+
+func init() {
+	md := New("")
+	evaluator := th.EvaluatorFromFunc(md[0].F)
+	metadata.SetEvaluator(evaluator)
+	helper.SetEvaluator(evaluator)
+	for _, m := range md {
+		metadata.RegisterFunction(m.Name, m.F)
+	}
+}
+
+func TestRound(t *testing.T) {
+	now32 := int32(time.Now().Unix())
+
+	tests := []th.EvalTestItem{
+		{
+			"round(metric1)",
+			map[parser.MetricRequest][]*types.MetricData{
+				{"metric1", 0, 1}: {types.MakeMetricData("metric1", []float64{0.4, 0.5, 1.5, 2.5, -0.5, -1.6, math.NaN(), 2.49}, 1, now32)},
+			},
+			[]*types.MetricData{types.MakeMetricData("round(metric1)",
+				[]float64{0, 0, 2, 2, 0, -2, math.NaN(), 2}, 1, now32)},
+		},
+		{
+			"round(metric1,2)",
+			map[parser.MetricRequest][]*types.MetricData{
+				{"metric1", 0, 1}: {types.MakeMetricData("metric1", []float64{1.234, 5.6789, -0.001}, 1, now32)},
+			},
+			[]*types.MetricData{types.MakeMetricData("round(metric1,2)",
+				[]float64{1.23, 5.68, 0}, 1, now32)},
+		},
+		{
+			"round(metric1,precision=-2)",
+			map[parser.MetricRequest][]*types.MetricData{
+				{"metric1", 0, 1}: {types.MakeMetricData("metric1", []float64{1234, 5678}, 1, now32)},
+			},
+			[]*types.MetricData{types.MakeMetricData("round(metric1,-2)",
+				[]float64{1200, 5700}, 1, now32)},
+		},
+	}
+
+	for _, tt := range tests {
+		testName := tt.Target
+		t.Run(testName, func(t *testing.T) {
+			th.TestEvalExpr(t, &tt)
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package setXFilesFactor
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/bookingcom/carbonapi/expr/helper"
+	"github.com/bookingcom/carbonapi/expr/metadata"
+	"github.com/bookingcom/carbonapi/expr/types"
+	"github.com/bookingcom/carbonapi/pkg/parser"
+	th "github.com/bookingcom/carbonapi/tests"
+)
+
+func init() {
+	md := New("")
+	evaluator := th.EvaluatorFromFunc(md[0].F)
+	metadata.SetEvaluator(evaluator)
+	helper.SetEvaluator(evaluator)
+	for _, m := range md {
+		metadata.RegisterFunction(m.Name, m.F)
+	}
+}
+
+func TestSetXFilesFactor(t *testing.T) {
+	for _, target := range []string{"setXFilesFactor(metric1,0.5)", "xFilesFactor(metric1,0.5)"} {
+		t.Run(target, func(t *testing.T) {
+			exp, _, err := parser.ParseExpr(target)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m := map[parser.MetricRequest][]*types.MetricData{
+				{Metric: "metric1", From: 0, Until: 1}: {
+					types.MakeMetricData("metric1", []float64{1, math.NaN(), math.NaN(), math.NaN(), 3, 4}, 1, 0),
+				},
+			}
+
+			res, err := metadata.GetEvaluator().EvalExpr(context.Background(), exp, 0, 1, m, th.NoopGetTargetData)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(res) != 1 {
+				t.Fatalf("Expected 1 series, got %d", len(res))
+			}
+			if res[0].Name != "metric1" || res[0].XFilesFactor != 0.5 {
+				t.Errorf("Expected metric1 with xFilesFactor 0.5, got %s with %v", res[0].Name, res[0].XFilesFactor)
+			}
+
+			// the second point has no present values, the first one just enough
+			want := types.MakeMetricData("metric1", []float64{1, math.NaN(), 3.5}, 2, 0)
+			if got := res[0].Consolidate(2); !th.NearlyEqualMetrics(got, want) {
+				t.Errorf("Expected consolidated values %v, got %v %v", want.Values, got.Values, got.IsAbsent)
+			}
+		})
+	}
+}
+
+func TestSetXFilesFactorInvalid(t *testing.T) {
+	exp, _, err := parser.ParseExpr("xFilesFactor(metric1,2)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := map[parser.MetricRequest][]*types.MetricData{
+		{Metric: "metric1", From: 0, Until: 1}: {types.MakeMetricData("metric1", []float64{1, 2}, 1, 0)},
+	}
+
+	if _, err := metadata.GetEvaluator().EvalExpr(context.Background(), exp, 0, 1, m, th.NoopGetTargetData); err != parser.ErrInvalidArgumentValue {
+		t.Errorf("Expected %v, got %v", parser.ErrInvalidArgumentValue, err)
+	}
+}
@@ -0,0 +1,125 @@
+package sinFunction
+
+import (
+	"context"
+	"math"
+
+	"github.com/bookingcom/carbonapi/expr/interfaces"
+	"github.com/bookingcom/carbonapi/expr/types"
+	"github.com/bookingcom/carbonapi/pkg/parser"
+	dataTypes "github.com/bookingcom/carbonapi/pkg/types"
+)
+
+type sinFunction struct {
+	interfaces.FunctionBase
+}
+
+func GetOrder() interfaces.Order {
+	return interfaces.Any
+}
+
+func New(configFile string) []interfaces.FunctionMetadata {
+	res := make([]interfaces.FunctionMetadata, 0)
+	f := &sinFunction{}
+	functions := []string{"sinFunction", "sin"}
+	for _, n := range functions {
+		res = append(res, interfaces.FunctionMetadata{Name: n, F: f})
+	}
+	return res
+}
+
+// sinFunction(name, amplitude=1, step=60)
+func (f *sinFunction) Do(ctx context.Context, e parser.Expr, from, until int32, values map[parser.MetricRequest][]*types.MetricData, getTargetData interfaces.GetTargetData) ([]*types.MetricData, error) {
+	name, err := e.GetStringArg(0)
+	if err != nil {
+		return nil, err
+	}
+
+	amplitude, err := e.GetFloatNamedOrPosArgDefault("amplitude", 1, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	stepInt, err := e.GetIntNamedOrPosArgDefault("step", 2, 60)
+	if err != nil {
+		return nil, err
+	}
+	if stepInt <= 0 {
+		return nil, parser.ParseError("step can't be less than 0")
+	}
+	step := int32(stepInt)
+
+	newValues := make([]float64, (until-from-1+step)/step)
+	value := from
+	for i := 0; i < len(newValues); i++ {
+		newValues[i] = math.Sin(float64(value)) * amplitude
+		value += step
+	}
+
+	p := types.MetricData{
+		Metric: dataTypes.Metric{
+			Name:      name,
+			StartTime: from,
+			StopTime:  until,
+			StepTime:  step,
+			Values:    newValues,
+			IsAbsent:  make([]bool, len(newValues)),
+		},
+	}
+
+	return []*types.MetricData{&p}, nil
+}
+
+// Description is auto-generated description, based on output of https://github.com/graphite-project/graphite-web
+func (f *sinFunction) Description() map[string]types.FunctionDescription {
+	return map[string]types.FunctionDescription{
+		"sinFunction": {
+			Description: "Short Alias: sin()\n\nJust returns the sine of the current time. The optional amplitude parameter\nchanges the amplitude of the wave.\n\nExample:\n\n.. code-block:: none\n\n  &target=sin(\"The.time.series\", 2)\n\nThis would create a series named \"The.time.series\" that contains sin(x)*2.\nAccepts optional second argument as 'amplitude' parameter (default amplitude is 1)\nAccepts optional third argument as 'step' parameter (default step is 60 sec)",
+			Function:    "sinFunction(name, amplitude=1, step=60)",
+			Group:       "Special",
+			Module:      "graphite.render.functions",
+			Name:        "sinFunction",
+			Params: []types.FunctionParam{
+				{
+					Name:     "name",
+					Required: true,
+					Type:     types.String,
+				},
+				{
+					Default: types.NewSuggestion(1),
+					Name:    "amplitude",
+					Type:    types.Integer,
+				},
+				{
+					Default: types.NewSuggestion(60),
+					Name:    "step",
+					Type:    types.Integer,
+				},
+			},
+		},
+		"sin": {
+			Description: "Short Alias: sin()\n\nJust returns the sine of the current time. The optional amplitude parameter\nchanges the amplitude of the wave.\n\nExample:\n\n.. code-block:: none\n\n  &target=sin(\"The.time.series\", 2)\n\nThis would create a series named \"The.time.series\" that contains sin(x)*2.\nAccepts optional second argument as 'amplitude' parameter (default amplitude is 1)\nAccepts optional third argument as 'step' parameter (default step is 60 sec)",
+			Function:    "sin(name, amplitude=1, step=60)",
+			Group:       "Special",
+			Module:      "graphite.render.functions",
+			Name:        "sin",
+			Params: []types.FunctionParam{
+				{
+					Name:     "name",
+					Required: true,
+					Type:     types.String,
+				},
+				{
+					Default: types.NewSuggestion(1),
+					Name:    "amplitude",
+					Type:    types.Integer,
+				},
+				{
+					Default: types.NewSuggestion(60),
+					Name:    "step",
+					Type:    types.Integer,
+				},
+			},
+		},
+	}
+}
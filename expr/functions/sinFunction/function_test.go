@@ -0,0 +1,76 @@
+package sinFunction
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/bookingcom/carbonapi/expr/helper"
+	"github.com/bookingcom/carbonapi/expr/metadata"
+	"github.com/bookingcom/carbonapi/expr/types"
+	"github.com/bookingcom/carbonapi/pkg/parser"
+	th "github.com/bookingcom/carbonapi/tests"
+)
+
+func init() {
+	md := New("")
+	evaluator := th.EvaluatorFromFunc(md[0].F)
+	metadata.SetEvaluator(evaluator)
+	helper.SetEvaluator(evaluator)
+	for _, m := range md {
+		metadata.RegisterFunction(m.Name, m.F)
+	}
+}
+
+func TestSinFunction(t *testing.T) {
+	tests := []struct {
+		target string
+		from   int32
+		until  int32
+		want   *types.MetricData
+	}{
+		{
+			"sinFunction('foo')",
+			0,
+			180,
+			types.MakeMetricData("foo", []float64{0, math.Sin(60), math.Sin(120)}, 60, 0),
+		},
+		{
+			"sin('bar',2,30)",
+			0,
+			90,
+			types.MakeMetricData("bar", []float64{0, 2 * math.Sin(30), 2 * math.Sin(60)}, 30, 0),
+		},
+		{
+			"sin('baz',amplitude=3,step=10)",
+			10,
+			30,
+			types.MakeMetricData("baz", []float64{3 * math.Sin(10), 3 * math.Sin(20)}, 10, 10),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			exp, _, err := parser.ParseExpr(tt.target)
+			if err != nil {
+				t.Fatalf("failed to parse %s: %v", tt.target, err)
+			}
+			g, err := metadata.GetEvaluator().EvalExpr(context.Background(), exp, tt.from, tt.until, nil, th.NoopGetTargetData)
+			if err != nil {
+				t.Fatalf("failed to eval %s: %v", tt.target, err)
+			}
+			if len(g) != 1 {
+				t.Fatalf("%s returned %d metrics, want 1", tt.target, len(g))
+			}
+			if g[0].Name != tt.want.Name {
+				t.Errorf("bad name: got %s, want %s", g[0].Name, tt.want.Name)
+			}
+			if g[0].StepTime != tt.want.StepTime {
+				t.Errorf("bad step: got %d, want %d", g[0].StepTime, tt.want.StepTime)
+			}
+			if !th.NearlyEqualMetrics(g[0], tt.want) {
+				t.Errorf("different values: got %v, want %v", g[0].Values, tt.want.Values)
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package smartSummarize
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bookingcom/carbonapi/expr/evalctx"
+	"github.com/bookingcom/carbonapi/expr/helper"
+	"github.com/bookingcom/carbonapi/expr/interfaces"
+	"github.com/bookingcom/carbonapi/expr/types"
+	"github.com/bookingcom/carbonapi/pkg/parser"
+	dataTypes "github.com/bookingcom/carbonapi/pkg/types"
+)
+
+type smartSummarize struct {
+	interfaces.FunctionBase
+}
+
+func GetOrder() interfaces.Order {
+	return interfaces.Any
+}
+
+func New(configFile string) []interfaces.FunctionMetadata {
+	res := make([]interfaces.FunctionMetadata, 0)
+	f := &smartSummarize{}
+	functions := []string{"smartSummarize"}
+	for _, n := range functions {
+		res = append(res, interfaces.FunctionMetadata{Name: n, F: f})
+	}
+	return res
+}
+
+// smartSummarize(seriesList, intervalString, func='sum', alignTo=None)
+func (f *smartSummarize) Do(ctx context.Context, e parser.Expr, from, until int32, values map[parser.MetricRequest][]*types.MetricData, getTargetData interfaces.GetTargetData) ([]*types.MetricData, error) {
+	bucketSize, err := e.GetIntervalArg(1, 1)
+	if err != nil {
+		return nil, err
+	}
+	if bucketSize <= 0 {
+		return nil, parser.ErrInvalidArgumentValue
+	}
+
+	summarizeFunction, err := e.GetStringNamedOrPosArgDefault("func", 2, "sum")
+	if err != nil {
+		return nil, err
+	}
+
+	alignTo, err := e.GetStringNamedOrPosArgDefault("alignTo", 3, "")
+	if err != nil {
+		return nil, err
+	}
+
+	start := from
+	if alignTo != "" {
+		alignInterval, err := parser.IntervalString(alignTo, 1)
+		if err != nil {
+			return nil, err
+		}
+		start = helper.AlignStartToInterval(from, until, alignInterval, evalctx.FromContext(ctx).Location)
+		if start != from {
+			// the aligned range starts earlier than the one fetched for the request
+			err, _ = getTargetData(ctx, e.Args()[0], start, until, values)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	args, err := helper.GetSeriesArg(ctx, e.Args()[0], start, until, values, getTargetData)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*types.MetricData, 0, len(args))
+	for _, arg := range args {
+		buckets := helper.GetBuckets(arg.StartTime, arg.StopTime, bucketSize)
+
+		r := types.MetricData{
+			Metric: dataTypes.Metric{
+				Name:      fmt.Sprintf("smartSummarize(%s,'%s','%s')", arg.Name, e.Args()[1].StringValue(), summarizeFunction),
+				Values:    make([]float64, buckets),
+				IsAbsent:  make([]bool, buckets),
+				StepTime:  bucketSize,
+				StartTime: arg.StartTime,
+				StopTime:  arg.StartTime + buckets*bucketSize,
+			},
+		}
+
+		bucketValues := make([][]float64, buckets)
+		t := arg.StartTime
+		for i, v := range arg.Values {
+			idx := (t - arg.StartTime) / bucketSize
+			if idx >= buckets {
+				break
+			}
+			if !arg.IsAbsent[i] {
+				bucketValues[idx] = append(bucketValues[idx], v)
+			}
+			t += arg.StepTime
+		}
+
+		for i, v := range bucketValues {
+			r.Values[i], r.IsAbsent[i], err = helper.SummarizeValues(summarizeFunction, v)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		results = append(results, &r)
+	}
+
+	return results, nil
+}
+
+// Description is auto-generated description, based on output of https://github.com/graphite-project/graphite-web
+func (f *smartSummarize) Description() map[string]types.FunctionDescription {
+	return map[string]types.FunctionDescription{
+		"smartSummarize": {
+			Description: "Smarter version of summarize.\n\nThe alignToFrom boolean parameter has been replaced by alignTo and no longer has any effect.\nAlignment can be to years, months, weeks, days, hours, and minutes.\n\nThis function can be used with aggregation functions ``average``, ``median``, ``sum``, ``min``,\n``max``, ``diff``, ``stddev``, ``count``, ``range``, ``multiply`` & ``last``.",
+			Function:    "smartSummarize(seriesList, intervalString, func='sum', alignTo=None)",
+			Group:       "Transform",
+			Module:      "graphite.render.functions",
+			Name:        "smartSummarize",
+			Params: []types.FunctionParam{
+				{
+					Name:     "seriesList",
+					Required: true,
+					Type:     types.SeriesList,
+				},
+				{
+					Name:     "intervalString",
+					Required: true,
+					Suggestions: types.NewSuggestions(
+						"10min",
+						"1h",
+						"1d",
+					),
+					Type: types.Interval,
+				},
+				{
+					Default: types.NewSuggestion("sum"),
+					Name:    "func",
+					Options: []string{
+						"average",
+						"count",
+						"diff",
+						"last",
+						"max",
+						"median",
+						"min",
+						"multiply",
+						"range",
+						"stddev",
+						"sum",
+					},
+					Type: types.AggFunc,
+				},
+				{
+					Name: "alignTo",
+					Suggestions: types.NewSuggestions(
+						"1m",
+						"1h",
+						"1d",
+					),
+					Type: types.Interval,
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,93 @@
+package smartSummarize
+
+import (
+	"math"
+	"testing"
+
+	"github.com/bookingcom/carbonapi/expr/helper"
+	"github.com/bookingcom/carbonapi/expr/metadata"
+	"github.com/bookingcom/carbonapi/expr/types"
+	"github.com/bookingcom/carbonapi/pkg/parser"
+	th "github.com/bookingcom/carbonapi/tests"
+)
+
+func init() {
+	md := New("")
+	evaluator := th.EvaluatorFromFunc(md[0].F)
+	metadata.SetEvaluator(evaluator)
+	helper.SetEvaluator(evaluator)
+	for _, m := range md {
+		metadata.RegisterFunction(m.Name, m.F)
+	}
+}
+
+func TestEvalSmartSummarize(t *testing.T) {
+	tenThirtyTwo, _, _ := th.InitTestSummarize()
+	now32 := tenThirtyTwo
+
+	tests := []th.SummarizeEvalTestItem{
+		{
+			"smartSummarize(metric1,'5s')",
+			map[parser.MetricRequest][]*types.MetricData{
+				{"metric1", 0, 1}: {types.MakeMetricData("metric1", []float64{
+					1, 1, 1, 1, 1,
+					2, 2, 2, 2, 2,
+					3, 3, 3, 3, 3,
+					math.NaN(), 2, 3, 4, 5,
+					math.NaN(), math.NaN(), math.NaN(), math.NaN(), math.NaN(),
+				}, 1, now32)},
+			},
+			[]float64{5, 10, 15, 14, math.NaN()},
+			"smartSummarize(metric1,'5s','sum')",
+			5,
+			now32,
+			now32 + 25,
+		},
+		{
+			"smartSummarize(metric1,'5s','avg')",
+			map[parser.MetricRequest][]*types.MetricData{
+				{"metric1", 0, 1}: {types.MakeMetricData("metric1", []float64{
+					1, 2, 3, 4, 5,
+					2, 2, 2, 2, 2,
+					1, 2, math.NaN(),
+				}, 1, now32)},
+			},
+			[]float64{3, 2, 1.5},
+			"smartSummarize(metric1,'5s','avg')",
+			5,
+			now32,
+			now32 + 15,
+		},
+		{
+			"smartSummarize(metric1,'10s','max')",
+			map[parser.MetricRequest][]*types.MetricData{
+				{"metric1", 0, 1}: {types.MakeMetricData("metric1", []float64{
+					1, 0, 7, 2, 4,
+					0, 1, 9, 3, 5,
+				}, 2, now32)},
+			},
+			[]float64{7, 9},
+			"smartSummarize(metric1,'10s','max')",
+			10,
+			now32,
+			now32 + 20,
+		},
+		{
+			"smartSummarize(metric1,'1min',func='last')",
+			map[parser.MetricRequest][]*types.MetricData{
+				{"metric1", 0, 1}: {types.MakeMetricData("metric1", []float64{
+					1, 2, 3, 4, 5, 6,
+				}, 20, now32)},
+			},
+			[]float64{3, 6},
+			"smartSummarize(metric1,'1min','last')",
+			60,
+			now32,
+			now32 + 120,
+		},
+	}
+
+	for _, tt := range tests {
+		th.TestSummarizeEvalExpr(t, &tt)
+	}
+}
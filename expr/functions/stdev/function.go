@@ -105,10 +105,10 @@ func (f *stdev) Description() map[string]types.FunctionDescription {
 		},
 		"stddev": {
 			Description: "Takes one metric or a wildcard seriesList followed by an integer N.\nDraw the Standard Deviation of all metrics passed for the past N datapoints.\nIf the ratio of null points in the window is greater than windowTolerance,\nskip the calculation. The default for windowTolerance is 0.1 (up to 10% of points\nin the window can be missing). Note that if this is set to 0.0, it will cause large\ngaps in the output anywhere a single point is missing.\n\nExample:\n\n.. code-block:: none\n\n  &target=stdev(server*.instance*.threads.busy,30)\n  &target=stdev(server*.instance*.cpu.system,30,0.0)",
-			Function:    "stdev(seriesList, points, windowTolerance=0.1)",
+			Function:    "stddev(seriesList, points, windowTolerance=0.1)",
 			Group:       "Calculate",
 			Module:      "graphite.render.functions",
-			Name:        "stdev",
+			Name:        "stddev",
 			Params: []types.FunctionParam{
 				{
 					Name:     "seriesList",
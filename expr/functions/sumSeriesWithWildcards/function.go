@@ -37,9 +37,13 @@ func (f *sumSeriesWithWildcards) Do(ctx context.Context, e parser.Expr, from, un
 		return nil, err
 	}
 
-	fields, err := e.GetIntArgs(1)
-	if err != nil {
-		return nil, err
+	// without positions every series is a group of its own, as in graphite-web
+	var fields []int
+	if len(e.Args()) > 1 {
+		fields, err = e.GetIntArgs(1)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	var results []*types.MetricData
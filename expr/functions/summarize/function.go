@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/bookingcom/carbonapi/expr/calendar"
+	"github.com/bookingcom/carbonapi/expr/evalctx"
 	"github.com/bookingcom/carbonapi/expr/helper"
 	"github.com/bookingcom/carbonapi/expr/interfaces"
 	"github.com/bookingcom/carbonapi/expr/types"
@@ -44,6 +46,9 @@ func (f *summarize) Do(ctx context.Context, e parser.Expr, from, until int32, va
 	if err != nil {
 		return nil, err
 	}
+	if bucketSize <= 0 {
+		return nil, parser.ErrInvalidArgumentValue
+	}
 
 	summarizeFunction, err := e.GetStringNamedOrPosArgDefault("func", 2, "sum")
 	if err != nil {
@@ -66,10 +71,19 @@ func (f *summarize) Do(ctx context.Context, e parser.Expr, from, until int32, va
 	start := args[0].StartTime
 	stop := args[0].StopTime
 	if !alignToFrom {
-		start, stop = helper.AlignToBucketSize(start, stop, bucketSize)
+		// the first bucket starts at a local midnight for buckets of days,
+		// the following ones keep bucketSize so that points stay evenly spaced
+		start = calendar.Truncate(start, bucketSize, evalctx.FromContext(ctx).Location)
+	}
+	bounds := make([]int32, helper.GetBuckets(start, stop, bucketSize)+1)
+	for i := range bounds {
+		bounds[i] = start + int32(i)*bucketSize
+	}
+	if !alignToFrom {
+		stop = bounds[len(bounds)-1]
 	}
 
-	buckets := helper.GetBuckets(start, stop, bucketSize)
+	buckets := int32(len(bounds) - 1)
 	results := make([]*types.MetricData, 0, len(args))
 	for _, arg := range args {
 
@@ -115,7 +129,10 @@ func (f *summarize) Do(ctx context.Context, e parser.Expr, from, until int32, va
 			}}
 
 		t := arg.StartTime // unadjusted
-		bucketEnd := start + bucketSize
+		bucketEnd := stop
+		if buckets > 0 {
+			bucketEnd = bounds[1]
+		}
 		values := make([]float64, 0, bucketSize/arg.StepTime)
 		ridx := 0
 		bucketItems := 0
@@ -137,7 +154,9 @@ func (f *summarize) Do(ctx context.Context, e parser.Expr, from, until int32, va
 					return []*types.MetricData{}, err
 				}
 				ridx++
-				bucketEnd += bucketSize
+				if ridx < len(bounds)-1 {
+					bucketEnd = bounds[ridx+1]
+				}
 				bucketItems = 0
 				values = values[:0]
 			}
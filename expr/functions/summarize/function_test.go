@@ -1,9 +1,12 @@
 package summarize
 
 import (
+	"context"
 	"math"
 	"testing"
+	"time"
 
+	"github.com/bookingcom/carbonapi/expr/evalctx"
 	"github.com/bookingcom/carbonapi/expr/helper"
 	"github.com/bookingcom/carbonapi/expr/metadata"
 	"github.com/bookingcom/carbonapi/expr/types"
@@ -233,3 +236,47 @@ func TestEvalSummarize(t *testing.T) {
 		th.TestSummarizeEvalExpr(t, &tt)
 	}
 }
+
+func TestSummarizeTimeZone(t *testing.T) {
+	ams, err := time.LoadLocation("Europe/Amsterdam")
+	if err != nil {
+		t.Skip(err)
+	}
+
+	// hours of the days around the switch to summer time, which has 23 hours,
+	// buckets start at the local midnight before it
+	start := int32(time.Date(2021, time.March, 27, 0, 0, 0, 0, ams).Unix())
+	stop := int32(time.Date(2021, time.March, 30, 0, 0, 0, 0, ams).Unix())
+	values := make([]float64, (stop-start)/3600)
+	for i := range values {
+		values[i] = 1
+	}
+	m := map[parser.MetricRequest][]*types.MetricData{
+		{"metric1", 0, 1}: {types.MakeMetricData("metric1", values, 3600, start)},
+	}
+
+	exp, _, err := parser.ParseExpr("summarize(metric1,'1d')")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := evalctx.NewContext(context.Background(), evalctx.Context{Location: ams})
+	g, err := metadata.GetEvaluator().EvalExpr(ctx, exp, 0, 1, m, th.NoopGetTargetData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if g[0].StartTime != start {
+		t.Errorf("Expected start at %v, got %v", time.Unix(int64(start), 0).In(ams), time.Unix(int64(g[0].StartTime), 0).In(ams))
+	}
+	// buckets keep their length after the switch, the last one missing the lost hour
+	if !th.NearlyEqual(g[0].Values, g[0].IsAbsent, []float64{24, 24, 23}) {
+		t.Errorf("Expected a sum per 24 hours, got %v", g[0].Values)
+	}
+	if g[0].StepTime != 86400 || g[0].StopTime != g[0].StartTime+int32(len(g[0].Values))*g[0].StepTime {
+		t.Errorf("Expected points a day apart, got step %d from %d to %d", g[0].StepTime, g[0].StartTime, g[0].StopTime)
+	}
+	last := g[0].StartTime + 2*g[0].StepTime
+	if want := int32(time.Date(2021, time.March, 29, 1, 0, 0, 0, ams).Unix()); last != want {
+		t.Errorf("Expected the last bucket at %v, got %v", time.Unix(int64(want), 0).In(ams), time.Unix(int64(last), 0).In(ams))
+	}
+}
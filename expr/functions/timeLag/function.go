@@ -157,9 +157,8 @@ func (f *timeLag) Description() map[string]types.FunctionDescription {
 					Type:     types.SeriesList,
 				},
 				{
-					Name:     "produceMaxOffsetSeries",
-					Required: true,
-					Type:     types.SeriesList,
+					Name: "produceMaxOffsetSeries",
+					Type: types.SeriesList,
 				},
 			},
 		},
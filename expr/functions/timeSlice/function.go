@@ -0,0 +1,107 @@
+package timeSlice
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bookingcom/carbonapi/date"
+	"github.com/bookingcom/carbonapi/expr/evalctx"
+	"github.com/bookingcom/carbonapi/expr/helper"
+	"github.com/bookingcom/carbonapi/expr/interfaces"
+	"github.com/bookingcom/carbonapi/expr/types"
+	"github.com/bookingcom/carbonapi/pkg/parser"
+)
+
+type timeSlice struct {
+	interfaces.FunctionBase
+}
+
+func GetOrder() interfaces.Order {
+	return interfaces.Any
+}
+
+func New(configFile string) []interfaces.FunctionMetadata {
+	res := make([]interfaces.FunctionMetadata, 0)
+	f := &timeSlice{}
+	functions := []string{"timeSlice"}
+	for _, n := range functions {
+		res = append(res, interfaces.FunctionMetadata{Name: n, F: f})
+	}
+	return res
+}
+
+// timeSlice(seriesList, startSliceAt, endSliceAt='now')
+func (f *timeSlice) Do(ctx context.Context, e parser.Expr, from, until int32, values map[parser.MetricRequest][]*types.MetricData, getTargetData interfaces.GetTargetData) ([]*types.MetricData, error) {
+	args, err := helper.GetSeriesArg(ctx, e.Args()[0], from, until, values, getTargetData)
+	if err != nil {
+		return nil, err
+	}
+
+	startStr, err := e.GetStringNamedOrPosArgDefault("startSliceAt", 1, "")
+	if err != nil {
+		return nil, err
+	}
+	if startStr == "" {
+		return nil, parser.ErrMissingArgument
+	}
+
+	endStr, err := e.GetStringNamedOrPosArgDefault("endSliceAt", 2, "now")
+	if err != nil {
+		return nil, err
+	}
+
+	start := date.DateParamToEpoch(startStr, "", int64(from), evalctx.FromContext(ctx).Location)
+	end := date.DateParamToEpoch(endStr, "", int64(until), evalctx.FromContext(ctx).Location)
+
+	results := make([]*types.MetricData, 0, len(args))
+	for _, a := range args {
+		r := *a
+		r.Name = fmt.Sprintf("timeSlice(%s, %d, %d)", a.Name, start, end)
+		r.Values = make([]float64, len(a.Values))
+		r.IsAbsent = make([]bool, len(a.Values))
+
+		t := a.StartTime
+		for i, v := range a.Values {
+			if a.IsAbsent[i] || t < start || t > end {
+				r.IsAbsent[i] = true
+			} else {
+				r.Values[i] = v
+			}
+			t += a.StepTime
+		}
+
+		results = append(results, &r)
+	}
+
+	return results, nil
+}
+
+// Description is auto-generated description, based on output of https://github.com/graphite-project/graphite-web
+func (f *timeSlice) Description() map[string]types.FunctionDescription {
+	return map[string]types.FunctionDescription{
+		"timeSlice": {
+			Description: "Takes one metric or a wildcard metric, followed by a quoted\nstring with the time to start the line and another quoted string\nwith the time to end the line. The start and end times are\ninclusive. See ``from / until`` in the render\\_api_ for examples of\ntime formats.\n\nUseful for filtering out a part of a series of data from a wider\nrange of data.\n\nExample:\n\n.. code-block:: none\n\n  &target=timeSlice(network.core.port1,\"00:00 20140101\",\"11:59 20140630\")\n  &target=timeSlice(network.core.port1,\"12:00 20140630\",\"now\")",
+			Function:    "timeSlice(seriesList, startSliceAt, endSliceAt='now')",
+			Group:       "Transform",
+			Module:      "graphite.render.functions",
+			Name:        "timeSlice",
+			Params: []types.FunctionParam{
+				{
+					Name:     "seriesList",
+					Required: true,
+					Type:     types.SeriesList,
+				},
+				{
+					Name:     "startSliceAt",
+					Required: true,
+					Type:     types.Date,
+				},
+				{
+					Default: types.NewSuggestion("now"),
+					Name:    "endSliceAt",
+					Type:    types.Date,
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,54 @@
+package timeSlice
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/bookingcom/carbonapi/expr/helper"
+	"github.com/bookingcom/carbonapi/expr/metadata"
+	"github.com/bookingcom/carbonapi/expr/types"
+	"github.com/bookingcom/carbonapi/pkg/parser"
+	th "github.com/bookingcom/carbonapi/tests"
+)
+
+func init() {
+	md := New("")
+	evaluator := th.EvaluatorFromFunc(md[0].F)
+	metadata.SetEvaluator(evaluator)
+	helper.SetEvaluator(evaluator)
+	for _, m := range md {
+		metadata.RegisterFunction(m.Name, m.F)
+	}
+}
+
+func TestTimeSlice(t *testing.T) {
+	now32 := int32(time.Now().Unix())
+
+	tests := []th.EvalTestItem{
+		{
+			fmt.Sprintf("timeSlice(metric1,'%d','%d')", now32+2, now32+4),
+			map[parser.MetricRequest][]*types.MetricData{
+				{"metric1", 0, 1}: {types.MakeMetricData("metric1", []float64{1, 2, 3, math.NaN(), 5, 6, 7}, 1, now32)},
+			},
+			[]*types.MetricData{types.MakeMetricData(fmt.Sprintf("timeSlice(metric1, %d, %d)", now32+2, now32+4),
+				[]float64{math.NaN(), math.NaN(), 3, math.NaN(), 5, math.NaN(), math.NaN()}, 1, now32)},
+		},
+		{
+			fmt.Sprintf("timeSlice(metric1,'%d',endSliceAt='%d')", now32+10, now32+20),
+			map[parser.MetricRequest][]*types.MetricData{
+				{"metric1", 0, 1}: {types.MakeMetricData("metric1", []float64{1, 2, 3, 4}, 10, now32)},
+			},
+			[]*types.MetricData{types.MakeMetricData(fmt.Sprintf("timeSlice(metric1, %d, %d)", now32+10, now32+20),
+				[]float64{math.NaN(), 2, 3, math.NaN()}, 10, now32)},
+		},
+	}
+
+	for _, tt := range tests {
+		testName := tt.Target
+		t.Run(testName, func(t *testing.T) {
+			th.TestEvalExpr(t, &tt)
+		})
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/bookingcom/carbonapi/expr/calendar"
+	"github.com/bookingcom/carbonapi/expr/evalctx"
 	"github.com/bookingcom/carbonapi/expr/helper"
 	"github.com/bookingcom/carbonapi/expr/interfaces"
 	"github.com/bookingcom/carbonapi/expr/types"
@@ -28,36 +30,52 @@ func New(configFile string) []interfaces.FunctionMetadata {
 	return res
 }
 
-// timeStack(seriesList, timeShiftUnit, timeShiftStart, timeShiftEnd)
+// timeStack(seriesList, timeShiftUnit='1d', timeShiftStart=0, timeShiftEnd=7)
 func (f *timeStack) Do(ctx context.Context, e parser.Expr, from, until int32, values map[parser.MetricRequest][]*types.MetricData, getTargetData interfaces.GetTargetData) ([]*types.MetricData, error) {
-	unit, err := e.GetIntervalArg(1, -1)
+	unitStr, err := e.GetStringNamedOrPosArgDefault("timeShiftUnit", 1, "1d")
 	if err != nil {
 		return nil, err
 	}
+	unit, err := parser.IntervalString(unitStr, -1)
+	if err != nil {
+		return nil, parser.ErrBadType
+	}
 
-	start, err := e.GetIntArg(2)
+	start, err := e.GetIntNamedOrPosArgDefault("timeShiftStart", 2, 0)
 	if err != nil {
 		return nil, err
 	}
 
-	end, err := e.GetIntArg(3)
+	end, err := e.GetIntNamedOrPosArgDefault("timeShiftEnd", 3, 7)
 	if err != nil {
 		return nil, err
 	}
 
+	loc := evalctx.FromContext(ctx).Location
 	var results []*types.MetricData
 	for i := int32(start); i < int32(end); i++ {
 		offs := i * unit
-		arg, err := helper.GetSeriesArg(ctx, e.Args()[0], from+offs, until+offs, values, getTargetData)
+		// shifts by days keep the wall clock time across DST transitions
+		shiftedFrom := calendar.Shift(from, offs, loc)
+		shiftedUntil := calendar.Shift(until, offs, loc)
+		if shiftedFrom != from+offs || shiftedUntil != until+offs {
+			// the range isn't the one fetched for the request
+			err, _ = getTargetData(ctx, e.Args()[0], shiftedFrom, shiftedUntil, values)
+			if err != nil {
+				return nil, err
+			}
+		}
+		arg, err := helper.GetSeriesArg(ctx, e.Args()[0], shiftedFrom, shiftedUntil, values, getTargetData)
 		if err != nil {
 			return nil, err
 		}
 
+		shift := shiftedFrom - from
 		for _, a := range arg {
 			r := *a
 			r.Name = fmt.Sprintf("timeShift(%s,%d)", a.Name, offs)
-			r.StartTime = a.StartTime - offs
-			r.StopTime = a.StopTime - offs
+			r.StartTime = a.StartTime - shift
+			r.StopTime = a.StopTime - shift
 			results = append(results, &r)
 		}
 	}
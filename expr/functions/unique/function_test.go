@@ -0,0 +1,57 @@
+package unique
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bookingcom/carbonapi/expr/helper"
+	"github.com/bookingcom/carbonapi/expr/metadata"
+	"github.com/bookingcom/carbonapi/expr/types"
+	"github.com/bookingcom/carbonapi/pkg/parser"
+	th "github.com/bookingcom/carbonapi/tests"
+)
+
+func init() {
+	md := New("")
+	evaluator := th.EvaluatorFromFunc(md[0].F)
+	metadata.SetEvaluator(evaluator)
+	helper.SetEvaluator(evaluator)
+	for _, m := range md {
+		metadata.RegisterFunction(m.Name, m.F)
+	}
+}
+
+func TestUnique(t *testing.T) {
+	now32 := int32(time.Now().Unix())
+
+	tests := []th.EvalTestItem{
+		{
+			"unique(metric[12],metric[23],metric3)",
+			map[parser.MetricRequest][]*types.MetricData{
+				{"metric[12]", 0, 1}: {
+					types.MakeMetricData("metric1", []float64{1, 2, 3}, 1, now32),
+					types.MakeMetricData("metric2", []float64{4, 5, 6}, 1, now32),
+				},
+				{"metric[23]", 0, 1}: {
+					types.MakeMetricData("metric2", []float64{4, 5, 6}, 1, now32),
+					types.MakeMetricData("metric3", []float64{7, 8, 9}, 1, now32),
+				},
+				{"metric3", 0, 1}: {
+					types.MakeMetricData("metric3", []float64{7, 8, 9}, 1, now32),
+				},
+			},
+			[]*types.MetricData{
+				types.MakeMetricData("metric1", []float64{1, 2, 3}, 1, now32),
+				types.MakeMetricData("metric2", []float64{4, 5, 6}, 1, now32),
+				types.MakeMetricData("metric3", []float64{7, 8, 9}, 1, now32),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		testName := tt.Target
+		t.Run(testName, func(t *testing.T) {
+			th.TestEvalExpr(t, &tt)
+		})
+	}
+}
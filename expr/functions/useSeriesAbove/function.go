@@ -0,0 +1,123 @@
+package useSeriesAbove
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"github.com/bookingcom/carbonapi/expr/helper"
+	"github.com/bookingcom/carbonapi/expr/interfaces"
+	"github.com/bookingcom/carbonapi/expr/types"
+	"github.com/bookingcom/carbonapi/pkg/parser"
+)
+
+type useSeriesAbove struct {
+	interfaces.FunctionBase
+}
+
+func GetOrder() interfaces.Order {
+	return interfaces.Any
+}
+
+func New(configFile string) []interfaces.FunctionMetadata {
+	res := make([]interfaces.FunctionMetadata, 0)
+	f := &useSeriesAbove{}
+	functions := []string{"useSeriesAbove"}
+	for _, n := range functions {
+		res = append(res, interfaces.FunctionMetadata{Name: n, F: f})
+	}
+	return res
+}
+
+// useSeriesAbove(seriesList, value, search, replace)
+func (f *useSeriesAbove) Do(ctx context.Context, e parser.Expr, from, until int32, values map[parser.MetricRequest][]*types.MetricData, getTargetData interfaces.GetTargetData) ([]*types.MetricData, error) {
+	args, err := helper.GetSeriesArg(ctx, e.Args()[0], from, until, values, getTargetData)
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := e.GetFloatArg(1)
+	if err != nil {
+		return nil, err
+	}
+
+	search, err := e.GetStringArg(2)
+	if err != nil {
+		return nil, err
+	}
+
+	replace, err := e.GetStringArg(3)
+	if err != nil {
+		return nil, err
+	}
+
+	re, err := regexp.Compile(search)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s %v", parser.ErrInvalidArgumentValue, search, err)
+	}
+
+	replace = helper.Backref.ReplaceAllString(replace, "$${$1}")
+
+	var results []*types.MetricData
+	for _, a := range args {
+		if helper.MaxValue(a.Values, a.IsAbsent) <= value {
+			continue
+		}
+
+		newTarget := re.ReplaceAllString(a.Name, replace)
+		newExpr, _, err := parser.ParseExpr(newTarget)
+		if err != nil {
+			return nil, err
+		}
+
+		// retrieve new metrics if required
+		err, _ = getTargetData(ctx, newExpr, from, until, values)
+		if err != nil {
+			return nil, err
+		}
+		result, err := f.Evaluator.EvalExpr(ctx, newExpr, from, until, values, getTargetData)
+		if err != nil {
+			return nil, err
+		}
+		if len(result) > 0 {
+			results = append(results, result[0])
+		}
+	}
+
+	return results, nil
+}
+
+// Description is auto-generated description, based on output of https://github.com/graphite-project/graphite-web
+func (f *useSeriesAbove) Description() map[string]types.FunctionDescription {
+	return map[string]types.FunctionDescription{
+		"useSeriesAbove": {
+			Description: "Compares the maximum of each series against the given `value`. If the series\nmaximum is greater than `value`, the regular expression search and replace is\napplied against the series name to plot a related metric\n\ne.g. given useSeriesAbove(ganglia.metric1.reqs,10,'reqs','time'),\nthe response time metric will be plotted only when the maximum value of the\ncorresponding request/s metric is > 10\n\n.. code-block:: none\n\n  &target=useSeriesAbove(ganglia.metric1.reqs,10,\"reqs\",\"time\")",
+			Function:    "useSeriesAbove(seriesList, value, search, replace)",
+			Group:       "Filter Series",
+			Module:      "graphite.render.functions",
+			Name:        "useSeriesAbove",
+			Params: []types.FunctionParam{
+				{
+					Name:     "seriesList",
+					Required: true,
+					Type:     types.SeriesList,
+				},
+				{
+					Name:     "value",
+					Required: true,
+					Type:     types.Float,
+				},
+				{
+					Name:     "search",
+					Required: true,
+					Type:     types.String,
+				},
+				{
+					Name:     "replace",
+					Required: true,
+					Type:     types.String,
+				},
+			},
+		},
+	}
+}
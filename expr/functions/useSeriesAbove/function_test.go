@@ -0,0 +1,73 @@
+package useSeriesAbove
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bookingcom/carbonapi/expr/helper"
+	"github.com/bookingcom/carbonapi/expr/metadata"
+	"github.com/bookingcom/carbonapi/expr/types"
+	"github.com/bookingcom/carbonapi/pkg/parser"
+	th "github.com/bookingcom/carbonapi/tests"
+)
+
+func init() {
+	md := New("")
+	evaluator := th.EvaluatorFromFunc(md[0].F)
+	metadata.SetEvaluator(evaluator)
+	helper.SetEvaluator(evaluator)
+	for _, m := range md {
+		metadata.RegisterFunction(m.Name, m.F)
+	}
+}
+
+func TestUseSeriesAbove(t *testing.T) {
+	now32 := int32(time.Now().Unix())
+
+	tests := []th.EvalTestItem{
+		{
+			"useSeriesAbove(host*.reqs,10,'reqs','time')",
+			map[parser.MetricRequest][]*types.MetricData{
+				{"host*.reqs", 0, 1}: {
+					types.MakeMetricData("host1.reqs", []float64{5, 11, 7}, 1, now32),
+					types.MakeMetricData("host2.reqs", []float64{5, 10, 7}, 1, now32),
+				},
+				{"host1.time", 0, 1}: {
+					types.MakeMetricData("host1.time", []float64{100, 200, 300}, 1, now32),
+				},
+				{"host2.time", 0, 1}: {
+					types.MakeMetricData("host2.time", []float64{400, 500, 600}, 1, now32),
+				},
+			},
+			[]*types.MetricData{
+				types.MakeMetricData("host1.time", []float64{100, 200, 300}, 1, now32),
+			},
+		},
+		{
+			"useSeriesAbove(host*.reqs,1,'host(\\d)\\.reqs','host\\1.time')",
+			map[parser.MetricRequest][]*types.MetricData{
+				{"host*.reqs", 0, 1}: {
+					types.MakeMetricData("host1.reqs", []float64{5, 11, 7}, 1, now32),
+					types.MakeMetricData("host2.reqs", []float64{5, 10, 7}, 1, now32),
+				},
+				{"host1.time", 0, 1}: {
+					types.MakeMetricData("host1.time", []float64{100, 200, 300}, 1, now32),
+				},
+				{"host2.time", 0, 1}: {
+					types.MakeMetricData("host2.time", []float64{400, 500, 600}, 1, now32),
+				},
+			},
+			[]*types.MetricData{
+				types.MakeMetricData("host1.time", []float64{100, 200, 300}, 1, now32),
+				types.MakeMetricData("host2.time", []float64{400, 500, 600}, 1, now32),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		testName := tt.Target
+		t.Run(testName, func(t *testing.T) {
+			th.TestEvalExpr(t, &tt)
+		})
+	}
+}
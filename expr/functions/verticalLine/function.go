@@ -0,0 +1,99 @@
+package verticalLine
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bookingcom/carbonapi/date"
+	"github.com/bookingcom/carbonapi/expr/evalctx"
+	"github.com/bookingcom/carbonapi/expr/interfaces"
+	"github.com/bookingcom/carbonapi/expr/types"
+	"github.com/bookingcom/carbonapi/pkg/parser"
+	dataTypes "github.com/bookingcom/carbonapi/pkg/types"
+)
+
+type verticalLine struct {
+	interfaces.FunctionBase
+}
+
+func GetOrder() interfaces.Order {
+	return interfaces.Any
+}
+
+func New(configFile string) []interfaces.FunctionMetadata {
+	res := make([]interfaces.FunctionMetadata, 0)
+	f := &verticalLine{}
+	functions := []string{"verticalLine"}
+	for _, n := range functions {
+		res = append(res, interfaces.FunctionMetadata{Name: n, F: f})
+	}
+	return res
+}
+
+// verticalLine(ts, label=None, color=None)
+func (f *verticalLine) Do(ctx context.Context, e parser.Expr, from, until int32, values map[parser.MetricRequest][]*types.MetricData, getTargetData interfaces.GetTargetData) ([]*types.MetricData, error) {
+	tsStr, err := e.GetStringArg(0)
+	if err != nil {
+		return nil, err
+	}
+
+	ts := date.DateParamToEpoch(tsStr, "", 0, evalctx.FromContext(ctx).Location)
+	if ts < from {
+		return nil, fmt.Errorf("%w: verticalLine(): timestamp %d exists before start of range", parser.ErrInvalidArgumentValue, ts)
+	}
+	if ts > until {
+		return nil, fmt.Errorf("%w: verticalLine(): timestamp %d exists after end of range", parser.ErrInvalidArgumentValue, ts)
+	}
+
+	label, err := e.GetStringNamedOrPosArgDefault("label", 1, tsStr)
+	if err != nil {
+		return nil, err
+	}
+
+	color, err := e.GetStringNamedOrPosArgDefault("color", 2, "")
+	if err != nil {
+		return nil, err
+	}
+
+	p := types.MetricData{
+		Metric: dataTypes.Metric{
+			Name:      label,
+			StartTime: ts,
+			StopTime:  ts,
+			StepTime:  1,
+			Values:    []float64{1, 1},
+			IsAbsent:  []bool{false, false},
+		},
+	}
+	setGraphOptions(&p, color)
+
+	return []*types.MetricData{&p}, nil
+}
+
+// Description is auto-generated description, based on output of https://github.com/graphite-project/graphite-web
+func (f *verticalLine) Description() map[string]types.FunctionDescription {
+	return map[string]types.FunctionDescription{
+		"verticalLine": {
+			Description: "Takes a timestamp string ts.\n\nDraws a vertical line at the designated timestamp with optional\n'label' and 'color'. Supported timestamp formats include both\nrelative (e.g. -3h) and absolute (e.g. 16:00_20110501) strings,\nsuch as those used with ``from`` and ``until`` parameters. When\nset, the 'label' will appear in the graph legend.\n\nNote: Any timestamps defined outside the requested range will\nraise a 'ValueError' exception.\n\nExample:\n\n.. code-block:: none\n\n  &target=verticalLine(\"12:3420131108\",\"event\",\"blue\")\n  &target=verticalLine(\"16:00_20110501\",\"event\")\n  &target=verticalLine(\"-5mins\")",
+			Function:    "verticalLine(ts, label=None, color=None)",
+			Group:       "Graph",
+			Module:      "graphite.render.functions",
+			Name:        "verticalLine",
+			Params: []types.FunctionParam{
+				{
+					Name:     "ts",
+					Required: true,
+					Type:     types.Date,
+				},
+				{
+					Name: "label",
+					Type: types.String,
+				},
+				{
+					Name: "color",
+					Type: types.String,
+				},
+			},
+		},
+	}
+}
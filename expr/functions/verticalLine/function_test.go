@@ -0,0 +1,88 @@
+package verticalLine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bookingcom/carbonapi/expr/helper"
+	"github.com/bookingcom/carbonapi/expr/metadata"
+	"github.com/bookingcom/carbonapi/expr/types"
+	"github.com/bookingcom/carbonapi/pkg/parser"
+	th "github.com/bookingcom/carbonapi/tests"
+)
+
+func init() {
+	md := New("")
+	evaluator := th.EvaluatorFromFunc(md[0].F)
+	metadata.SetEvaluator(evaluator)
+	helper.SetEvaluator(evaluator)
+	for _, m := range md {
+		metadata.RegisterFunction(m.Name, m.F)
+	}
+}
+
+func TestVerticalLine(t *testing.T) {
+	tests := []struct {
+		target  string
+		from    int32
+		until   int32
+		want    *types.MetricData
+		wantErr bool
+	}{
+		{
+			target: "verticalLine('1500000100')",
+			from:   1500000000,
+			until:  1500000200,
+			want:   types.MakeMetricData("1500000100", []float64{1, 1}, 1, 1500000100),
+		},
+		{
+			target: "verticalLine('1500000100','event','blue')",
+			from:   1500000000,
+			until:  1500000200,
+			want:   types.MakeMetricData("event", []float64{1, 1}, 1, 1500000100),
+		},
+		{
+			target:  "verticalLine('1400000000')",
+			from:    1500000000,
+			until:   1500000200,
+			wantErr: true,
+		},
+		{
+			target:  "verticalLine('1600000000')",
+			from:    1500000000,
+			until:   1500000200,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			exp, _, err := parser.ParseExpr(tt.target)
+			if err != nil {
+				t.Fatalf("failed to parse %s: %v", tt.target, err)
+			}
+			g, err := metadata.GetEvaluator().EvalExpr(context.Background(), exp, tt.from, tt.until, nil, th.NoopGetTargetData)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error for %s, got %v", tt.target, g)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to eval %s: %v", tt.target, err)
+			}
+			if len(g) != 1 {
+				t.Fatalf("%s returned %d metrics, want 1", tt.target, len(g))
+			}
+			if g[0].Name != tt.want.Name {
+				t.Errorf("bad name: got %s, want %s", g[0].Name, tt.want.Name)
+			}
+			if g[0].StartTime != tt.want.StartTime || g[0].StopTime != tt.want.StartTime {
+				t.Errorf("bad range: got %d-%d, want %d-%d", g[0].StartTime, g[0].StopTime, tt.want.StartTime, tt.want.StartTime)
+			}
+			if !th.NearlyEqualMetrics(g[0], tt.want) {
+				t.Errorf("different values: got %v, want %v", g[0].Values, tt.want.Values)
+			}
+		})
+	}
+}
@@ -0,0 +1,10 @@
+// +build cairo
+
+package verticalLine
+
+import "github.com/bookingcom/carbonapi/expr/types"
+
+func setGraphOptions(p *types.MetricData, color string) {
+	p.DrawAsInfinite = true
+	p.Color = color
+}
@@ -0,0 +1,7 @@
+// +build !cairo
+
+package verticalLine
+
+import "github.com/bookingcom/carbonapi/expr/types"
+
+func setGraphOptions(p *types.MetricData, color string) {}
@@ -0,0 +1,168 @@
+package weightedAverage
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/bookingcom/carbonapi/expr/helper"
+	"github.com/bookingcom/carbonapi/expr/interfaces"
+	"github.com/bookingcom/carbonapi/expr/types"
+	"github.com/bookingcom/carbonapi/pkg/parser"
+)
+
+type weightedAverage struct {
+	interfaces.FunctionBase
+}
+
+func GetOrder() interfaces.Order {
+	return interfaces.Any
+}
+
+func New(configFile string) []interfaces.FunctionMetadata {
+	res := make([]interfaces.FunctionMetadata, 0)
+	f := &weightedAverage{}
+	functions := []string{"weightedAverage"}
+	for _, n := range functions {
+		res = append(res, interfaces.FunctionMetadata{Name: n, F: f})
+	}
+	return res
+}
+
+// weightedAverage(seriesListAvg, seriesListWeight, *nodes)
+func (f *weightedAverage) Do(ctx context.Context, e parser.Expr, from, until int32, values map[parser.MetricRequest][]*types.MetricData, getTargetData interfaces.GetTargetData) ([]*types.MetricData, error) {
+	if len(e.Args()) < 2 {
+		return nil, parser.ErrMissingArgument
+	}
+
+	avgs, err := helper.GetSeriesArg(ctx, e.Args()[0], from, until, values, getTargetData)
+	if err != nil {
+		return nil, err
+	}
+
+	weights, err := helper.GetSeriesArg(ctx, e.Args()[1], from, until, values, getTargetData)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields []int
+	if len(e.Args()) > 2 {
+		fields, err = e.GetIntArgs(2)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	nodeStrs := make([]string, 0, len(fields))
+	for _, field := range fields {
+		nodeStrs = append(nodeStrs, strconv.Itoa(field))
+	}
+	name := fmt.Sprintf("weightedAverage(%s, %s, %s)", e.Args()[0].ToString(), e.Args()[1].ToString(), strings.Join(nodeStrs, ","))
+
+	avgByKey := make(map[string]*types.MetricData, len(avgs))
+	for _, a := range avgs {
+		key, err := aggKey(e, a, fields)
+		if err != nil {
+			return nil, err
+		}
+		avgByKey[key] = a
+	}
+
+	weightByKey := make(map[string]*types.MetricData, len(weights))
+	var keys []string
+	for _, w := range weights {
+		key, err := aggKey(e, w, fields)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := weightByKey[key]; !ok {
+			keys = append(keys, key)
+		}
+		weightByKey[key] = w
+	}
+
+	var products []*types.MetricData
+	for _, key := range keys {
+		a, ok := avgByKey[key]
+		if !ok {
+			continue
+		}
+		product, err := helper.AggregateSeries(ctx, key, []*types.MetricData{a, weightByKey[key]}, false, true, func(values []float64) (float64, bool) {
+			return values[0] * values[1], false
+		})
+		if err != nil {
+			return nil, err
+		}
+		products = append(products, product...)
+	}
+	if len(products) == 0 {
+		return []*types.MetricData{}, nil
+	}
+
+	sumProducts, err := helper.AggregateSeries(ctx, name, products, false, false, sum)
+	if err != nil {
+		return nil, err
+	}
+	sumWeights, err := helper.AggregateSeries(ctx, name, weights, false, false, sum)
+	if err != nil {
+		return nil, err
+	}
+
+	return helper.AggregateSeries(ctx, name, []*types.MetricData{sumProducts[0], sumWeights[0]}, false, true, func(values []float64) (float64, bool) {
+		if values[1] == 0 {
+			return 0, true
+		}
+		return values[0] / values[1], false
+	})
+}
+
+func aggKey(e parser.Expr, a *types.MetricData, fields []int) (string, error) {
+	nodes := strings.Split(helper.ExtractMetric(a.Name), ".")
+	nodeKey := make([]string, 0, len(fields))
+	for _, f := range fields {
+		if f < 0 || f >= len(nodes) {
+			return "", fmt.Errorf("%s: %w: %d", e.Target(), parser.ErrInvalidArgumentValue, f)
+		}
+		nodeKey = append(nodeKey, nodes[f])
+	}
+	return strings.Join(nodeKey, "."), nil
+}
+
+func sum(values []float64) (float64, bool) {
+	var s float64
+	for _, v := range values {
+		s += v
+	}
+	return s, false
+}
+
+// Description is auto-generated description, based on output of https://github.com/graphite-project/graphite-web
+func (f *weightedAverage) Description() map[string]types.FunctionDescription {
+	return map[string]types.FunctionDescription{
+		"weightedAverage": {
+			Description: "Takes a series of average values and a series of weights and\nproduces a weighted average for all values.\nThe corresponding values should share one or more zero-indexed nodes and/or tags.\n\nExample:\n\n.. code-block:: none\n\n  &target=weightedAverage(*.transactions.mean,*.transactions.count,0)\n\nEach node may be an integer referencing a node in the series name or a string identifying a tag.",
+			Function:    "weightedAverage(seriesListAvg, seriesListWeight, *nodes)",
+			Group:       "Combine",
+			Module:      "graphite.render.functions",
+			Name:        "weightedAverage",
+			Params: []types.FunctionParam{
+				{
+					Name:     "seriesListAvg",
+					Required: true,
+					Type:     types.SeriesList,
+				},
+				{
+					Name:     "seriesListWeight",
+					Required: true,
+					Type:     types.SeriesList,
+				},
+				{
+					Multiple: true,
+					Name:     "nodes",
+					Type:     types.NodeOrTag,
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,64 @@
+package weightedAverage
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/bookingcom/carbonapi/expr/helper"
+	"github.com/bookingcom/carbonapi/expr/metadata"
+	"github.com/bookingcom/carbonapi/expr/types"
+	"github.com/bookingcom/carbonapi/pkg/parser"
+	th "github.com/bookingcom/carbonapi/tests"
+)
+
+func init() {
+	md := New("")
+	evaluator := th.EvaluatorFromFunc(md[0].F)
+	metadata.SetEvaluator(evaluator)
+	helper.SetEvaluator(evaluator)
+	for _, m := range md {
+		metadata.RegisterFunction(m.Name, m.F)
+	}
+}
+
+func TestWeightedAverage(t *testing.T) {
+	now32 := int32(time.Now().Unix())
+
+	tests := []th.EvalTestItem{
+		{
+			"weightedAverage(*.mean,*.count,0)",
+			map[parser.MetricRequest][]*types.MetricData{
+				{"*.mean", 0, 1}: {
+					types.MakeMetricData("a.mean", []float64{10, 20, math.NaN(), 5}, 1, now32),
+					types.MakeMetricData("b.mean", []float64{40, 20, 30, 5}, 1, now32),
+				},
+				{"*.count", 0, 1}: {
+					types.MakeMetricData("a.count", []float64{3, 1, 2, 0}, 1, now32),
+					types.MakeMetricData("b.count", []float64{1, 1, 2, 0}, 1, now32),
+				},
+			},
+			[]*types.MetricData{types.MakeMetricData("weightedAverage(*.mean, *.count, 0)",
+				[]float64{17.5, 20, 15, math.NaN()}, 1, now32)},
+		},
+		{
+			"weightedAverage(*.mean,*.count,0)",
+			map[parser.MetricRequest][]*types.MetricData{
+				{"*.mean", 0, 1}: {
+					types.MakeMetricData("a.mean", []float64{10, 20}, 1, now32),
+				},
+				{"*.count", 0, 1}: {
+					types.MakeMetricData("b.count", []float64{3, 1}, 1, now32),
+				},
+			},
+			[]*types.MetricData{},
+		},
+	}
+
+	for _, tt := range tests {
+		testName := tt.Target
+		t.Run(testName, func(t *testing.T) {
+			th.TestEvalExpr(t, &tt)
+		})
+	}
+}
@@ -3,6 +3,8 @@ package helper
 import (
 	"math"
 	"time"
+
+	"github.com/bookingcom/carbonapi/expr/calendar"
 )
 
 // GetBuckets returns amount buckets for timeSeries (defined with startTime, stopTime and step (bucket) size.
@@ -10,11 +12,12 @@ func GetBuckets(start, stop, bucketSize int32) int32 {
 	return int32(math.Ceil(float64(stop-start) / float64(bucketSize)))
 }
 
-// AlignStartToInterval aligns start of serie to interval
-func AlignStartToInterval(start, stop, bucketSize int32) int32 {
+// AlignStartToInterval aligns start of serie to the start of the day, hour or minute
+// in the time zone loc, depending on interval. A nil loc is UTC.
+func AlignStartToInterval(start, stop, bucketSize int32, loc *time.Location) int32 {
 	for _, v := range []int32{86400, 3600, 60} {
 		if bucketSize >= v {
-			start -= start % v
+			start = calendar.Truncate(start, v, loc)
 			break
 		}
 	}
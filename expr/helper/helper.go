@@ -2,6 +2,7 @@ package helper
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math"
 	"regexp"
@@ -11,6 +12,7 @@ import (
 	"unicode/utf8"
 
 	"github.com/bookingcom/carbonapi/expr/interfaces"
+	"github.com/bookingcom/carbonapi/expr/limits"
 	"github.com/bookingcom/carbonapi/expr/types"
 	"github.com/bookingcom/carbonapi/pkg/parser"
 
@@ -41,6 +43,15 @@ func GetSeriesArg(ctx context.Context, arg parser.Expr, from, until int32, value
 		return nil, err
 	}
 
+	if arg.IsName() {
+		if err := limits.Account(ctx, a); err != nil {
+			return nil, err
+		}
+	}
+	if err := limits.CheckSeries(ctx, a); err != nil {
+		return nil, err
+	}
+
 	return a, nil
 }
 
@@ -95,25 +106,41 @@ type seriesFunc func(*types.MetricData, *types.MetricData) *types.MetricData
 func ForEachSeriesDo(ctx context.Context, e parser.Expr, from, until int32, values map[parser.MetricRequest][]*types.MetricData, function seriesFunc, getTargetData interfaces.GetTargetData) ([]*types.MetricData, error) {
 	arg, err := GetSeriesArg(ctx, e.Args()[0], from, until, values, getTargetData)
 	if err != nil {
+		var limitErr limits.ErrLimitExceeded
+		if errors.As(err, &limitErr) {
+			return nil, err
+		}
 		return nil, parser.ErrMissingTimeseries
 	}
 	var results []*types.MetricData
 
 	for _, a := range arg {
+		if err := limits.CheckTime(ctx); err != nil {
+			return nil, err
+		}
 		r := *a
 		r.Name = fmt.Sprintf("%s(%s)", e.Target(), a.Name)
 		r.Values = make([]float64, len(a.Values))
 		r.IsAbsent = make([]bool, len(a.Values))
 		results = append(results, function(a, &r))
+		if err := limits.AccountOutput(ctx, results[len(results)-1:]); err != nil {
+			return nil, err
+		}
 	}
 	return results, nil
 }
 
+// checkTimeEvery is how often, in datapoints, AggregateSeries checks the time budget
+const checkTimeEvery = 1024
+
 // AggregateFunc type that defined aggregate function
 type AggregateFunc func([]float64) (float64, bool)
 
 // AggregateSeries aggregates series
-func AggregateSeries(name string, args []*types.MetricData, absent_if_first_series_absent bool, absent_if_any_absent bool, function AggregateFunc) ([]*types.MetricData, error) {
+func AggregateSeries(ctx context.Context, name string, args []*types.MetricData, absent_if_first_series_absent bool, absent_if_any_absent bool, function AggregateFunc) ([]*types.MetricData, error) {
+	if err := limits.CheckSeries(ctx, args); err != nil {
+		return nil, err
+	}
 	seriesList, start, end, step, err := Normalize(args)
 	if err != nil {
 		return nil, err
@@ -125,6 +152,11 @@ func AggregateSeries(name string, args []*types.MetricData, absent_if_first_seri
 	result := make([]float64, length)
 	isAbsent := make([]bool, length)
 	for i := 0; i < length; i++ {
+		if i%checkTimeEvery == 0 {
+			if err := limits.CheckTime(ctx); err != nil {
+				return nil, err
+			}
+		}
 		var values []float64
 		absent := false
 		for _, s := range seriesList {
@@ -142,8 +174,11 @@ func AggregateSeries(name string, args []*types.MetricData, absent_if_first_seri
 			result[i], isAbsent[i] = function(values)
 		}
 	}
-	ret := types.New(name, result, isAbsent, step, start)
-	return []*types.MetricData{ret}, nil
+	ret := []*types.MetricData{types.New(name, result, isAbsent, step, start)}
+	if err := limits.AccountOutput(ctx, ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
 }
 
 // SummarizeValues summarizes values
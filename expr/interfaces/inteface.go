@@ -43,6 +43,7 @@ type FunctionMetadata struct {
 }
 
 // Function is interface that all graphite functions should follow
+// Do gets properties of the request from the evaluation context in ctx, see package evalctx.
 type Function interface {
 	SetEvaluator(evaluator Evaluator)
 	GetEvaluator() Evaluator
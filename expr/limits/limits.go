@@ -0,0 +1,245 @@
+// Package limits implements a resource budget for evaluation of a single target.
+// The budget is carried in context, helpers check it while evaluating arguments
+// and iterating over series.
+package limits
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bookingcom/carbonapi/expr/types"
+)
+
+// Limits bound resources evaluation of a target may use. Zero values mean no limit.
+type Limits struct {
+	// MaxSeries is the maximum number of series a single argument or function may produce.
+	MaxSeries int
+	// MaxDatapoints is the maximum number of datapoints materialised during evaluation.
+	MaxDatapoints int64
+	// MaxFunctionTime is the maximum wall-clock time a single function call may
+	// spend, not counting evaluation of its arguments. It isn't CPU time, as Go
+	// has no CPU time per goroutine: time waiting for the CPU under load counts.
+	MaxFunctionTime time.Duration
+}
+
+// ErrLimitExceeded is returned when evaluation goes over its budget.
+type ErrLimitExceeded struct {
+	Function string
+	Limit    string
+	Value    string
+	Max      string
+}
+
+func (e ErrLimitExceeded) Error() string {
+	return fmt.Sprintf("%s: %s limit exceeded (%s > %s)", e.Function, e.Limit, e.Value, e.Max)
+}
+
+// Budget tracks resources used by evaluation of a target.
+type Budget struct {
+	limits     Limits
+	datapoints int64
+
+	mu  sync.Mutex
+	err error
+}
+
+// NewBudget creates a budget with the given limits.
+func NewBudget(l Limits) *Budget {
+	return &Budget{limits: l}
+}
+
+// Datapoints returns number of datapoints accounted so far.
+func (b *Budget) Datapoints() int64 {
+	return atomic.LoadInt64(&b.datapoints)
+}
+
+// Err returns the first violation of the budget.
+func (b *Budget) Err() error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.err
+}
+
+func (b *Budget) fail(err ErrLimitExceeded) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.err == nil {
+		b.err = err
+	}
+	return err
+}
+
+// Frame is the cost of a single function call.
+type Frame struct {
+	Function string
+
+	parent     *Frame
+	start      time.Time
+	children   int64
+	datapoints int64
+}
+
+// Datapoints returns number of datapoints the call has produced.
+func (f *Frame) Datapoints() int64 {
+	return atomic.LoadInt64(&f.datapoints)
+}
+
+// OwnTime returns wall-clock time spent in the call, not counting nested function calls.
+func (f *Frame) OwnTime() time.Duration {
+	return time.Since(f.start) - time.Duration(atomic.LoadInt64(&f.children))
+}
+
+// Exit finishes the call and excludes its time from the time of the caller.
+func (f *Frame) Exit() {
+	if f.parent != nil {
+		atomic.AddInt64(&f.parent.children, int64(time.Since(f.start)))
+	}
+}
+
+type budgetKey struct{}
+type frameKey struct{}
+
+// NewContext returns a context carrying the budget.
+func NewContext(ctx context.Context, b *Budget) context.Context {
+	return context.WithValue(ctx, budgetKey{}, b)
+}
+
+// FromContext returns the budget of the context or nil if there is none.
+func FromContext(ctx context.Context) *Budget {
+	b, _ := ctx.Value(budgetKey{}).(*Budget)
+	return b
+}
+
+// Enter starts accounting of a function call.
+func Enter(ctx context.Context, function string) (context.Context, *Frame) {
+	parent, _ := ctx.Value(frameKey{}).(*Frame)
+	f := &Frame{
+		Function: function,
+		parent:   parent,
+		start:    time.Now(),
+	}
+	return context.WithValue(ctx, frameKey{}, f), f
+}
+
+// Err returns the first violation of the budget of the context.
+func Err(ctx context.Context) error {
+	return FromContext(ctx).Err()
+}
+
+// CheckSeries checks number of series produced for the current function
+// and the time the function has spent so far.
+func CheckSeries(ctx context.Context, series []*types.MetricData) error {
+	b := FromContext(ctx)
+	if b == nil {
+		return nil
+	}
+	f, _ := ctx.Value(frameKey{}).(*Frame)
+
+	if b.limits.MaxSeries > 0 && len(series) > b.limits.MaxSeries {
+		return b.fail(ErrLimitExceeded{
+			Function: functionName(f),
+			Limit:    "series",
+			Value:    fmt.Sprint(len(series)),
+			Max:      fmt.Sprint(b.limits.MaxSeries),
+		})
+	}
+
+	return checkTime(b, f)
+}
+
+// CheckTime checks the time the current function has spent so far.
+func CheckTime(ctx context.Context) error {
+	b := FromContext(ctx)
+	if b == nil {
+		return nil
+	}
+	f, _ := ctx.Value(frameKey{}).(*Frame)
+	return checkTime(b, f)
+}
+
+func checkTime(b *Budget, f *Frame) error {
+	if b.limits.MaxFunctionTime <= 0 || f == nil {
+		return nil
+	}
+	if t := f.OwnTime(); t > b.limits.MaxFunctionTime {
+		return b.fail(ErrLimitExceeded{
+			Function: f.Function,
+			Limit:    "function time",
+			Value:    t.Round(time.Millisecond).String(),
+			Max:      b.limits.MaxFunctionTime.String(),
+		})
+	}
+	return nil
+}
+
+// Account adds datapoints of series fetched for evaluation to the budget.
+func Account(ctx context.Context, series []*types.MetricData) error {
+	b := FromContext(ctx)
+	if b == nil {
+		return nil
+	}
+	f, _ := ctx.Value(frameKey{}).(*Frame)
+	return account(b, f, countDatapoints(series), false)
+}
+
+// AccountOutput adds datapoints of series produced by the current function to the budget.
+func AccountOutput(ctx context.Context, series []*types.MetricData) error {
+	b := FromContext(ctx)
+	if b == nil {
+		return nil
+	}
+	f, _ := ctx.Value(frameKey{}).(*Frame)
+	return account(b, f, countDatapoints(series), true)
+}
+
+// AccountRemaining accounts datapoints of the output of a finished function call,
+// which helpers haven't accounted while the function was running.
+func AccountRemaining(ctx context.Context, f *Frame, output []*types.MetricData) error {
+	b := FromContext(ctx)
+	if b == nil {
+		return nil
+	}
+
+	n := countDatapoints(output) - f.Datapoints()
+	if n <= 0 {
+		return nil
+	}
+	return account(b, f, n, true)
+}
+
+func account(b *Budget, f *Frame, n int64, output bool) error {
+	if f != nil && output {
+		atomic.AddInt64(&f.datapoints, n)
+	}
+	total := atomic.AddInt64(&b.datapoints, n)
+	if b.limits.MaxDatapoints > 0 && total > b.limits.MaxDatapoints {
+		return b.fail(ErrLimitExceeded{
+			Function: functionName(f),
+			Limit:    "datapoints",
+			Value:    fmt.Sprint(total),
+			Max:      fmt.Sprint(b.limits.MaxDatapoints),
+		})
+	}
+	return nil
+}
+
+func countDatapoints(series []*types.MetricData) int64 {
+	n := int64(0)
+	for _, s := range series {
+		n += int64(len(s.Values))
+	}
+	return n
+}
+
+func functionName(f *Frame) string {
+	if f == nil {
+		return "fetch"
+	}
+	return f.Function
+}
@@ -0,0 +1,52 @@
+package limits
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bookingcom/carbonapi/expr/types"
+)
+
+func TestOwnTimeExcludesNestedCalls(t *testing.T) {
+	ctx := NewContext(context.Background(), NewBudget(Limits{MaxFunctionTime: 20 * time.Millisecond}))
+
+	ctx, outer := Enter(ctx, "outer")
+	inner, frame := Enter(ctx, "inner")
+	time.Sleep(30 * time.Millisecond)
+	if err := CheckTime(inner); err == nil {
+		t.Errorf("expected inner call to exceed its time")
+	}
+	frame.Exit()
+
+	if outer.OwnTime() >= 20*time.Millisecond {
+		t.Errorf("got outer time %s, nested call is not excluded", outer.OwnTime())
+	}
+	if err := Err(ctx); err == nil || err.(ErrLimitExceeded).Function != "inner" {
+		t.Errorf("got budget error %v, want violation by inner", err)
+	}
+}
+
+func TestAccountRemaining(t *testing.T) {
+	b := NewBudget(Limits{})
+	ctx := NewContext(context.Background(), b)
+	series := []*types.MetricData{types.MakeMetricData("foo", []float64{1, 2, 3}, 60, 0)}
+
+	if err := Account(ctx, series); err != nil {
+		t.Fatal(err)
+	}
+	ctx, f := Enter(ctx, "f")
+	if err := AccountOutput(ctx, series[:1]); err != nil {
+		t.Fatal(err)
+	}
+	if err := AccountRemaining(ctx, f, append(series, series...)); err != nil {
+		t.Fatal(err)
+	}
+
+	if f.Datapoints() != 6 {
+		t.Errorf("got %d datapoints produced by f, want 6", f.Datapoints())
+	}
+	if b.Datapoints() != 9 {
+		t.Errorf("got %d datapoints in budget, want 9", b.Datapoints())
+	}
+}
@@ -9,6 +9,10 @@ import (
 	"go.uber.org/zap"
 )
 
+// GraphiteWebVersion is the version of graphite-web which descriptions of
+// functions follow, both in their schema and in the set of functions.
+const GraphiteWebVersion = "1.1.0"
+
 // RegisterFunction registers function in metadata and fills out all Description structs
 func RegisterFunction(name string, function interfaces.Function) {
 	FunctionMD.Lock()
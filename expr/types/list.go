@@ -10,8 +10,10 @@ import (
 type FunctionType int
 
 const (
+	// Any is a constant for parameters of any type, it's used when type is not specified
+	Any FunctionType = iota
 	// AggFunc is a constant for AggregationFunction type
-	AggFunc FunctionType = iota
+	AggFunc
 	// Boolean is a constant for Boolean type
 	Boolean
 	// Date is a constant for Date type
@@ -39,6 +41,7 @@ const (
 )
 
 var strToFunctionType = map[string]FunctionType{
+	"any":           Any,
 	"aggFunc":       AggFunc,
 	"boolean":       Boolean,
 	"date":          Date,
@@ -55,6 +58,7 @@ var strToFunctionType = map[string]FunctionType{
 }
 
 var functionTypeToStr = map[FunctionType]string{
+	Any:           "any",
 	AggFunc:       "aggFunc",
 	Boolean:       "boolean",
 	Date:          "date",
@@ -170,7 +174,7 @@ func (t Suggestion) MarshalJSON() ([]byte, error) {
 	case SBool:
 		return json.Marshal(t.Value.(bool))
 	case SNone:
-		return []byte{}, nil
+		return []byte("null"), nil
 	}
 
 	return nil, fmt.Errorf("unknown type %v", t.Type)
@@ -187,6 +191,8 @@ func (t *Suggestion) UnmarshalJSON(d []byte) error {
 		return err
 	}
 	switch v := res.(type) {
+	case nil:
+		t.Type = SNone
 	case int:
 		t.Type = SInt
 		t.Value = v
@@ -212,25 +218,28 @@ func (t *Suggestion) UnmarshalJSON(d []byte) error {
 	return nil
 }
 
-// FunctionParam contains list of all available parameters of function
+// FunctionParam contains list of all available parameters of function.
+// It's marshaled to JSON as graphite-web 1.1 does, only type is always present.
 type FunctionParam struct {
 	Name        string        `json:"name"`
 	Multiple    bool          `json:"multiple,omitempty"`
 	Required    bool          `json:"required,omitempty"`
-	Type        FunctionType  `json:"type,omitempty"`
+	Type        FunctionType  `json:"type"`
 	Options     []string      `json:"options,omitempty"`
 	Suggestions []*Suggestion `json:"suggestions,omitempty"`
 	Default     *Suggestion   `json:"default,omitempty"`
 }
 
-// FunctionDescription contains full function description.
+// FunctionDescription contains full function description, as served by
+// graphite-web 1.1 on /functions. Params are null for undescribed parameters.
 type FunctionDescription struct {
 	Description string          `json:"description"`
 	Function    string          `json:"function"`
 	Group       string          `json:"group"`
 	Module      string          `json:"module"`
 	Name        string          `json:"name"`
-	Params      []FunctionParam `json:"params,omitempty"`
+	Params      []FunctionParam `json:"params"`
 
-	Proxied bool `json:"proxied"`
+	// Proxied is set for functions evaluated by graphite-web, it's not a part of the schema
+	Proxied bool `json:"-"`
 }
@@ -0,0 +1,84 @@
+package types
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFunctionDescriptionMarshalJSON(t *testing.T) {
+	d := FunctionDescription{
+		Description: "Example",
+		Function:    "example(seriesList, func='sum', n=5, *args)",
+		Group:       "Transform",
+		Module:      "graphite.render.functions",
+		Name:        "example",
+		Params: []FunctionParam{
+			{Name: "seriesList", Required: true, Type: SeriesList},
+			{Name: "func", Type: AggFunc, Default: NewSuggestion("sum"), Options: []string{"sum", "max"}},
+			{Name: "n", Type: Integer, Default: NewSuggestion(5), Suggestions: NewSuggestions(5, 10)},
+			{Name: "args", Multiple: true},
+		},
+		Proxied: true,
+	}
+
+	// as graphite-web 1.1 serves it on /functions
+	want := `{
+		"description": "Example",
+		"function": "example(seriesList, func='sum', n=5, *args)",
+		"group": "Transform",
+		"module": "graphite.render.functions",
+		"name": "example",
+		"params": [
+			{"name": "seriesList", "type": "seriesList", "required": true},
+			{"name": "func", "type": "aggFunc", "default": "sum", "options": ["sum", "max"]},
+			{"name": "n", "type": "integer", "default": 5, "suggestions": [5, 10]},
+			{"name": "args", "type": "any", "multiple": true}
+		]
+	}`
+
+	b, err := json.Marshal(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got, expected interface{}
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(want), &expected); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(expected, got); diff != "" {
+		t.Errorf("Unexpected JSON (-want +got):\n%s", diff)
+	}
+
+	var parsed FunctionDescription
+	if err := json.Unmarshal(b, &parsed); err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Params[1].Default.Value != "sum" || parsed.Params[2].Suggestions[1].Value != float64(10) || parsed.Params[3].Type != Any {
+		t.Errorf("Unexpected params after a round trip: %+v", parsed.Params)
+	}
+}
+
+func TestFunctionDescriptionWithoutParams(t *testing.T) {
+	b, err := json.Marshal(FunctionDescription{Name: "example", Group: "Ungrouped"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"description":"","function":"","group":"Ungrouped","module":"","name":"example","params":null}`
+	if string(b) != want {
+		t.Errorf("Expected %s, got %s", want, b)
+	}
+}
+
+func TestSuggestionNone(t *testing.T) {
+	b, err := json.Marshal([]*Suggestion{NewSuggestion(nil)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "[null]" {
+		t.Errorf("Expected [null], got %s", b)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"math"
 	"math/rand"
+	"strconv"
 	"testing"
 )
 
@@ -30,6 +31,57 @@ func TestJSONResponse(t *testing.T) {
 	}
 }
 
+// chunkRecorder records the chunks written to it.
+type chunkRecorder struct {
+	chunks [][]byte
+}
+
+func (r *chunkRecorder) Write(p []byte) (int, error) {
+	r.chunks = append(r.chunks, append([]byte(nil), p...))
+	return len(p), nil
+}
+
+func TestWriteJSONInChunks(t *testing.T) {
+	data := getData(20000)
+	data[10] = math.NaN()
+	results := []*MetricData{
+		MakeMetricData("metric1", data, 100, 100),
+		MakeMetricData("metric2", []float64{1}, 100, 100),
+	}
+
+	expected := []byte(`[{"target":"metric1","datapoints":[`)
+	for i, v := range data {
+		if i > 0 {
+			expected = append(expected, ',')
+		}
+		expected = append(expected, '[')
+		if results[0].IsAbsent[i] {
+			expected = append(expected, "null"...)
+		} else {
+			expected = strconv.AppendFloat(expected, v, 'f', -1, 64)
+		}
+		expected = append(expected, ',')
+		expected = strconv.AppendInt(expected, int64(100+100*i), 10)
+		expected = append(expected, ']')
+	}
+	expected = append(expected, `]},{"target":"metric2","datapoints":[[1,100]]}]`...)
+
+	var rec chunkRecorder
+	if err := WriteJSON(&rec, results); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rec.chunks) < 2 {
+		t.Errorf("Expected response to be written in several chunks, got %d", len(rec.chunks))
+	}
+	if b := bytes.Join(rec.chunks, nil); !bytes.Equal(b, expected) {
+		t.Errorf("Expected streamed response to be the same as marshalled, got %d bytes instead of %d", len(b), len(expected))
+	}
+	if b := MarshalJSON(results); !bytes.Equal(b, expected) {
+		t.Errorf("Expected marshalled response of %d bytes, got %d", len(expected), len(b))
+	}
+}
+
 func TestRawResponse(t *testing.T) {
 
 	tests := []struct {
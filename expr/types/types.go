@@ -2,6 +2,7 @@ package types
 
 import (
 	"bytes"
+	"io"
 	"math"
 	"strconv"
 	"time"
@@ -9,7 +10,9 @@ import (
 	"github.com/bookingcom/carbonapi/pkg/parser"
 	"github.com/bookingcom/carbonapi/pkg/types"
 
+	"github.com/bookingcom/carbonapi/pkg/types/encoding/arrow"
 	"github.com/bookingcom/carbonapi/pkg/types/encoding/carbonapi_v2"
+	"github.com/bookingcom/carbonapi/pkg/types/encoding/msgpack"
 
 	pickle "github.com/lomik/og-rek"
 )
@@ -29,6 +32,10 @@ type MetricData struct {
 
 	ValuesPerPoint    int
 	AggregateFunction func([]float64, []bool) (float64, bool)
+	// ConsolidationFunc is the name of AggregateFunction, empty for the default average
+	ConsolidationFunc string
+	// XFilesFactor is the ratio of points a consolidated point needs to be non-null, as in graphite-web
+	XFilesFactor float32
 }
 
 // New creates new MetricData with given metric timeseries values and isAbsent
@@ -68,8 +75,19 @@ func MakeMetricData(name string, values []float64, step, start int32) *MetricDat
 	}}
 }
 
+// streamChunkSize is the size of chunks written by the streaming encoders
+const streamChunkSize = 32 * 1024
+
 // MarshalCSV marshals metric data to CSV
 func MarshalCSV(results []*MetricData, location *time.Location) []byte {
+	var buf bytes.Buffer
+	WriteCSV(&buf, results, location)
+	return buf.Bytes()
+}
+
+// WriteCSV writes metric data to w as CSV, in chunks as it is encoded.
+// The output is the same as of MarshalCSV.
+func WriteCSV(w io.Writer, results []*MetricData, location *time.Location) error {
 
 	var b []byte
 
@@ -95,17 +113,48 @@ func MarshalCSV(results []*MetricData, location *time.Location) []byte {
 			}
 			b = append(b, '\n')
 			t += step
+
+			if len(b) >= streamChunkSize {
+				if _, err := w.Write(b); err != nil {
+					return err
+				}
+				b = b[:0]
+			}
 		}
 	}
-	return b
+
+	if len(b) == 0 {
+		return nil
+	}
+	_, err := w.Write(b)
+	return err
 }
 
-// ConsolidateJSON consolidates values to maxDataPoints size
-func ConsolidateJSON(maxDataPoints int, results []*MetricData) (consolidated []*MetricData) {
+// Consolidate consolidates values of results for them to fit in maxDataPoints
+// points over the time range of all results, as graphite-web does for
+// maxDataPoints. Results are returned as they are if maxDataPoints is 0.
+func Consolidate(maxDataPoints int, results []*MetricData) []*MetricData {
+	if maxDataPoints <= 0 {
+		return results
+	}
+	if maxDataPoints == 1 {
+		// graphite-web has a single point at the start of each series then
+		ret := make([]*MetricData, len(results))
+		for i, r := range results {
+			if r != nil {
+				ret[i] = r.Consolidate(len(r.Values))
+			}
+		}
+		return ret
+	}
+
 	var startTime int32 = -1
 	var endTime int32 = -1
 
 	for _, r := range results {
+		if r == nil {
+			continue
+		}
 		t := r.StartTime
 		if startTime == -1 || startTime > t {
 			startTime = t
@@ -119,15 +168,16 @@ func ConsolidateJSON(maxDataPoints int, results []*MetricData) (consolidated []*
 	timeRange := endTime - startTime
 
 	if timeRange <= 0 {
-		return
+		return results
 	}
 
 	ret := make([]*MetricData, len(results))
 	for i, r := range results {
-		numberOfDataPoints := math.Floor(float64(timeRange) / float64(r.StepTime))
-		if numberOfDataPoints > float64(maxDataPoints) {
-			valuesPerPoint := math.Ceil(numberOfDataPoints / float64(maxDataPoints))
-			ret[i] = r.Consolidate(int(valuesPerPoint))
+		if r == nil {
+			continue
+		}
+		if valuesPerPoint := types.ValuesPerPoint(timeRange, r.StepTime, maxDataPoints); valuesPerPoint > 1 {
+			ret[i] = r.consolidateAligned(valuesPerPoint)
 		} else {
 			ret[i] = r
 		}
@@ -137,6 +187,14 @@ func ConsolidateJSON(maxDataPoints int, results []*MetricData) (consolidated []*
 
 // MarshalJSON marshals metric data to JSON
 func MarshalJSON(results []*MetricData) []byte {
+	var buf bytes.Buffer
+	WriteJSON(&buf, results)
+	return buf.Bytes()
+}
+
+// WriteJSON writes metric data to w as JSON, in chunks as it is encoded.
+// The output is the same as of MarshalJSON.
+func WriteJSON(w io.Writer, results []*MetricData) error {
 	var b []byte
 	b = append(b, '[')
 
@@ -179,6 +237,13 @@ func MarshalJSON(results []*MetricData) []byte {
 			b = append(b, ']')
 
 			t += r.StepTime
+
+			if len(b) >= streamChunkSize {
+				if _, err := w.Write(b); err != nil {
+					return err
+				}
+				b = b[:0]
+			}
 		}
 
 		b = append(b, `]}`...)
@@ -186,7 +251,8 @@ func MarshalJSON(results []*MetricData) []byte {
 
 	b = append(b, ']')
 
-	return b
+	_, err := w.Write(b)
+	return err
 }
 
 // MarshalPickle marshals metric data to pickle format
@@ -231,6 +297,36 @@ func MarshalProtobuf(results []*MetricData) ([]byte, error) {
 	return carbonapi_v2.RenderEncoder(metrics)
 }
 
+// MarshalMsgpack marshals metric data to msgpack in the format of graphite-web
+func MarshalMsgpack(results []*MetricData) ([]byte, error) {
+	metrics := make([]types.Metric, 0, len(results))
+	for _, metric := range results {
+		metrics = append(metrics, metric.Metric)
+	}
+
+	return msgpack.RenderEncoder(metrics)
+}
+
+// MarshalArrow marshals metric data to an Arrow IPC stream
+func MarshalArrow(results []*MetricData) ([]byte, error) {
+	return arrow.RenderEncoder(arrowMetrics(results))
+}
+
+// WriteArrow writes metric data as an Arrow IPC stream to w, a series at a time
+func WriteArrow(w io.Writer, results []*MetricData) error {
+	return arrow.WriteRender(w, arrowMetrics(results))
+}
+
+func arrowMetrics(results []*MetricData) []types.Metric {
+	metrics := make([]types.Metric, 0, len(results))
+	for _, metric := range results {
+		if metric != nil {
+			metrics = append(metrics, metric.Metric)
+		}
+	}
+	return metrics
+}
+
 // MarshalRaw marshals metric data to graphite's internal format, called 'raw'
 func MarshalRaw(results []*MetricData) []byte {
 
@@ -279,40 +375,23 @@ func (r *MetricData) Consolidate(valuesPerPoint int) *MetricData {
 	}
 
 	ret.ValuesPerPoint = valuesPerPoint
-	ret.StepTime = r.StepTime * int32(valuesPerPoint)
 	if ret.AggregateFunction == nil {
 		ret.AggregateFunction = AggMean
 	}
+	ret.Metric = r.Metric.Consolidate(valuesPerPoint, ret.AggregateFunction, r.XFilesFactor)
 
-	n := len(r.Values)/valuesPerPoint + 1
-	aggV := make([]float64, 0, n)
-	aggA := make([]bool, 0, n)
-
-	v := r.Values
-	absent := r.IsAbsent
-
-	for len(v) >= valuesPerPoint {
-		val, abs := ret.AggregateFunction(v[:valuesPerPoint], absent[:valuesPerPoint])
-		if math.IsNaN(val) {
-			val = 0
-		}
-		aggV = append(aggV, val)
-		aggA = append(aggA, abs)
-		v = v[valuesPerPoint:]
-		absent = absent[valuesPerPoint:]
-	}
+	return &ret
+}
 
-	if len(v) > 0 {
-		val, abs := ret.AggregateFunction(v, absent)
-		if math.IsNaN(val) {
-			val = 0
-		}
-		aggV = append(aggV, val)
-		aggA = append(aggA, abs)
+// consolidateAligned consolidates like Consolidate, with the buckets of
+// graphite-web responses to maxDataPoints.
+func (r *MetricData) consolidateAligned(valuesPerPoint int) *MetricData {
+	ret := *r
+	ret.ValuesPerPoint = valuesPerPoint
+	if ret.AggregateFunction == nil {
+		ret.AggregateFunction = AggMean
 	}
-
-	ret.Values = aggV
-	ret.IsAbsent = aggA
+	ret.Metric = r.Metric.ConsolidateAligned(valuesPerPoint, ret.AggregateFunction, r.XFilesFactor)
 
 	return &ret
 }
@@ -1,6 +1,8 @@
 package types
 
 import (
+	"bytes"
+	"fmt"
 	"math"
 	"testing"
 	"time"
@@ -58,6 +60,33 @@ func TestMarshalCSVNotInUTC(t *testing.T) {
 	}
 }
 
+func TestWriteCSVInChunks(t *testing.T) {
+	results := []*MetricData{
+		MakeMetricData("foo", getData(5000), 60, 0),
+	}
+
+	var expected []byte
+	for i, v := range results[0].Values {
+		line := fmt.Sprintf("\"foo\",%s,%v\n", time.Unix(int64(60*i), 0).UTC().Format("2006-01-02 15:04:05"), v)
+		expected = append(expected, line...)
+	}
+
+	var rec chunkRecorder
+	if err := WriteCSV(&rec, results, time.UTC); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rec.chunks) < 2 {
+		t.Errorf("Expected response to be written in several chunks, got %d", len(rec.chunks))
+	}
+	if b := bytes.Join(rec.chunks, nil); !bytes.Equal(b, expected) {
+		t.Errorf("Expected streamed response to be the same as marshalled, got %d bytes instead of %d", len(b), len(expected))
+	}
+	if b := MarshalCSV(results, time.UTC); !bytes.Equal(b, expected) {
+		t.Errorf("Expected marshalled response of %d bytes, got %d", len(expected), len(b))
+	}
+}
+
 func TestConsolidate(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -137,3 +166,29 @@ func TestConsolidate(t *testing.T) {
 
 	}
 }
+
+func TestConsolidateMaxDataPoints(t *testing.T) {
+	consolidated := MakeMetricData("metric1", []float64{1, 2, 3, 4, 5, 6}, 10, 0)
+	consolidated.AggregateFunction = AggMax
+	kept := MakeMetricData("metric2", []float64{1, 2}, 30, 0)
+
+	got := Consolidate(3, []*MetricData{consolidated, nil, kept})
+	if len(got) != 3 || got[1] != nil {
+		t.Fatalf("Expected 3 results with nil kept, got %v", got)
+	}
+	// buckets are aligned as graphite-web does, the first value is dropped
+	if diff := cmp.Diff([]float64{3, 5, 6}, got[0].Values); diff != "" {
+		t.Errorf("Consolidated values (-want +got):\n%s", diff)
+	}
+	if got[0].ValuesPerPoint != 2 || got[0].StepTime != 20 || got[0].StartTime != 20 {
+		t.Errorf("Expected 2 values per point of 20s from 20, got %d of %ds from %d", got[0].ValuesPerPoint, got[0].StepTime, got[0].StartTime)
+	}
+	if got[2] != kept {
+		t.Errorf("Expected series with few points as they are")
+	}
+
+	results := []*MetricData{consolidated}
+	if got := Consolidate(0, results); len(got) != 1 || got[0] != consolidated {
+		t.Errorf("Expected results as they are without maxDataPoints")
+	}
+}
@@ -0,0 +1,335 @@
+package expr
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/bookingcom/carbonapi/expr/helper"
+	"github.com/bookingcom/carbonapi/expr/metadata"
+	"github.com/bookingcom/carbonapi/expr/types"
+	"github.com/bookingcom/carbonapi/pkg/parser"
+)
+
+// ErrInvalidArgument is returned by Validate when an expression doesn't match
+// the signature of a function.
+type ErrInvalidArgument struct {
+	// Function is the name of the function called with invalid arguments
+	Function string
+	// Position is 1-based position of the argument, 0 for named and missing arguments
+	Position int
+	// Param is the name of the parameter as described by the function
+	Param  string
+	Reason string
+	// Suggestions are names of known functions close to an unknown one
+	Suggestions []string
+	// Err is the underlying parser error
+	Err error
+}
+
+func (e ErrInvalidArgument) Error() string {
+	if len(e.Suggestions) > 0 {
+		return fmt.Sprintf("%s: %s, did you mean %s?", e.Function, e.Reason, strings.Join(e.Suggestions, " or "))
+	}
+	switch {
+	case e.Position > 0 && e.Param != "":
+		return fmt.Sprintf("%s: argument %d (%s): %s", e.Function, e.Position, e.Param, e.Reason)
+	case e.Position > 0:
+		return fmt.Sprintf("%s: argument %d: %s", e.Function, e.Position, e.Reason)
+	case e.Param != "":
+		return fmt.Sprintf("%s: argument %s: %s", e.Function, e.Param, e.Reason)
+	}
+	return fmt.Sprintf("%s: %s", e.Function, e.Reason)
+}
+
+func (e ErrInvalidArgument) Unwrap() error {
+	return e.Err
+}
+
+// uncheckedSignatures lists functions which accept arguments in forms their
+// descriptions can't express, only nested calls are checked for them.
+var uncheckedSignatures = map[string]bool{
+	// mostDeviant(n, seriesList) is accepted for compatibility with graphite-web 0.9
+	"mostDeviant": true,
+}
+
+// Validate checks the expression tree against descriptions of registered functions:
+// unknown functions, number of arguments, their types, options and named arguments.
+// Functions without described parameters are not checked, but their arguments are.
+func Validate(e parser.Expr) error {
+	if !e.IsFunc() {
+		return nil
+	}
+
+	metadata.FunctionMD.RLock()
+	_, known := metadata.FunctionMD.Functions[e.Target()]
+	desc, described := metadata.FunctionMD.Descriptions[e.Target()]
+	metadata.FunctionMD.RUnlock()
+
+	if !known {
+		return ErrInvalidArgument{
+			Function:    e.Target(),
+			Reason:      "unknown function",
+			Suggestions: SuggestFunctions(e.Target()),
+			Err:         helper.ErrUnknownFunction,
+		}
+	}
+
+	args := e.Args()
+	for _, a := range args {
+		if err := Validate(a); err != nil {
+			return err
+		}
+	}
+
+	if !described || len(desc.Params) == 0 || uncheckedSignatures[e.Target()] {
+		return nil
+	}
+
+	return validateArgs(e.Target(), desc.Params, args, e.NamedArgs())
+}
+
+func validateArgs(function string, params []types.FunctionParam, args []parser.Expr, named map[string]parser.Expr) error {
+	last := params[len(params)-1]
+	if len(args) > len(params) && !last.Multiple {
+		return ErrInvalidArgument{
+			Function: function,
+			Position: len(params) + 1,
+			Reason:   fmt.Sprintf("too many arguments, expected at most %d", len(params)),
+			Err:      parser.ErrBadType,
+		}
+	}
+
+	for i, a := range args {
+		p := last
+		if i < len(params) {
+			p = params[i]
+		}
+		if reason := checkArg(p, a); reason != "" {
+			return ErrInvalidArgument{
+				Function: function,
+				Position: i + 1,
+				Param:    p.Name,
+				Reason:   reason,
+				Err:      parser.ErrBadType,
+			}
+		}
+	}
+
+	byName := make(map[string]int, len(params))
+	for i, p := range params {
+		byName[p.Name] = i
+	}
+	for name, a := range named {
+		i, ok := byName[name]
+		if !ok {
+			return ErrInvalidArgument{
+				Function: function,
+				Param:    name,
+				Reason:   "unknown named argument",
+				Err:      parser.ErrBadType,
+			}
+		}
+		if i < len(args) {
+			return ErrInvalidArgument{
+				Function: function,
+				Param:    name,
+				Reason:   fmt.Sprintf("already given as argument %d", i+1),
+				Err:      parser.ErrBadType,
+			}
+		}
+		if reason := checkArg(params[i], a); reason != "" {
+			return ErrInvalidArgument{
+				Function: function,
+				Param:    name,
+				Reason:   reason,
+				Err:      parser.ErrBadType,
+			}
+		}
+	}
+
+	for i := len(args); i < len(params); i++ {
+		p := params[i]
+		if _, ok := named[p.Name]; ok || !p.Required {
+			continue
+		}
+		return ErrInvalidArgument{
+			Function: function,
+			Position: i + 1,
+			Param:    p.Name,
+			Reason:   "missing required argument",
+			Err:      parser.ErrMissingArgument,
+		}
+	}
+
+	return nil
+}
+
+// checkArg returns the reason the argument doesn't fit the parameter, or an empty string.
+// It accepts everything the argument getters of parser.Expr accept.
+func checkArg(p types.FunctionParam, a parser.Expr) string {
+	switch p.Type {
+	case types.SeriesList, types.SeriesLists:
+		if a.IsName() || a.IsFunc() {
+			return ""
+		}
+		return "expected " + typeName(p.Type) + ", got " + describe(a)
+	case types.Integer, types.Float, types.Node:
+		if a.IsConst() {
+			return ""
+		}
+		return "expected " + typeName(p.Type) + ", got " + describe(a)
+	case types.Boolean:
+		if a.IsConst() {
+			return ""
+		}
+		if a.IsString() {
+			switch a.Target() {
+			case "True", "true", "False", "false":
+				return ""
+			}
+		}
+		return "expected boolean, got " + describe(a)
+	case types.Interval:
+		if !a.IsString() {
+			return "expected interval, got " + describe(a)
+		}
+		if _, err := parser.IntervalString(a.StringValue(), 1); err != nil {
+			return fmt.Sprintf("invalid interval '%s'", a.StringValue())
+		}
+		return ""
+	case types.IntOrInterval:
+		if a.IsConst() {
+			return ""
+		}
+		if !a.IsString() {
+			return "expected integer or interval, got " + describe(a)
+		}
+		if _, err := parser.IntervalString(a.StringValue(), 1); err != nil {
+			return fmt.Sprintf("invalid interval '%s'", a.StringValue())
+		}
+		return ""
+	case types.NodeOrTag, types.Date:
+		if a.IsConst() || a.IsString() {
+			return ""
+		}
+		return "expected " + typeName(p.Type) + ", got " + describe(a)
+	case types.String, types.Tag, types.AggFunc:
+		if !a.IsString() {
+			return "expected " + typeName(p.Type) + ", got " + describe(a)
+		}
+		if len(p.Options) == 0 {
+			return ""
+		}
+		for _, o := range p.Options {
+			if o == a.StringValue() {
+				return ""
+			}
+		}
+		// aggregations are also accepted by their aliases, e.g. avg or p50
+		if _, _, err := helper.SummarizeValues(a.StringValue(), []float64{0}); err == nil {
+			return ""
+		}
+		return fmt.Sprintf("'%s' is not one of %v", a.StringValue(), p.Options)
+	}
+
+	return ""
+}
+
+func typeName(t types.FunctionType) string {
+	b, err := t.MarshalJSON()
+	if err != nil {
+		return "unknown"
+	}
+	return string(b[1 : len(b)-1])
+}
+
+func describe(a parser.Expr) string {
+	switch {
+	case a.IsName():
+		return "series " + a.Target()
+	case a.IsFunc():
+		return "function " + a.Target()
+	case a.IsConst():
+		return fmt.Sprintf("number %g", a.FloatValue())
+	}
+	return fmt.Sprintf("string '%s'", a.StringValue())
+}
+
+// maxSuggestions is the maximum number of function names SuggestFunctions returns
+const maxSuggestions = 3
+
+// SuggestFunctions returns names of registered functions that are a few edits
+// away from name, closest first.
+func SuggestFunctions(name string) []string {
+	maxDistance := 2
+	if len(name) > 12 {
+		maxDistance = 3
+	}
+
+	type candidate struct {
+		name     string
+		distance int
+	}
+	var candidates []candidate
+
+	lower := strings.ToLower(name)
+	metadata.FunctionMD.RLock()
+	for f := range metadata.FunctionMD.Functions {
+		d := editDistance(lower, strings.ToLower(f))
+		if d <= maxDistance {
+			candidates = append(candidates, candidate{f, d})
+		}
+	}
+	metadata.FunctionMD.RUnlock()
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].distance == candidates[j].distance {
+			return candidates[i].name < candidates[j].name
+		}
+		return candidates[i].distance < candidates[j].distance
+	})
+
+	var res []string
+	for i := 0; i < len(candidates) && i < maxSuggestions; i++ {
+		res = append(res, candidates[i].name)
+	}
+	return res
+}
+
+// editDistance is the optimal string alignment distance: number of insertions,
+// deletions, substitutions and transpositions of adjacent bytes turning a into b.
+func editDistance(a, b string) int {
+	prev2 := make([]int, len(b)+1)
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = minInt(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				cur[j] = minInt(cur[j], prev2[j-2]+1)
+			}
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+
+	return prev[len(b)]
+}
+
+func minInt(v int, vs ...int) int {
+	for _, x := range vs {
+		if x < v {
+			v = x
+		}
+	}
+	return v
+}
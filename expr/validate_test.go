@@ -0,0 +1,128 @@
+package expr
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/bookingcom/carbonapi/pkg/parser"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		target  string
+		want    string
+		wantErr error
+	}{
+		{target: "sumSeries(a.*,b.*)"},
+		{target: "movingAverage(a.*,'5min')"},
+		{target: "movingAverage(a.*,10)"},
+		{target: "summarize(a.*,'1h','p99')"},
+		{target: "asPercent(a.*,100)"},
+		{target: "highestCurrent(a.*)"},
+		{target: "polyfit(a.*,degree=2)"},
+		{target: "mostDeviant(2,a.*)"},
+		{target: "a.*|aliasByNode(1)|sortByMaxima()"},
+		{
+			target:  "sumSeries(a.*,'b')",
+			want:    "sumSeries: argument 2 (seriesLists): expected seriesList, got string 'b'",
+			wantErr: parser.ErrBadType,
+		},
+		{
+			target:  "movingAverage(a.*,'5 lightyears')",
+			want:    "movingAverage: argument 2 (windowSize): invalid interval '5 lightyears'",
+			wantErr: parser.ErrBadType,
+		},
+		{
+			target:  "scale(a.*)",
+			want:    "scale: argument 2 (factor): missing required argument",
+			wantErr: parser.ErrMissingArgument,
+		},
+		{
+			target:  "absolute(a.*,b.*)",
+			want:    "absolute: argument 2: too many arguments, expected at most 1",
+			wantErr: parser.ErrBadType,
+		},
+		{
+			target:  "alias(sumSeries(a.*,scale(b.*,'x')),'foo')",
+			want:    "scale: argument 2 (factor): expected float, got string 'x'",
+			wantErr: parser.ErrBadType,
+		},
+		{
+			target:  "pearsonClosest(a,b.*,2,'sideways')",
+			want:    "pearsonClosest: argument 4 (direction): 'sideways' is not one of [abs pos neg]",
+			wantErr: parser.ErrBadType,
+		},
+		{
+			target:  "polyfit(a.*,power=2)",
+			want:    "polyfit: argument power: unknown named argument",
+			wantErr: parser.ErrBadType,
+		},
+		{
+			target:  "polyfit(a.*,2,degree=2)",
+			want:    "polyfit: argument degree: already given as argument 2",
+			wantErr: parser.ErrBadType,
+		},
+		{
+			target: "nosuchFunction(a.*)",
+			want:   "nosuchFunction: unknown function",
+		},
+		{
+			target: "sumSereis(a.*)",
+			want:   "sumSereis: unknown function, did you mean sumSeries?",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			exp, _, err := parser.ParseExpr(tt.target)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = Validate(exp)
+			if tt.want == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("expected error %q", tt.want)
+			}
+			if err.Error() != tt.want {
+				t.Errorf("got error %q, want %q", err, tt.want)
+			}
+			var parseError parser.ParseError
+			if !errors.As(err, &parseError) {
+				t.Errorf("error %v is not a parse error", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("error %v doesn't wrap %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSuggestFunctions(t *testing.T) {
+	tests := []struct {
+		name string
+		want []string
+	}{
+		{"sumSereis", []string{"sumSeries"}},
+		{"aliasbynode", []string{"aliasByNode"}},
+		{"movingAvrage", []string{"movingAverage"}},
+		{"completelyUnrelated", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SuggestFunctions(tt.name)
+			if len(got) == 0 && len(tt.want) == 0 {
+				return
+			}
+			if len(got) == 0 || got[0] != tt.want[0] {
+				t.Errorf("got suggestions %v, want %v first", got, tt.want)
+			}
+		})
+	}
+}
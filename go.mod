@@ -6,6 +6,7 @@ require (
 	bitbucket.org/tebeka/strftime v0.0.0-20121209190902-af5e0ef38369
 	github.com/JaderDias/movingmedian v0.0.0-20170611140316-de8c410559fa
 	github.com/aclements/go-moremath v0.0.0-20180329182055-b1aff36309c7 // indirect
+	github.com/alicebob/miniredis/v2 v2.14.1
 	github.com/beorn7/perks v0.0.0-20180321164747-3a771d992973 // indirect
 	github.com/bradfitz/gomemcache v0.0.0-20180710155616-bc664df96737
 	github.com/davecgh/go-spew v1.1.1
@@ -25,10 +26,12 @@ require (
 	github.com/facebookgo/stats v0.0.0-20151006221625-1b76add642e4 // indirect
 	github.com/facebookgo/subset v0.0.0-20200203212716-c811ad88dec4 // indirect
 	github.com/go-graphite/protocol v0.4.3-0.20180919144146-ba004f8085ad
+	github.com/go-redis/redis/v7 v7.4.0
 	github.com/gogo/protobuf v1.3.1 // indirect
 	github.com/google/go-cmp v0.5.0
 	github.com/gorilla/handlers v1.4.0
 	github.com/gorilla/mux v1.7.4
+	github.com/klauspost/compress v1.11.4
 	github.com/kr/pretty v0.2.0 // indirect
 	github.com/lomik/og-rek v0.0.0-20170411191824-628eefeb8d80
 	github.com/lomik/zapwriter v0.0.0-20180906104450-2ec2b9a61680
@@ -37,9 +40,11 @@ require (
 	github.com/peterbourgon/g2g v0.0.0-20161124161852-0c2bab2b173d
 	github.com/pkg/errors v0.8.0
 	github.com/prometheus/client_golang v0.8.0
+	github.com/prometheus/client_model v0.0.0-20190812154241-14fe0d1b01d4
 	github.com/prometheus/common v0.0.0-20180801064454-c7de2306084e // indirect
 	github.com/prometheus/procfs v0.0.0-20180920065004-418d78d0b9a7 // indirect
 	github.com/satori/go.uuid v1.2.0
+	github.com/vmihailenco/msgpack/v5 v5.0.0
 	github.com/wangjohn/quickselect v0.0.0-20161129230411-ed8402a42d5f
 	go.opentelemetry.io/contrib/instrumentation/gorilla/mux v0.7.0
 	go.opentelemetry.io/otel v0.8.0
@@ -210,7 +210,7 @@ type requestRes struct {
 	err  error
 }
 
-func (b Backend) do(ctx context.Context, trace types.Trace, req *http.Request) (string, []byte, error) {
+func (b Backend) do(ctx context.Context, trace types.Trace, req *http.Request) (http.Header, []byte, error) {
 
 	ch := make(chan requestRes, 1)
 	t0 := time.Now()
@@ -236,22 +236,22 @@ func (b Backend) do(ctx context.Context, trace types.Trace, req *http.Request) (
 
 		// TODO (grzkv): we should not try to interpret the body if there is an error
 		if res.err != nil {
-			return "", nil, res.err
+			return nil, nil, res.err
 		}
 
 		if bodyErr != nil {
-			return "", nil, bodyErr
+			return nil, nil, bodyErr
 		}
 
 		if res.resp.StatusCode != http.StatusOK {
-			return "", body, ErrHTTPCode(res.resp.StatusCode)
+			return nil, body, ErrHTTPCode(res.resp.StatusCode)
 		}
 
-		return res.resp.Header.Get("Content-Type"), body, nil
+		return res.resp.Header, body, nil
 
 	case <-ctx.Done():
 		trace.ObserveOutDuration(time.Now().Sub(t0), b.dc, b.cluster)
-		return "", nil, ctx.Err()
+		return nil, nil, ctx.Err()
 	}
 }
 
@@ -259,7 +259,7 @@ func (b Backend) do(ctx context.Context, trace types.Trace, req *http.Request) (
 // If the backend timeout is positive, Call will override the context timeout
 // with the backend timeout.
 // Call ensures that the outgoing request has a UUID set.
-func (b Backend) call(ctx context.Context, trace types.Trace, u *url.URL, body io.Reader) (string, []byte, error) {
+func (b Backend) call(ctx context.Context, trace types.Trace, u *url.URL, body io.Reader) (http.Header, []byte, error) {
 	ctx, cancel := b.setTimeout(ctx)
 	defer cancel()
 
@@ -267,7 +267,7 @@ func (b Backend) call(ctx context.Context, trace types.Trace, u *url.URL, body i
 	err := b.enter(ctx)
 	trace.AddLimiter(t0)
 	if err != nil {
-		return "", nil, err
+		return nil, nil, err
 	}
 
 	defer func() {
@@ -285,7 +285,7 @@ func (b Backend) call(ctx context.Context, trace types.Trace, u *url.URL, body i
 
 	trace.AddMarshal(t1)
 	if err != nil {
-		return "", nil, err
+		return nil, nil, err
 	}
 
 	return b.do(ctx, trace, req)
@@ -309,6 +309,8 @@ func (b Backend) Contains(targets []string) bool {
 }
 
 // Render fetches raw metrics from a backend.
+// If the backend is a zipper some of whose backends failed, the metrics are
+// returned with types.ErrPartialResponse.
 func (b Backend) Render(ctx context.Context, request types.RenderRequest) ([]types.Metric, error) {
 	from := request.From
 	until := request.Until
@@ -316,10 +318,10 @@ func (b Backend) Render(ctx context.Context, request types.RenderRequest) ([]typ
 
 	t0 := time.Now()
 	u := b.url("/render/")
-	u, body := carbonapiV2RenderEncoder(u, from, until, targets)
+	u, body := carbonapiV2RenderEncoder(u, from, until, targets, request.MaxDataPoints)
 	request.Trace.AddMarshal(t0)
 
-	contentType, resp, err := b.call(ctx, request.Trace, u, body)
+	header, resp, err := b.call(ctx, request.Trace, u, body)
 	if err != nil {
 		if code, ok := err.(ErrHTTPCode); ok && code == http.StatusNotFound {
 			return nil, types.ErrMetricsNotFound
@@ -334,6 +336,7 @@ func (b Backend) Render(ctx context.Context, request types.RenderRequest) ([]typ
 	}()
 	var metrics []types.Metric
 
+	contentType := header.Get("Content-Type")
 	switch contentType {
 	case "application/x-protobuf", "application/protobuf", "application/octet-stream":
 		metrics, err = carbonapi_v2.RenderDecoder(resp)
@@ -367,16 +370,23 @@ func (b Backend) Render(ctx context.Context, request types.RenderRequest) ([]typ
 		b.cache.Set(metric.Name, struct{}{}, 0, b.cacheExpirySec)
 	}
 
+	if header.Get(types.PartialResponseHeader) != "" {
+		return metrics, types.ErrPartialResponse
+	}
+
 	return metrics, nil
 }
 
-func carbonapiV2RenderEncoder(u *url.URL, from int32, until int32, targets []string) (*url.URL, io.Reader) {
+func carbonapiV2RenderEncoder(u *url.URL, from int32, until int32, targets []string, maxDataPoints int) (*url.URL, io.Reader) {
 	vals := url.Values{
 		"target": targets,
 		"format": fmtProto,
 		"from":   []string{strconv.Itoa(int(from))},
 		"until":  []string{strconv.Itoa(int(until))},
 	}
+	if maxDataPoints > 0 {
+		vals.Set("maxDataPoints", strconv.Itoa(maxDataPoints))
+	}
 	u.RawQuery = vals.Encode()
 
 	return u, nil
@@ -442,7 +452,7 @@ func (b Backend) Find(ctx context.Context, request types.FindRequest) (types.Mat
 	u, body := carbonapiV2FindEncoder(u, query)
 	request.Trace.AddMarshal(t0)
 
-	contentType, resp, err := b.call(ctx, request.Trace, u, body)
+	header, resp, err := b.call(ctx, request.Trace, u, body)
 	if err != nil {
 		if code, ok := err.(ErrHTTPCode); ok && code == http.StatusNotFound {
 			return types.Matches{}, types.ErrMatchesNotFound
@@ -457,6 +467,7 @@ func (b Backend) Find(ctx context.Context, request types.FindRequest) (types.Mat
 	}()
 	var matches types.Matches
 
+	contentType := header.Get("Content-Type")
 	switch contentType {
 	case "application/x-protobuf", "application/protobuf", "application/octet-stream":
 		matches, err = carbonapi_v2.FindDecoder(resp)
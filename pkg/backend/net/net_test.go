@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"github.com/bookingcom/carbonapi/pkg/types"
+	"github.com/bookingcom/carbonapi/pkg/types/encoding/carbonapi_v2"
 
 	"github.com/dgryski/go-expirecache"
 )
@@ -113,6 +114,52 @@ func TestCall(t *testing.T) {
 	}
 }
 
+func TestRenderPartialResponse(t *testing.T) {
+	exp := []types.Metric{{
+		Name:      "foo",
+		StartTime: 60,
+		StopTime:  120,
+		StepTime:  60,
+		Values:    []float64{1},
+		IsAbsent:  []bool{false},
+	}}
+	blob, err := carbonapi_v2.RenderEncoder(exp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, partial := range []bool{false, true} {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/x-protobuf")
+			if partial {
+				w.Header().Set(types.PartialResponseHeader, "true")
+			}
+			w.Write(blob)
+		}))
+
+		b, err := New(Config{
+			Address: server.URL,
+			Client:  server.Client(),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := b.Render(context.Background(), types.NewRenderRequest([]string{"foo"}, 60, 120))
+		server.Close()
+
+		if partial && err != types.ErrPartialResponse {
+			t.Errorf("expected a partial response error, got %v", err)
+		}
+		if !partial && err != nil {
+			t.Errorf("unexpected error %v", err)
+		}
+		if len(got) != 1 || got[0].Name != "foo" {
+			t.Errorf("expected the metrics along, got %v", got)
+		}
+	}
+}
+
 func TestCallServerError(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Bad", 500)
@@ -434,7 +481,7 @@ func TestCarbonapiv2RenderEncoder(t *testing.T) {
 	var until int32 = 200
 	metrics := []string{"foo", "bar"}
 
-	gotURL, gotReader := carbonapiV2RenderEncoder(u, from, until, metrics)
+	gotURL, gotReader := carbonapiV2RenderEncoder(u, from, until, metrics, 0)
 	if gotReader != nil {
 		t.Error("Expected nil reader")
 	}
@@ -453,6 +500,15 @@ func TestCarbonapiv2RenderEncoder(t *testing.T) {
 	if len(got) != 2 || got[0] != "foo" || got[1] != "bar" {
 		t.Errorf("Bad target: got %v, expected %v", got, metrics)
 	}
+
+	if _, ok := vals["maxDataPoints"]; ok {
+		t.Errorf("Expected no maxDataPoints, got %v", vals["maxDataPoints"])
+	}
+
+	gotURL, _ = carbonapiV2RenderEncoder(&url.URL{}, from, until, metrics, 300)
+	if got := gotURL.Query()["maxDataPoints"]; len(got) != 1 || got[0] != "300" {
+		t.Errorf("Expected maxDataPoints=300, got %v", got)
+	}
 }
 
 func TestCarbonapiv2InfoEncoder(t *testing.T) {
@@ -17,6 +17,7 @@ package backend
 
 import (
 	"context"
+	"errors"
 
 	"github.com/bookingcom/carbonapi/pkg/types"
 
@@ -52,7 +53,8 @@ func Renders(ctx context.Context, backends []Backend, request types.RenderReques
 		request.IncCall()
 		go func(b Backend) {
 			msg, err := b.Render(ctx, request)
-			if err != nil {
+			// the metrics of a partial response are as good as any
+			if err != nil && !errors.Is(err, types.ErrPartialResponse) {
 				errCh <- err
 			} else {
 				msgCh <- msg
@@ -0,0 +1,133 @@
+package parser
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Format returns the canonical form of the expression. Pipes are written as
+// nested function calls, arguments are separated by commas without spaces,
+// named arguments follow positional ones sorted by name, strings are single
+// quoted unless they contain a single quote and booleans are lower case.
+// Expressions that differ only in these respects have the same canonical form.
+func Format(e Expr) string {
+	var b strings.Builder
+	format(&b, e)
+	return b.String()
+}
+
+func format(b *strings.Builder, e Expr) {
+	switch {
+	case e.IsFunc():
+		b.WriteString(e.Target())
+		b.WriteByte('(')
+		for i, a := range e.Args() {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			format(b, a)
+		}
+		named := e.NamedArgs()
+		for i, k := range sortedKeys(named) {
+			if i > 0 || len(e.Args()) > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(k)
+			b.WriteByte('=')
+			format(b, named[k])
+		}
+		b.WriteByte(')')
+	case e.IsConst():
+		b.WriteString(formatConst(e.FloatValue()))
+	case isBool(e):
+		b.WriteString(strings.ToLower(e.Target()))
+	case e.IsString():
+		b.WriteString(quote(e.StringValue()))
+	default:
+		b.WriteString(e.Target())
+	}
+}
+
+// formatConst writes numbers without exponent unless they are very large or very small.
+func formatConst(v float64) string {
+	if a := math.Abs(v); a != 0 && (a < 1e-6 || a >= 1e21) {
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// quote quotes s the way the parser reads it back, strings have no escapes.
+func quote(s string) string {
+	if strings.Contains(s, "'") && !strings.Contains(s, `"`) {
+		return `"` + s + `"`
+	}
+	return "'" + s + "'"
+}
+
+// isBool checks if e is an unquoted true or false, which the parser keeps as a string with a target.
+func isBool(e Expr) bool {
+	return e.IsString() && e.Target() != ""
+}
+
+func sortedKeys(m map[string]Expr) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Node types as reported in Node.Type
+const (
+	NodeFunction = "function"
+	NodeMetric   = "metric"
+	NodeNumber   = "number"
+	NodeString   = "string"
+	NodeBoolean  = "boolean"
+)
+
+// Node is the parse tree of an expression in a form suitable for JSON encoding.
+type Node struct {
+	Type string `json:"type"`
+	// Function is the name of the called function for function nodes
+	Function string `json:"function,omitempty"`
+	// Metric is the metric name or glob for metric nodes
+	Metric string `json:"metric,omitempty"`
+	// Value is float64, string or bool for number, string and boolean nodes
+	Value     interface{}      `json:"value,omitempty"`
+	Args      []*Node          `json:"args,omitempty"`
+	NamedArgs map[string]*Node `json:"namedArgs,omitempty"`
+}
+
+// NewNode returns the parse tree of the expression.
+func NewNode(e Expr) *Node {
+	switch {
+	case e.IsFunc():
+		n := &Node{
+			Type:     NodeFunction,
+			Function: e.Target(),
+		}
+		for _, a := range e.Args() {
+			n.Args = append(n.Args, NewNode(a))
+		}
+		named := e.NamedArgs()
+		if len(named) > 0 {
+			n.NamedArgs = make(map[string]*Node, len(named))
+			for k, a := range named {
+				n.NamedArgs[k] = NewNode(a)
+			}
+		}
+		return n
+	case e.IsConst():
+		return &Node{Type: NodeNumber, Value: e.FloatValue()}
+	case isBool(e):
+		return &Node{Type: NodeBoolean, Value: strings.ToLower(e.Target()) == "true"}
+	case e.IsString():
+		return &Node{Type: NodeString, Value: e.StringValue()}
+	}
+
+	return &Node{Type: NodeMetric, Metric: e.Target()}
+}
@@ -0,0 +1,82 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		target string
+		want   string
+	}{
+		{"metric.foo", "metric.foo"},
+		{"sumSeries( a.b , c.{d,e} )", "sumSeries(a.b,c.{d,e})"},
+		{`alias(a.b, "name")`, "alias(a.b,'name')"},
+		{`alias(a.b, "it's")`, `alias(a.b,"it's")`},
+		{"a.b|scale(2)|alias('x')", "alias(scale(a.b,2),'x')"},
+		{"a.b | movingAverage('5min')", "movingAverage(a.b,'5min')"},
+		{"scale(a.b, 2.50)", "scale(a.b,2.5)"},
+		{"scale(a.b, 1e6)", "scale(a.b,1000000)"},
+		{"scale(a.b, -0.5)", "scale(a.b,-0.5)"},
+		{"legendValue(a.b, True)", "legendValue(a.b,true)"},
+		{"legendValue(a.b, 'True')", "legendValue(a.b,'True')"},
+		{"func(a.b, z=1, a='x', m=name)", "func(a.b,a='x',m=name,z=1)"},
+		{"func(z=1)", "func(z=1)"},
+		{"func()", "func()"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			e, _, err := ParseExpr(tt.target)
+			if err != nil {
+				t.Fatalf("failed to parse %s: %v", tt.target, err)
+			}
+			got := Format(e)
+			if got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+
+			// the canonical form parses to the same canonical form
+			e2, _, err := ParseExpr(got)
+			if err != nil {
+				t.Fatalf("failed to parse canonical form %s: %v", got, err)
+			}
+			if Format(e2) != got {
+				t.Errorf("canonical form is not stable: %s -> %s", got, Format(e2))
+			}
+		})
+	}
+}
+
+func TestNewNode(t *testing.T) {
+	e, _, err := ParseExpr("a.b|aliasByNode(1)|legendValue(true, name='x', factor=2)")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &Node{
+		Type:     NodeFunction,
+		Function: "legendValue",
+		Args: []*Node{
+			{
+				Type:     NodeFunction,
+				Function: "aliasByNode",
+				Args: []*Node{
+					{Type: NodeMetric, Metric: "a.b"},
+					{Type: NodeNumber, Value: float64(1)},
+				},
+			},
+			{Type: NodeBoolean, Value: true},
+		},
+		NamedArgs: map[string]*Node{
+			"name":   {Type: NodeString, Value: "x"},
+			"factor": {Type: NodeNumber, Value: float64(2)},
+		},
+	}
+
+	got := NewNode(e)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
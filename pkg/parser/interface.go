@@ -49,6 +49,15 @@ var (
 	ErrDifferentCountMetrics = ParseError("both arguments must have the same number of metrics")
 	// ErrInvalidArgumentValue is an eval error returned when a function received an argument that has the right type but invalid value
 	ErrInvalidArgumentValue = ParseError("invalid function argument value")
+	// ErrPipeToNonFunction is a parse error returned when an expression is piped to something that is not a function.
+	ErrPipeToNonFunction = ParseError("pipe to not a function")
+	// ErrTrailingInput is a parse error returned when there is unparsed input after a complete expression.
+	ErrTrailingInput = ParseError("unexpected input after expression")
+)
+
+var (
+	expectedExpr     = []string{"metric", "function", "number", "string"}
+	expectedAfterArg = []string{",", ")"}
 )
 
 // ParseError is a type of errors returned from the parser
@@ -58,6 +67,79 @@ func (p ParseError) Error() string {
 	return string(p)
 }
 
+// SyntaxError is a parse error with the position in the input where parsing stopped.
+type SyntaxError struct {
+	// Input is the whole parsed string
+	Input string
+	// Offset is the byte offset of the error in Input
+	Offset int
+	// Expected is the set of tokens that would be valid at Offset
+	Expected []string
+	// Err is the underlying parse error
+	Err ParseError
+}
+
+func (e *SyntaxError) Error() string {
+	msg := fmt.Sprintf("%s at offset %d", e.Err, e.Offset)
+	if len(e.Expected) > 0 {
+		msg += ", expected " + quoteList(e.Expected)
+	}
+	return msg
+}
+
+func (e *SyntaxError) Unwrap() error {
+	return e.Err
+}
+
+// TrailingInputError returns a *SyntaxError for rest, the part of input left after
+// ParseExpr, or nil if the whole input has been parsed.
+func TrailingInputError(input, rest string) error {
+	if strings.TrimSpace(rest) == "" {
+		return nil
+	}
+	return newSyntaxError(input, rest, expected(ErrTrailingInput, "|", "end of input"))
+}
+
+// expected records tokens the parser expected where err happened. Offset is set by ParseExpr.
+func expected(err ParseError, tokens ...string) *SyntaxError {
+	return &SyntaxError{Err: err, Expected: tokens}
+}
+
+func newSyntaxError(input, rest string, err error) *SyntaxError {
+	se, ok := err.(*SyntaxError)
+	if !ok {
+		var pe ParseError
+		if pe, ok = err.(ParseError); !ok {
+			pe = ParseError(err.Error())
+		}
+		se = &SyntaxError{Err: pe}
+	}
+	se.Input = input
+	se.Offset = len(input) - len(rest)
+	if se.Offset < 0 || se.Offset > len(input) {
+		se.Offset = len(input)
+	}
+	return se
+}
+
+func quoteList(tokens []string) string {
+	q := make([]string, len(tokens))
+	for i, t := range tokens {
+		// punctuation is quoted, classes of tokens like 'number' are not
+		switch {
+		case t == "'":
+			t = `"'"`
+		case len(t) == 1:
+			t = "'" + t + "'"
+		}
+		q[i] = t
+	}
+	if len(q) == 1 {
+		return q[0]
+	}
+	return "one of " + strings.Join(q, ", ")
+}
+
 // Expr defines an interface to talk with expressions
 type Expr interface {
 	// IsName checks if Expression is 'Series Name' expression
@@ -170,7 +170,7 @@ func (e *expr) Metrics() []MetricRequest {
 			for i := range r {
 				r[i].From -= 7 * 86400 // starts -7 days from where the original starts
 			}
-		case "movingAverage", "movingMedian", "movingMin", "movingMax", "movingSum":
+		case "movingAverage", "movingMedian", "movingMin", "movingMax", "movingSum", "exponentialMovingAverage":
 			switch e.args[1].etype {
 			case EtString:
 				offs, err := e.GetIntervalArg(1, 1)
@@ -318,7 +318,7 @@ func (e *expr) GetBoolArgDefault(n int, b bool) (bool, error) {
 
 func (e *expr) insertFirstArg(exp *expr) error {
 	if e.etype != EtFunc {
-		return fmt.Errorf("pipe to not a function")
+		return ErrPipeToNonFunction
 	}
 
 	newArgs := []*expr{exp}
@@ -340,14 +340,17 @@ func parseExprWithoutPipe(e string) (Expr, string, error) {
 	}
 
 	if len(e) == 0 {
-		return nil, "", ErrMissingExpr
+		return nil, "", expected(ErrMissingExpr, expectedExpr...)
 	}
 
 	if '0' <= e[0] && e[0] <= '9' || e[0] == '-' || e[0] == '+' {
-		val, e, err := parseConst(e)
-		r, _ := utf8.DecodeRuneInString(e)
+		val, rest, err := parseConst(e)
+		r, _ := utf8.DecodeRuneInString(rest)
 		if !unicode.IsLetter(r) {
-			return &expr{val: val, etype: EtConst}, e, err
+			if err != nil {
+				return nil, e, expected(ParseError(err.Error()), "number")
+			}
+			return &expr{val: val, etype: EtConst}, rest, nil
 		}
 	}
 
@@ -356,14 +359,15 @@ func parseExprWithoutPipe(e string) (Expr, string, error) {
 		return &expr{valStr: val, etype: EtString}, e, err
 	}
 
-	name, e := parseName(e)
+	name, rest := parseName(e)
 
 	if strings.ToLower(name) == "false" || strings.ToLower(name) == "true" {
-		return &expr{valStr: name, etype: EtString, target: name}, e, nil
+		return &expr{valStr: name, etype: EtString, target: name}, rest, nil
 	}
 	if name == "" {
-		return nil, e, ErrMissingArgument
+		return nil, e, expected(ErrMissingArgument, expectedExpr...)
 	}
+	e = rest
 
 	if e != "" && e[0] == '(' {
 		exp := &expr{target: name, etype: EtFunc}
@@ -379,8 +383,17 @@ func parseExprWithoutPipe(e string) (Expr, string, error) {
 	return &expr{target: name}, e, nil
 }
 
-// ParseExpr actually do all the parsing. It returns expression, original string and error (if any)
+// ParseExpr actually do all the parsing. It returns expression, unparsed rest of the string and error (if any).
+// Errors are *SyntaxError with position of the error in the string.
 func ParseExpr(e string) (Expr, string, error) {
+	exp, rest, err := parseExpr(e)
+	if err != nil {
+		return exp, rest, newSyntaxError(e, rest, err)
+	}
+	return exp, rest, nil
+}
+
+func parseExpr(e string) (Expr, string, error) {
 	exp, e, err := parseExprWithoutPipe(e)
 	if err != nil {
 		return exp, e, err
@@ -397,7 +410,9 @@ func pipe(exp *expr, e string) (*expr, string, error) {
 		return exp, e, nil
 	}
 
-	wr, e, err := parseExprWithoutPipe(e[1:])
+	// the expression piped to starts after the pipe and its whitespace
+	start := strings.TrimLeft(e[1:], " ")
+	wr, e, err := parseExprWithoutPipe(start)
 	if err != nil {
 		return exp, e, err
 	}
@@ -407,7 +422,7 @@ func pipe(exp *expr, e string) (*expr, string, error) {
 
 	err = wr.(*expr).insertFirstArg(exp)
 	if err != nil {
-		return exp, e, err
+		return exp, start, expected(err.(ParseError), "function")
 	}
 	exp = wr.(*expr)
 
@@ -459,29 +474,29 @@ func parseArgList(e string) (string, []*expr, map[string]*expr, string, error) {
 		var err error
 
 		argString := e
-		arg, e, err = ParseExpr(e)
+		arg, e, err = parseExpr(e)
 		if err != nil {
 			return "", nil, nil, e, err
 		}
 
 		if e == "" {
-			return "", nil, nil, "", ErrMissingComma
+			return "", nil, nil, "", expected(ErrMissingComma, expectedAfterArg...)
 		}
 
 		// we now know we're parsing a key-value pair
 		if arg.IsName() && e[0] == '=' {
 			e = e[1:]
-			argCont, eCont, errCont := ParseExpr(e)
+			argCont, eCont, errCont := parseExpr(e)
 			if errCont != nil {
 				return "", nil, nil, eCont, errCont
 			}
 
 			if eCont == "" {
-				return "", nil, nil, "", ErrMissingComma
+				return "", nil, nil, "", expected(ErrMissingComma, expectedAfterArg...)
 			}
 
 			if !argCont.IsConst() && !argCont.IsName() && !argCont.IsString() {
-				return "", nil, nil, eCont, ErrBadType
+				return "", nil, nil, e, expected(ErrBadType, "number", "string", "name")
 			}
 
 			if namedArgs == nil {
@@ -525,7 +540,7 @@ func parseArgList(e string) (string, []*expr, map[string]*expr, string, error) {
 			// TODO(asurikov): This probably warrants a separate error, but before we
 			// introduce new errors we should move existing ones to their respective
 			// packages (expr and parser).
-			return "", nil, nil, "", ErrUnexpectedCharacter
+			return "", nil, nil, "", expected(ErrUnexpectedCharacter, expectedAfterArg...)
 		}
 
 		if e[0] == ')' {
@@ -533,7 +548,7 @@ func parseArgList(e string) (string, []*expr, map[string]*expr, string, error) {
 		}
 
 		if e[0] != ',' && e[0] != ' ' {
-			return "", nil, nil, "", ErrUnexpectedCharacter
+			return "", nil, nil, e, expected(ErrUnexpectedCharacter, expectedAfterArg...)
 		}
 
 		e = e[1:]
@@ -620,8 +635,7 @@ func parseString(s string) (string, string, error) {
 	}
 
 	if i == len(s) {
-		return "", "", ErrMissingQuote
-
+		return "", "", expected(ErrMissingQuote, string(match))
 	}
 
 	return s[:i], s[i+1:], nil
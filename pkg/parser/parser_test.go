@@ -373,3 +373,70 @@ func TestParseExpr(t *testing.T) {
 		}
 	}
 }
+
+func TestParseExprErrors(t *testing.T) {
+	tests := []struct {
+		s        string
+		err      ParseError
+		offset   int
+		expected []string
+		msg      string
+	}{
+		{"", ErrMissingExpr, 0, expectedExpr, "missing expression at offset 0, expected one of metric, function, number, string"},
+		{"sumSeries(a.b", ErrMissingComma, 13, expectedAfterArg, "missing comma at offset 13, expected one of ',', ')'"},
+		{"sumSeries(a.b c)", ErrUnexpectedCharacter, 14, expectedAfterArg, ""},
+		{"sumSeries(a.b;c)", ErrUnexpectedCharacter, 13, expectedAfterArg, ""},
+		{"alias(a.b,'foo)", ErrMissingQuote, 15, []string{"'"}, `missing quote at offset 15, expected "'"`},
+		{`alias(a.b,"foo)`, ErrMissingQuote, 15, []string{`"`}, `missing quote at offset 15, expected '"'`},
+		{"scale(a.b,)", ErrMissingArgument, 10, expectedExpr, ""},
+		{"scale(a.b,1.2.3)", ParseError(`strconv.ParseFloat: parsing "1.2.3": invalid syntax`), 10, []string{"number"}, ""},
+		{"scale(a.b,factor=sum(c))", ErrBadType, 17, []string{"number", "string", "name"}, ""},
+		{"a.b|1", ErrPipeToNonFunction, 4, []string{"function"}, "pipe to not a function at offset 4, expected function"},
+		{"a.b| 'x'", ErrPipeToNonFunction, 5, []string{"function"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			_, _, err := ParseExpr(tt.s)
+			se, ok := err.(*SyntaxError)
+			if !ok {
+				t.Fatalf("got error %v (%T), want *SyntaxError", err, err)
+			}
+			if se.Err != tt.err {
+				t.Errorf("got error %q, want %q", se.Err, tt.err)
+			}
+			if se.Offset != tt.offset {
+				t.Errorf("got offset %d, want %d", se.Offset, tt.offset)
+			}
+			if !reflect.DeepEqual(se.Expected, tt.expected) {
+				t.Errorf("got expected tokens %v, want %v", se.Expected, tt.expected)
+			}
+			if tt.msg != "" && se.Error() != tt.msg {
+				t.Errorf("got message %q, want %q", se.Error(), tt.msg)
+			}
+			if se.Input != tt.s {
+				t.Errorf("got input %q, want %q", se.Input, tt.s)
+			}
+		})
+	}
+}
+
+func TestTrailingInputError(t *testing.T) {
+	target := "sumSeries(a.b))"
+	_, rest, err := ParseExpr(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = TrailingInputError(target, rest)
+	se, ok := err.(*SyntaxError)
+	if !ok {
+		t.Fatalf("got error %v, want *SyntaxError", err)
+	}
+	if se.Offset != 14 || se.Err != ErrTrailingInput {
+		t.Errorf("got %v at offset %d, want %v at offset 14", se.Err, se.Offset, ErrTrailingInput)
+	}
+
+	if err := TrailingInputError("a.b ", " "); err != nil {
+		t.Errorf("unexpected error for trailing whitespace: %v", err)
+	}
+}
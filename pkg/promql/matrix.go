@@ -0,0 +1,105 @@
+package promql
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Sample is a value of a series at a time in seconds.
+type Sample struct {
+	T int64
+	V float64
+}
+
+// MarshalJSON encodes the sample as Prometheus does, the value being a string.
+func (s Sample) MarshalJSON() ([]byte, error) {
+	b := []byte{'['}
+	b = strconv.AppendInt(b, s.T, 10)
+	b = append(b, ',', '"')
+	b = strconv.AppendFloat(b, s.V, 'f', -1, 64)
+	b = append(b, '"', ']')
+	return b, nil
+}
+
+// Series is a series of a range query result.
+type Series struct {
+	Metric map[string]string `json:"metric"`
+	Values []Sample          `json:"values"`
+}
+
+// Resample returns the samples of a series with points every step seconds
+// from start, at times from from to until every every seconds. As in Prometheus,
+// the sample at a time is the last point at most lookback seconds before.
+// Absent points are skipped.
+func Resample(start, step int32, values []float64, absent []bool, from, until, every, lookback int64) []Sample {
+	if step <= 0 || every <= 0 {
+		return nil
+	}
+
+	var samples []Sample
+	for t := from; t <= until; t += every {
+		i := int64(math.Floor(float64(t-int64(start)) / float64(step)))
+		if i < 0 {
+			continue
+		}
+		if i >= int64(len(values)) {
+			i = int64(len(values)) - 1
+		}
+		// the last present point not after t
+		for ; i >= 0 && absent[i]; i-- {
+		}
+		if i < 0 || t-(int64(start)+i*int64(step)) > lookback {
+			continue
+		}
+		samples = append(samples, Sample{T: t, V: values[i]})
+	}
+	return samples
+}
+
+// SortSeries sorts series by their labels, as Prometheus does.
+func SortSeries(series []Series) {
+	keys := make([]string, len(series))
+	for i, s := range series {
+		keys[i] = labelsKey(s.Metric)
+	}
+	sort.Sort(byKey{series, keys})
+}
+
+type byKey struct {
+	series []Series
+	keys   []string
+}
+
+func (s byKey) Len() int           { return len(s.series) }
+func (s byKey) Less(i, j int) bool { return s.keys[i] < s.keys[j] }
+func (s byKey) Swap(i, j int) {
+	s.series[i], s.series[j] = s.series[j], s.series[i]
+	s.keys[i], s.keys[j] = s.keys[j], s.keys[i]
+}
+
+// labelsKey returns the labels as a string sorting like the labels.
+func labelsKey(labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for n := range labels {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, n := range names {
+		b.WriteString(n)
+		b.WriteByte(0)
+		b.WriteString(labels[n])
+		b.WriteByte(0)
+	}
+	return b.String()
+}
+
+// SortLabelSets sorts sets of labels.
+func SortLabelSets(sets []map[string]string) {
+	sort.Slice(sets, func(i, j int) bool {
+		return labelsKey(sets[i]) < labelsKey(sets[j])
+	})
+}
@@ -0,0 +1,53 @@
+package promql
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestResample(t *testing.T) {
+	values := []float64{1, 2, 0, 4}
+	absent := []bool{false, false, true, false}
+
+	tests := []struct {
+		from, until, every, lookback int64
+		samples                      []Sample
+	}{
+		// points every 60s from 100
+		{100, 280, 60, 300, []Sample{{100, 1}, {160, 2}, {220, 2}, {280, 4}}},
+		{130, 250, 30, 300, []Sample{{130, 1}, {160, 2}, {190, 2}, {220, 2}, {250, 2}}},
+		{40, 160, 60, 300, []Sample{{100, 1}, {160, 2}}},
+		// the last point is taken after the end of the series, within lookback
+		{280, 460, 90, 120, []Sample{{280, 4}, {370, 4}}},
+		{220, 220, 60, 30, nil},
+	}
+
+	for _, tt := range tests {
+		samples := Resample(100, 60, values, absent, tt.from, tt.until, tt.every, tt.lookback)
+		if !reflect.DeepEqual(samples, tt.samples) {
+			t.Errorf("%d-%d every %d: expected %v, got %v", tt.from, tt.until, tt.every, tt.samples, samples)
+		}
+	}
+}
+
+func TestSeriesJSON(t *testing.T) {
+	series := []Series{
+		{Metric: map[string]string{"host": "b"}, Values: []Sample{{100, 1.5}}},
+		{Metric: map[string]string{"host": "a", "dc": "ams"}, Values: []Sample{{100, math.NaN()}, {160, math.Inf(1)}}},
+		{Metric: map[string]string{"host": "a"}, Values: []Sample{{100, 2}}},
+	}
+	SortSeries(series)
+
+	b, err := json.Marshal(series)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `[{"metric":{"dc":"ams","host":"a"},"values":[[100,"NaN"],[160,"+Inf"]]},` +
+		`{"metric":{"host":"a"},"values":[[100,"2"]]},` +
+		`{"metric":{"host":"b"},"values":[[100,"1.5"]]}]`
+	if string(b) != expected {
+		t.Errorf("Expected %s, got %s", expected, b)
+	}
+}
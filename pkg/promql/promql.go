@@ -0,0 +1,511 @@
+package promql
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Expr is a parsed PromQL expression.
+//
+// The supported subset of PromQL consists of vector selectors with label
+// matchers, rate over a range of a selector, sum and avg with or without
+// grouping by labels, and topk.
+type Expr interface {
+	String() string
+}
+
+// MatchType is the type of a label matcher.
+type MatchType string
+
+// Types of label matchers
+const (
+	MatchEqual     MatchType = "="
+	MatchNotEqual  MatchType = "!="
+	MatchRegexp    MatchType = "=~"
+	MatchNotRegexp MatchType = "!~"
+)
+
+// Matcher matches values of a label.
+type Matcher struct {
+	Name  string
+	Type  MatchType
+	Value string
+
+	re *regexp.Regexp
+}
+
+func newMatcher(name string, typ MatchType, value string) (*Matcher, error) {
+	m := &Matcher{Name: name, Type: typ, Value: value}
+	if typ == MatchRegexp || typ == MatchNotRegexp {
+		re, err := regexp.Compile("^(?:" + value + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("bad regexp %q of label %s: %v", value, name, err)
+		}
+		m.re = re
+	}
+	return m, nil
+}
+
+// Matches tells whether the value of the label matches.
+func (m *Matcher) Matches(v string) bool {
+	switch m.Type {
+	case MatchEqual:
+		return v == m.Value
+	case MatchNotEqual:
+		return v != m.Value
+	case MatchRegexp:
+		return m.re.MatchString(v)
+	case MatchNotRegexp:
+		return !m.re.MatchString(v)
+	}
+	return false
+}
+
+func (m *Matcher) String() string {
+	return m.Name + string(m.Type) + strconv.Quote(m.Value)
+}
+
+// Selector is a vector selector, that is label matchers.
+type Selector struct {
+	Matchers []*Matcher
+}
+
+// Matches tells whether a series with the labels is selected.
+func (s *Selector) Matches(labels map[string]string) bool {
+	for _, m := range s.Matchers {
+		if !m.Matches(labels[m.Name]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Selector) String() string {
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, m := range s.Matchers {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(m.String())
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// rate is the per-second rate of increase of counters over a range.
+type rate struct {
+	selector *Selector
+	window   time.Duration
+}
+
+func (r *rate) String() string {
+	return "rate(" + r.selector.String() + "[" + r.window.String() + "])"
+}
+
+// aggregation is sum or avg of series grouped by labels.
+type aggregation struct {
+	op       string
+	grouping []string
+	expr     Expr
+}
+
+func (a *aggregation) String() string {
+	if len(a.grouping) == 0 {
+		return a.op + "(" + a.expr.String() + ")"
+	}
+	return a.op + " by (" + strings.Join(a.grouping, ",") + ") (" + a.expr.String() + ")"
+}
+
+// topK is the k series with the largest values.
+type topK struct {
+	k    int
+	expr Expr
+}
+
+func (t *topK) String() string {
+	return "topk(" + strconv.Itoa(t.k) + ", " + t.expr.String() + ")"
+}
+
+// ParseExpr parses an expression of the supported subset of PromQL.
+func ParseExpr(s string) (Expr, error) {
+	p := &promParser{input: s}
+	if err := p.lex(); err != nil {
+		return nil, err
+	}
+
+	e, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.typ != tokEOF {
+		return nil, p.errorf(t, "unexpected %s", t)
+	}
+	return e, nil
+}
+
+// ParseSelector parses a vector selector, as in match[] parameters.
+func ParseSelector(s string) (*Selector, error) {
+	p := &promParser{input: s}
+	if err := p.lex(); err != nil {
+		return nil, err
+	}
+
+	sel, err := p.parseSelector()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.typ != tokEOF {
+		return nil, p.errorf(t, "unexpected %s", t)
+	}
+	return sel, nil
+}
+
+type tokenType int
+
+const (
+	tokEOF tokenType = iota
+	tokIdent
+	tokString
+	tokNumber
+	tokDuration
+	tokPunct
+)
+
+type token struct {
+	typ tokenType
+	val string
+	pos int
+}
+
+func (t token) String() string {
+	if t.typ == tokEOF {
+		return "end of input"
+	}
+	return strconv.Quote(t.val)
+}
+
+type promParser struct {
+	input  string
+	tokens []token
+	i      int
+}
+
+func (p *promParser) errorf(t token, format string, args ...interface{}) error {
+	return fmt.Errorf("parse error at char %d: %s", t.pos+1, fmt.Sprintf(format, args...))
+}
+
+func isIdentChar(r rune, first bool) bool {
+	return r == '_' || r == ':' || unicode.IsLetter(r) && r < unicode.MaxASCII || !first && '0' <= r && r <= '9'
+}
+
+// lex splits the input into tokens.
+func (p *promParser) lex() error {
+	s := p.input
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case isIdentChar(rune(c), true):
+			j := i + 1
+			for j < len(s) && isIdentChar(rune(s[j]), false) {
+				j++
+			}
+			p.tokens = append(p.tokens, token{tokIdent, s[i:j], i})
+			i = j
+		case '0' <= c && c <= '9' || c == '.':
+			j := i + 1
+			for j < len(s) && ('0' <= s[j] && s[j] <= '9' || s[j] == '.' || 'a' <= s[j] && s[j] <= 'z') {
+				j++
+			}
+			typ := tokNumber
+			if strings.IndexAny(s[i:j], "smhdwy") >= 0 {
+				typ = tokDuration
+			}
+			p.tokens = append(p.tokens, token{typ, s[i:j], i})
+			i = j
+		case c == '"' || c == '\'' || c == '`':
+			j := i + 1
+			for j < len(s) && s[j] != c {
+				if s[j] == '\\' && c != '`' {
+					j++
+				}
+				j++
+			}
+			if j >= len(s) {
+				return p.errorf(token{pos: i}, "unterminated string")
+			}
+			v, err := unquote(s[i : j+1])
+			if err != nil {
+				return p.errorf(token{pos: i}, "bad string %s", s[i:j+1])
+			}
+			p.tokens = append(p.tokens, token{tokString, v, i})
+			i = j + 1
+		case strings.HasPrefix(s[i:], "!=") || strings.HasPrefix(s[i:], "=~") || strings.HasPrefix(s[i:], "!~"):
+			p.tokens = append(p.tokens, token{tokPunct, s[i : i+2], i})
+			i += 2
+		case strings.IndexByte("(){}[],=", c) >= 0:
+			p.tokens = append(p.tokens, token{tokPunct, s[i : i+1], i})
+			i++
+		default:
+			return p.errorf(token{pos: i}, "unexpected character %q", c)
+		}
+	}
+	p.tokens = append(p.tokens, token{tokEOF, "", len(s)})
+	return nil
+}
+
+// unquote unquotes a string in double, single or back quotes.
+func unquote(s string) (string, error) {
+	if s[0] == '\'' {
+		// single quoted strings are double quoted ones with the quotes swapped
+		s = `"` + strings.Replace(strings.Replace(s[1:len(s)-1], `\'`, `'`, -1), `"`, `\"`, -1) + `"`
+	}
+	return strconv.Unquote(s)
+}
+
+func (p *promParser) peek() token {
+	return p.tokens[p.i]
+}
+
+func (p *promParser) next() token {
+	t := p.tokens[p.i]
+	if t.typ != tokEOF {
+		p.i++
+	}
+	return t
+}
+
+func (p *promParser) expect(val string) error {
+	if t := p.next(); t.typ != tokPunct || t.val != val {
+		return p.errorf(t, "expected %q, got %s", val, t)
+	}
+	return nil
+}
+
+func (p *promParser) isPunct(val string) bool {
+	t := p.peek()
+	return t.typ == tokPunct && t.val == val
+}
+
+func (p *promParser) parseExpr() (Expr, error) {
+	t := p.peek()
+	if t.typ == tokPunct && t.val == "{" {
+		return p.parseSelector()
+	}
+	if t.typ != tokIdent {
+		return nil, p.errorf(t, "unexpected %s", t)
+	}
+
+	switch t.val {
+	case "sum", "avg":
+		return p.parseAggregation()
+	case "topk":
+		return p.parseTopK()
+	case "rate":
+		return p.parseRate()
+	case "by", "without", "count", "min", "max", "bottomk", "irate", "increase", "offset":
+		return nil, p.errorf(t, "%s isn't supported", t)
+	}
+	return p.parseSelector()
+}
+
+// parseSelector parses a selector like name{label="value",...}.
+func (p *promParser) parseSelector() (*Selector, error) {
+	sel := &Selector{}
+	if t := p.peek(); t.typ == tokIdent {
+		p.next()
+		m, _ := newMatcher(MetricNameLabel, MatchEqual, t.val)
+		sel.Matchers = append(sel.Matchers, m)
+	}
+
+	if p.isPunct("{") {
+		p.next()
+		for !p.isPunct("}") {
+			name := p.next()
+			if name.typ != tokIdent {
+				return nil, p.errorf(name, "expected label name, got %s", name)
+			}
+			op := p.next()
+			if op.typ != tokPunct || (op.val != "=" && op.val != "!=" && op.val != "=~" && op.val != "!~") {
+				return nil, p.errorf(op, "expected label matching operator, got %s", op)
+			}
+			value := p.next()
+			if value.typ != tokString {
+				return nil, p.errorf(value, "expected label value, got %s", value)
+			}
+			m, err := newMatcher(name.val, MatchType(op.val), value.val)
+			if err != nil {
+				return nil, p.errorf(value, "%v", err)
+			}
+			sel.Matchers = append(sel.Matchers, m)
+
+			if !p.isPunct(",") {
+				break
+			}
+			p.next()
+		}
+		if err := p.expect("}"); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(sel.Matchers) == 0 {
+		return nil, p.errorf(p.peek(), "expected vector selector")
+	}
+	for _, m := range sel.Matchers {
+		if !m.Matches("") {
+			return sel, nil
+		}
+	}
+	return nil, p.errorf(p.peek(), "vector selector must contain at least one non-empty matcher")
+}
+
+func (p *promParser) parseRate() (Expr, error) {
+	p.next()
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	sel, err := p.parseSelector()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect("["); err != nil {
+		return nil, err
+	}
+	t := p.next()
+	if t.typ != tokDuration {
+		return nil, p.errorf(t, "expected range duration, got %s", t)
+	}
+	window, err := ParseDuration(t.val)
+	if err != nil {
+		return nil, p.errorf(t, "%v", err)
+	}
+	if err := p.expect("]"); err != nil {
+		return nil, err
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	return &rate{selector: sel, window: window}, nil
+}
+
+func (p *promParser) parseGrouping() ([]string, error) {
+	t := p.next()
+	if t.val == "without" {
+		return nil, p.errorf(t, "without isn't supported")
+	}
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	grouping := []string{}
+	for !p.isPunct(")") {
+		l := p.next()
+		if l.typ != tokIdent {
+			return nil, p.errorf(l, "expected label name, got %s", l)
+		}
+		grouping = append(grouping, l.val)
+		if !p.isPunct(",") {
+			break
+		}
+		p.next()
+	}
+	return grouping, p.expect(")")
+}
+
+func (p *promParser) isGrouping() bool {
+	t := p.peek()
+	return t.typ == tokIdent && (t.val == "by" || t.val == "without")
+}
+
+// parseAggregation parses sum or avg, with grouping before or after the argument.
+func (p *promParser) parseAggregation() (Expr, error) {
+	agg := &aggregation{op: p.next().val}
+
+	var err error
+	if p.isGrouping() {
+		if agg.grouping, err = p.parseGrouping(); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	if agg.expr, err = p.parseExpr(); err != nil {
+		return nil, err
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	if agg.grouping == nil && p.isGrouping() {
+		if agg.grouping, err = p.parseGrouping(); err != nil {
+			return nil, err
+		}
+	}
+	return agg, nil
+}
+
+func (p *promParser) parseTopK() (Expr, error) {
+	p.next()
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	t := p.next()
+	k, err := strconv.Atoi(t.val)
+	if t.typ != tokNumber || err != nil || k < 1 {
+		return nil, p.errorf(t, "expected positive integer parameter of topk, got %s", t)
+	}
+	if err := p.expect(","); err != nil {
+		return nil, err
+	}
+	e, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	return &topK{k: k, expr: e}, nil
+}
+
+var durationUnits = map[string]time.Duration{
+	"ms": time.Millisecond,
+	"s":  time.Second,
+	"m":  time.Minute,
+	"h":  time.Hour,
+	"d":  24 * time.Hour,
+	"w":  7 * 24 * time.Hour,
+	"y":  365 * 24 * time.Hour,
+}
+
+// ParseDuration parses a duration in the format of Prometheus, like 5m or 1h30m.
+func ParseDuration(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, fmt.Errorf("empty duration")
+	}
+
+	var d time.Duration
+	for rest := s; rest != ""; {
+		i := 0
+		for i < len(rest) && '0' <= rest[i] && rest[i] <= '9' {
+			i++
+		}
+		j := i
+		for j < len(rest) && 'a' <= rest[j] && rest[j] <= 'z' {
+			j++
+		}
+		n, err := strconv.Atoi(rest[:i])
+		unit, ok := durationUnits[rest[i:j]]
+		if err != nil || !ok {
+			return 0, fmt.Errorf("bad duration %q", s)
+		}
+		d += time.Duration(n) * unit
+		rest = rest[j:]
+	}
+	return d, nil
+}
@@ -0,0 +1,106 @@
+package promql
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseExpr(t *testing.T) {
+	tests := []struct {
+		s string
+		e string
+	}{
+		{`cpu`, `{__name__="cpu"}`},
+		{`cpu{host="a",dc=~"ams|lon"}`, `{__name__="cpu",host="a",dc=~"ams|lon"}`},
+		{`{__name__="cpu", host!='a'}`, `{__name__="cpu",host!="a"}`},
+		{`rate(requests{code!~"5.."}[5m])`, `rate({__name__="requests",code!~"5.."}[5m0s])`},
+		{`sum(cpu)`, `sum({__name__="cpu"})`},
+		{`sum by (dc) (cpu)`, `sum by (dc) ({__name__="cpu"})`},
+		{`avg(cpu) by (dc, host)`, `avg by (dc,host) ({__name__="cpu"})`},
+		{`topk(3, sum by (host) (rate(requests[1h30m])))`, `topk(3, sum by (host) (rate({__name__="requests"}[1h30m0s])))`},
+	}
+
+	for _, tt := range tests {
+		e, err := ParseExpr(tt.s)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.s, err)
+			continue
+		}
+		if e.String() != tt.e {
+			t.Errorf("%s: expected %s, got %s", tt.s, tt.e, e)
+		}
+	}
+}
+
+func TestParseExprErrors(t *testing.T) {
+	tests := []string{
+		``,
+		`{}`,
+		`{host=""}`,
+		`{host=~".*"}`,
+		`cpu{host="a"`,
+		`cpu{host=~"("}`,
+		`rate(cpu)`,
+		`rate(cpu[5x])`,
+		`count(cpu)`,
+		`sum without (host) (cpu)`,
+		`topk(cpu)`,
+		`cpu + 1`,
+	}
+
+	for _, s := range tests {
+		if e, err := ParseExpr(s); err == nil {
+			t.Errorf("%s: expected error, got %s", s, e)
+		}
+	}
+}
+
+func TestSelectorMatches(t *testing.T) {
+	sel, err := ParseSelector(`cpu{host=~"a.*",dc!="lon",rack=""}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		labels  map[string]string
+		matches bool
+	}{
+		{map[string]string{"__name__": "cpu", "host": "ab", "dc": "ams"}, true},
+		{map[string]string{"__name__": "cpu", "host": "ba", "dc": "ams"}, false},
+		{map[string]string{"__name__": "cpu", "host": "ab", "dc": "lon"}, false},
+		{map[string]string{"__name__": "cpu", "host": "ab", "rack": "r1"}, false},
+		{map[string]string{"__name__": "mem", "host": "ab"}, false},
+	}
+
+	for _, tt := range tests {
+		if m := sel.Matches(tt.labels); m != tt.matches {
+			t.Errorf("%v: expected match %v, got %v", tt.labels, tt.matches, m)
+		}
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		s string
+		d time.Duration
+	}{
+		{"30s", 30 * time.Second},
+		{"1h30m", 90 * time.Minute},
+		{"2d", 48 * time.Hour},
+		{"1w", 7 * 24 * time.Hour},
+		{"500ms", 500 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		d, err := ParseDuration(tt.s)
+		if err != nil || d != tt.d {
+			t.Errorf("%s: expected %v, got %v and error %v", tt.s, tt.d, d, err)
+		}
+	}
+
+	for _, s := range []string{"", "5", "5x", "m5", "1.5h"} {
+		if d, err := ParseDuration(s); err == nil {
+			t.Errorf("%s: expected error, got %v", s, d)
+		}
+	}
+}
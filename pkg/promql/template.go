@@ -0,0 +1,107 @@
+/*
+Package promql maps Graphite data onto the Prometheus data model. Nodes of
+Graphite paths become labels according to a Template, and queries in a small
+subset of PromQL are translated into Graphite targets.
+*/
+package promql
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// MetricNameLabel is the label holding the metric name.
+const MetricNameLabel = "__name__"
+
+var labelNameRe = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// Template maps nodes of Graphite paths to labels. It is written as a path
+// with nodes either literal or a label name in braces, for example
+// "servers.{dc}.{host}.{__name__}". Paths match the template if they have as
+// many nodes and the same literal nodes.
+type Template struct {
+	// labels has the label of every node, empty for literal nodes
+	labels []string
+	// literals has the value of every literal node
+	literals []string
+}
+
+// ParseTemplate parses a template.
+func ParseTemplate(s string) (*Template, error) {
+	if s == "" {
+		return nil, errors.New("empty template")
+	}
+
+	nodes := strings.Split(s, ".")
+	t := &Template{
+		labels:   make([]string, len(nodes)),
+		literals: make([]string, len(nodes)),
+	}
+	seen := make(map[string]bool)
+	for i, n := range nodes {
+		if !strings.HasPrefix(n, "{") || !strings.HasSuffix(n, "}") {
+			if n == "" || strings.ContainsAny(n, "{}*?[],") {
+				return nil, fmt.Errorf("bad node %q in template %q", n, s)
+			}
+			t.literals[i] = n
+			continue
+		}
+
+		label := n[1 : len(n)-1]
+		if !labelNameRe.MatchString(label) {
+			return nil, fmt.Errorf("bad label name %q in template %q", label, s)
+		}
+		if seen[label] {
+			return nil, fmt.Errorf("label %q repeated in template %q", label, s)
+		}
+		seen[label] = true
+		t.labels[i] = label
+	}
+
+	return t, nil
+}
+
+// LabelNames returns the sorted names of the labels of the template.
+func (t *Template) LabelNames() []string {
+	var names []string
+	for _, l := range t.labels {
+		if l != "" {
+			names = append(names, l)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Labels returns the labels of a path, false if the path doesn't match the template.
+func (t *Template) Labels(path string) (map[string]string, bool) {
+	nodes := strings.Split(path, ".")
+	if len(nodes) != len(t.labels) {
+		return nil, false
+	}
+
+	labels := make(map[string]string, len(nodes))
+	for i, n := range nodes {
+		if t.labels[i] == "" {
+			if n != t.literals[i] {
+				return nil, false
+			}
+			continue
+		}
+		labels[t.labels[i]] = n
+	}
+	return labels, true
+}
+
+// index returns the node of the label, -1 if the template has no such label.
+func (t *Template) index(label string) int {
+	for i, l := range t.labels {
+		if l == label {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,273 @@
+package promql
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Query is a PromQL expression translated into a Graphite target.
+type Query struct {
+	// Target is the Graphite target evaluating the expression
+	Target string
+	// Warnings tell how results of the target differ from the ones of Prometheus
+	Warnings []string
+	// nodes has the label of every node of names of the resulting series,
+	// empty for nodes that aren't labels
+	nodes []string
+}
+
+// Labels returns the labels of a series resulting from the query.
+func (q Query) Labels(name string) map[string]string {
+	labels := make(map[string]string, len(q.nodes))
+	if len(q.nodes) == 0 {
+		return labels
+	}
+
+	values := strings.Split(name, ".")
+	if len(values) != len(q.nodes) {
+		// shouldn't happen, but the series must be told apart
+		labels[MetricNameLabel] = name
+		return labels
+	}
+	for i, l := range q.nodes {
+		if l != "" {
+			labels[l] = values[i]
+		}
+	}
+	return labels
+}
+
+// translation is a translated subexpression.
+type translation struct {
+	target string
+	// grouped tells that names of the series are the values of the labels
+	// in grouping joined with dots, otherwise they are paths in the template,
+	// possibly wrapped in function calls
+	grouped  bool
+	grouping []string
+	// dropName drops the metric name from labels of the series
+	dropName bool
+	// warnings tell how the target differs from the subexpression
+	warnings []string
+}
+
+// Warnings of approximated functions.
+const (
+	rateWarning = "rate is the average per-second increase over the window, " +
+		"without the extrapolation and the handling of counter resets of Prometheus"
+	topKWarning = "topk picks series by their last value over the whole range, " +
+		"not by their value at every step as Prometheus does"
+)
+
+// warn adds the warning, unless the translation has it already.
+func (tr *translation) warn(warning string) {
+	for _, w := range tr.warnings {
+		if w == warning {
+			return
+		}
+	}
+	tr.warnings = append(tr.warnings, warning)
+}
+
+// Translate translates a PromQL expression into a Graphite target.
+func (t *Template) Translate(e Expr) (Query, error) {
+	tr, err := t.translate(e)
+	if err != nil {
+		return Query{}, err
+	}
+
+	if tr.grouped {
+		return Query{Target: tr.target, Warnings: tr.warnings, nodes: tr.grouping}, nil
+	}
+
+	// reduce the names of series to their paths
+	indexes := make([]string, len(t.labels))
+	nodes := make([]string, len(t.labels))
+	for i, l := range t.labels {
+		indexes[i] = strconv.Itoa(i)
+		if l != MetricNameLabel || !tr.dropName {
+			nodes[i] = l
+		}
+	}
+	return Query{
+		Target:   "aliasByNode(" + tr.target + "," + strings.Join(indexes, ",") + ")",
+		Warnings: tr.warnings,
+		nodes:    nodes,
+	}, nil
+}
+
+func (t *Template) translate(e Expr) (translation, error) {
+	switch e := e.(type) {
+	case *Selector:
+		target, err := t.selectorTarget(e)
+		return translation{target: target}, err
+
+	case *rate:
+		target, err := t.selectorTarget(e.selector)
+		if err != nil {
+			return translation{}, err
+		}
+		// the average rate over the window, without the extrapolation and
+		// the handling of counter resets of Prometheus, see COMPATIBILITY.md
+		target = fmt.Sprintf("movingAverage(perSecond(%s),'%ds')", target, int64(e.window.Seconds()))
+		return translation{target: target, dropName: true, warnings: []string{rateWarning}}, nil
+
+	case *aggregation:
+		arg, err := t.translate(e.expr)
+		if err != nil {
+			return translation{}, err
+		}
+
+		callback := "sum"
+		if e.op == "avg" {
+			callback = "average"
+		}
+		if len(e.grouping) == 0 {
+			return translation{target: callback + "Series(" + arg.target + ")", grouped: true, warnings: arg.warnings}, nil
+		}
+
+		nodes := make([]string, len(e.grouping))
+		for i, l := range e.grouping {
+			n := -1
+			if arg.grouped {
+				for j, g := range arg.grouping {
+					if g == l {
+						n = j
+					}
+				}
+			} else if l != MetricNameLabel || !arg.dropName {
+				n = t.index(l)
+			}
+			if n < 0 {
+				return translation{}, fmt.Errorf("grouping by label %s, which isn't in series of %s", l, e.expr)
+			}
+			nodes[i] = strconv.Itoa(n)
+		}
+		return translation{
+			target:   "groupByNodes(" + arg.target + ",'" + callback + "'," + strings.Join(nodes, ",") + ")",
+			grouped:  true,
+			grouping: e.grouping,
+			warnings: arg.warnings,
+		}, nil
+
+	case *topK:
+		arg, err := t.translate(e.expr)
+		if err != nil {
+			return translation{}, err
+		}
+		// unlike topk, series are picked once for the whole range
+		arg.target = "highestCurrent(" + arg.target + "," + strconv.Itoa(e.k) + ")"
+		arg.warn(topKWarning)
+		return arg, nil
+	}
+
+	return translation{}, fmt.Errorf("unsupported expression %s", e)
+}
+
+// Glob returns the Graphite glob of paths that may be selected.
+// Only matchers of equality, or of regexps which are alternatives of
+// plain values, narrow the glob down.
+func (t *Template) Glob(sel *Selector) (string, error) {
+	glob, _, err := t.glob(sel)
+	return glob, err
+}
+
+// glob returns the glob and the matchers that remain to be checked on the paths it matches.
+func (t *Template) glob(sel *Selector) (string, []*Matcher, error) {
+	nodes := make([]string, len(t.labels))
+	copy(nodes, t.literals)
+	var rest []*Matcher
+	for _, m := range sel.Matchers {
+		i := t.index(m.Name)
+		if i < 0 {
+			if m.Matches("") {
+				// series don't have the label, as if it was empty
+				continue
+			}
+			return "", nil, fmt.Errorf("label %s isn't in the template", m.Name)
+		}
+		if nodes[i] != "" {
+			rest = append(rest, m)
+			continue
+		}
+
+		switch m.Type {
+		case MatchEqual:
+			if isPlainValue(m.Value) {
+				nodes[i] = m.Value
+				continue
+			}
+		case MatchRegexp:
+			alternatives := strings.Split(m.Value, "|")
+			plain := true
+			for _, a := range alternatives {
+				plain = plain && isPlainValue(a)
+			}
+			if plain && len(alternatives) == 1 {
+				nodes[i] = m.Value
+				continue
+			}
+			if plain {
+				nodes[i] = "{" + strings.Join(alternatives, ",") + "}"
+				continue
+			}
+		}
+		rest = append(rest, m)
+	}
+
+	for i, n := range nodes {
+		if n == "" {
+			nodes[i] = "*"
+		}
+	}
+	return strings.Join(nodes, "."), rest, nil
+}
+
+var plainValueRe = regexp.MustCompile(`^[a-zA-Z0-9_:-]+$`)
+
+// isPlainValue tells whether the value is a node to be used as is in globs and regexps.
+func isPlainValue(v string) bool {
+	return plainValueRe.MatchString(v)
+}
+
+// selectorTarget returns the target fetching the series selected, that is
+// series of the glob of the selector filtered by the other matchers.
+func (t *Template) selectorTarget(sel *Selector) (string, error) {
+	target, rest, err := t.glob(sel)
+	if err != nil {
+		return "", err
+	}
+
+	for _, m := range rest {
+		re := m.Value
+		if m.Type == MatchEqual || m.Type == MatchNotEqual {
+			re = regexp.QuoteMeta(m.Value)
+		}
+
+		// the regexp of paths with the node of the label matching
+		nodes := make([]string, len(t.labels))
+		for i := range nodes {
+			nodes[i] = `[^.]*`
+		}
+		nodes[t.index(m.Name)] = "(?:" + re + ")"
+		pattern := "^" + strings.Join(nodes, `\.`) + "$"
+
+		quote := `"`
+		if strings.Contains(pattern, quote) {
+			quote = `'`
+			if strings.Contains(pattern, quote) {
+				return "", fmt.Errorf("value of label %s has both kinds of quotes", m.Name)
+			}
+		}
+
+		f := "grep"
+		if m.Type == MatchNotEqual || m.Type == MatchNotRegexp {
+			f = "exclude"
+		}
+		target = f + "(" + target + "," + quote + pattern + quote + ")"
+	}
+
+	return target, nil
+}
@@ -0,0 +1,167 @@
+package promql
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTemplate(t *testing.T) {
+	tmpl, err := ParseTemplate("servers.{dc}.{host}.{__name__}")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if names := tmpl.LabelNames(); !reflect.DeepEqual(names, []string{"__name__", "dc", "host"}) {
+		t.Errorf("Unexpected label names %v", names)
+	}
+
+	labels, ok := tmpl.Labels("servers.ams.host1.cpu")
+	expected := map[string]string{"dc": "ams", "host": "host1", "__name__": "cpu"}
+	if !ok || !reflect.DeepEqual(labels, expected) {
+		t.Errorf("Expected labels %v, got %v", expected, labels)
+	}
+	for _, path := range []string{"servers.ams.host1", "clients.ams.host1.cpu", "servers.ams.host1.cpu.user"} {
+		if labels, ok := tmpl.Labels(path); ok {
+			t.Errorf("%s: expected path not to match, got %v", path, labels)
+		}
+	}
+
+	for _, s := range []string{"", "servers..{host}", "servers.*.{host}", "{host}.{host}", "{1host}"} {
+		if _, err := ParseTemplate(s); err == nil {
+			t.Errorf("%s: expected error", s)
+		}
+	}
+}
+
+func TestTranslate(t *testing.T) {
+	tmpl, err := ParseTemplate("servers.{dc}.{host}.{__name__}")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		query    string
+		target   string
+		name     string
+		labels   map[string]string
+		warnings []string
+	}{
+		{
+			`cpu`,
+			`aliasByNode(servers.*.*.cpu,0,1,2,3)`,
+			"servers.ams.host1.cpu",
+			map[string]string{"dc": "ams", "host": "host1", "__name__": "cpu"},
+			nil,
+		},
+		{
+			`cpu{dc=~"ams|lon",host="host1"}`,
+			`aliasByNode(servers.{ams,lon}.host1.cpu,0,1,2,3)`,
+			"servers.ams.host1.cpu",
+			map[string]string{"dc": "ams", "host": "host1", "__name__": "cpu"},
+			nil,
+		},
+		{
+			`cpu{host=~"host[0-9]+",dc!="lon"}`,
+			`aliasByNode(exclude(grep(servers.*.*.cpu,"^[^.]*\.[^.]*\.(?:host[0-9]+)\.[^.]*$"),"^[^.]*\.(?:lon)\.[^.]*\.[^.]*$"),0,1,2,3)`,
+			"servers.ams.host1.cpu",
+			map[string]string{"dc": "ams", "host": "host1", "__name__": "cpu"},
+			nil,
+		},
+		{
+			`cpu{rack=""}`,
+			`aliasByNode(servers.*.*.cpu,0,1,2,3)`,
+			"servers.ams.host1.cpu",
+			map[string]string{"dc": "ams", "host": "host1", "__name__": "cpu"},
+			nil,
+		},
+		{
+			`rate(requests[5m])`,
+			`aliasByNode(movingAverage(perSecond(servers.*.*.requests),'300s'),0,1,2,3)`,
+			"servers.ams.host1.requests",
+			map[string]string{"dc": "ams", "host": "host1"},
+			[]string{rateWarning},
+		},
+		{
+			`sum(cpu)`,
+			`sumSeries(servers.*.*.cpu)`,
+			"sumSeries(servers.*.*.cpu)",
+			map[string]string{},
+			nil,
+		},
+		{
+			`avg by (dc) (cpu)`,
+			`groupByNodes(servers.*.*.cpu,'average',1)`,
+			"ams",
+			map[string]string{"dc": "ams"},
+			nil,
+		},
+		{
+			`sum by (host) (sum by (dc, host) (rate(requests[1m])))`,
+			`groupByNodes(groupByNodes(movingAverage(perSecond(servers.*.*.requests),'60s'),'sum',1,2),'sum',1)`,
+			"host1",
+			map[string]string{"host": "host1"},
+			[]string{rateWarning},
+		},
+		{
+			`topk(2, sum by (dc) (cpu))`,
+			`highestCurrent(groupByNodes(servers.*.*.cpu,'sum',1),2)`,
+			"ams",
+			map[string]string{"dc": "ams"},
+			[]string{topKWarning},
+		},
+		{
+			`topk(1, rate(requests[1m]))`,
+			`aliasByNode(highestCurrent(movingAverage(perSecond(servers.*.*.requests),'60s'),1),0,1,2,3)`,
+			"servers.ams.host1.requests",
+			map[string]string{"dc": "ams", "host": "host1"},
+			[]string{rateWarning, topKWarning},
+		},
+	}
+
+	for _, tt := range tests {
+		e, err := ParseExpr(tt.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		q, err := tmpl.Translate(e)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.query, err)
+			continue
+		}
+		if q.Target != tt.target {
+			t.Errorf("%s: expected target %s, got %s", tt.query, tt.target, q.Target)
+		}
+		if labels := q.Labels(tt.name); !reflect.DeepEqual(labels, tt.labels) {
+			t.Errorf("%s: expected labels %v, got %v", tt.query, tt.labels, labels)
+		}
+		if !reflect.DeepEqual(q.Warnings, tt.warnings) {
+			t.Errorf("%s: expected warnings %q, got %q", tt.query, tt.warnings, q.Warnings)
+		}
+	}
+}
+
+func TestTranslateErrors(t *testing.T) {
+	tmpl, err := ParseTemplate("servers.{dc}.{host}.{__name__}")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []string{
+		`cpu{rack="r1"}`,
+		`sum by (rack) (cpu)`,
+		`sum by (__name__) (rate(requests[5m]))`,
+		`sum by (host) (sum by (dc) (cpu))`,
+		`cpu{host=~"a'b\"c"}`,
+	}
+
+	for _, s := range tests {
+		e, err := ParseExpr(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if q, err := tmpl.Translate(e); err == nil {
+			t.Errorf("%s: expected error, got %s", s, q.Target)
+		}
+	}
+}
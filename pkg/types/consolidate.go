@@ -0,0 +1,145 @@
+package types
+
+import (
+	"math"
+)
+
+// Aggregate aggregates values into a point, leaving out absent ones. It tells
+// whether the point is absent.
+type Aggregate func(values []float64, absent []bool) (float64, bool)
+
+// ValuesPerPoint returns the number of values of step seconds consolidated
+// into a point, for a time range of timeRange seconds to fit in maxDataPoints
+// points. It returns 1 if no consolidation is needed.
+func ValuesPerPoint(timeRange, step int32, maxDataPoints int) int {
+	if maxDataPoints <= 0 || timeRange <= 0 || step <= 0 {
+		return 1
+	}
+	points := math.Floor(float64(timeRange) / float64(step))
+	if points <= float64(maxDataPoints) {
+		return 1
+	}
+	return int(math.Ceil(points / float64(maxDataPoints)))
+}
+
+// Consolidate returns the metric with every valuesPerPoint values aggregated
+// into a point, by average if aggregate is nil. As in graphite-web, points
+// with a lower ratio of present values than xFilesFactor are absent.
+func (m Metric) Consolidate(valuesPerPoint int, aggregate Aggregate, xFilesFactor float32) Metric {
+	if aggregate == nil {
+		aggregate = average
+	}
+
+	ret := m
+	ret.StepTime = m.StepTime * int32(valuesPerPoint)
+	n := (len(m.Values) + valuesPerPoint - 1) / valuesPerPoint
+	ret.Values = make([]float64, 0, n)
+	ret.IsAbsent = make([]bool, 0, n)
+
+	for i := 0; i < len(m.Values); i += valuesPerPoint {
+		end := i + valuesPerPoint
+		if end > len(m.Values) {
+			end = len(m.Values)
+		}
+		v, absent := m.Values[i:end], m.IsAbsent[i:end]
+
+		val, abs := aggregate(v, absent)
+		if !abs && xFilesFactor > 0 {
+			present := 0
+			for _, a := range absent {
+				if !a {
+					present++
+				}
+			}
+			abs = float32(present)/float32(len(v)) < xFilesFactor
+		}
+		if abs || math.IsNaN(val) {
+			val = 0
+		}
+		ret.Values = append(ret.Values, val)
+		ret.IsAbsent = append(ret.IsAbsent, abs)
+	}
+
+	return ret
+}
+
+// ConsolidateAligned consolidates the metric like Consolidate, with the
+// buckets of graphite-web responses to maxDataPoints: the points start at a
+// multiple of the consolidated step after the start of the metric, so that they
+// don't shift as the time range moves. As in graphite-web, the values before
+// the first point are dropped but the last one, which goes to the first point,
+// and there are no points past the stop time.
+func (m Metric) ConsolidateAligned(valuesPerPoint int, aggregate Aggregate, xFilesFactor float32) Metric {
+	if valuesPerPoint <= 1 || m.StepTime <= 0 {
+		return m.Consolidate(valuesPerPoint, aggregate, xFilesFactor)
+	}
+
+	seconds := m.StepTime * int32(valuesPerPoint)
+	nudge := seconds + m.StartTime%m.StepTime - m.StartTime%seconds
+	drop := int(nudge/m.StepTime) - 1
+	if drop > len(m.Values) {
+		drop = len(m.Values)
+	}
+
+	nudged := m
+	nudged.StartTime = m.StartTime + nudge
+	nudged.Values = m.Values[drop:]
+	nudged.IsAbsent = m.IsAbsent[drop:]
+	ret := nudged.Consolidate(valuesPerPoint, aggregate, xFilesFactor)
+
+	n := 0
+	if ret.StopTime >= ret.StartTime {
+		n = int((ret.StopTime-ret.StartTime)/seconds) + 1
+	}
+	if n < len(ret.Values) {
+		ret.Values = ret.Values[:n]
+		ret.IsAbsent = ret.IsAbsent[:n]
+	}
+	return ret
+}
+
+// ConsolidateMetrics returns the metrics averaged to at most maxDataPoints
+// points over their common time range, as carbonapi consolidates responses.
+func ConsolidateMetrics(metrics []Metric, maxDataPoints int) []Metric {
+	if maxDataPoints <= 0 || len(metrics) == 0 {
+		return metrics
+	}
+
+	start, stop := metrics[0].StartTime, metrics[0].StopTime
+	for _, m := range metrics[1:] {
+		if m.StartTime < start {
+			start = m.StartTime
+		}
+		if m.StopTime > stop {
+			stop = m.StopTime
+		}
+	}
+
+	ret := make([]Metric, len(metrics))
+	for i, m := range metrics {
+		if maxDataPoints == 1 {
+			// graphite-web has a single point at the start of the metric then
+			ret[i] = m.Consolidate(len(m.Values), nil, 0)
+		} else if vpp := ValuesPerPoint(stop-start, m.StepTime, maxDataPoints); vpp > 1 {
+			ret[i] = m.ConsolidateAligned(vpp, nil, 0)
+		} else {
+			ret[i] = m
+		}
+	}
+	return ret
+}
+
+func average(values []float64, absent []bool) (float64, bool) {
+	var sum float64
+	n := 0
+	for i, v := range values {
+		if !absent[i] && !math.IsNaN(v) {
+			sum += v
+			n++
+		}
+	}
+	if n == 0 {
+		return 0, true
+	}
+	return sum / float64(n), false
+}
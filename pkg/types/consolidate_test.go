@@ -0,0 +1,132 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestValuesPerPoint(t *testing.T) {
+	tests := []struct {
+		timeRange, step int32
+		maxDataPoints   int
+		want            int
+	}{
+		{600, 60, 0, 1},
+		{600, 60, 10, 1},
+		{600, 60, 20, 1},
+		{600, 60, 5, 2},
+		{600, 60, 3, 4},
+		{600, 60, 1, 10},
+		{0, 60, 1, 1},
+	}
+
+	for _, tt := range tests {
+		if got := ValuesPerPoint(tt.timeRange, tt.step, tt.maxDataPoints); got != tt.want {
+			t.Errorf("ValuesPerPoint(%d, %d, %d) = %d, want %d", tt.timeRange, tt.step, tt.maxDataPoints, got, tt.want)
+		}
+	}
+}
+
+func TestMetricConsolidate(t *testing.T) {
+	m := Metric{
+		Name:      "foo",
+		StartTime: 0,
+		StopTime:  60,
+		StepTime:  10,
+		Values:    []float64{1, 2, 0, 4, 0, 0},
+		IsAbsent:  []bool{false, false, true, false, true, true},
+	}
+
+	tests := []struct {
+		name         string
+		xFilesFactor float32
+		values       []float64
+		absent       []bool
+	}{
+		{"no xFilesFactor", 0, []float64{1.5, 4, 0}, []bool{false, false, true}},
+		{"half present", 0.5, []float64{1.5, 4, 0}, []bool{false, false, true}},
+		{"all present", 1, []float64{1.5, 0, 0}, []bool{false, true, true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := m.Consolidate(2, nil, tt.xFilesFactor)
+			if got.StepTime != 20 || got.StartTime != 0 || got.StopTime != 60 {
+				t.Errorf("Unexpected times start=%d stop=%d step=%d", got.StartTime, got.StopTime, got.StepTime)
+			}
+			if diff := cmp.Diff(tt.values, got.Values); diff != "" {
+				t.Errorf("Values (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.absent, got.IsAbsent); diff != "" {
+				t.Errorf("IsAbsent (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestMetricConsolidateAligned(t *testing.T) {
+	// graphite-web for maxDataPoints=3:
+	// nudge = 240 + 90%60 - 90%240 = 180, start = 270, del series[:2], consolidate(4),
+	// timestamps = range(270, 690+1, 240)
+	m := Metric{
+		Name:      "foo",
+		StartTime: 90,
+		StopTime:  690,
+		StepTime:  60,
+		Values:    []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10},
+		IsAbsent:  make([]bool, 10),
+	}
+
+	got := m.ConsolidateAligned(4, nil, 0)
+	if got.StartTime != 270 || got.StepTime != 240 {
+		t.Errorf("Expected start 270 and step 240, got %d and %d", got.StartTime, got.StepTime)
+	}
+	if diff := cmp.Diff([]float64{4.5, 8.5}, got.Values); diff != "" {
+		t.Errorf("Values (-want +got):\n%s", diff)
+	}
+
+	if got := m.ConsolidateAligned(1, nil, 0); !cmp.Equal(got.Values, m.Values) || got.StartTime != m.StartTime {
+		t.Errorf("Expected the metric as it is for a value per point, got %v", got)
+	}
+}
+
+func TestConsolidateMetrics(t *testing.T) {
+	metrics := []Metric{
+		{
+			Name:      "foo",
+			StartTime: 0,
+			StopTime:  60,
+			StepTime:  10,
+			Values:    []float64{1, 2, 3, 4, 5, 6},
+			IsAbsent:  make([]bool, 6),
+		},
+		{
+			Name:      "bar",
+			StartTime: 0,
+			StopTime:  60,
+			StepTime:  30,
+			Values:    []float64{1, 2},
+			IsAbsent:  make([]bool, 2),
+		},
+	}
+
+	got := ConsolidateMetrics(metrics, 3)
+	if diff := cmp.Diff([]float64{2.5, 4.5, 6}, got[0].Values); diff != "" {
+		t.Errorf("Values of foo (-want +got):\n%s", diff)
+	}
+	if got[0].StartTime != 20 || got[0].StepTime != 20 {
+		t.Errorf("Expected foo to start at 20 with step 20, got %d and %d", got[0].StartTime, got[0].StepTime)
+	}
+	if diff := cmp.Diff(metrics[1], got[1]); diff != "" {
+		t.Errorf("Expected bar as it is (-want +got):\n%s", diff)
+	}
+
+	if got := ConsolidateMetrics(metrics, 1); len(got[0].Values) != 1 || got[0].Values[0] != 3.5 || got[0].StartTime != 0 {
+		t.Errorf("Expected foo averaged into a point at its start, got %v", got[0])
+	}
+
+	if got := ConsolidateMetrics(metrics, 0); !cmp.Equal(got, metrics) {
+		t.Errorf("Expected metrics as they are without maxDataPoints, got %v", got)
+	}
+}
@@ -0,0 +1,210 @@
+/*
+Package arrow defines encoding of Render responses as an Apache Arrow IPC stream,
+which analytics tools like pandas and polars read without parsing.
+
+The stream has a record batch per metric, with a timestamp column of seconds
+and a nullable float64 value column, absent values being null. The names and
+steps of the metrics are in the metadata of the schema, as JSON arrays in the
+order of the batches, and in the metadata of every batch.
+*/
+package arrow
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"math"
+	"strconv"
+
+	"github.com/bookingcom/carbonapi/pkg/types"
+)
+
+// ContentType is the media type of Arrow IPC streams.
+const ContentType = "application/vnd.apache.arrow.stream"
+
+// Values from the Arrow format
+const (
+	metadataV5 = 4
+
+	headerSchema      = 1
+	headerRecordBatch = 3
+
+	typeFloatingPoint = 3
+	typeTimestamp     = 10
+
+	precisionDouble = 2
+	unitSecond      = 0
+)
+
+// continuation starts every message of a stream.
+var continuation = []byte{0xff, 0xff, 0xff, 0xff}
+
+// endOfStream is the continuation with a message of size 0.
+var endOfStream = []byte{0xff, 0xff, 0xff, 0xff, 0, 0, 0, 0}
+
+// RenderEncoder encodes a Render response as an Arrow IPC stream.
+func RenderEncoder(metrics []types.Metric) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := WriteRender(&buf, metrics); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// WriteRender writes a Render response as an Arrow IPC stream to w, a message at a time.
+func WriteRender(w io.Writer, metrics []types.Metric) error {
+	names := make([]string, len(metrics))
+	steps := make([]int32, len(metrics))
+	for i, m := range metrics {
+		names[i] = m.Name
+		steps[i] = m.StepTime
+	}
+	namesJSON, err := json.Marshal(names)
+	if err != nil {
+		return err
+	}
+	stepsJSON, err := json.Marshal(steps)
+	if err != nil {
+		return err
+	}
+
+	schema := fbTable{
+		nil, // little endian
+		fbOffset(fbVector{
+			fbTable{
+				fbOffset(fbString("timestamp")),
+				fbBool(false),
+				fbUint8(typeTimestamp),
+				fbOffset(fbTable{fbInt16(unitSecond), fbOffset(fbString("UTC"))}),
+				nil,
+				fbOffset(fbVector{}),
+			},
+			fbTable{
+				fbOffset(fbString("value")),
+				fbBool(true),
+				fbUint8(typeFloatingPoint),
+				fbOffset(fbTable{fbInt16(precisionDouble)}),
+				nil,
+				fbOffset(fbVector{}),
+			},
+		}),
+		fbOffset(keyValues("name", string(namesJSON), "step", string(stepsJSON))),
+	}
+	if err := writeMessage(w, headerSchema, schema, nil, nil); err != nil {
+		return err
+	}
+
+	for _, m := range metrics {
+		if err := writeRecordBatch(w, m); err != nil {
+			return err
+		}
+	}
+
+	_, err = w.Write(endOfStream)
+	return err
+}
+
+// writeRecordBatch writes a metric as a record batch.
+func writeRecordBatch(w io.Writer, m types.Metric) error {
+	n := len(m.Values)
+
+	var nulls int
+	validity := make([]byte, (n+7)/8)
+	for i := 0; i < n; i++ {
+		if m.IsAbsent[i] {
+			nulls++
+		} else {
+			validity[i/8] |= 1 << uint(i%8)
+		}
+	}
+	if nulls == 0 {
+		// a column without nulls needs no bitmap
+		validity = nil
+	}
+	validity = pad(validity)
+
+	body := make([]byte, 0, 16*n+len(validity)+8)
+	for i := 0; i < n; i++ {
+		body = appendUint64(body, uint64(int64(m.StartTime)+int64(i)*int64(m.StepTime)))
+	}
+	timestamps := int64(len(body))
+	body = append(body, validity...)
+	values := int64(len(body))
+	for i, v := range m.Values {
+		if m.IsAbsent[i] {
+			// null slots are zeroed, as the reference implementations do
+			v = 0
+		}
+		body = appendUint64(body, math.Float64bits(v))
+	}
+
+	batch := fbTable{
+		fbInt64(int64(n)),
+		fbOffset(fbStructs{{int64(n), 0}, {int64(n), int64(nulls)}}),
+		fbOffset(fbStructs{
+			{0, 0},
+			{0, timestamps},
+			{timestamps, int64(len(validity))},
+			{values, int64(len(body)) - values},
+		}),
+	}
+	metadata := keyValues("name", m.Name, "step", strconv.Itoa(int(m.StepTime)))
+	return writeMessage(w, headerRecordBatch, batch, metadata, body)
+}
+
+// writeMessage writes a message with the header and the body.
+func writeMessage(w io.Writer, headerType uint8, header fbTable, metadata fbVector, body []byte) error {
+	var customMetadata *fbField
+	if metadata != nil {
+		customMetadata = fbOffset(metadata)
+	}
+	body = pad(body)
+
+	meta := fbFinish(fbTable{
+		fbInt16(metadataV5),
+		fbUint8(headerType),
+		fbOffset(header),
+		fbInt64(int64(len(body))),
+		customMetadata,
+	})
+	// the continuation and the size are followed by the metadata, padded to 8 bytes
+	meta = pad(meta)
+
+	msg := make([]byte, 0, 8+len(meta)+len(body))
+	msg = append(msg, continuation...)
+	msg = appendUint32(msg, uint32(len(meta)))
+	msg = append(msg, meta...)
+	msg = append(msg, body...)
+	_, err := w.Write(msg)
+	return err
+}
+
+// keyValues returns KeyValue tables of pairs of keys and values.
+func keyValues(kv ...string) fbVector {
+	var v fbVector
+	for i := 0; i+1 < len(kv); i += 2 {
+		v = append(v, fbTable{fbOffset(fbString(kv[i])), fbOffset(fbString(kv[i+1]))})
+	}
+	return v
+}
+
+// pad pads b with zeros to a multiple of 8 bytes.
+func pad(b []byte) []byte {
+	for len(b)%8 != 0 {
+		b = append(b, 0)
+	}
+	return b
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	var s [4]byte
+	binary.LittleEndian.PutUint32(s[:], v)
+	return append(b, s[:]...)
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	var s [8]byte
+	binary.LittleEndian.PutUint64(s[:], v)
+	return append(b, s[:]...)
+}
@@ -0,0 +1,336 @@
+package arrow
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"math"
+	"strconv"
+	"testing"
+
+	"github.com/bookingcom/carbonapi/pkg/types"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// table reads a flatbuffers table, as generated readers do.
+type table struct {
+	buf []byte
+	pos int
+}
+
+func root(buf []byte) table {
+	return table{buf, int(binary.LittleEndian.Uint32(buf))}
+}
+
+// field returns the position of the field, 0 if it's absent.
+func (t table) field(id int) int {
+	vtable := t.pos - int(int32(binary.LittleEndian.Uint32(t.buf[t.pos:])))
+	if 4+2*id >= int(binary.LittleEndian.Uint16(t.buf[vtable:])) {
+		return 0
+	}
+	o := int(binary.LittleEndian.Uint16(t.buf[vtable+4+2*id:]))
+	if o == 0 {
+		return 0
+	}
+	return t.pos + o
+}
+
+func (t table) uint8(id int) uint8 {
+	if p := t.field(id); p != 0 {
+		return t.buf[p]
+	}
+	return 0
+}
+
+func (t table) int16(id int) int16 {
+	if p := t.field(id); p != 0 {
+		return int16(binary.LittleEndian.Uint16(t.buf[p:]))
+	}
+	return 0
+}
+
+func (t table) int64(id int) int64 {
+	if p := t.field(id); p != 0 {
+		return int64(binary.LittleEndian.Uint64(t.buf[p:]))
+	}
+	return 0
+}
+
+func (t table) deref(id int) int {
+	p := t.field(id)
+	if p == 0 {
+		return 0
+	}
+	return p + int(binary.LittleEndian.Uint32(t.buf[p:]))
+}
+
+func (t table) table(id int) table {
+	return table{t.buf, t.deref(id)}
+}
+
+func (t table) string(id int) string {
+	p := t.deref(id)
+	n := int(binary.LittleEndian.Uint32(t.buf[p:]))
+	return string(t.buf[p+4 : p+4+n])
+}
+
+func (t table) tables(id int) []table {
+	p := t.deref(id)
+	if p == 0 {
+		return nil
+	}
+	res := []table{}
+	for i := 0; i < int(binary.LittleEndian.Uint32(t.buf[p:])); i++ {
+		e := p + 4 + 4*i
+		res = append(res, table{t.buf, e + int(binary.LittleEndian.Uint32(t.buf[e:]))})
+	}
+	return res
+}
+
+func (t table) structs(id int) [][2]int64 {
+	p := t.deref(id)
+	if (p+4)%8 != 0 {
+		panic("unaligned structs")
+	}
+	var res [][2]int64
+	for i := 0; i < int(binary.LittleEndian.Uint32(t.buf[p:])); i++ {
+		e := p + 4 + 16*i
+		res = append(res, [2]int64{
+			int64(binary.LittleEndian.Uint64(t.buf[e:])),
+			int64(binary.LittleEndian.Uint64(t.buf[e+8:])),
+		})
+	}
+	return res
+}
+
+func (t table) keyValues(id int) map[string]string {
+	res := make(map[string]string)
+	for _, kv := range t.tables(id) {
+		res[kv.string(0)] = kv.string(1)
+	}
+	return res
+}
+
+type message struct {
+	header table
+	meta   map[string]string
+	body   []byte
+}
+
+// metadataV4 is the version of metadata written by the reference implementation of the golden stream.
+const metadataV4 = 3
+
+// readStream splits a stream of metadata of the version into messages.
+func readStream(t *testing.T, blob []byte, version int16) []message {
+	var res []message
+	for {
+		if len(blob) < 8 || binary.LittleEndian.Uint32(blob) != 0xffffffff {
+			t.Fatalf("Expected continuation, got %v", blob)
+		}
+		size := int(binary.LittleEndian.Uint32(blob[4:]))
+		if size == 0 {
+			if len(blob) != 8 {
+				t.Fatalf("Expected end of stream, got %v", blob)
+			}
+			return res
+		}
+		if (8+size)%8 != 0 {
+			t.Fatalf("Expected padded metadata, got size %d", size)
+		}
+
+		m := root(blob[8 : 8+size])
+		if v := m.int16(0); v != version {
+			t.Fatalf("Expected metadata version %d, got %d", version, v)
+		}
+		bodyLength := int(m.int64(3))
+		if bodyLength%8 != 0 {
+			t.Fatalf("Expected padded body, got length %d", bodyLength)
+		}
+		if m.uint8(1) == headerRecordBatch || m.uint8(1) == headerSchema {
+			res = append(res, message{
+				header: m.table(2),
+				meta:   m.keyValues(4),
+				body:   blob[8+size : 8+size+bodyLength],
+			})
+		} else {
+			t.Fatalf("Unexpected message header %d", m.uint8(1))
+		}
+		blob = blob[8+size+bodyLength:]
+	}
+}
+
+var testMetrics = []types.Metric{
+	{
+		Name:      "foo.bar",
+		StartTime: 60,
+		StopTime:  600,
+		StepTime:  60,
+		Values:    []float64{1, 0, 3, 4, 5, 6, 7, 8, 9},
+		IsAbsent:  []bool{false, true, false, false, false, false, false, false, true},
+	},
+	{
+		Name:      "foo.baz",
+		StartTime: 100,
+		StopTime:  300,
+		StepTime:  100,
+		Values:    []float64{1.5, math.Inf(1)},
+		IsAbsent:  []bool{false, false},
+	},
+}
+
+func TestRenderEncoder(t *testing.T) {
+	blob, err := RenderEncoder(testMetrics)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	messages := readStream(t, blob, metadataV5)
+	if len(messages) != 3 {
+		t.Fatalf("Expected schema and 2 record batches, got %d messages", len(messages))
+	}
+
+	schema := messages[0].header
+	fields := schema.tables(1)
+	if len(fields) != 2 {
+		t.Fatalf("Expected 2 fields, got %d", len(fields))
+	}
+	ts, value := fields[0], fields[1]
+	if ts.string(0) != "timestamp" || ts.uint8(1) != 0 || ts.uint8(2) != typeTimestamp ||
+		ts.table(3).int16(0) != unitSecond || ts.table(3).string(1) != "UTC" || len(ts.tables(5)) != 0 {
+		t.Error("Unexpected timestamp field")
+	}
+	if value.string(0) != "value" || value.uint8(1) != 1 || value.uint8(2) != typeFloatingPoint ||
+		value.table(3).int16(0) != precisionDouble || len(value.tables(5)) != 0 {
+		t.Error("Unexpected value field")
+	}
+	expectedMeta := map[string]string{"name": `["foo.bar","foo.baz"]`, "step": `[60,100]`}
+	if meta := schema.keyValues(2); !cmp.Equal(meta, expectedMeta) {
+		t.Errorf("Expected schema metadata %v, got %v", expectedMeta, meta)
+	}
+
+	for i, m := range testMetrics {
+		batch := messages[i+1]
+		n := len(m.Values)
+
+		expectedMeta := map[string]string{"name": m.Name, "step": strconv.Itoa(int(m.StepTime))}
+		if !cmp.Equal(batch.meta, expectedMeta) {
+			t.Errorf("Expected batch metadata %v, got %v", expectedMeta, batch.meta)
+		}
+		if l := batch.header.int64(0); l != int64(n) {
+			t.Errorf("%s: expected length %d, got %d", m.Name, n, l)
+		}
+
+		nulls := 0
+		for _, a := range m.IsAbsent {
+			if a {
+				nulls++
+			}
+		}
+		if nodes := batch.header.structs(1); !cmp.Equal(nodes, [][2]int64{{int64(n), 0}, {int64(n), int64(nulls)}}) {
+			t.Errorf("%s: unexpected field nodes %v", m.Name, nodes)
+		}
+
+		buffers := batch.header.structs(2)
+		if len(buffers) != 4 {
+			t.Fatalf("%s: expected 4 buffers, got %v", m.Name, buffers)
+		}
+		for _, b := range buffers {
+			if b[0]%8 != 0 || b[0]+b[1] > int64(len(batch.body)) {
+				t.Fatalf("%s: bad buffer %v in body of %d bytes", m.Name, b, len(batch.body))
+			}
+		}
+		buffer := func(i int) []byte {
+			return batch.body[buffers[i][0] : buffers[i][0]+buffers[i][1]]
+		}
+
+		if len(buffer(0)) != 0 {
+			t.Errorf("%s: expected no validity of timestamps", m.Name)
+		}
+		validity := buffer(2)
+		if nulls == 0 && len(validity) != 0 {
+			t.Errorf("%s: expected no validity of values without nulls", m.Name)
+		}
+		for j := 0; j < n; j++ {
+			if ts := int64(binary.LittleEndian.Uint64(buffer(1)[8*j:])); ts != int64(m.StartTime)+int64(j)*int64(m.StepTime) {
+				t.Errorf("%s: unexpected timestamp %d at %d", m.Name, ts, j)
+			}
+			valid := len(validity) == 0 || validity[j/8]&(1<<uint(j%8)) != 0
+			if valid == m.IsAbsent[j] {
+				t.Errorf("%s: expected value at %d to be valid %v", m.Name, j, !m.IsAbsent[j])
+			}
+			if v := math.Float64frombits(binary.LittleEndian.Uint64(buffer(3)[8*j:])); valid && v != m.Values[j] {
+				t.Errorf("%s: expected value %v at %d, got %v", m.Name, m.Values[j], j, v)
+			}
+		}
+	}
+}
+
+func TestRenderEncoderEmpty(t *testing.T) {
+	blob, err := RenderEncoder(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	messages := readStream(t, blob, metadataV5)
+	if len(messages) != 1 {
+		t.Fatalf("Expected only the schema, got %d messages", len(messages))
+	}
+	if meta := messages[0].header.keyValues(2); meta["name"] != "[]" {
+		t.Errorf("Expected no names in schema metadata, got %v", meta)
+	}
+}
+
+// testdata/render.arrow is the stream of testMetrics written by the IPC writer
+// of the Go implementation of Arrow, at commit 651201b0f516 of apache/arrow.
+// It has metadata of version 4 and no metadata of record batches.
+func TestRenderEncoderGolden(t *testing.T) {
+	golden, err := ioutil.ReadFile("testdata/render.arrow")
+	if err != nil {
+		t.Fatal(err)
+	}
+	blob, err := RenderEncoder(testMetrics)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := readStream(t, golden, metadataV4)
+	got := readStream(t, blob, metadataV5)
+	if len(got) != len(want) {
+		t.Fatalf("Expected %d messages, got %d", len(want), len(got))
+	}
+
+	field := func(f table) []interface{} {
+		return []interface{}{f.string(0), f.uint8(1), f.uint8(2), f.table(3).int16(0), len(f.tables(5))}
+	}
+	wantFields, gotFields := want[0].header.tables(1), got[0].header.tables(1)
+	if len(gotFields) != len(wantFields) {
+		t.Fatalf("Expected %d fields, got %d", len(wantFields), len(gotFields))
+	}
+	for i := range wantFields {
+		if !cmp.Equal(field(gotFields[i]), field(wantFields[i])) {
+			t.Errorf("Expected field %v, got %v", field(wantFields[i]), field(gotFields[i]))
+		}
+	}
+	if tz, want := gotFields[0].table(3).string(1), wantFields[0].table(3).string(1); tz != want {
+		t.Errorf("Expected time zone %s, got %s", want, tz)
+	}
+	if diff := cmp.Diff(want[0].header.keyValues(2), got[0].header.keyValues(2)); diff != "" {
+		t.Errorf("Unexpected schema metadata (-want +got):\n%s", diff)
+	}
+
+	for i := 1; i < len(want); i++ {
+		w, g := want[i].header, got[i].header
+		if g.int64(0) != w.int64(0) {
+			t.Errorf("Batch %d: expected length %d, got %d", i, w.int64(0), g.int64(0))
+		}
+		if diff := cmp.Diff(w.structs(1), g.structs(1)); diff != "" {
+			t.Errorf("Batch %d: unexpected field nodes (-want +got):\n%s", i, diff)
+		}
+		if diff := cmp.Diff(w.structs(2), g.structs(2)); diff != "" {
+			t.Errorf("Batch %d: unexpected buffers (-want +got):\n%s", i, diff)
+		}
+		if diff := cmp.Diff(want[i].body, got[i].body); diff != "" {
+			t.Errorf("Batch %d: unexpected body (-want +got):\n%s", i, diff)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package arrow
+
+import (
+	"encoding/binary"
+)
+
+// The metadata of Arrow messages is in flatbuffers. Only the few tables of
+// the format written here are needed, so they are built by this minimal
+// builder instead of generated code.
+//
+// Unlike the builders of the flatbuffers library, it writes the buffer front
+// to back: tables come before the strings, vectors and tables they refer to,
+// so that offsets to them are forward as the format requires, and every
+// table is preceded by its vtable.
+
+// fbObject is an object referred to by an offset.
+type fbObject interface {
+	// write writes the object at the end of the buffer and returns its position
+	write(b *fbBuilder) int
+}
+
+// fbField is a field of a table. Fields are either scalars or offsets to objects.
+type fbField struct {
+	scalar []byte
+	object fbObject
+}
+
+func fbUint8(v uint8) *fbField {
+	return &fbField{scalar: []byte{v}}
+}
+
+func fbBool(v bool) *fbField {
+	if v {
+		return fbUint8(1)
+	}
+	return fbUint8(0)
+}
+
+func fbInt16(v int16) *fbField {
+	s := make([]byte, 2)
+	binary.LittleEndian.PutUint16(s, uint16(v))
+	return &fbField{scalar: s}
+}
+
+func fbInt64(v int64) *fbField {
+	s := make([]byte, 8)
+	binary.LittleEndian.PutUint64(s, uint64(v))
+	return &fbField{scalar: s}
+}
+
+func fbOffset(o fbObject) *fbField {
+	return &fbField{object: o}
+}
+
+func (f *fbField) size() int {
+	if f.object != nil {
+		return 4
+	}
+	return len(f.scalar)
+}
+
+// fbTable is a table, with its fields in order of their ids. Absent fields are nil.
+type fbTable []*fbField
+
+func (t fbTable) write(b *fbBuilder) int {
+	// the inline layout: the offset to the vtable, then fields aligned to their size
+	offsets := make([]int, len(t))
+	size := 4
+	for i, f := range t {
+		if f == nil {
+			continue
+		}
+		n := f.size()
+		size = (size + n - 1) / n * n
+		offsets[i] = size
+		size += n
+	}
+
+	b.align(2)
+	vtable := len(b.buf)
+	b.putUint16(uint16(4 + 2*len(t)))
+	b.putUint16(uint16(size))
+	for _, o := range offsets {
+		b.putUint16(uint16(o))
+	}
+
+	// tables are aligned to their largest possible field
+	b.align(8)
+	table := len(b.buf)
+	b.buf = append(b.buf, make([]byte, size)...)
+	binary.LittleEndian.PutUint32(b.buf[table:], uint32(table-vtable))
+	for i, f := range t {
+		if f != nil && f.object == nil {
+			copy(b.buf[table+offsets[i]:], f.scalar)
+		}
+	}
+
+	for i, f := range t {
+		if f != nil && f.object != nil {
+			b.refer(table+offsets[i], f.object)
+		}
+	}
+	return table
+}
+
+// fbString is a string.
+type fbString string
+
+func (s fbString) write(b *fbBuilder) int {
+	b.align(4)
+	pos := len(b.buf)
+	b.putUint32(uint32(len(s)))
+	b.buf = append(b.buf, s...)
+	b.buf = append(b.buf, 0)
+	return pos
+}
+
+// fbVector is a vector of offsets to objects.
+type fbVector []fbObject
+
+func (v fbVector) write(b *fbBuilder) int {
+	b.align(4)
+	pos := len(b.buf)
+	b.putUint32(uint32(len(v)))
+	b.buf = append(b.buf, make([]byte, 4*len(v))...)
+	for i, o := range v {
+		b.refer(pos+4+4*i, o)
+	}
+	return pos
+}
+
+// fbStructs is a vector of structs of two longs, like FieldNode and Buffer.
+type fbStructs [][2]int64
+
+func (v fbStructs) write(b *fbBuilder) int {
+	// the structs are aligned, not the length before them
+	for (len(b.buf)+4)%8 != 0 {
+		b.buf = append(b.buf, 0)
+	}
+	pos := len(b.buf)
+	b.putUint32(uint32(len(v)))
+	for _, s := range v {
+		b.putUint64(uint64(s[0]))
+		b.putUint64(uint64(s[1]))
+	}
+	return pos
+}
+
+type fbBuilder struct {
+	buf []byte
+}
+
+// fbFinish returns a buffer with the root table.
+func fbFinish(root fbTable) []byte {
+	b := &fbBuilder{buf: make([]byte, 4, 256)}
+	b.refer(0, root)
+	return b.buf
+}
+
+// refer writes the object and points the offset at pos to it.
+func (b *fbBuilder) refer(pos int, o fbObject) {
+	target := o.write(b)
+	binary.LittleEndian.PutUint32(b.buf[pos:], uint32(target-pos))
+}
+
+func (b *fbBuilder) align(n int) {
+	for len(b.buf)%n != 0 {
+		b.buf = append(b.buf, 0)
+	}
+}
+
+func (b *fbBuilder) putUint16(v uint16) {
+	b.buf = append(b.buf, byte(v), byte(v>>8))
+}
+
+func (b *fbBuilder) putUint32(v uint32) {
+	b.buf = append(b.buf, 0, 0, 0, 0)
+	binary.LittleEndian.PutUint32(b.buf[len(b.buf)-4:], v)
+}
+
+func (b *fbBuilder) putUint64(v uint64) {
+	b.buf = append(b.buf, 0, 0, 0, 0, 0, 0, 0, 0)
+	binary.LittleEndian.PutUint64(b.buf[len(b.buf)-8:], v)
+}
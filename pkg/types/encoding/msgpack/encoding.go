@@ -0,0 +1,74 @@
+/*
+Package msgpack defines encoding methods for Find and Render responses in the
+msgpack format of graphite-web 1.1, which it accepts from its CLUSTER_SERVERS
+when they respond with the application/x-msgpack Content-Type.
+*/
+package msgpack
+
+import (
+	"time"
+
+	"github.com/bookingcom/carbonapi/pkg/types"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// match is a Find response entry as graphite-web 1.1 sends it.
+type match struct {
+	Path      string     `msgpack:"path"`
+	IsLeaf    bool       `msgpack:"is_leaf"`
+	Intervals [][2]int64 `msgpack:"intervals"`
+}
+
+// metric is a Render response entry as graphite-web 1.1 sends it.
+// Absent values are nil.
+type metric struct {
+	Name           string     `msgpack:"name"`
+	PathExpression string     `msgpack:"pathExpression"`
+	Start          int32      `msgpack:"start"`
+	End            int32      `msgpack:"end"`
+	Step           int32      `msgpack:"step"`
+	Values         []*float64 `msgpack:"values"`
+}
+
+// FindEncoder encodes a Find response in a format graphite-web 1.1 can understand.
+func FindEncoder(matches types.Matches) ([]byte, error) {
+	// like in the pickle format, all metrics claim to have data up to now
+	now := time.Now().Unix() + 60
+	intervals := [][2]int64{{0, now}}
+
+	result := make([]match, 0, len(matches.Matches))
+	for _, m := range matches.Matches {
+		result = append(result, match{
+			Path:      m.Path,
+			IsLeaf:    m.IsLeaf,
+			Intervals: intervals,
+		})
+	}
+
+	return msgpack.Marshal(result)
+}
+
+// RenderEncoder encodes a Render response in a format graphite-web 1.1 can understand.
+func RenderEncoder(metrics []types.Metric) ([]byte, error) {
+	result := make([]metric, 0, len(metrics))
+	for _, m := range metrics {
+		values := make([]*float64, len(m.Values))
+		for i := range m.Values {
+			if !m.IsAbsent[i] {
+				values[i] = &m.Values[i]
+			}
+		}
+
+		result = append(result, metric{
+			Name:           m.Name,
+			PathExpression: m.Name,
+			Start:          m.StartTime,
+			End:            m.StopTime,
+			Step:           m.StepTime,
+			Values:         values,
+		})
+	}
+
+	return msgpack.Marshal(result)
+}
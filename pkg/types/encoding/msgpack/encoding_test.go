@@ -0,0 +1,69 @@
+package msgpack
+
+import (
+	"testing"
+
+	"github.com/bookingcom/carbonapi/pkg/types"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestRenderEncoder(t *testing.T) {
+	blob, err := RenderEncoder([]types.Metric{{
+		Name:      "foo.bar",
+		StartTime: 60,
+		StopTime:  240,
+		StepTime:  60,
+		Values:    []float64{1, 0, 3},
+		IsAbsent:  []bool{false, true, false},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// decoded as graphite-web does, into plain maps
+	var got []map[string]interface{}
+	if err := msgpack.Unmarshal(blob, &got); err != nil {
+		t.Fatal(err)
+	}
+	expected := []map[string]interface{}{{
+		"name":           "foo.bar",
+		"pathExpression": "foo.bar",
+		"start":          int32(60),
+		"end":            int32(240),
+		"step":           int32(60),
+		"values":         []interface{}{1.0, nil, 3.0},
+	}}
+	if !cmp.Equal(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+}
+
+func TestFindEncoder(t *testing.T) {
+	blob, err := FindEncoder(types.Matches{
+		Name: "foo.*",
+		Matches: []types.Match{
+			{Path: "foo.bar", IsLeaf: true},
+			{Path: "foo.baz", IsLeaf: false},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []map[string]interface{}
+	if err := msgpack.Unmarshal(blob, &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("Expected 2 matches, got %v", got)
+	}
+	if got[0]["path"] != "foo.bar" || got[0]["is_leaf"] != true || got[1]["is_leaf"] != false {
+		t.Errorf("Unexpected matches %v", got)
+	}
+	intervals, ok := got[0]["intervals"].([]interface{})
+	if !ok || len(intervals) != 1 {
+		t.Errorf("Expected a single interval, got %v", got[0]["intervals"])
+	}
+}
@@ -9,6 +9,7 @@ package types
 // TODO (grzkv): Name of this module makes 0 sense
 
 import (
+	"errors"
 	"sort"
 	"sync/atomic"
 	"time"
@@ -26,8 +27,15 @@ var (
 	ErrMetricsNotFound = ErrNotFound("No metrics returned")
 	ErrMatchesNotFound = ErrNotFound("No matches found")
 	ErrInfoNotFound    = ErrNotFound("No information found")
+
+	// ErrPartialResponse is returned along with the metrics of a render some
+	// backends failed to answer, which may lack series or points
+	ErrPartialResponse = errors.New("some backends failed to respond")
 )
 
+// PartialResponseHeader is set on render responses of the zipper some backends failed to answer.
+const PartialResponseHeader = "X-Partial-Response"
+
 // ErrNotFound signals the HTTP not found error
 type ErrNotFound string
 
@@ -71,6 +79,8 @@ type RenderRequest struct {
 	Targets []string
 	From    int32
 	Until   int32
+	// MaxDataPoints is the number of points the response may be consolidated to, 0 for raw points
+	MaxDataPoints int
 	Trace
 }
 